@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ecommerce-backend/pkg/health"
+)
+
+// ==============================================================================
+// LOAD BALANCING (Birden fazla instance)
+// ==============================================================================
+/*
+Her upstream birden fazla instance'a (target) sahip olabilir:
+
+	product:
+	  targets: [http://product-1:3001, http://product-2:3001]
+	  balancer: least_conn
+
+Balancer'lar:
+  - round_robin → Sırayla (varsayılan)
+  - least_conn  → O an en az açık isteği olan instance
+
+Aktif health check: Her instance "interval"da bir GET <target><path> ile
+yoklanır (pkg/health formatı, {"status": "healthy"|"degraded"}). Art arda
+"unhealthy_threshold" başarısız yoklamada instance havuzdan ÇIKARILIR,
+art arda "healthy_threshold" başarılı yoklamada geri alınır.
+
+Tüm instance'lar çıkarılmışsa istekler yine hepsine dağıtılır (yoklama
+yanılıyor olabilir); gerçekten çökmüşlerse circuit breaker devreye girer.
+*/
+
+const (
+	defaultHealthPath         = "/health"
+	defaultHealthInterval     = 10 * time.Second
+	defaultHealthTimeout      = 2 * time.Second
+	defaultUnhealthyThreshold = 2
+	defaultHealthyThreshold   = 2
+)
+
+// HealthCheck - Derlenmiş yoklama ayarları
+type HealthCheck struct {
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	UnhealthyThreshold int
+	HealthyThreshold   int
+}
+
+// Target - Upstream'in tek bir instance'ı
+// Reload'lar arasında aynı nesne kullanılır (sağlık durumu ve sayaçlar korunur).
+type Target struct {
+	Upstream string
+	URL      string
+
+	healthy  atomic.Bool
+	inflight atomic.Int64
+
+	// Sadece yoklama goroutine'leri dokunur
+	mu        sync.Mutex
+	probing   bool
+	nextProbe time.Time
+	failures  int // Art arda başarısız yoklama
+	successes int // Art arda başarılı yoklama
+}
+
+// NewTarget - Yoklama sonucu gelene kadar sağlıklı kabul edilir
+func NewTarget(upstream, url string) *Target {
+	t := &Target{Upstream: upstream, URL: url}
+	t.healthy.Store(true)
+	return t
+}
+
+func (t *Target) Healthy() bool { return t.healthy.Load() }
+
+// Balancer - Uygun instance'lar arasından birini seçer
+type Balancer interface {
+	Pick(targets []*Target) *Target
+}
+
+// NewBalancer - "" ve "round_robin" → RoundRobin, "least_conn" → LeastConn
+func NewBalancer(name string) (Balancer, error) {
+	switch name {
+	case "", "round_robin":
+		return &RoundRobin{}, nil
+	case "least_conn":
+		return &LeastConn{}, nil
+	default:
+		return nil, fmt.Errorf("geçersiz balancer %q (round_robin, least_conn)", name)
+	}
+}
+
+type RoundRobin struct {
+	next atomic.Uint64
+}
+
+func (rr *RoundRobin) Pick(targets []*Target) *Target {
+	return targets[(rr.next.Add(1)-1)%uint64(len(targets))]
+}
+
+// LeastConn - Eşitlikte sırayla (hep ilk instance'a yığılmasın)
+type LeastConn struct {
+	next atomic.Uint64
+}
+
+func (lc *LeastConn) Pick(targets []*Target) *Target {
+	start := int(lc.next.Add(1) % uint64(len(targets)))
+	best := targets[start]
+	for i := 1; i < len(targets); i++ {
+		t := targets[(start+i)%len(targets)]
+		if t.inflight.Load() < best.inflight.Load() {
+			best = t
+		}
+	}
+	return best
+}
+
+// pick - Sağlıklı instance'lardan birini seçer
+// Tekrar denemede (previous != nil) mümkünse farklı bir instance seçilir.
+func (u *Upstream) pick(previous *Target) *Target {
+	candidates := make([]*Target, 0, len(u.Targets))
+	for _, t := range u.Targets {
+		if t.Healthy() && t != previous {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, t := range u.Targets {
+			if t.Healthy() {
+				candidates = append(candidates, t)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = u.Targets // Hepsi çıkarılmış → yine de dene
+	}
+	return u.Balancer.Pick(candidates)
+}
+
+// ==============================================================================
+// AKTİF HEALTH CHECK
+// ==============================================================================
+
+// healthCheckTick - Yoklama zamanı gelen instance'lar bu aralıkla taranır
+const healthCheckTick = time.Second
+
+// StartHealthChecks - Aktif tablodaki tüm instance'ları düzenli yoklar
+// Reload'da eklenen/çıkan upstream'ler otomatik olarak takip edilir.
+func (g *Gateway) StartHealthChecks(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(healthCheckTick)
+		defer ticker.Stop()
+		for {
+			g.probeDue(ctx, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// probeDue - Zamanı gelmiş instance'ları paralel yoklar
+func (g *Gateway) probeDue(ctx context.Context, now time.Time) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for _, u := range g.table.Load().upstreams {
+		for _, t := range u.Targets {
+			t.mu.Lock()
+			due := !t.probing && !now.Before(t.nextProbe)
+			if due {
+				t.probing = true
+				t.nextProbe = now.Add(u.Health.Interval)
+			}
+			t.mu.Unlock()
+			if !due {
+				continue
+			}
+
+			wg.Add(1)
+			go func(u *Upstream, t *Target) {
+				defer wg.Done()
+				t.probe(ctx, u.Health)
+			}(u, t)
+		}
+	}
+	return &wg
+}
+
+// probe - Tek yoklama; eşikler aşılınca instance'ı havuzdan çıkarır/geri alır
+func (t *Target) probe(ctx context.Context, hc HealthCheck) {
+	ctx, cancel := context.WithTimeout(ctx, hc.Timeout)
+	defer cancel()
+	result := health.NewHTTPChecker(t.URL + hc.Path).Check(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probing = false
+
+	// degraded instance istek alabilir → havuzda kalır
+	if result.Status != health.StatusUnhealthy {
+		t.failures = 0
+		t.successes++
+		if !t.Healthy() && t.successes >= hc.HealthyThreshold {
+			t.healthy.Store(true)
+			upstreamTargetHealthy.WithLabelValues(t.Upstream, t.URL).Set(1)
+			log.Printf("✅ %s instance'ı havuza geri alındı: %s", t.Upstream, t.URL)
+		}
+		return
+	}
+
+	t.successes = 0
+	t.failures++
+	if t.Healthy() && t.failures >= hc.UnhealthyThreshold {
+		t.healthy.Store(false)
+		upstreamTargetHealthy.WithLabelValues(t.Upstream, t.URL).Set(0)
+		log.Printf("🚫 %s instance'ı havuzdan çıkarıldı: %s (%s)", t.Upstream, t.URL, result.Message)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBalancers(t *testing.T) {
+	a, b, c := NewTarget("svc", "http://a"), NewTarget("svc", "http://b"), NewTarget("svc", "http://c")
+	targets := []*Target{a, b, c}
+
+	// Round robin: her instance sırayla
+	rr := &RoundRobin{}
+	counts := map[*Target]int{}
+	for range 9 {
+		counts[rr.Pick(targets)]++
+	}
+	if counts[a] != 3 || counts[b] != 3 || counts[c] != 3 {
+		t.Errorf("round robin dağılımı eşit değil: a=%d b=%d c=%d", counts[a], counts[b], counts[c])
+	}
+
+	// Least conn: en az açık isteği olan
+	a.inflight.Store(4)
+	b.inflight.Store(1)
+	c.inflight.Store(2)
+	lc := &LeastConn{}
+	for range 3 {
+		if got := lc.Pick(targets); got != b {
+			t.Errorf("least_conn = %s, want http://b", got.URL)
+		}
+	}
+	a.inflight.Store(0)
+	b.inflight.Store(0)
+	c.inflight.Store(0)
+
+	u := &Upstream{Name: "svc", Targets: targets, Balancer: &RoundRobin{}}
+
+	// Çıkarılmış instance'a istek gitmez
+	b.healthy.Store(false)
+	for range 6 {
+		if u.pick(nil) == b {
+			t.Fatal("havuzdan çıkarılmış instance seçildi")
+		}
+	}
+
+	// Tekrar deneme farklı instance'a gider
+	for range 6 {
+		if u.pick(a) != c {
+			t.Fatal("tekrar deneme aynı instance'a gitmemeli")
+		}
+	}
+
+	// Hepsi çıkarılmışsa yine de bir instance seçilir
+	a.healthy.Store(false)
+	c.healthy.Store(false)
+	if u.pick(nil) == nil {
+		t.Error("hepsi çıkarılmışken de instance seçilmeli")
+	}
+
+	if _, err := NewBalancer("random"); err == nil {
+		t.Error("bilinmeyen balancer reddedilmeli")
+	}
+}
+
+func TestUpstreamTargetsFromEnv(t *testing.T) {
+	t.Setenv("PRODUCT_SERVICE_URL", "http://product-1:3001, http://product-2:3001/")
+	cfg, err := LoadConfig(writeConfig(t, t.TempDir(), `
+upstreams:
+  product:
+    targets: ["${PRODUCT_SERVICE_URL}", "http://product-3:3001"]
+    balancer: least_conn
+routes: [{path: /x, upstream: product, policy: public}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := NewRouteTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := table.upstreams["product"]
+	var urls []string
+	for _, target := range u.Targets {
+		urls = append(urls, target.URL)
+	}
+	want := []string{"http://product-1:3001", "http://product-2:3001", "http://product-3:3001"}
+	if len(urls) != len(want) || urls[0] != want[0] || urls[1] != want[1] || urls[2] != want[2] {
+		t.Errorf("targets = %v, want %v", urls, want)
+	}
+	if _, ok := u.Balancer.(*LeastConn); !ok {
+		t.Errorf("balancer = %T, want *LeastConn", u.Balancer)
+	}
+}
+
+func TestHealthProbing(t *testing.T) {
+	var healthyHits, flakyHits atomic.Int32
+	var flakyDown atomic.Bool
+	newInstance := func(hits *atomic.Int32, down *atomic.Bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" {
+				if down != nil && down.Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(`{"status": "unhealthy", "service": "svc"}`))
+					return
+				}
+				w.Write([]byte(`{"status": "healthy", "service": "svc"}`))
+				return
+			}
+			hits.Add(1)
+			w.Write([]byte(`{}`))
+		}))
+	}
+	healthy := newInstance(&healthyHits, nil)
+	defer healthy.Close()
+	flaky := newInstance(&flakyHits, &flakyDown)
+	defer flaky.Close()
+
+	g, err := NewGateway(writeConfig(t, t.TempDir(), `
+upstreams:
+  svc:
+    targets: ["`+healthy.URL+`", "`+flaky.URL+`"]
+    health_check: {interval: 10s, unhealthy_threshold: 2, healthy_threshold: 1}
+routes: [{path: /x, upstream: svc, policy: public}]
+`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Use(g.Handler())
+	send := func(n int) {
+		for range n {
+			resp, err := app.Test(httptest.NewRequest("GET", "/x", nil))
+			if err != nil || resp.StatusCode != 200 {
+				t.Fatalf("status = %v, err = %v", resp.StatusCode, err)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	probe := func() {
+		g.probeDue(ctx, now).Wait()
+		now = now.Add(10 * time.Second)
+	}
+
+	// İki instance da sağlıklı → yük paylaşılır
+	probe()
+	send(4)
+	if healthyHits.Load() != 2 || flakyHits.Load() != 2 {
+		t.Fatalf("dağılım: healthy=%d flaky=%d, want 2/2", healthyHits.Load(), flakyHits.Load())
+	}
+
+	// Tek başarısız yoklama yetmez, ikincisinde havuzdan çıkar
+	flakyDown.Store(true)
+	probe()
+	if target := g.targets["svc "+flaky.URL]; !target.Healthy() {
+		t.Fatal("tek başarısız yoklamada çıkarılmamalı")
+	}
+	probe()
+	send(4)
+	if flakyHits.Load() != 2 || healthyHits.Load() != 6 {
+		t.Fatalf("çıkarılmış instance'a istek gitti: healthy=%d flaky=%d", healthyHits.Load(), flakyHits.Load())
+	}
+
+	// Reload çıkarılmış instance'ı geri almaz
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	send(2)
+	if flakyHits.Load() != 2 {
+		t.Fatal("reload sonrası çıkarılmış instance'a istek gitti")
+	}
+
+	// Aralık dolmadan tekrar yoklanmaz
+	flakyDown.Store(false)
+	g.probeDue(ctx, now.Add(-5*time.Second)).Wait()
+	if g.targets["svc "+flaky.URL].Healthy() {
+		t.Fatal("interval dolmadan yoklanmamalı")
+	}
+
+	// İyileşince havuza geri döner
+	probe()
+	send(4)
+	if flakyHits.Load() != 4 {
+		t.Errorf("iyileşen instance havuza dönmedi: flaky=%d", flakyHits.Load())
+	}
+}
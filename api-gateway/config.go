@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ==============================================================================
+// GATEWAY KONFİGÜRASYONU (routes.yaml)
+// ==============================================================================
+/*
+Route'lar, upstream servisler, path rewrite'ları, izinli method'lar ve erişim
+politikası Go kodunda değil, config dosyasında tanımlanır (YAML veya JSON):
+
+	upstreams:
+	  product:
+	    targets:                    # Birden fazla instance (veya tek "url")
+	      - ${PRODUCT_SERVICE_URL:-http://localhost:3001}
+	    balancer: round_robin       # round_robin | least_conn
+	    health_check:               # Instance'lar /health ile yoklanır
+	      path: /health
+	      interval: 10s
+	    timeout: 5s                 # İstek başına süre (boşsa 10s)
+	    retries: 2                  # Sadece idempotent method'lar (GET, PUT, DELETE...)
+	    retry_backoff: 100ms        # İlk bekleme, her denemede 2 katına çıkar
+	    breaker:
+	      failures: 5               # Art arda bu kadar hata → devre açılır (503)
+	      cooldown: 30s             # Açık kalma süresi, sonra tek deneme isteği
+
+	routes:
+	  - path: /api/products/*       # :param tek segment, * kalan her şey
+	    upstream: product
+	    rewrite: /products/*        # Aynı :param ve * kullanılabilir
+	    methods: [GET, HEAD]        # Boşsa tüm method'lar
+	    policy: public              # public | authenticated | admin
+	    rate_limit: default         # rate_limits'ten grup (boşsa "default")
+
+	rate_limits:
+	  default:
+	    requests: 300               # "per" süresinde izin verilen istek
+	    per: 1m
+	    burst: 50                   # Anlık patlama kapasitesi (boşsa requests)
+
+Kurallar:
+  - İLK eşleşen route kazanır → spesifik route'lar üste yazılır
+  - Query string her zaman upstream'e iletilir
+  - ${VAR:-default} ifadeleri ortam değişkeninden doldurulur
+
+Dosya başlangıçta doğrulanır; hatalıysa gateway AÇILMAZ. Çalışırken
+değiştirilirse (veya SIGHUP gelirse) yeniden yüklenir; yeni dosya hatalıysa
+eski tablo kullanılmaya devam eder.
+*/
+
+// Config - Dosyanın ham hali
+type Config struct {
+	Upstreams  map[string]UpstreamConfig  `yaml:"upstreams" json:"upstreams"`
+	Routes     []RouteConfig              `yaml:"routes" json:"routes"`
+	RateLimits map[string]RateLimitConfig `yaml:"rate_limits" json:"rate_limits"`
+}
+
+// UpstreamConfig - İsteklerin yönlendirileceği servis
+// Instance adresleri "url" ve/veya "targets" ile verilir; her satır virgülle
+// ayrılmış liste de olabilir (PRODUCT_SERVICE_URL=http://p1:3001,http://p2:3001).
+type UpstreamConfig struct {
+	URL          string            `yaml:"url" json:"url"`
+	Targets      []string          `yaml:"targets" json:"targets"`
+	Balancer     string            `yaml:"balancer" json:"balancer"` // Boşsa round_robin
+	HealthCheck  HealthCheckConfig `yaml:"health_check" json:"health_check"`
+	Timeout      string            `yaml:"timeout" json:"timeout"`             // Boşsa 10s
+	Retries      int               `yaml:"retries" json:"retries"`             // Boşsa 0 (tekrar yok)
+	RetryBackoff string            `yaml:"retry_backoff" json:"retry_backoff"` // Boşsa 100ms
+	Breaker      BreakerConfig     `yaml:"breaker" json:"breaker"`
+}
+
+// HealthCheckConfig - Instance'ların aktif olarak yoklanması (pkg/health formatı)
+type HealthCheckConfig struct {
+	Path               string `yaml:"path" json:"path"`                               // Boşsa /health
+	Interval           string `yaml:"interval" json:"interval"`                       // Boşsa 10s
+	Timeout            string `yaml:"timeout" json:"timeout"`                         // Boşsa 2s
+	UnhealthyThreshold int    `yaml:"unhealthy_threshold" json:"unhealthy_threshold"` // Boşsa 2
+	HealthyThreshold   int    `yaml:"healthy_threshold" json:"healthy_threshold"`     // Boşsa 2
+}
+
+// BreakerConfig - Circuit breaker eşikleri
+type BreakerConfig struct {
+	Failures int    `yaml:"failures" json:"failures"` // Boşsa 5
+	Cooldown string `yaml:"cooldown" json:"cooldown"` // Boşsa 30s
+}
+
+// RouteConfig - Tek bir route tanımı
+type RouteConfig struct {
+	Path     string   `yaml:"path" json:"path"`
+	Upstream string   `yaml:"upstream" json:"upstream"`
+	Rewrite  string   `yaml:"rewrite" json:"rewrite"`
+	Methods  []string `yaml:"methods" json:"methods"`
+	Policy   Policy   `yaml:"policy" json:"policy"`
+
+	// RateLimit - rate_limits'teki grup adı (boşsa "default" grubu, o da yoksa limitsiz)
+	RateLimit string `yaml:"rate_limit" json:"rate_limit"`
+}
+
+// RateLimitConfig - Token bucket limiti: "per" süresinde "requests" istek
+type RateLimitConfig struct {
+	Requests int    `yaml:"requests" json:"requests"`
+	Per      string `yaml:"per" json:"per"`     // "1s", "1m", "1h"
+	Burst    int    `yaml:"burst" json:"burst"` // Boşsa requests
+}
+
+// defaultRateLimit - Route'ta grup belirtilmezse kullanılan grup
+const defaultRateLimit = "default"
+
+var validMethods = map[string]bool{
+	fiber.MethodGet: true, fiber.MethodHead: true, fiber.MethodPost: true, fiber.MethodPut: true,
+	fiber.MethodPatch: true, fiber.MethodDelete: true, fiber.MethodOptions: true,
+}
+
+// LoadConfig - Dosyayı okur (.json → JSON, diğerleri YAML) ve env'leri açar
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = []byte(os.Expand(string(data), expandEnv))
+
+	var cfg Config
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &cfg)
+	} else {
+		err = yaml.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s ayrıştırılamadı: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// expandEnv - ${VAR} ve ${VAR:-default} desteği
+func expandEnv(expr string) string {
+	name, fallback, _ := strings.Cut(expr, ":-")
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Validate - Tüm hataları birlikte döner (ilk hatada durmaz)
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if len(cfg.Routes) == 0 {
+		errs = append(errs, errors.New("hiç route tanımlanmamış"))
+	}
+
+	for name, upstream := range cfg.Upstreams {
+		if _, err := upstream.Compile(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for name, rl := range cfg.RateLimits {
+		if _, err := rl.Rule(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	seen := make(map[string]int)
+	for i, route := range cfg.Routes {
+		where := fmt.Sprintf("routes[%d] (%s)", i, route.Path)
+
+		if !strings.HasPrefix(route.Path, "/") {
+			errs = append(errs, fmt.Errorf("%s: path '/' ile başlamalı", where))
+		}
+		if _, ok := cfg.Upstreams[route.Upstream]; !ok {
+			errs = append(errs, fmt.Errorf("%s: bilinmeyen upstream %q", where, route.Upstream))
+		}
+		switch route.Policy {
+		case PolicyPublic, PolicyAuthenticated, PolicyAdmin:
+		default:
+			errs = append(errs, fmt.Errorf("%s: geçersiz policy %q (public, authenticated, admin)", where, route.Policy))
+		}
+		for _, method := range route.Methods {
+			if !validMethods[strings.ToUpper(method)] {
+				errs = append(errs, fmt.Errorf("%s: geçersiz method %q", where, method))
+			}
+		}
+
+		segments := splitPath(route.Path)
+		for j, seg := range segments {
+			if seg == "*" && j != len(segments)-1 {
+				errs = append(errs, fmt.Errorf("%s: '*' sadece son segment olabilir", where))
+			}
+		}
+		if route.Rewrite != "" {
+			if !strings.HasPrefix(route.Rewrite, "/") {
+				errs = append(errs, fmt.Errorf("%s: rewrite '/' ile başlamalı", where))
+			}
+			for _, seg := range splitPath(route.Rewrite) {
+				if (seg == "*" || strings.HasPrefix(seg, ":")) && !containsSegment(segments, seg) {
+					errs = append(errs, fmt.Errorf("%s: rewrite'taki %q path'te yok", where, seg))
+				}
+			}
+		}
+
+		if route.RateLimit != "" {
+			if _, ok := cfg.RateLimits[route.RateLimit]; !ok {
+				errs = append(errs, fmt.Errorf("%s: bilinmeyen rate_limit grubu %q", where, route.RateLimit))
+			}
+		}
+
+		key := strings.ToUpper(strings.Join(route.Methods, ",")) + " " + route.Path
+		if prev, dup := seen[key]; dup {
+			errs = append(errs, fmt.Errorf("%s: routes[%d] ile aynı", where, prev))
+		}
+		seen[key] = i
+	}
+
+	return errors.Join(errs...)
+}
+
+func containsSegment(segments []string, seg string) bool {
+	for _, s := range segments {
+		if s == seg {
+			return true
+		}
+	}
+	return false
+}
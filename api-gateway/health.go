@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ecommerce-backend/pkg/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ==============================================================================
+// HEALTH ENDPOINT'LERİ (Gateway + tüm servisler)
+// ==============================================================================
+/*
+	GET /livez  → Gateway süreci ayakta mı? (Kubernetes liveness)
+	              Bağımlılıklara BAKMAZ: auth-service çöktü diye gateway
+	              yeniden başlatılmamalı.
+	GET /readyz → Gateway trafik almaya hazır mı? (Kubernetes readiness)
+	              Route tablosu yüklü ve kapanma sürecinde değilse 200.
+	GET /health → Tüm upstream instance'larının /health yanıtları (paralel,
+	              timeout'lu) tek raporda: servis ve bağımlılık bazında durum.
+
+/health durumu:
+  - healthy   → Tüm servisler sağlıklı
+  - degraded  → Bazı servisler/instance'lar sorunlu (200, gateway çalışıyor)
+  - unhealthy → Hiçbir servis yanıt vermiyor (503)
+*/
+
+// GatewayHealth - Birleşik rapor (pkg/health.HealthResponse + servisler)
+type GatewayHealth struct {
+	health.HealthResponse
+	Services map[string]ServiceHealth `json:"services"`
+}
+
+// ServiceHealth - Bir upstream'in tüm instance'larının özeti
+type ServiceHealth struct {
+	Status    string           `json:"status"`
+	Breaker   string           `json:"breaker"` // closed | open | half_open
+	Instances []InstanceHealth `json:"instances"`
+}
+
+// InstanceHealth - Tek instance'ın kendi /health yanıtı
+type InstanceHealth struct {
+	URL      string                        `json:"url"`
+	Status   string                        `json:"status"`
+	Ejected  bool                          `json:"ejected"` // Aktif yoklama havuzdan çıkardı mı
+	Duration time.Duration                 `json:"duration"`
+	Checks   map[string]health.CheckResult `json:"checks,omitempty"` // Bağımlılıklar (postgres, redis...)
+	Error    string                        `json:"error,omitempty"`
+}
+
+// Health - Tüm instance'ları paralel yoklar; timeout'u aşan unhealthy sayılır
+func (g *Gateway) Health(ctx context.Context, timeout time.Duration) GatewayHealth {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	table := g.table.Load()
+	client := &http.Client{}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	instances := make(map[string][]InstanceHealth)
+	for name, u := range table.upstreams {
+		for _, t := range u.Targets {
+			wg.Add(1)
+			go func(name string, t *Target, path string) {
+				defer wg.Done()
+				result := probeInstance(ctx, client, t, path)
+				mu.Lock()
+				instances[name] = append(instances[name], result)
+				mu.Unlock()
+			}(name, t, u.Health.Path)
+		}
+	}
+	wg.Wait()
+
+	report := GatewayHealth{
+		HealthResponse: health.HealthResponse{
+			Status:    health.StatusHealthy,
+			Timestamp: time.Now(),
+			Service:   "api-gateway",
+			Checks: map[string]health.CheckResult{
+				"self": {Status: health.StatusHealthy, Message: "gateway is running"},
+			},
+		},
+		Services: make(map[string]ServiceHealth),
+	}
+
+	down := 0
+	for name, u := range table.upstreams {
+		list := instances[name]
+		sort.Slice(list, func(i, j int) bool { return list[i].URL < list[j].URL })
+
+		service := ServiceHealth{Status: combineInstances(list), Breaker: BreakerClosed.String(), Instances: list}
+		if u.breaker != nil {
+			service.Breaker = u.breaker.State().String()
+		}
+		report.Services[name] = service
+
+		if service.Status != health.StatusHealthy {
+			report.Status = health.StatusDegraded
+		}
+		if service.Status == health.StatusUnhealthy {
+			down++
+		}
+	}
+	if down > 0 && down == len(table.upstreams) {
+		report.Status = health.StatusUnhealthy
+	}
+	return report
+}
+
+func probeInstance(ctx context.Context, client *http.Client, t *Target, path string) InstanceHealth {
+	start := time.Now()
+	response, err := health.FetchRemote(ctx, client, t.URL+path)
+
+	result := InstanceHealth{
+		URL:      t.URL,
+		Status:   response.Status,
+		Ejected:  !t.Healthy(),
+		Duration: time.Since(start),
+		Checks:   response.Checks,
+	}
+	if err != nil {
+		result.Status = health.StatusUnhealthy
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// combineInstances - Hepsi sağlıklı → healthy, hiçbiri → unhealthy, arası degraded
+func combineInstances(list []InstanceHealth) string {
+	up, healthy := 0, 0
+	for _, i := range list {
+		switch i.Status {
+		case health.StatusHealthy:
+			healthy++
+			up++
+		case health.StatusDegraded:
+			up++
+		}
+	}
+	switch {
+	case up == 0:
+		return health.StatusUnhealthy
+	case healthy == len(list):
+		return health.StatusHealthy
+	default:
+		return health.StatusDegraded
+	}
+}
+
+// ==============================================================================
+// HANDLER'LAR
+// ==============================================================================
+
+// HealthHandler - GET /health
+func (g *Gateway) HealthHandler(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := g.Health(c.UserContext(), timeout)
+		status := fiber.StatusOK
+		if report.Status == health.StatusUnhealthy {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(report)
+	}
+}
+
+// LivenessHandler - GET /livez (süreç yanıt verebiliyorsa 200)
+func LivenessHandler(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": health.StatusHealthy})
+}
+
+// ReadinessHandler - GET /readyz
+// draining: SIGTERM alındığında true olur → load balancer trafiği keser.
+func (g *Gateway) ReadinessHandler(draining *atomic.Bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch {
+		case draining.Load():
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": health.StatusUnhealthy, "message": "shutting down"})
+		case g.table.Load() == nil:
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": health.StatusUnhealthy, "message": "routes not loaded"})
+		}
+		return c.JSON(fiber.Map{"status": health.StatusHealthy})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ecommerce-backend/pkg/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func healthServer(status int, body string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestGatewayHealth(t *testing.T) {
+	product := healthServer(200, `{"status": "healthy", "service": "product-service", "checks": {"postgres": {"status": "healthy"}}}`, 0)
+	defer product.Close()
+	orderOK := healthServer(200, `{"status": "healthy", "service": "order-service"}`, 0)
+	defer orderOK.Close()
+	orderDB := healthServer(503, `{"status": "unhealthy", "service": "order-service", "checks": {"postgres": {"status": "unhealthy", "message": "ping failed"}}}`, 0)
+	defer orderDB.Close()
+	search := healthServer(200, `{"status": "healthy"}`, time.Second) // Askıda
+	defer search.Close()
+
+	g, err := NewGateway(writeConfig(t, t.TempDir(), `
+upstreams:
+  product: {url: "`+product.URL+`"}
+  order: {targets: ["`+orderOK.URL+`", "`+orderDB.URL+`"]}
+  search: {url: "`+search.URL+`"}
+routes: [{path: /x, upstream: product, policy: public}]
+`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Get("/health", g.HealthHandler(200*time.Millisecond))
+
+	start := time.Now()
+	resp, err := app.Test(httptest.NewRequest("GET", "/health", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("askıdaki servis raporu bekletti: %s", elapsed)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status = %d, want 200 (degraded)", resp.StatusCode)
+	}
+
+	var report GatewayHealth
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != health.StatusDegraded || report.Service != "api-gateway" {
+		t.Errorf("rapor = %s/%s, want degraded/api-gateway", report.Status, report.Service)
+	}
+
+	want := map[string]string{
+		"product": health.StatusHealthy,
+		"order":   health.StatusDegraded, // 2 instance'tan biri sorunlu
+		"search":  health.StatusUnhealthy,
+	}
+	for name, status := range want {
+		if got := report.Services[name].Status; got != status {
+			t.Errorf("%s = %s, want %s", name, got, status)
+		}
+	}
+
+	// Bağımlılık bazında durum instance raporunda
+	for _, instance := range report.Services["order"].Instances {
+		if instance.URL == orderDB.URL && instance.Checks["postgres"].Status != health.StatusUnhealthy {
+			t.Errorf("order postgres durumu raporda yok: %+v", instance)
+		}
+	}
+	if report.Services["search"].Instances[0].Error == "" {
+		t.Error("timeout hatası raporda olmalı")
+	}
+
+	// Tüm servisler çökmüşse unhealthy (→ 503)
+	down, err := NewGateway(writeConfig(t, t.TempDir(), `
+upstreams:
+  order: {url: "`+orderDB.URL+`"}
+  search: {url: "`+search.URL+`"}
+routes: [{path: /x, upstream: order, policy: public}]
+`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := down.Health(t.Context(), 200*time.Millisecond); report.Status != health.StatusUnhealthy {
+		t.Errorf("hepsi çökmüşken status = %s, want unhealthy", report.Status)
+	}
+}
+
+func TestLivenessReadiness(t *testing.T) {
+	g, err := NewGateway(writeConfig(t, t.TempDir(), `
+upstreams: {a: {url: "http://127.0.0.1:1"}}
+routes: [{path: /x, upstream: a, policy: public}]
+`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var draining atomic.Bool
+	app := fiber.New()
+	app.Get("/livez", LivenessHandler)
+	app.Get("/readyz", g.ReadinessHandler(&draining))
+
+	check := func(path string, want int) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+
+	// Upstream'e ulaşılamasa da gateway canlı ve hazır
+	check("/livez", 200)
+	check("/readyz", 200)
+
+	// Kapanırken: canlı ama trafik almıyor
+	draining.Store(true)
+	check("/livez", 200)
+	check("/readyz", 503)
+}
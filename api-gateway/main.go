@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strconv" // Status code'u stringe çevirmek için
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"ecommerce-backend/pkg/auth"
+
 	"github.com/gofiber/adaptor/v2" // Fiber'ı standart Go handler'ına çevirir
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/proxy"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 )
 
 // 1. KENDİ METRİĞİMİZİ TANIMLIYORUZ (Custom Metric)
@@ -25,6 +32,67 @@ var (
 		},
 		[]string{"method", "path", "status"}, // Bu etiketlere göre kırılım yapabiliriz
 	)
+
+	// Rate limit'e takılan istekler (route grubuna göre)
+	rateLimitedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_rate_limited_total",
+			Help: "Rate limit nedeniyle reddedilen istek sayısı",
+		},
+		[]string{"group"},
+	)
+
+	// Circuit breaker durumu: 0=closed, 1=open, 2=half_open
+	breakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_circuit_breaker_state",
+			Help: "Upstream circuit breaker durumu (0=closed, 1=open, 2=half_open)",
+		},
+		[]string{"upstream"},
+	)
+
+	breakerTransitionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_circuit_breaker_transitions_total",
+			Help: "Circuit breaker durum değişiklikleri",
+		},
+		[]string{"upstream", "state"},
+	)
+
+	// Devre açıkken upstream'e gönderilmeden 503 dönen istekler
+	breakerRejectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_circuit_breaker_rejected_total",
+			Help: "Circuit breaker açık olduğu için reddedilen istek sayısı",
+		},
+		[]string{"upstream"},
+	)
+
+	upstreamRetriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_upstream_retries_total",
+			Help: "Upstream'e yapılan tekrar deneme sayısı",
+		},
+		[]string{"upstream"},
+	)
+
+	// Aktif health check sonucu: 1=havuzda, 0=havuzdan çıkarılmış
+	upstreamTargetHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_upstream_target_healthy",
+			Help: "Upstream instance'ının sağlık durumu (1=healthy, 0=ejected)",
+		},
+		[]string{"upstream", "target"},
+	)
+
+	// reason: timeout, connection, 502, 503, 504
+	upstreamErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_upstream_errors_total",
+			Help: "Başarısız upstream denemeleri",
+		},
+		[]string{"upstream", "reason"},
+	)
 )
 
 func getEnv(key, fallback string) string {
@@ -37,23 +105,24 @@ func getEnv(key, fallback string) string {
 func main() {
 	app := fiber.New()
 
-	// Service URLs
-	authServiceURL := getEnv("AUTH_SERVICE_URL", "http://localhost:3002")
-	productServiceURL := getEnv("PRODUCT_SERVICE_URL", "http://localhost:3001")
-	cartServiceURL := getEnv("CART_SERVICE_URL", "http://localhost:3003")
-	orderServiceURL := getEnv("ORDER_SERVICE_URL", "http://localhost:3004")
-	searchServiceURL := getEnv("SEARCH_SERVICE_URL", "http://localhost:3006")
-	reviewServiceURL := getEnv("REVIEW_SERVICE_URL", "http://localhost:3008")
-	wishlistServiceURL := getEnv("WISHLIST_SERVICE_URL", "http://localhost:3009")
-	couponServiceURL := getEnv("COUPON_SERVICE_URL", "http://localhost:3010")
-
 	// --- CORS AYARLARI (EN BAŞTA OLMALI!) ---
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, Idempotency-Key",
 		AllowMethods: "GET, POST, HEAD, PUT, DELETE, PATCH, OPTIONS",
 	}))
 
+	// --- TOKEN DOĞRULAYICI ---
+	// Auth Service'in public anahtarları (JWKS) + logout denylist'i (Redis)
+	redisAddr := fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379"))
+	verifier, err := auth.NewVerifier(auth.Config{
+		JWKSURL:  getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		Denylist: auth.DialRedisDenylist(redisAddr),
+	})
+	if err != nil {
+		log.Fatal("❌ Token doğrulayıcı oluşturulamadı: ", err)
+	}
+
 	// --- MANUEL MIDDLEWARE (Prometheus Metrics) ---
 	// Her istek geldiğinde bu fonksiyon çalışır
 	app.Use(func(c *fiber.Ctx) error {
@@ -74,151 +143,64 @@ func main() {
 	// Prometheus gelip verileri buradan okuyacak
 	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
 
-	// ==============================================================================
-	// HEALTH CHECK ENDPOINT
-	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status":    "healthy",
-			"service":   "api-gateway",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks": fiber.Map{
-				"self": fiber.Map{"status": "healthy", "message": "gateway is running"},
-			},
-		})
-	})
+	// --- RATE LIMITER ---
+	// memory: tek instance, redis: birden fazla gateway aynı limitleri paylaşır
+	var limiter RateLimiter
+	switch backend := getEnv("RATE_LIMIT_BACKEND", "memory"); backend {
+	case "redis":
+		limiter = NewRedisRateLimiter(redis.NewClient(&redis.Options{Addr: redisAddr}))
+	case "memory":
+		limiter = NewMemoryRateLimiter()
+	default:
+		log.Fatalf("❌ Geçersiz RATE_LIMIT_BACKEND: %s (memory veya redis)", backend)
+	}
 
 	// --- ROTALAR ---
+	// Route'lar, upstream'ler, erişim politikaları ve limitler routes.yaml'dan gelir.
+	// Config hatalıysa gateway başlamaz; çalışırken değişirse yeniden yüklenir.
+	gateway, err := NewGateway(getEnv("GATEWAY_CONFIG", "routes.yaml"), verifier, limiter)
+	if err != nil {
+		log.Fatal("❌ Route config yüklenemedi: ", err)
+	}
+	reloadInterval, err := time.ParseDuration(getEnv("GATEWAY_CONFIG_RELOAD", "5s"))
+	if err != nil {
+		reloadInterval = 5 * time.Second
+	}
+	gateway.Watch(reloadInterval)
+	gateway.StartHealthChecks(context.Background())
 
-	// Auth Service (3002) - Login/Register
-	app.Group("/api/auth", func(c *fiber.Ctx) error {
-		path := c.Path()[len("/api/auth"):]
-		return proxy.Do(c, authServiceURL+path)
-	})
-
-	// Profile Service (3002 - Auth Service içinde)
-	app.All("/api/profile/:id/password", func(c *fiber.Ctx) error {
-		// /api/profile/5/password -> http://localhost:3002/profile/5/password
-		id := c.Params("id")
-		return proxy.Do(c, authServiceURL+"/profile/"+id+"/password")
-	})
-
-	app.All("/api/profile/:id", func(c *fiber.Ctx) error {
-		// /api/profile/5 -> http://localhost:3002/profile/5
-		id := c.Params("id")
-		return proxy.Do(c, authServiceURL+"/profile/"+id)
-	})
-
-	// Address Service (3002 - Auth Service içinde)
-	app.All("/api/addresses/:id/default", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		return proxy.Do(c, authServiceURL+"/addresses/"+id+"/default")
-	})
-
-	app.All("/api/addresses/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		return proxy.Do(c, authServiceURL+"/addresses/"+id)
-	})
-
-	// Product Service (3001) - Ürünler
-	app.Group("/api/products", func(c *fiber.Ctx) error {
-		path := c.Path()[len("/api/products"):]
-		// Query string'i de ekle (filtreleme için)
-		queryString := string(c.Request().URI().QueryString())
-		url := productServiceURL + "/products" + path
-		if queryString != "" {
-			url += "?" + queryString
-		}
-		return proxy.Do(c, url)
-	})
-
-	// Category Service (3001 - Product Service içinde)
-	app.Group("/api/categories", func(c *fiber.Ctx) error {
-		path := c.Path()[len("/api/categories"):]
-		return proxy.Do(c, productServiceURL+"/categories"+path)
-	})
-
-	// Cart Service (3003)
-	app.Group("/api/cart", func(c *fiber.Ctx) error {
-		path := c.Path()[len("/api/cart"):]
-		return proxy.Do(c, cartServiceURL+"/cart"+path)
-	})
-
-	// ===========================================
-	// Order Service (3004) - Sipariş Yönetimi
-	// ===========================================
-
-	// 1. İstatistikler (Admin) - En spesifik, ilk sıraya
-	app.Get("/api/orders/stats", func(c *fiber.Ctx) error {
-		return proxy.Do(c, orderServiceURL+"/orders/stats")
-	})
-
-	// 2. Kullanıcının siparişleri - /api/orders/user/:userid
-	app.Get("/api/orders/user/:userid", func(c *fiber.Ctx) error {
-		userid := c.Params("userid")
-		return proxy.Do(c, orderServiceURL+"/orders/user/"+userid)
-	})
-
-	// 3. Sipariş durumu güncelle - /api/orders/:id/status
-	app.Patch("/api/orders/:id/status", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		return proxy.Do(c, orderServiceURL+"/orders/"+id+"/status")
-	})
-
-	// 4. Tek sipariş detayı - /api/orders/:id
-	app.Get("/api/orders/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		return proxy.Do(c, orderServiceURL+"/orders/"+id)
-	})
-
-	// 5. Tüm siparişler (Admin) veya Sipariş oluştur
-	app.All("/api/orders", func(c *fiber.Ctx) error {
-		// Query parametrelerini de ilet (pagination için: ?page=1&limit=20)
-		queryString := string(c.Request().URI().QueryString())
-		url := orderServiceURL + "/orders"
-		if queryString != "" {
-			url += "?" + queryString
+	// ==============================================================================
+	// HEALTH CHECK ENDPOINT'LERİ
+	// ==============================================================================
+	// /livez: süreç ayakta, /readyz: trafik almaya hazır, /health: tüm servisler
+	healthTimeout, err := time.ParseDuration(getEnv("GATEWAY_HEALTH_TIMEOUT", "3s"))
+	if err != nil {
+		healthTimeout = 3 * time.Second
+	}
+	var draining atomic.Bool
+	app.Get("/livez", LivenessHandler)
+	app.Get("/readyz", gateway.ReadinessHandler(&draining))
+	app.Get("/health", gateway.HealthHandler(healthTimeout))
+
+	app.Use(gateway.Handler())
+
+	// --- GRACEFUL SHUTDOWN ---
+	// SIGTERM → /readyz 503 döner, load balancer trafiği keser, sonra kapanır
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+		<-stop
+		draining.Store(true)
+		delay, err := time.ParseDuration(getEnv("SHUTDOWN_DELAY", "5s"))
+		if err != nil {
+			delay = 5 * time.Second
 		}
-		return proxy.Do(c, url)
-	})
+		log.Printf("🛑 Kapanıyor, %s boyunca yeni trafik bekleniyor...", delay)
+		time.Sleep(delay)
+		app.ShutdownWithTimeout(30 * time.Second)
+	}()
 
-	// Search Service (3006)
-	app.Use("/api/search", func(c *fiber.Ctx) error {
-		url := searchServiceURL + c.OriginalURL()[4:]
-		return proxy.Do(c, url)
-	})
-	// 6. Review Service (YENİ) - Port 3008
-	app.Group("/api/reviews", func(c *fiber.Ctx) error {
-		// /api/reviews/10 -> http://localhost:3008/reviews/10
-		url := reviewServiceURL + "/reviews" + c.Path()[len("/api/reviews"):]
-		return proxy.Do(c, url)
-	})
-	// 7. Wishlist Service (YENİ) - Port 3009
-	app.Group("/api/wishlist", func(c *fiber.Ctx) error {
-		// /api/wishlist/5 -> http://localhost:3009/wishlist/5
-		url := wishlistServiceURL + "/wishlist" + c.Path()[len("/api/wishlist"):]
-		return proxy.Do(c, url)
-	})
-
-	// 8. Coupon Service - Port 3010
-	// Kupon yönetimi ve uygulama endpoint'leri
-	app.All("/api/coupons/apply", func(c *fiber.Ctx) error {
-		return proxy.Do(c, couponServiceURL+"/coupons/apply")
-	})
-	app.All("/api/coupons/use", func(c *fiber.Ctx) error {
-		return proxy.Do(c, couponServiceURL+"/coupons/use")
-	})
-	app.All("/api/coupons/:id/stats", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		return proxy.Do(c, couponServiceURL+"/coupons/"+id+"/stats")
-	})
-	app.All("/api/coupons/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		return proxy.Do(c, couponServiceURL+"/coupons/"+id)
-	})
-	app.All("/api/coupons", func(c *fiber.Ctx) error {
-		return proxy.Do(c, couponServiceURL+"/coupons")
-	})
-
-	log.Fatal(app.Listen(":8080"))
+	if err := app.Listen(":8080"); err != nil {
+		log.Fatal(err)
+	}
 }
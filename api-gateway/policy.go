@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ==============================================================================
+// MERKEZİ KİMLİK DOĞRULAMA (Gateway)
+// ==============================================================================
+/*
+Token her istekte BİR KEZ gateway'de doğrulanır:
+
+ 1. Client'ın gönderdiği X-User-* / X-Session-ID header'ları silinir
+    (kimse "X-User-ID: 1" yazarak admin olamaz)
+ 2. Route'un politikası (routes.yaml → policy) uygulanır
+ 3. Token doğrulanır (imza, süre, denylist)
+ 4. Doğrulanan kimlik servislere güvenilir header'larla iletilir:
+
+	X-User-ID: 5
+	X-User-Roles: customer,admin
+	X-User-Permissions: orders:create,orders:read_own,...
+	X-Session-ID: 2c26b46b...
+
+Servisler kendi token kontrollerini de yapmaya devam eder (defense in depth).
+*/
+
+// Policy - Route erişim politikası
+type Policy string
+
+const (
+	PolicyPublic        Policy = "public"        // Token opsiyonel
+	PolicyAuthenticated Policy = "authenticated" // Geçerli token şart
+	PolicyAdmin         Policy = "admin"         // Geçerli token + admin rolü
+)
+
+// authorize - Doğrulama sonucuna (Verifier.Authenticate) politikayı uygular
+// İzin verilirse status=0 döner ve kimlik header'ları upstream isteğine yazılır.
+func authorize(c *fiber.Ctx, policy Policy, authenticated bool, err error) (int, string) {
+	if policy != PolicyPublic {
+		if errors.Is(err, auth.ErrRevoked) {
+			return fiber.StatusUnauthorized, "Oturumunuz sonlandırıldı, lütfen tekrar giriş yapın!"
+		}
+		if !authenticated {
+			return fiber.StatusUnauthorized, "Giriş yapmanız gerekiyor!"
+		}
+		if policy == PolicyAdmin && !auth.HasRole(c, auth.RoleAdmin) {
+			return fiber.StatusForbidden, "Bu işlem için yetkiniz yok!"
+		}
+	}
+
+	// Public route'ta geçersiz token → anonim kullanıcı gibi devam et
+	if authenticated {
+		forwardIdentity(c)
+	}
+	return 0, ""
+}
+
+// stripIdentityHeaders - Client'tan gelen kimlik header'larını siler
+// X-User-* ile başlayan HER header silinir (ileride eklenecekler dahil).
+// Servis anahtarı (X-Service-Token) da silinir: iç endpoint'lere dışarıdan ulaşılamaz.
+func stripIdentityHeaders(c *fiber.Ctx) {
+	var spoofed []string
+	c.Request().Header.VisitAll(func(key, _ []byte) {
+		name := strings.ToLower(string(key))
+		if strings.HasPrefix(name, "x-user-") || name == strings.ToLower(auth.HeaderSessionID) ||
+			name == strings.ToLower(auth.HeaderServiceToken) {
+			spoofed = append(spoofed, string(key))
+		}
+	})
+	for _, key := range spoofed {
+		c.Request().Header.Del(key)
+	}
+}
+
+// forwardIdentity - Doğrulanmış kimliği upstream'e giden isteğe yazar
+func forwardIdentity(c *fiber.Ctx) {
+	userID, _ := auth.UserID(c)
+	c.Request().Header.Set(auth.HeaderUserID, strconv.FormatUint(uint64(userID), 10))
+	c.Request().Header.Set(auth.HeaderUserRoles, strings.Join(auth.Roles(c), ","))
+	c.Request().Header.Set(auth.HeaderUserPermissions, strings.Join(auth.Permissions(c), ","))
+	if sid := auth.SessionID(c); sid != "" {
+		c.Request().Header.Set(auth.HeaderSessionID, sid)
+	}
+}
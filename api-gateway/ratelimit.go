@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// ==============================================================================
+// RATE LIMITING (Token Bucket)
+// ==============================================================================
+/*
+Her istemcinin her route grubu için bir "kovası" (bucket) vardır:
+
+  - Kova en fazla "burst" token alır, saniyede requests/per token dolar
+  - Her istek 1 token harcar; token yoksa 429 Too Many Requests
+  - İstemci: giriş yapmışsa kullanıcı ID'si (token'dan), değilse IP
+
+Limitler routes.yaml → rate_limits altında gruplanır; route'lar
+"rate_limit: login" ile gruba bağlanır. Login ve kupon uygulama gibi
+brute-force'a açık route'ların grupları daha sıkıdır.
+
+Backend (RATE_LIMIT_BACKEND):
+  - memory → Tek gateway instance'ı için (varsayılan)
+  - redis  → Birden fazla gateway instance'ı aynı kovaları paylaşır
+
+Yanıt header'ları (IETF RateLimit header'ları):
+
+	RateLimit-Limit: 5         → Kova kapasitesi
+	RateLimit-Remaining: 3     → Kalan istek hakkı
+	RateLimit-Reset: 24        → Kova kaç saniyede tamamen dolar
+	Retry-After: 12            → (sadece 429'da) Kaç saniye sonra tekrar denenmeli
+*/
+
+// RateRule - Derlenmiş limit kuralı
+type RateRule struct {
+	Name  string
+	Rate  float64 // Saniyede eklenen token
+	Burst int     // Kova kapasitesi
+}
+
+// Rule - Config satırını doğrulayıp kurala çevirir
+func (rl RateLimitConfig) Rule(name string) (*RateRule, error) {
+	per, err := time.ParseDuration(rl.Per)
+	if err != nil || per <= 0 {
+		return nil, fmt.Errorf("rate_limits.%s: geçersiz per %q", name, rl.Per)
+	}
+	if rl.Requests <= 0 {
+		return nil, fmt.Errorf("rate_limits.%s: requests pozitif olmalı", name)
+	}
+	if rl.Burst < 0 {
+		return nil, fmt.Errorf("rate_limits.%s: burst negatif olamaz", name)
+	}
+	burst := rl.Burst
+	if burst == 0 {
+		burst = rl.Requests
+	}
+	return &RateRule{Name: name, Rate: float64(rl.Requests) / per.Seconds(), Burst: burst}, nil
+}
+
+// Decision - Tek bir isteğin limit sonucu
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Bir sonraki token'a kadar (sadece reddedilince)
+	Reset      time.Duration // Kovanın tamamen dolmasına kadar
+}
+
+// decide - Kovada kalan token sayısından kararı hesaplar (backend'lerden bağımsız)
+func decide(rule *RateRule, tokens float64, allowed bool) Decision {
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     rule.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((float64(rule.Burst) - tokens) / rule.Rate),
+	}
+	if !allowed {
+		d.RetryAfter = secondsToDuration((1 - tokens) / rule.Rate)
+	}
+	return d
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimiter - Kova deposu (memory veya Redis)
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, rule *RateRule) (Decision, error)
+}
+
+// ==============================================================================
+// MEMORY BACKEND
+// ==============================================================================
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryRateLimiter - Süreç içi kovalar (tek instance)
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryRateLimiter - Boş kovalarla başlar; dolu ve eskimiş kovalar
+// dakikada bir temizlenir (bellek sınırsız büyümesin)
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	m := &MemoryRateLimiter{buckets: make(map[string]*bucket), now: time.Now}
+	go func() {
+		for range time.Tick(time.Minute) {
+			m.sweep(10 * time.Minute)
+		}
+	}()
+	return m
+}
+
+func (m *MemoryRateLimiter) Allow(ctx context.Context, key string, rule *RateRule) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), last: now}
+		m.buckets[key] = b
+	}
+
+	// Geçen süre kadar token ekle (kapasiteyi aşmadan)
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.Rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return decide(rule, b.tokens, allowed), nil
+}
+
+func (m *MemoryRateLimiter) sweep(idle time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := m.now().Add(-idle)
+	for key, b := range m.buckets {
+		if b.last.Before(cutoff) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// ==============================================================================
+// REDIS BACKEND
+// ==============================================================================
+
+// tokenBucketScript - Oku → doldur → harca → yaz işlemi Redis'te atomik
+// Birden fazla gateway aynı anda aynı kovaya dokunsa da sayım kaymaz.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil then
+  tokens = burst
+  ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimiter - Instance'lar arası paylaşılan kovalar
+type RedisRateLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimiter(client *redis.Client) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client}
+}
+
+func (r *RedisRateLimiter) Allow(ctx context.Context, key string, rule *RateRule) (Decision, error) {
+	res, err := tokenBucketScript.Run(ctx, r.client, []string{"ratelimit:" + key},
+		rule.Rate, rule.Burst, time.Now().UnixMilli()).Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(res) != 2 {
+		return Decision{}, fmt.Errorf("beklenmeyen rate limit yanıtı: %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Decision{}, err
+	}
+	return decide(rule, tokens, allowed == 1), nil
+}
+
+// ==============================================================================
+// MIDDLEWARE YARDIMCILARI
+// ==============================================================================
+
+// rateLimitTimeout - Redis yavaşsa istek bekletilmez (fail-open)
+const rateLimitTimeout = 100 * time.Millisecond
+
+// clientKey - Kova anahtarı: "<grup>:user:<id>" veya "<grup>:ip:<ip>"
+// Token doğrulandıktan sonra çağrılmalı (auth.UserID kullanır).
+func clientKey(c *fiber.Ctx, rule *RateRule) string {
+	if userID, ok := auth.UserID(c); ok {
+		return rule.Name + ":user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return rule.Name + ":ip:" + c.IP()
+}
+
+// applyRateLimit - Limiti uygular; reddedilirse 429 yanıtını yazar ve false döner
+// Limit yoksa veya backend erişilemezse (fail-open) decision nil döner.
+func applyRateLimit(c *fiber.Ctx, limiter RateLimiter, rule *RateRule) (*Decision, bool) {
+	if limiter == nil || rule == nil {
+		return nil, true
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), rateLimitTimeout)
+	defer cancel()
+	d, err := limiter.Allow(ctx, clientKey(c, rule), rule)
+	if err != nil {
+		log.Printf("⚠️ Rate limit kontrol edilemedi (%s): %v", rule.Name, err)
+		return nil, true
+	}
+	if d.Allowed {
+		return &d, true
+	}
+
+	rateLimitedTotal.WithLabelValues(rule.Name).Inc()
+	c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Çok fazla istek gönderdiniz, lütfen biraz bekleyin"})
+	writeRateLimitHeaders(c, &d)
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(d.RetryAfter)))
+	return &d, false
+}
+
+// writeRateLimitHeaders - RateLimit-* header'larını yanıta yazar
+// proxy.Do yanıtı upstream'inkiyle değiştirdiği için proxy'den SONRA çağrılır.
+func writeRateLimitHeaders(c *fiber.Ctx, d *Decision) {
+	if d == nil {
+		return
+	}
+	c.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRateLimitRule(t *testing.T) {
+	rule, err := RateLimitConfig{Requests: 5, Per: "1m"}.Rule("login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Burst != 5 || rule.Rate != 5.0/60 {
+		t.Errorf("rule = %+v, want burst=5 rate=5/60", rule)
+	}
+
+	for _, bad := range []RateLimitConfig{
+		{Requests: 5, Per: ""},
+		{Requests: 5, Per: "-1s"},
+		{Requests: 0, Per: "1m"},
+		{Requests: 5, Per: "1m", Burst: -1},
+	} {
+		if _, err := bad.Rule("x"); err == nil {
+			t.Errorf("%+v reddedilmeliydi", bad)
+		}
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	m := &MemoryRateLimiter{buckets: make(map[string]*bucket), now: func() time.Time { return now }}
+	rule := &RateRule{Name: "login", Rate: 1, Burst: 3} // saniyede 1 token, en fazla 3
+	ctx := context.Background()
+
+	// Burst kadar istek hemen geçer
+	for i := 2; i >= 0; i-- {
+		d, _ := m.Allow(ctx, "a", rule)
+		if !d.Allowed || d.Remaining != i || d.Limit != 3 {
+			t.Fatalf("istek %d: %+v", 3-i, d)
+		}
+	}
+
+	// Kova boş → 429, bir sonraki token 1 sn sonra
+	d, _ := m.Allow(ctx, "a", rule)
+	if d.Allowed || d.RetryAfter != time.Second || d.Reset != 3*time.Second {
+		t.Fatalf("boş kova: %+v", d)
+	}
+
+	// Başka istemcinin kovası etkilenmez
+	if d, _ := m.Allow(ctx, "b", rule); !d.Allowed {
+		t.Error("farklı anahtar kendi kovasını kullanmalı")
+	}
+
+	// 1.5 sn sonra 1 token dolmuş olur
+	now = now.Add(1500 * time.Millisecond)
+	if d, _ := m.Allow(ctx, "a", rule); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("dolum sonrası: %+v", d)
+	}
+
+	// Uzun beklemede kova kapasiteyi aşmaz
+	now = now.Add(time.Hour)
+	if d, _ := m.Allow(ctx, "a", rule); d.Remaining != 2 {
+		t.Errorf("remaining = %d, want 2", d.Remaining)
+	}
+
+	// Boşta kalan kovalar temizlenir
+	now = now.Add(11 * time.Minute)
+	m.sweep(10 * time.Minute)
+	if len(m.buckets) != 0 {
+		t.Errorf("%d kova temizlenmedi", len(m.buckets))
+	}
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, *RateRule) (Decision, error) {
+	return Decision{}, errors.New("redis down")
+}
+
+func TestGatewayRateLimit(t *testing.T) {
+	key, _ := auth.NewEd25519Key("gw")
+	keys := auth.NewKeySet("gw", key)
+	verifier, err := auth.NewVerifier(auth.Config{KeyFunc: keys.Keyfunc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := keys.Sign(auth.NewClaims(7, auth.RolesFor(false), time.Hour))
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+	config := writeConfig(t, t.TempDir(), `
+upstreams: {svc: {url: "`+upstream.URL+`"}}
+rate_limits:
+  default: {requests: 100, per: 1m}
+  login: {requests: 2, per: 1m}
+routes:
+  - {path: /api/auth/login, upstream: svc, policy: public, rate_limit: login}
+  - {path: /api/products, upstream: svc, policy: public}
+`)
+
+	newApp := func(limiter RateLimiter) *fiber.App {
+		g, err := NewGateway(config, verifier, limiter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := fiber.New()
+		app.Use(g.Handler())
+		return app
+	}
+	send := func(app *fiber.App, path, token string) *http.Response {
+		req := httptest.NewRequest("POST", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	app := newApp(NewMemoryRateLimiter())
+
+	// Anonim istemci IP'ye göre limitlenir: 2 login denemesi, 3.'sü 429
+	for i, wantRemaining := range []string{"1", "0"} {
+		resp := send(app, "/api/auth/login", "")
+		if resp.StatusCode != 200 || resp.Header.Get("RateLimit-Remaining") != wantRemaining {
+			t.Fatalf("istek %d: status=%d remaining=%q", i+1, resp.StatusCode, resp.Header.Get("RateLimit-Remaining"))
+		}
+	}
+	resp := send(app, "/api/auth/login", "")
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") != "30" || resp.Header.Get("RateLimit-Limit") != "2" {
+		t.Errorf("Retry-After=%q RateLimit-Limit=%q", resp.Header.Get("Retry-After"), resp.Header.Get("RateLimit-Limit"))
+	}
+
+	// Giriş yapmış kullanıcının kovası ayrıdır
+	if resp := send(app, "/api/auth/login", token); resp.StatusCode != 200 {
+		t.Errorf("kullanıcı kovası: status = %d", resp.StatusCode)
+	}
+	// Diğer route'lar "default" grubunu kullanır
+	if resp := send(app, "/api/products", ""); resp.StatusCode != 200 || resp.Header.Get("RateLimit-Limit") != "100" {
+		t.Errorf("default grup: status=%d limit=%q", resp.StatusCode, resp.Header.Get("RateLimit-Limit"))
+	}
+
+	// Backend hatası isteği engellemez (fail-open)
+	app = newApp(failingLimiter{})
+	for range 3 {
+		if resp := send(app, "/api/auth/login", ""); resp.StatusCode != 200 || resp.Header.Get("RateLimit-Limit") != "" {
+			t.Fatalf("fail-open: status=%d", resp.StatusCode)
+		}
+	}
+}
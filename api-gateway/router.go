@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ==============================================================================
+// ROUTE TABLOSU
+// ==============================================================================
+
+// Route - Derlenmiş (doğrulanmış) route
+type Route struct {
+	RouteConfig
+	segments []string
+	upstream *Upstream
+	rateRule *RateRule // nil → limitsiz
+}
+
+// RouteTable - Config'ten üretilen, değişmez route listesi
+// Reload'da yenisi oluşturulup atomik olarak değiştirilir.
+type RouteTable struct {
+	routes    []*Route
+	upstreams map[string]*Upstream
+}
+
+// NewRouteTable - Config'i doğrular ve route'ları derler
+func NewRouteTable(cfg *Config) (*RouteTable, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string]*RateRule)
+	for name, rl := range cfg.RateLimits {
+		rules[name], _ = rl.Rule(name)
+	}
+
+	table := &RouteTable{upstreams: make(map[string]*Upstream)}
+	for name, uc := range cfg.Upstreams {
+		table.upstreams[name], _ = uc.Compile(name)
+	}
+
+	for _, rc := range cfg.Routes {
+		methods := make([]string, len(rc.Methods))
+		for i, m := range rc.Methods {
+			methods[i] = strings.ToUpper(m)
+		}
+		rc.Methods = methods
+
+		group := rc.RateLimit
+		if group == "" {
+			group = defaultRateLimit
+		}
+
+		table.routes = append(table.routes, &Route{
+			RouteConfig: rc,
+			segments:    splitPath(rc.Path),
+			upstream:    table.upstreams[rc.Upstream],
+			rateRule:    rules[group],
+		})
+	}
+	return table, nil
+}
+
+// Match - İsteğe uyan ilk route
+// Path uyuyor ama method uymuyorsa methodAllowed=false döner (→ 405).
+func (t *RouteTable) Match(method, path string) (route *Route, params map[string]string, methodAllowed bool) {
+	pathSegs := splitPath(path)
+	pathMatched := false
+
+	for _, r := range t.routes {
+		p, ok := matchSegments(r.segments, pathSegs)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+		if len(r.Methods) > 0 && !containsSegment(r.Methods, method) {
+			continue
+		}
+		return r, p, true
+	}
+	return nil, nil, !pathMatched
+}
+
+// RequestURI - Upstream'e gidecek path (rewrite uygulanmış, query string korunmuş)
+// Instance adresi istek anında load balancer tarafından önüne eklenir.
+func (r *Route) RequestURI(path string, params map[string]string, query string) string {
+	target := path
+	if r.Rewrite != "" {
+		var out []string
+		for _, seg := range splitPath(r.Rewrite) {
+			if seg == "*" || strings.HasPrefix(seg, ":") {
+				seg = params[seg]
+			}
+			if seg != "" {
+				out = append(out, seg)
+			}
+		}
+		target = "/" + strings.Join(out, "/")
+	}
+
+	if query != "" {
+		target += "?" + query
+	}
+	return target
+}
+
+// matchSegments - Desen segmentlerini path ile eşler
+// Parametreler ":id" → "5", "*" → "kalan/kısım" olarak döner.
+func matchSegments(pattern, path []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, seg := range pattern {
+		if seg == "*" {
+			params["*"] = strings.Join(path[i:], "/")
+			return params, true
+		}
+		if i >= len(path) {
+			return nil, false
+		}
+		if strings.HasPrefix(seg, ":") {
+			params[seg] = path[i]
+			continue
+		}
+		if seg != path[i] {
+			return nil, false
+		}
+	}
+	if len(pattern) != len(path) {
+		return nil, false
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+}
+
+// ==============================================================================
+// GATEWAY (Hot-reload destekli)
+// ==============================================================================
+
+// Gateway - Aktif route tablosu, token doğrulayıcı ve rate limiter
+type Gateway struct {
+	configPath string
+	verifier   *auth.Verifier
+	limiter    RateLimiter
+	table      atomic.Pointer[RouteTable]
+
+	mu       sync.Mutex
+	modTime  time.Time
+	breakers map[string]*Breaker // Upstream adına göre; reload'lar arasında korunur
+	targets  map[string]*Target  // "upstream url" → instance
+}
+
+// NewGateway - Config'i yükler; hatalıysa gateway başlamamalı
+func NewGateway(configPath string, verifier *auth.Verifier, limiter RateLimiter) (*Gateway, error) {
+	g := &Gateway{configPath: configPath, verifier: verifier, limiter: limiter, breakers: make(map[string]*Breaker)}
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Reload - Config'i yeniden okur; hata varsa mevcut tablo korunur
+func (g *Gateway) Reload() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	info, err := os.Stat(g.configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(g.configPath)
+	if err != nil {
+		return err
+	}
+	table, err := NewRouteTable(cfg)
+	if err != nil {
+		return fmt.Errorf("%s geçersiz:\n%w", g.configPath, err)
+	}
+	g.attachState(table)
+
+	g.table.Store(table)
+	g.modTime = info.ModTime()
+	fmt.Printf("🗺️ %d route yüklendi (%s)\n", len(table.routes), g.configPath)
+	return nil
+}
+
+// Watch - Dosya değişince veya SIGHUP gelince config'i yeniden yükler
+func (g *Gateway) Watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+			case <-ticker.C:
+				if !g.changed() {
+					continue
+				}
+			}
+			if err := g.Reload(); err != nil {
+				log.Printf("⚠️ Route config yüklenemedi, eski tablo kullanılıyor: %v", err)
+			}
+		}
+	}()
+}
+
+// attachState - Upstream'lere breaker ve instance durumlarını bağlar (mu tutulurken)
+// Reload açık bir devreyi kapatmamalı, havuzdan çıkarılmış bir instance'ı geri
+// almamalı: aynı isimli upstream aynı breaker'ı, aynı URL aynı Target'ı alır.
+func (g *Gateway) attachState(table *RouteTable) {
+	targets := make(map[string]*Target)
+	for name, u := range table.upstreams {
+		b, ok := g.breakers[name]
+		if ok {
+			b.Configure(u.Failures, u.Cooldown)
+		} else {
+			b = NewBreaker(name, u.Failures, u.Cooldown)
+			g.breakers[name] = b
+		}
+		u.breaker = b
+
+		for i, t := range u.Targets {
+			key := name + " " + t.URL
+			if prev, ok := g.targets[key]; ok {
+				u.Targets[i] = prev
+			} else {
+				upstreamTargetHealthy.WithLabelValues(name, t.URL).Set(1)
+			}
+			targets[key] = u.Targets[i]
+		}
+	}
+
+	for name := range g.breakers {
+		if _, ok := table.upstreams[name]; !ok {
+			delete(g.breakers, name)
+			breakerState.DeleteLabelValues(name)
+		}
+	}
+	for key, t := range g.targets {
+		if _, ok := targets[key]; !ok {
+			upstreamTargetHealthy.DeleteLabelValues(t.Upstream, t.URL)
+		}
+	}
+	g.targets = targets
+}
+
+func (g *Gateway) changed() bool {
+	info, err := os.Stat(g.configPath)
+	if err != nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !info.ModTime().Equal(g.modTime)
+}
+
+// Handler - Route bul → kimlik → rate limit → politika → upstream'e proxy
+// (timeout, retry ve circuit breaker için bkz. upstream.go)
+func (g *Gateway) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		route, params, methodAllowed := g.table.Load().Match(c.Method(), c.Path())
+		if route == nil {
+			if !methodAllowed {
+				return c.Status(fiber.StatusMethodNotAllowed).JSON(fiber.Map{"error": "Bu method desteklenmiyor"})
+			}
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Endpoint bulunamadı"})
+		}
+
+		// Kimlik rate limit'ten ÖNCE belirlenir (kova kullanıcıya göre seçilir),
+		// politika SONRA uygulanır (geçersiz token'la deneme yapan da limitlenir)
+		stripIdentityHeaders(c)
+		authenticated, authErr := g.verifier.Authenticate(c)
+
+		decision, ok := applyRateLimit(c, g.limiter, route.rateRule)
+		if !ok {
+			return nil
+		}
+
+		if status, message := authorize(c, route.Policy, authenticated, authErr); status != 0 {
+			writeRateLimitHeaders(c, decision)
+			return c.Status(status).JSON(fiber.Map{"error": message})
+		}
+
+		uri := route.RequestURI(c.Path(), params, string(c.Request().URI().QueryString()))
+		err := route.upstream.forward(c, uri)
+		writeRateLimitHeaders(c, decision)
+		return err
+	}
+}
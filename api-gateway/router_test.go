@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func mustTable(t *testing.T, path string) *RouteTable {
+	t.Helper()
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := NewRouteTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "routes.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// routes.yaml'ın kendisi test edilir: yanlış bir satır eklenirse burada yakalanır
+func TestShippedRoutes(t *testing.T) {
+	t.Setenv("CART_SERVICE_URL", "http://cart-service:3003")
+	table := mustTable(t, "routes.yaml")
+
+	tests := []struct {
+		method     string
+		url        string
+		wantTarget string
+		wantPolicy Policy
+	}{
+		{"POST", "/api/auth/login", "http://localhost:3002/login", PolicyPublic},
+		{"GET", "/api/auth/sessions", "http://localhost:3002/sessions", PolicyAuthenticated},
+		{"PUT", "/api/profile/me", "http://localhost:3002/profile/me", PolicyAuthenticated},
+		{"PUT", "/api/addresses/4/default", "http://localhost:3002/addresses/4/default", PolicyAuthenticated},
+		{"GET", "/api/products?page=2&limit=12", "http://localhost:3001/products?page=2&limit=12", PolicyPublic},
+		{"GET", "/api/products/5", "http://localhost:3001/products/5", PolicyPublic},
+		{"DELETE", "/api/products/5", "http://localhost:3001/products/5", PolicyAdmin},
+		{"GET", "/api/categories?active=true", "http://localhost:3001/categories?active=true", PolicyPublic},
+		{"GET", "/api/cart/me/count?fresh=1", "http://cart-service:3003/cart/me/count?fresh=1", PolicyAuthenticated},
+		{"GET", "/api/orders?page=1&limit=20", "http://localhost:3004/orders?page=1&limit=20", PolicyAdmin},
+		{"POST", "/api/orders", "http://localhost:3004/orders", PolicyAuthenticated},
+		{"GET", "/api/orders/stats", "http://localhost:3004/orders/stats", PolicyAdmin},
+		{"GET", "/api/orders/reconciliation/issues?status=open", "http://localhost:3004/orders/reconciliation/issues?status=open", PolicyAdmin},
+		{"PATCH", "/api/orders/reconciliation/issues/4", "http://localhost:3004/orders/reconciliation/issues/4", PolicyAdmin},
+		{"GET", "/api/orders/user/me", "http://localhost:3004/orders/user/me", PolicyAuthenticated},
+		{"PATCH", "/api/orders/12/status", "http://localhost:3004/orders/12/status", PolicyAdmin},
+		{"POST", "/api/payments/tokens", "http://localhost:3005/tokens", PolicyAuthenticated},
+		{"POST", "/api/payments/webhooks", "http://localhost:3005/webhooks/provider", PolicyPublic},
+		{"GET", "/api/payments/3ds/SIM_000001", "http://localhost:3005/simulator/3ds/SIM_000001", PolicyPublic},
+		{"GET", "/api/search?q=laptop", "http://localhost:3006/search?q=laptop", PolicyPublic},
+		{"POST", "/api/search/sync", "http://localhost:3006/search/sync", PolicyAdmin},
+		{"GET", "/api/reviews/3?sort=new", "http://localhost:3008/reviews/3?sort=new", PolicyPublic},
+		{"POST", "/api/reviews", "http://localhost:3008/reviews", PolicyAuthenticated},
+		{"GET", "/api/wishlist/me?limit=5", "http://localhost:3009/wishlist/me?limit=5", PolicyAuthenticated},
+		{"GET", "/api/coupons?limit=1000", "http://localhost:3010/coupons?limit=1000", PolicyAdmin},
+		{"POST", "/api/coupons/apply", "http://localhost:3010/coupons/apply", PolicyAuthenticated},
+		{"GET", "/api/coupons/4", "http://localhost:3010/coupons/4", PolicyAuthenticated},
+		{"PUT", "/api/coupons/4", "http://localhost:3010/coupons/4", PolicyAdmin},
+		{"GET", "/api/coupons/4/stats", "http://localhost:3010/coupons/4/stats", PolicyAdmin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			path, query, _ := strings.Cut(tt.url, "?")
+			route, params, _ := table.Match(tt.method, path)
+			if route == nil {
+				t.Fatal("route bulunamadı")
+			}
+			if got := route.upstream.Targets[0].URL + route.RequestURI(path, params, query); got != tt.wantTarget {
+				t.Errorf("target = %s, want %s", got, tt.wantTarget)
+			}
+			if route.Policy != tt.wantPolicy {
+				t.Errorf("policy = %s, want %s", route.Policy, tt.wantPolicy)
+			}
+		})
+	}
+
+	// Brute-force'a açık route'lar sıkı gruplarda, diğerleri "default"ta
+	for path, want := range map[string]string{
+		"/api/auth/login":      "login",
+		"/api/coupons/apply":   "coupon",
+		"/api/payments/tokens": "payment",
+		"/api/orders":          defaultRateLimit,
+	} {
+		route, _, _ := table.Match("POST", path)
+		if route == nil || route.rateRule == nil || route.rateRule.Name != want {
+			t.Errorf("%s rate limit grubu %q olmalı", path, want)
+		}
+	}
+
+	if route, _, allowed := table.Match("DELETE", "/api/orders/12"); route != nil || allowed {
+		t.Error("DELETE /api/orders/12 → 405 olmalı")
+	}
+	if route, _, allowed := table.Match("GET", "/api/unknown"); route != nil || !allowed {
+		t.Error("/api/unknown → 404 olmalı")
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"unknown upstream", `
+upstreams: {a: {url: "http://a:1"}}
+routes: [{path: /x, upstream: b, policy: public}]`, `bilinmeyen upstream "b"`},
+		{"bad policy", `
+upstreams: {a: {url: "http://a:1"}}
+routes: [{path: /x, upstream: a, policy: everyone}]`, `geçersiz policy`},
+		{"bad method", `
+upstreams: {a: {url: "http://a:1"}}
+routes: [{path: /x, upstream: a, policy: public, methods: [FETCH]}]`, `geçersiz method`},
+		{"rewrite param missing in path", `
+upstreams: {a: {url: "http://a:1"}}
+routes: [{path: /x/:id, upstream: a, policy: public, rewrite: /y/:code}]`, `":code" path'te yok`},
+		{"wildcard not last", `
+upstreams: {a: {url: "http://a:1"}}
+routes: [{path: /x/*/y, upstream: a, policy: public}]`, `sadece son segment`},
+		{"bad upstream url", `
+upstreams: {a: {url: "a:1"}}
+routes: [{path: /x, upstream: a, policy: public}]`, `geçersiz url`},
+		{"duplicate route", `
+upstreams: {a: {url: "http://a:1"}}
+routes: [{path: /x, upstream: a, policy: public}, {path: /x, upstream: a, policy: admin}]`, `ile aynı`},
+		{"bad balancer", `
+upstreams: {a: {url: "http://a:1", balancer: random}}
+routes: [{path: /x, upstream: a, policy: public}]`, `geçersiz balancer "random"`},
+		{"upstream without targets", `
+upstreams: {a: {timeout: 1s}}
+routes: [{path: /x, upstream: a, policy: public}]`, `url veya targets tanımlanmalı`},
+		{"unknown rate limit group", `
+upstreams: {a: {url: "http://a:1"}}
+routes: [{path: /x, upstream: a, policy: public, rate_limit: strict}]`, `bilinmeyen rate_limit grubu "strict"`},
+		{"bad rate limit", `
+upstreams: {a: {url: "http://a:1"}}
+rate_limits: {default: {requests: 10, per: soon}}
+routes: [{path: /x, upstream: a, policy: public}]`, `geçersiz per "soon"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeConfig(t, t.TempDir(), tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `
+upstreams: {a: {url: "http://a:1"}}
+routes: [{path: /old, upstream: a, policy: public}]`)
+
+	g, err := NewGateway(path, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hatalı config → eski tablo korunur
+	writeConfig(t, dir, `routes: [{path: /new, upstream: missing, policy: public}]`)
+	if err := g.Reload(); err == nil {
+		t.Fatal("hatalı config reddedilmeliydi")
+	}
+	if route, _, _ := g.table.Load().Match("GET", "/old"); route == nil {
+		t.Error("eski tablo korunmalıydı")
+	}
+
+	// Geçerli config → yeni tablo (JSON da desteklenir)
+	jsonPath := filepath.Join(dir, "routes.json")
+	os.WriteFile(jsonPath, []byte(`{"upstreams": {"a": {"url": "http://a:1"}}, "routes": [{"path": "/new", "upstream": "a", "policy": "public"}]}`), 0o644)
+	g.configPath = jsonPath
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if route, _, _ := g.table.Load().Match("GET", "/new"); route == nil {
+		t.Error("yeni route yüklenmeliydi")
+	}
+}
+
+func TestGatewayAuth(t *testing.T) {
+	key, _ := auth.NewEd25519Key("gw")
+	keys := auth.NewKeySet("gw", key)
+	denylist := auth.NewMemoryDenylist()
+	verifier, err := auth.NewVerifier(auth.Config{KeyFunc: keys.Keyfunc, Denylist: denylist})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(userID uint, admin bool, sid string) string {
+		claims := auth.NewClaims(userID, auth.RolesFor(admin), time.Hour)
+		claims["sid"] = sid
+		token, err := keys.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	customer := sign(7, false, "laptop")
+	admin := sign(1, true, "office")
+	loggedOut := sign(7, false, "phone")
+	denylist.Add(t.Context(), auth.SessionKey("phone"), time.Hour)
+
+	// Upstream: gelen kimlik header'larını ve path'i geri döner
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"path":    r.URL.RequestURI(),
+			"user_id": r.Header.Get(auth.HeaderUserID),
+			"roles":   r.Header.Get(auth.HeaderUserRoles),
+			"session": r.Header.Get(auth.HeaderSessionID),
+			"extra":   r.Header.Get("X-User-Email"),
+			"service": r.Header.Get(auth.HeaderServiceToken),
+		})
+	}))
+	defer upstream.Close()
+
+	g, err := NewGateway(writeConfig(t, t.TempDir(), `
+upstreams: {svc: {url: "`+upstream.URL+`"}}
+routes:
+  - {path: /api/products/*, upstream: svc, rewrite: /products/*, methods: [GET], policy: public}
+  - {path: /api/cart/*, upstream: svc, rewrite: /cart/*, policy: authenticated}
+  - {path: /api/orders/stats, upstream: svc, rewrite: /orders/stats, policy: admin}
+`), verifier, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Use(g.Handler())
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		spoof    bool
+		want     int
+		wantUser string
+		wantPath string
+	}{
+		{"public route anonymous", "GET", "/api/products?page=2", "", false, 200, "", "/products?page=2"},
+		{"public route spoofed identity is stripped", "GET", "/api/products", "", true, 200, "", "/products"},
+		{"public route with token forwards identity", "GET", "/api/products/3", customer, false, 200, "7", "/products/3"},
+		{"public route with garbage token", "GET", "/api/products", "garbage", false, 200, "", "/products"},
+		{"method not allowed", "POST", "/api/products", admin, false, 405, "", ""},
+		{"unknown route", "GET", "/api/nothing", customer, false, 404, "", ""},
+		{"authenticated route without token", "GET", "/api/cart/me", "", false, 401, "", ""},
+		{"authenticated route spoofed header only", "GET", "/api/cart/me", "", true, 401, "", ""},
+		{"authenticated route with token", "GET", "/api/cart/me?x=1", customer, true, 200, "7", "/cart/me?x=1"},
+		{"admin route as customer", "GET", "/api/orders/stats", customer, false, 403, "", ""},
+		{"admin route as admin", "GET", "/api/orders/stats", admin, false, 200, "1", "/orders/stats"},
+		{"revoked session", "GET", "/api/cart/me", loggedOut, false, 401, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.spoof {
+				req.Header.Set("X-User-ID", "1")
+				req.Header.Set("X-User-Roles", "admin")
+				req.Header.Set("X-User-Email", "admin@test.com")
+				req.Header.Set("X-Session-ID", "stolen")
+				req.Header.Set("X-Service-Token", "guessed")
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want != 200 {
+				return
+			}
+
+			var got map[string]string
+			json.NewDecoder(resp.Body).Decode(&got)
+			if got["path"] != tt.wantPath {
+				t.Errorf("upstream path = %q, want %q", got["path"], tt.wantPath)
+			}
+			if got["user_id"] != tt.wantUser {
+				t.Errorf("X-User-ID = %q, want %q", got["user_id"], tt.wantUser)
+			}
+			if got["extra"] != "" {
+				t.Errorf("X-User-Email silinmeliydi: %q", got["extra"])
+			}
+			if got["service"] != "" {
+				t.Errorf("X-Service-Token silinmeliydi: %q", got["service"])
+			}
+			if tt.wantUser == "" && (got["roles"] != "" || got["session"] != "") {
+				t.Errorf("anonim istekte kimlik header'ı olmamalı: %+v", got)
+			}
+		})
+	}
+}
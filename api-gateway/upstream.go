@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/valyala/fasthttp"
+)
+
+// ==============================================================================
+// UPSTREAM ÇAĞRILARI (Timeout, Retry, Circuit Breaker)
+// ==============================================================================
+/*
+Bir servis (ör. search-service) askıda kalırsa istekler gateway'de birikmesin:
+
+  - Timeout: Her deneme upstream'in "timeout" süresiyle sınırlıdır
+  - Retry:   Sadece idempotent method'lar (GET, HEAD, OPTIONS, PUT, DELETE)
+             bağlantı hatası, timeout veya 502/503/504'te tekrar denenir.
+             Bekleme: retry_backoff, 2x, 4x... (+ rastgele jitter)
+             POST/PATCH ASLA tekrar denenmez (çift sipariş riski!)
+  - Circuit breaker: Art arda "failures" kadar hata → devre AÇIK.
+             Açıkken istekler upstream'e hiç gitmez, anında 503 döner.
+             "cooldown" sonunda tek bir deneme isteği geçer (YARI AÇIK):
+             başarılıysa devre kapanır, değilse tekrar açılır.
+
+Hata yanıtları:
+
+	503 {"error": "Servis geçici olarak kullanılamıyor..."} + Retry-After  → devre açık
+	504 {"error": "Servis zamanında yanıt vermedi"}                         → timeout
+	502 {"error": "Servise ulaşılamadı"}                                    → bağlantı hatası
+*/
+
+const (
+	defaultUpstreamTimeout = 10 * time.Second
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 30 * time.Second
+	maxRetries             = 5
+)
+
+// Upstream - Derlenmiş upstream ayarları
+type Upstream struct {
+	Name     string
+	Targets  []*Target
+	Balancer Balancer
+	Health   HealthCheck
+	Timeout  time.Duration
+	Retries  int
+	Backoff  time.Duration
+	Failures int
+	Cooldown time.Duration
+
+	// breaker - Gateway tarafından bağlanır; reload'da aynı breaker korunur.
+	// nil ise (sadece route tablosu testlerinde) devre hiç açılmaz.
+	breaker *Breaker
+}
+
+// Compile - Config satırını doğrular, boş alanlara varsayılanları yazar
+func (uc UpstreamConfig) Compile(name string) (*Upstream, error) {
+	var errs []error
+	u := &Upstream{
+		Name:     name,
+		Retries:  uc.Retries,
+		Failures: uc.Breaker.Failures,
+		Health: HealthCheck{
+			Path:               uc.HealthCheck.Path,
+			UnhealthyThreshold: uc.HealthCheck.UnhealthyThreshold,
+			HealthyThreshold:   uc.HealthCheck.HealthyThreshold,
+		},
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range append([]string{uc.URL}, uc.Targets...) {
+		for _, raw := range strings.Split(entry, ",") {
+			raw = strings.TrimSuffix(strings.TrimSpace(raw), "/")
+			if raw == "" || seen[raw] {
+				continue
+			}
+			seen[raw] = true
+			parsed, err := url.Parse(raw)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				errs = append(errs, fmt.Errorf("upstream %q: geçersiz url %q", name, raw))
+				continue
+			}
+			u.Targets = append(u.Targets, NewTarget(name, raw))
+		}
+	}
+	if len(seen) == 0 {
+		errs = append(errs, fmt.Errorf("upstream %q: url veya targets tanımlanmalı", name))
+	}
+
+	balancer, err := NewBalancer(uc.Balancer)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("upstream %q: %w", name, err))
+	}
+	u.Balancer = balancer
+
+	if !strings.HasPrefix(u.Health.Path, "/") {
+		if u.Health.Path != "" {
+			errs = append(errs, fmt.Errorf("upstream %q: health_check.path '/' ile başlamalı", name))
+		}
+		u.Health.Path = defaultHealthPath
+	}
+	if u.Health.UnhealthyThreshold < 0 || u.Health.HealthyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("upstream %q: health_check eşikleri negatif olamaz", name))
+	}
+	if u.Health.UnhealthyThreshold == 0 {
+		u.Health.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	if u.Health.HealthyThreshold == 0 {
+		u.Health.HealthyThreshold = defaultHealthyThreshold
+	}
+	if uc.Retries < 0 || uc.Retries > maxRetries {
+		errs = append(errs, fmt.Errorf("upstream %q: retries 0-%d arasında olmalı", name, maxRetries))
+	}
+	if uc.Breaker.Failures < 0 {
+		errs = append(errs, fmt.Errorf("upstream %q: breaker.failures negatif olamaz", name))
+	}
+	if u.Failures == 0 {
+		u.Failures = defaultBreakerFailures
+	}
+
+	for _, d := range []struct {
+		field    string
+		value    string
+		fallback time.Duration
+		dst      *time.Duration
+	}{
+		{"timeout", uc.Timeout, defaultUpstreamTimeout, &u.Timeout},
+		{"retry_backoff", uc.RetryBackoff, defaultRetryBackoff, &u.Backoff},
+		{"breaker.cooldown", uc.Breaker.Cooldown, defaultBreakerCooldown, &u.Cooldown},
+		{"health_check.interval", uc.HealthCheck.Interval, defaultHealthInterval, &u.Health.Interval},
+		{"health_check.timeout", uc.HealthCheck.Timeout, defaultHealthTimeout, &u.Health.Timeout},
+	} {
+		*d.dst = d.fallback
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed <= 0 {
+			errs = append(errs, fmt.Errorf("upstream %q: geçersiz %s %q", name, d.field, d.value))
+			continue
+		}
+		*d.dst = parsed
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ==============================================================================
+// CIRCUIT BREAKER
+// ==============================================================================
+
+// BreakerState - Prometheus'a da bu değerle yazılır
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 0: Normal, istekler geçer
+	BreakerOpen                         // 1: Upstream çökmüş, istekler anında 503
+	BreakerHalfOpen                     // 2: Cooldown bitti, tek deneme isteği geçer
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker - Tek bir upstream'in devre kesicisi
+type Breaker struct {
+	name string
+	now  func() time.Time
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int // Art arda hata sayısı
+	threshold int
+	cooldown  time.Duration
+	openedAt  time.Time
+	probing   bool // Yarı açıkken deneme isteği uçuşta mı
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	b := &Breaker{name: name, now: time.Now, threshold: threshold, cooldown: cooldown}
+	breakerState.WithLabelValues(name).Set(float64(BreakerClosed))
+	return b
+}
+
+// Configure - Reload'da eşikleri günceller (durum korunur)
+func (b *Breaker) Configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold, b.cooldown = threshold, cooldown
+}
+
+// State - Anlık durum (health/metrik için)
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow - İstek upstream'e gidebilir mi? Gidemezse kalan bekleme süresini döner.
+// true dönen her çağrıdan sonra Record çağrılmalıdır.
+func (b *Breaker) Allow() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		remaining := b.cooldown - b.now().Sub(b.openedAt)
+		if remaining > 0 {
+			return remaining, false
+		}
+		b.transition(BreakerHalfOpen)
+		b.probing = true
+		return 0, true
+	case BreakerHalfOpen:
+		// Deneme isteği sonuçlanana kadar diğerleri beklemez, reddedilir
+		if b.probing {
+			return time.Second, false
+		}
+		b.probing = true
+		return 0, true
+	default:
+		return 0, true
+	}
+}
+
+// Record - Denemenin sonucunu işler
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.transition(BreakerOpen)
+	}
+}
+
+// transition - mu tutulurken çağrılır
+func (b *Breaker) transition(to BreakerState) {
+	switch to {
+	case BreakerOpen:
+		log.Printf("⚡ Circuit breaker AÇILDI: %s (%d ardışık hata, %s bekleniyor)", b.name, b.failures, b.cooldown)
+	case BreakerClosed:
+		log.Printf("✅ Circuit breaker kapandı: %s", b.name)
+	}
+	b.state = to
+	breakerState.WithLabelValues(b.name).Set(float64(to))
+	breakerTransitionsTotal.WithLabelValues(b.name, to.String()).Inc()
+}
+
+// ==============================================================================
+// PROXY
+// ==============================================================================
+
+// idempotentMethods - Tekrar gönderilmesi güvenli method'lar (RFC 9110)
+var idempotentMethods = map[string]bool{
+	fiber.MethodGet: true, fiber.MethodHead: true, fiber.MethodOptions: true,
+	fiber.MethodPut: true, fiber.MethodDelete: true,
+}
+
+// forward - İsteği bir instance'a iletir (load balancing + timeout + retry + breaker)
+// uri: rewrite uygulanmış path + query. Tekrar denemeler mümkünse başka
+// instance'a gider. Upstream'in yanıtı olduğu gibi client'a döner; sadece
+// gateway'in kendi hataları (devre açık, timeout, bağlantı hatası) JSON yazılır.
+func (u *Upstream) forward(c *fiber.Ctx, uri string) error {
+	attempts := 1
+	if idempotentMethods[c.Method()] {
+		attempts += u.Retries
+	}
+
+	var err error
+	var target *Target
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			upstreamRetriesTotal.WithLabelValues(u.Name).Inc()
+			time.Sleep(u.backoff(attempt))
+		}
+
+		if u.breaker != nil {
+			if wait, ok := u.breaker.Allow(); !ok {
+				breakerRejectedTotal.WithLabelValues(u.Name).Inc()
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(wait)))
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+					"error": "Servis geçici olarak kullanılamıyor, lütfen daha sonra tekrar deneyin",
+				})
+			}
+		}
+
+		target = u.pick(target)
+		target.inflight.Add(1)
+		err = proxy.DoTimeout(c, target.URL+uri, u.Timeout)
+		target.inflight.Add(-1)
+		failed := err != nil || retryableStatus(c.Response().StatusCode())
+		if u.breaker != nil {
+			u.breaker.Record(!failed)
+		}
+		if !failed {
+			return nil
+		}
+		upstreamErrorsTotal.WithLabelValues(u.Name, failureReason(c, err)).Inc()
+	}
+
+	// Son deneme de başarısız: upstream yanıt verdiyse (502/503/504) aynen ilet
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fasthttp.ErrTimeout):
+		log.Printf("⏱️ %s zamanında yanıt vermedi (%s): %s", u.Name, u.Timeout, c.Path())
+		return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": "Servis zamanında yanıt vermedi"})
+	default:
+		log.Printf("❌ %s'e ulaşılamadı (%s): %v", u.Name, target.URL, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Servise ulaşılamadı"})
+	}
+}
+
+// backoff - retry_backoff * 2^(deneme-1), ±%50 jitter (thundering herd önlenir)
+func (u *Upstream) backoff(attempt int) time.Duration {
+	d := u.Backoff << (attempt - 1)
+	return d/2 + rand.N(d)
+}
+
+// retryableStatus - Upstream'in "şu an yanıt veremiyorum" dediği durumlar
+// 500 sayılmaz: isteğe özel bir hata olabilir, tekrar denemek düzeltmez.
+func retryableStatus(status int) bool {
+	return status == fiber.StatusBadGateway || status == fiber.StatusServiceUnavailable || status == fiber.StatusGatewayTimeout
+}
+
+func failureReason(c *fiber.Ctx, err error) string {
+	switch {
+	case err == nil:
+		return strconv.Itoa(c.Response().StatusCode())
+	case errors.Is(err, fasthttp.ErrTimeout):
+		return "timeout"
+	default:
+		return "connection"
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUpstreamCompile(t *testing.T) {
+	u, err := UpstreamConfig{URL: "http://a:1"}.Compile("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Timeout != defaultUpstreamTimeout || u.Retries != 0 || u.Backoff != defaultRetryBackoff ||
+		u.Failures != defaultBreakerFailures || u.Cooldown != defaultBreakerCooldown {
+		t.Errorf("varsayılanlar yazılmadı: %+v", u)
+	}
+
+	tests := []struct {
+		config  UpstreamConfig
+		wantErr string
+	}{
+		{UpstreamConfig{URL: "a:1"}, "geçersiz url"},
+		{UpstreamConfig{URL: "http://a:1", Timeout: "fast"}, `geçersiz timeout "fast"`},
+		{UpstreamConfig{URL: "http://a:1", Timeout: "0s"}, `geçersiz timeout "0s"`},
+		{UpstreamConfig{URL: "http://a:1", Retries: 10}, "retries 0-5"},
+		{UpstreamConfig{URL: "http://a:1", RetryBackoff: "-1ms"}, "geçersiz retry_backoff"},
+		{UpstreamConfig{URL: "http://a:1", Breaker: BreakerConfig{Failures: -1}}, "breaker.failures"},
+		{UpstreamConfig{URL: "http://a:1", Breaker: BreakerConfig{Cooldown: "later"}}, "geçersiz breaker.cooldown"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.config.Compile("a"); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%+v: err = %v, want %q", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := NewBreaker("test", 3, 30*time.Second)
+	b.now = func() time.Time { return now }
+
+	// Araya giren başarı sayacı sıfırlar
+	b.Record(false)
+	b.Record(false)
+	b.Record(true)
+	b.Record(false)
+	b.Record(false)
+	if b.State() != BreakerClosed {
+		t.Fatal("ardışık olmayan hatalar devreyi açmamalı")
+	}
+
+	b.Record(false)
+	if b.State() != BreakerOpen {
+		t.Fatal("3 ardışık hatada devre açılmalı")
+	}
+	if wait, ok := b.Allow(); ok || wait != 30*time.Second {
+		t.Errorf("açık devre: ok=%v wait=%s", ok, wait)
+	}
+
+	// Cooldown bitti → tek deneme isteği geçer, diğerleri beklemez
+	now = now.Add(30 * time.Second)
+	if _, ok := b.Allow(); !ok || b.State() != BreakerHalfOpen {
+		t.Fatal("cooldown sonrası deneme isteği geçmeli")
+	}
+	if _, ok := b.Allow(); ok {
+		t.Error("deneme isteği uçuştayken ikinci istek reddedilmeli")
+	}
+
+	// Deneme başarısız → tekrar açık, cooldown baştan
+	b.Record(false)
+	if wait, ok := b.Allow(); ok || wait != 30*time.Second {
+		t.Errorf("başarısız deneme sonrası: ok=%v wait=%s", ok, wait)
+	}
+
+	// Deneme başarılı → kapalı
+	now = now.Add(time.Minute)
+	b.Allow()
+	b.Record(true)
+	if _, ok := b.Allow(); !ok || b.State() != BreakerClosed {
+		t.Error("başarılı deneme devreyi kapatmalı")
+	}
+}
+
+func TestGatewayUpstreamFailures(t *testing.T) {
+	var flakyHits, postHits, slowHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky": // İlk iki deneme 503, sonra başarılı
+			if flakyHits.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/orders":
+			postHits.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		case "/slow":
+			slowHits.Add(1)
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer upstream.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close() // Bağlantı reddedilir
+
+	g, err := NewGateway(writeConfig(t, t.TempDir(), `
+upstreams:
+  svc: {url: "`+upstream.URL+`", timeout: 1s, retries: 2, retry_backoff: 1ms}
+  slow: {url: "`+upstream.URL+`", timeout: 50ms, retries: 2, retry_backoff: 1ms, breaker: {failures: 3, cooldown: 1h}}
+  down: {url: "`+down.URL+`", retries: 0}
+routes:
+  - {path: /flaky, upstream: svc, policy: public}
+  - {path: /orders, upstream: svc, policy: public}
+  - {path: /slow, upstream: slow, policy: public}
+  - {path: /down, upstream: down, policy: public}
+`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Use(g.Handler())
+
+	send := func(method, path string) (int, string, *http.Response) {
+		resp, err := app.Test(httptest.NewRequest(method, path, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		msg, _ := body["error"].(string)
+		return resp.StatusCode, msg, resp
+	}
+
+	// GET tekrar denenir: 503, 503, 200
+	if status, _, _ := send("GET", "/flaky"); status != 200 || flakyHits.Load() != 3 {
+		t.Errorf("GET /flaky: status=%d hits=%d, want 200/3", status, flakyHits.Load())
+	}
+
+	// POST asla tekrar denenmez; upstream'in yanıtı aynen iletilir
+	if status, _, _ := send("POST", "/orders"); status != 502 || postHits.Load() != 1 {
+		t.Errorf("POST /orders: status=%d hits=%d, want 502/1", status, postHits.Load())
+	}
+
+	// Askıda kalan upstream → 504 (3 deneme = 3 ardışık hata → devre açılır)
+	if status, msg, _ := send("GET", "/slow"); status != 504 || msg != "Servis zamanında yanıt vermedi" {
+		t.Errorf("GET /slow: status=%d error=%q", status, msg)
+	}
+	if g.breakers["slow"].State() != BreakerOpen {
+		t.Fatal("slow upstream'in devresi açılmalıydı")
+	}
+
+	// Devre açık → upstream'e gitmeden 503 + Retry-After
+	hits := slowHits.Load()
+	status, msg, resp := send("GET", "/slow")
+	if status != 503 || !strings.Contains(msg, "geçici olarak kullanılamıyor") || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("açık devre: status=%d error=%q retry-after=%q", status, msg, resp.Header.Get("Retry-After"))
+	}
+	if slowHits.Load() != hits {
+		t.Error("açık devrede upstream'e istek gitmemeli")
+	}
+
+	// Reload açık devreyi sıfırlamaz
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _, _ := send("GET", "/slow"); status != 503 {
+		t.Errorf("reload sonrası status = %d, want 503", status)
+	}
+
+	// Diğer upstream'ler etkilenmez
+	if status, _, _ := send("GET", "/flaky"); status != 200 {
+		t.Errorf("svc etkilenmemeli: status = %d", status)
+	}
+
+	// Ulaşılamayan upstream → 502
+	if status, msg, _ := send("GET", "/down"); status != 502 || msg != "Servise ulaşılamadı" {
+		t.Errorf("GET /down: status=%d error=%q", status, msg)
+	}
+}
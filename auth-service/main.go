@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
-	jwtware "github.com/gofiber/contrib/jwt"
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/health"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -17,7 +19,8 @@ import (
 
 var DB *gorm.DB
 
-const SecretKey = "benim_cok_gizli_anahtarim_senior_oluyorum"
+// Keys - Token imzalama anahtarları (JWT_KEYS_DIR'den yüklenir)
+var Keys *auth.KeySet
 
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -86,7 +89,7 @@ func initDatabase() {
 	}
 
 	// Tabloları migrate et
-	DB.AutoMigrate(&User{}, &Address{})
+	DB.AutoMigrate(&User{}, &Address{}, &Session{}, &RefreshToken{})
 	fmt.Println("✅ Auth Service Veritabanına Bağlandı!")
 
 	// Default admin kullanıcısı oluştur
@@ -135,12 +138,45 @@ func seedAdminUser() {
 	fmt.Println("🛡️ Default admin kullanıcı oluşturuldu: admin@test.com / 123456")
 }
 
+/*
+initKeys: Token imzalama anahtarlarını yükler
+
+📝 Ayarlar:
+
+	JWT_KEYS_DIR   → PEM anahtar dizini (varsayılan ./keys), dosya adı = kid
+	JWT_ACTIVE_KID → Yeni token'ları imzalayan kid (boşsa en sonuncusu)
+
+Dizin boşsa geliştirme kolaylığı için Ed25519 anahtarı üretilip yazılır.
+⚠️ Production'da anahtarlar secret manager / volume ile verilmeli!
+*/
+func initKeys() {
+	dir := getEnv("JWT_KEYS_DIR", "./keys")
+	activeKID := getEnv("JWT_ACTIVE_KID", "")
+
+	if existing, _ := filepath.Glob(filepath.Join(dir, "*.pem")); len(existing) == 0 {
+		kid := time.Now().Format("2006-01-02")
+		if err := auth.GenerateKeyFile(dir, kid); err != nil {
+			log.Fatal("❌ İmzalama anahtarı üretilemedi: ", err)
+		}
+		fmt.Printf("🔑 %s boştu, yeni Ed25519 anahtarı üretildi (kid: %s)\n", dir, kid)
+	}
+
+	var err error
+	Keys, err = auth.LoadKeySet(dir, activeKID)
+	if err != nil {
+		log.Fatal("❌ İmzalama anahtarları yüklenemedi: ", err)
+	}
+	fmt.Printf("🔑 %d anahtar yüklendi, aktif kid: %s\n", len(Keys.KIDs()), Keys.ActiveKID())
+}
+
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
 }
 
 func main() {
+	initKeys()
+	initSessions()
 	initDatabase()
 	app := fiber.New()
 
@@ -152,41 +188,23 @@ func main() {
 	}))
 
 	// ==============================================================================
-	// HEALTH CHECK ENDPOINT
+	// HEALTH CHECK ENDPOINT'LERİ (/health, /livez, /readyz)
 	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		checks := make(map[string]interface{})
-		status := "healthy"
-
-		// PostgreSQL kontrolü
-		sqlDB, err := DB.DB()
-		if err != nil {
-			checks["postgres"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else if err := sqlDB.Ping(); err != nil {
-			checks["postgres"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else {
-			checks["postgres"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		statusCode := 200
-		if status != "healthy" {
-			statusCode = 503
-		}
-
-		return c.Status(statusCode).JSON(fiber.Map{
-			"status":    status,
-			"service":   "auth-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks":    checks,
-		})
-	})
+	sqlDB, _ := DB.DB()
+	checker := health.NewHealthChecker("auth-service")
+	checker.AddCheck("postgres", health.NewPostgresChecker(sqlDB))
+	// Redis sadece logout denylist'i için: çökerse giriş yine yapılabilir → degraded
+	checker.AddCheck("redis", health.NewRedisChecker(rdb), health.NonCritical())
+	checker.Register(app)
 
 	// =====================
 	// PUBLIC ENDPOINT'LER (Token gerektirmez)
 	// =====================
 
+	// --- JWKS (Public anahtarlar) ---
+	// Diğer servisler ve gateway token doğrulamak için buradan anahtarları çeker
+	app.Get("/.well-known/jwks.json", Keys.JWKSHandler())
+
 	// --- REGISTER ---
 	app.Post("/register", func(c *fiber.Ctx) error {
 		var data map[string]string
@@ -225,30 +243,6 @@ func main() {
 		})
 	})
 
-	// --- ADMIN DURUMLARINI DÜZELT (Geliştirme için) ---
-	/*
-	   Bu endpoint tüm kullanıcıların is_admin durumunu düzeltir:
-	   - admin@test.com → is_admin: true
-	   - Diğer herkes → is_admin: false
-
-	   ⚠️ Production'da bu endpoint kaldırılmalı!
-	*/
-	app.Post("/fix-admins", func(c *fiber.Ctx) error {
-		// 1. Tüm kullanıcıları is_admin: false yap
-		result := DB.Model(&User{}).Where("email != ?", "admin@test.com").Update("is_admin", false)
-		fmt.Printf("🔧 %d kullanıcı is_admin: false yapıldı\n", result.RowsAffected)
-
-		// 2. Sadece admin@test.com'u is_admin: true yap
-		DB.Model(&User{}).Where("email = ?", "admin@test.com").Update("is_admin", true)
-		fmt.Println("✅ admin@test.com is_admin: true yapıldı")
-
-		return c.JSON(fiber.Map{
-			"message":     "Admin durumları düzeltildi",
-			"users_fixed": result.RowsAffected,
-			"admin_email": "admin@test.com",
-		})
-	})
-
 	// --- LOGIN ---
 	app.Post("/login", func(c *fiber.Ctx) error {
 		var data map[string]string
@@ -273,45 +267,80 @@ func main() {
 
 		fmt.Printf("✅ Giriş başarılı: %s (is_admin: %v)\n", user.Name, user.IsAdmin)
 
-		// Token Oluştur
-		claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"sub": user.ID,
-			"exp": time.Now().Add(time.Hour * 24).Unix(),
-		})
-
-		token, err := claims.SignedString([]byte(SecretKey))
+		// Yeni oturum (cihaz) aç, access + refresh token üret
+		// Access token'a roller, yetkiler ve oturum kimliği (sid) yazılır
+		session, err := createSession(c, user)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"message": "Oturum açılamadı"})
+		}
+		response, err := issueTokens(DB, session, user)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"message": "Token oluşturulamadı"})
 		}
 
-		return c.JSON(fiber.Map{
-			"message": "Giriş başarılı",
-			"token":   token,
-			"user": fiber.Map{
-				"id":       user.ID,
-				"name":     user.Name,
-				"email":    user.Email,
-				"phone":    user.Phone,
-				"is_admin": user.IsAdmin,
-			},
-		})
+		response["message"] = "Giriş başarılı"
+		response["user"] = fiber.Map{
+			"id":       user.ID,
+			"name":     user.Name,
+			"email":    user.Email,
+			"phone":    user.Phone,
+			"is_admin": user.IsAdmin,
+		}
+		return c.JSON(response)
 	})
 
+	// --- TOKEN YENİLE ---
+	// Access token süresi dolunca client refresh_token ile yeni çift alır
+	app.Post("/token/refresh", refreshTokenHandler)
+
 	// =====================
 	// PROTECTED ENDPOINT'LER (Token gerektirir)
 	// =====================
 
-	// JWT Middleware
-	app.Use(jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: []byte(SecretKey)},
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Giriş yapmanız gerekiyor!"})
-		},
-	}))
+	// JWT Middleware (kapatılmış oturumların token'ları reddedilir)
+	app.Use(auth.New(auth.Config{KeyFunc: Keys.Keyfunc, Denylist: Denylist}))
+
+	// --- OTURUMLAR ---
+	// POST /logout          → Bu cihazdan çıkış
+	// GET /sessions         → Açık oturumlarım (cihazlar)
+	// DELETE /sessions/:id  → Bir cihazdan çıkış yaptır
+	app.Post("/logout", logoutHandler)
+	app.Get("/sessions", listSessionsHandler)
+	app.Delete("/sessions/:id", revokeSessionHandler)
+
+	// --- ADMIN DURUMLARINI DÜZELT (Geliştirme için) ---
+	/*
+	   Bu endpoint tüm kullanıcıların is_admin durumunu düzeltir:
+	   - admin@test.com → is_admin: true
+	   - Diğer herkes → is_admin: false
+
+	   🔒 Sadece admin çağırabilir (users:admin yetkisi)
+	   ⚠️ Production'da bu endpoint kaldırılmalı!
+	*/
+	app.Post("/fix-admins", auth.RequirePermission(auth.PermUsersAdmin), func(c *fiber.Ctx) error {
+		// 1. Tüm kullanıcıları is_admin: false yap
+		result := DB.Model(&User{}).Where("email != ?", "admin@test.com").Update("is_admin", false)
+		fmt.Printf("🔧 %d kullanıcı is_admin: false yapıldı\n", result.RowsAffected)
+
+		// 2. Sadece admin@test.com'u is_admin: true yap
+		DB.Model(&User{}).Where("email = ?", "admin@test.com").Update("is_admin", true)
+		fmt.Println("✅ admin@test.com is_admin: true yapıldı")
+
+		return c.JSON(fiber.Map{
+			"message":     "Admin durumları düzeltildi",
+			"users_fixed": result.RowsAffected,
+			"admin_email": "admin@test.com",
+		})
+	})
+
+	/*
+	   👤 SAHİPLİK KONTROLÜ:
+	   Path'teki :id / :userid token sahibine ait olmalı (admin hariç).
+	   "me" takma adı da kullanılabilir: /profile/me, /addresses/me
+	*/
 
 	// --- PROFİL BİLGİLERİNİ GETİR ---
-	app.Get("/profile/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
+	getProfile := func(c *fiber.Ctx, id string) error {
 		var user User
 		if err := DB.First(&user, id).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "Kullanıcı bulunamadı"})
@@ -325,11 +354,21 @@ func main() {
 			"is_admin":   user.IsAdmin,
 			"created_at": user.CreatedAt,
 		})
+	}
+
+	// GET /me → Token sahibinin profili (client kullanıcı ID'si taşımaz)
+	app.Get("/me", func(c *fiber.Ctx) error {
+		userID, _ := auth.UserID(c)
+		return getProfile(c, fmt.Sprint(userID))
+	})
+
+	app.Get("/profile/:id", auth.RequireSelfOrAdmin("id"), func(c *fiber.Ctx) error {
+		return getProfile(c, auth.ParamUserID(c, "id"))
 	})
 
 	// --- PROFİL GÜNCELLE ---
-	app.Put("/profile/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
+	app.Put("/profile/:id", auth.RequireSelfOrAdmin("id"), func(c *fiber.Ctx) error {
+		id := auth.ParamUserID(c, "id")
 		var user User
 		if err := DB.First(&user, id).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "Kullanıcı bulunamadı"})
@@ -359,8 +398,8 @@ func main() {
 	})
 
 	// --- ŞİFRE DEĞİŞTİR ---
-	app.Post("/profile/:id/password", func(c *fiber.Ctx) error {
-		id := c.Params("id")
+	app.Post("/profile/:id/password", auth.RequireSelfOrAdmin("id"), func(c *fiber.Ctx) error {
+		id := auth.ParamUserID(c, "id")
 		var user User
 		if err := DB.First(&user, id).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "Kullanıcı bulunamadı"})
@@ -382,9 +421,12 @@ func main() {
 		user.Password = hashedPassword
 		DB.Save(&user)
 
-		fmt.Printf("🔐 Şifre değiştirildi: %s\n", user.Email)
+		// Diğer cihazlardaki oturumları kapat (şifre çalınmış olabilir)
+		revoked, _ := revokeOtherSessions(c.UserContext(), user.ID, auth.SessionID(c))
+
+		fmt.Printf("🔐 Şifre değiştirildi: %s (%d oturum kapatıldı)\n", user.Email, revoked)
 
-		return c.JSON(fiber.Map{"message": "Şifre başarıyla değiştirildi"})
+		return c.JSON(fiber.Map{"message": "Şifre başarıyla değiştirildi", "revoked_sessions": revoked})
 	})
 
 	// =====================
@@ -392,8 +434,8 @@ func main() {
 	// =====================
 
 	// --- ADRESLERİ LİSTELE ---
-	app.Get("/addresses/:userid", func(c *fiber.Ctx) error {
-		userid := c.Params("userid")
+	app.Get("/addresses/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userid := auth.ParamUserID(c, "userid")
 		var addresses []Address
 		DB.Where("user_id = ?", userid).Order("is_default DESC, created_at DESC").Find(&addresses)
 
@@ -406,8 +448,8 @@ func main() {
 	})
 
 	// --- ADRES EKLE ---
-	app.Post("/addresses/:userid", func(c *fiber.Ctx) error {
-		userid := c.Params("userid")
+	app.Post("/addresses/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userid := auth.ParamUserID(c, "userid")
 
 		var address Address
 		if err := c.BodyParser(&address); err != nil {
@@ -442,6 +484,9 @@ func main() {
 		if err := DB.First(&address, id).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "Adres bulunamadı"})
 		}
+		if !auth.IsSelfOrAdmin(c, address.UserID) {
+			return auth.Forbidden(c)
+		}
 
 		var updateData Address
 		if err := c.BodyParser(&updateData); err != nil {
@@ -470,6 +515,9 @@ func main() {
 		if err := DB.First(&address, id).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "Adres bulunamadı"})
 		}
+		if !auth.IsSelfOrAdmin(c, address.UserID) {
+			return auth.Forbidden(c)
+		}
 
 		DB.Delete(&address)
 		fmt.Printf("🗑️ Adres silindi: %s\n", address.Title)
@@ -484,6 +532,9 @@ func main() {
 		if err := DB.First(&address, id).Error; err != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "Adres bulunamadı"})
 		}
+		if !auth.IsSelfOrAdmin(c, address.UserID) {
+			return auth.Forbidden(c)
+		}
 
 		// Önce tüm adreslerin varsayılanını kaldır
 		DB.Model(&Address{}).Where("user_id = ?", address.UserID).Update("is_default", false)
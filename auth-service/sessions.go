@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// ==============================================================================
+// OTURUMLAR (Session) VE REFRESH TOKEN'LAR
+// ==============================================================================
+/*
+Login artık iki token döner:
+
+	token          → Kısa ömürlü access token (JWT, varsayılan 15 dk)
+	refresh_token  → Uzun ömürlü, opak token (varsayılan 30 gün)
+
+Her login bir oturum (Session = cihaz) açar. Access token'a oturum kimliği
+"sid" olarak yazılır. Refresh token DB'de sadece SHA-256 hash'i ile tutulur.
+
+🔄 Rotasyon: /token/refresh her çağrıldığında eski refresh token "kullanıldı"
+olarak işaretlenir ve yenisi verilir. Kullanılmış bir refresh token tekrar
+gelirse token çalınmış demektir → oturum komple kapatılır.
+
+🚪 İptal: /logout veya DELETE /sessions/:id oturumu DB'de kapatır ve "sid"
+Redis denylist'ine yazılır; diğer servisler o oturumun access token'larını
+süreleri dolmadan reddeder.
+*/
+
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	// Denylist - İptal edilen oturumlar (tüm servislerle paylaşılan Redis)
+	Denylist auth.Denylist
+	rdb      *redis.Client
+)
+
+// Session - Bir cihazdaki oturum
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:32"`
+	UserID     uint       `json:"user_id" gorm:"index"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current" gorm:"-"` // İsteği yapan oturum mu?
+}
+
+// RefreshToken - Oturuma ait refresh token'lar (sadece hash saklanır)
+type RefreshToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	SessionID string `gorm:"index;size:32"`
+	TokenHash string `gorm:"uniqueIndex;size:64"`
+	UsedAt    *time.Time
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+/*
+initSessions: Token süreleri ve denylist bağlantısı
+
+📝 Ayarlar:
+
+	ACCESS_TOKEN_TTL  → Access token ömrü (varsayılan 15m)
+	REFRESH_TOKEN_TTL → Refresh token / oturum ömrü (varsayılan 720h)
+	REDIS_HOST, REDIS_PORT → Denylist Redis'i
+*/
+func initSessions() {
+	if d, err := time.ParseDuration(getEnv("ACCESS_TOKEN_TTL", "15m")); err == nil {
+		AccessTokenTTL = d
+	}
+	if d, err := time.ParseDuration(getEnv("REFRESH_TOKEN_TTL", "720h")); err == nil {
+		RefreshTokenTTL = d
+	}
+
+	rdb = redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379")),
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		fmt.Println("⚠️ Redis'e bağlanılamadı, logout diğer servislerde token süresi dolana kadar geçerli olmayabilir:", err)
+	}
+	Denylist = auth.NewRedisDenylist(rdb)
+
+	fmt.Printf("🎫 Access token: %s, refresh token: %s\n", AccessTokenTTL, RefreshTokenTTL)
+}
+
+// hashToken - Refresh token'ın DB'de saklanan hali
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRefreshToken - 256 bit rastgele, URL-safe opak token
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// createSession - Login sonrası yeni oturum açar
+func createSession(c *fiber.Ctx, user User) (*Session, error) {
+	now := time.Now()
+	session := &Session{
+		ID:         auth.NewTokenID(),
+		UserID:     user.ID,
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		IP:         c.IP(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+	}
+	if err := DB.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// issueTokens - Oturum için yeni access + refresh token üretir
+func issueTokens(tx *gorm.DB, session *Session, user User) (fiber.Map, error) {
+	claims := auth.NewClaims(user.ID, auth.RolesFor(user.IsAdmin), AccessTokenTTL)
+	claims["sid"] = session.ID
+	accessToken, err := Keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	record := RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(AccessTokenTTL.Seconds()),
+		"session_id":    session.ID,
+	}, nil
+}
+
+// revokeSession - Oturumu kapatır ve access token'larını denylist'e yazar
+func revokeSession(ctx context.Context, session *Session) error {
+	if session.RevokedAt == nil {
+		now := time.Now()
+		if err := DB.Model(session).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+	}
+	// Oturumun en son access token'ı en fazla AccessTokenTTL daha geçerli
+	return auth.RevokeSession(ctx, Denylist, session.ID, AccessTokenTTL)
+}
+
+// revokeOtherSessions - Kullanıcının (keepID hariç) tüm açık oturumlarını kapatır
+func revokeOtherSessions(ctx context.Context, userID uint, keepID string) (int, error) {
+	var sessions []Session
+	DB.Where("user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", userID, keepID, time.Now()).Find(&sessions)
+	for i := range sessions {
+		if err := revokeSession(ctx, &sessions[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// ==============================================================================
+// HANDLER'LAR
+// ==============================================================================
+
+// POST /token/refresh - Refresh token ile yeni token çifti (public)
+func refreshTokenHandler(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "refresh_token gerekli"})
+	}
+
+	invalid := func() error {
+		return c.Status(401).JSON(fiber.Map{"error": "Geçersiz veya süresi dolmuş refresh token"})
+	}
+
+	var record RefreshToken
+	if err := DB.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&record).Error; err != nil {
+		return invalid()
+	}
+
+	var session Session
+	if err := DB.First(&session, "id = ?", record.SessionID).Error; err != nil {
+		return invalid()
+	}
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(record.ExpiresAt) {
+		return invalid()
+	}
+
+	var user User
+	if err := DB.First(&user, session.UserID).Error; err != nil {
+		return invalid()
+	}
+
+	var tokens fiber.Map
+	reused := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Koşullu güncelleme: aynı token'ı aynı anda iki istek kullanamaz
+		result := tx.Model(&RefreshToken{}).Where("id = ? AND used_at IS NULL", record.ID).Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+
+		// Kayan süre: her refresh oturumu uzatır
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(RefreshTokenTTL)
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		tokens, err = issueTokens(tx, &session, user)
+		return err
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Token yenilenemedi"})
+	}
+
+	// 🚨 Kullanılmış refresh token tekrar geldi → çalınmış olabilir, oturumu kapat
+	if reused {
+		fmt.Printf("🚨 Refresh token tekrar kullanıldı, oturum kapatılıyor: user=%d session=%s\n", session.UserID, session.ID)
+		revokeSession(c.UserContext(), &session)
+		return invalid()
+	}
+
+	tokens["message"] = "Token yenilendi"
+	return c.JSON(tokens)
+}
+
+// POST /logout - Mevcut oturumu kapatır
+func logoutHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	// Bu access token her durumda iptal edilir (sid'siz eski token'lar dahil)
+	if err := auth.RevokeToken(ctx, Denylist, auth.TokenID(c), auth.ExpiresAt(c)); err != nil {
+		fmt.Println("⚠️ Token denylist'e yazılamadı:", err)
+	}
+
+	if sid := auth.SessionID(c); sid != "" {
+		var session Session
+		if err := DB.First(&session, "id = ?", sid).Error; err == nil {
+			if err := revokeSession(ctx, &session); err != nil {
+				fmt.Println("⚠️ Oturum kapatılamadı:", err)
+			}
+		}
+	}
+
+	return c.JSON(fiber.Map{"message": "Çıkış yapıldı"})
+}
+
+// GET /sessions - Kullanıcının açık oturumları (cihazlar)
+func listSessionsHandler(c *fiber.Ctx) error {
+	userID, _ := auth.UserID(c)
+	current := auth.SessionID(c)
+
+	var sessions []Session
+	DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions)
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+	if sessions == nil {
+		return c.JSON([]Session{})
+	}
+	return c.JSON(sessions)
+}
+
+// DELETE /sessions/:id - Tek bir oturumu (cihazı) kapatır
+func revokeSessionHandler(c *fiber.Ctx) error {
+	var session Session
+	if err := DB.First(&session, "id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Oturum bulunamadı"})
+	}
+	if !auth.IsSelfOrAdmin(c, session.UserID) {
+		return auth.Forbidden(c)
+	}
+
+	if err := revokeSession(c.UserContext(), &session); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Oturum kapatılamadı"})
+	}
+
+	fmt.Printf("🚪 Oturum kapatıldı: user=%d session=%s\n", session.UserID, session.ID)
+	return c.JSON(fiber.Map{"message": "Oturum kapatıldı"})
+}
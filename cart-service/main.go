@@ -7,17 +7,15 @@ import (
 	"log"
 	"os"
 	"strconv" // String çevirmek için lazım
-	"time"
 
-	jwtware "github.com/gofiber/contrib/jwt"
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/health"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/redis/go-redis/v9"
 )
 
-// JWT Secret Key - Auth Service ile AYNI olmalı!
-const SecretKey = "benim_cok_gizli_anahtarim_senior_oluyorum"
-
 var ctx = context.Background()
 var rdb *redis.Client
 
@@ -57,33 +55,11 @@ func main() {
 	}))
 
 	// ==============================================================================
-	// HEALTH CHECK ENDPOINT (JWT'den ÖNCE - public olmalı)
+	// HEALTH CHECK ENDPOINT'LERİ (/health, /livez, /readyz) - JWT'den ÖNCE, public olmalı
 	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		checks := make(map[string]interface{})
-		status := "healthy"
-
-		// Redis kontrolü
-		_, err := rdb.Ping(ctx).Result()
-		if err != nil {
-			checks["redis"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else {
-			checks["redis"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		statusCode := 200
-		if status != "healthy" {
-			statusCode = 503
-		}
-
-		return c.Status(statusCode).JSON(fiber.Map{
-			"status":    status,
-			"service":   "cart-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks":    checks,
-		})
-	})
+	checker := health.NewHealthChecker("cart-service")
+	checker.AddCheck("redis", health.NewRedisChecker(rdb))
+	checker.Register(app)
 
 	// ==========================================================================
 	// JWT MIDDLEWARE - Tüm endpoint'ler için token gerekli!
@@ -97,20 +73,19 @@ func main() {
 	   💡 Neden Backend'de Kontrol?
 	   Frontend güvenliği kolayca bypass edilebilir (DevTools, Postman, curl).
 	   Backend HER ZAMAN son güvenlik katmanıdır.
+
+	   👤 Sahiplik: Path'teki :userid token sahibine ait olmalı (admin hariç).
+	   /cart/me → token sahibinin sepeti (client ID taşımak zorunda değil)
 	*/
-	app.Use(jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: []byte(SecretKey)},
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			fmt.Println("❌ Yetkisiz sepet erişimi denemesi!")
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Sepete erişmek için giriş yapmalısınız!",
-			})
-		},
+	app.Use(auth.New(auth.Config{
+		JWKSURL:             getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		UnauthorizedMessage: "Sepete erişmek için giriş yapmalısınız!",
+		Denylist:            auth.NewRedisDenylist(rdb),
 	}))
 
 	// --- 1. Sepete Ekle / Güncelle / Adet Değiştir ---
-	app.Post("/cart/:userid", func(c *fiber.Ctx) error {
-		userid := c.Params("userid")
+	app.Post("/cart/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userid := auth.ParamUserID(c, "userid")
 		key := "cart_" + userid
 
 		newItem := new(CartItem)
@@ -164,8 +139,8 @@ func main() {
 		return c.JSON(fiber.Map{"message": "Sepet güncellendi", "items": updatedItems})
 	})
 	// --- SEPET SAYACI (GÜNCELLENMİŞ & LOGLU) ---
-	app.Get("/cart/:userid/count", func(c *fiber.Ctx) error {
-		userID := c.Params("userid")
+	app.Get("/cart/:userid/count", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userID := auth.ParamUserID(c, "userid")
 		key := fmt.Sprintf("cart_%s", userID)
 
 		// 1. Redis'ten çek (String olarak - diğer endpoint'lerle tutarlı)
@@ -199,8 +174,8 @@ func main() {
 	})
 
 	// --- 2. Sepeti Getir ---
-	app.Get("/cart/:userid", func(c *fiber.Ctx) error {
-		userid := c.Params("userid")
+	app.Get("/cart/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userid := auth.ParamUserID(c, "userid")
 		key := "cart_" + userid
 
 		val, err := rdb.Get(ctx, key).Result()
@@ -222,8 +197,8 @@ func main() {
 
 	   Redis DEL komutu: Key'i tamamen siler
 	*/
-	app.Delete("/cart/:userid", func(c *fiber.Ctx) error {
-		userid := c.Params("userid")
+	app.Delete("/cart/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userid := auth.ParamUserID(c, "userid")
 		key := "cart_" + userid
 
 		// Redis'ten sil
@@ -239,8 +214,8 @@ func main() {
 
 	// --- 4. Sepetten Tek Ürün Sil ---
 	// DELETE /cart/:userid/:productid
-	app.Delete("/cart/:userid/:productid", func(c *fiber.Ctx) error {
-		userid := c.Params("userid")
+	app.Delete("/cart/:userid/:productid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userid := auth.ParamUserID(c, "userid")
 		productidStr := c.Params("productid")
 		productid, _ := strconv.Atoi(productidStr) // String'i sayıya çevir
 
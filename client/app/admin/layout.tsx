@@ -22,7 +22,7 @@ Görevleri:
 import Link from "next/link";
 import { useRouter, usePathname } from "next/navigation";
 import { useEffect, useState } from "react";
-import { LayoutDashboard, Package, ShoppingCart, LogOut, Tag, Shield, User } from "lucide-react";
+import { LayoutDashboard, Package, ShoppingCart, LogOut, Tag, Shield, User, Scale } from "lucide-react";
 import { Button } from "@/components/ui/button";
 import { toast } from "sonner";
 
@@ -184,6 +184,17 @@ export default function AdminLayout({ children }: { children: React.ReactNode })
               <Tag className="mr-2 h-4 w-4" /> Kuponlar
             </Button>
           </Link>
+          <Link href="/admin/reconciliation">
+            <Button
+              variant="ghost"
+              className={`w-full justify-start ${isActive("/admin/reconciliation")
+                ? "bg-indigo-600 text-white hover:bg-indigo-700"
+                : "text-slate-300 hover:text-white hover:bg-slate-800"
+                }`}
+            >
+              <Scale className="mr-2 h-4 w-4" /> Mutabakat
+            </Button>
+          </Link>
         </nav>
 
         {/* Footer */}
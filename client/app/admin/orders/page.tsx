@@ -231,9 +231,10 @@ export default function AdminOrdersPage() {
       ));
 
       toast.success(`Sipariş #${orderId} → ${newStatus}`);
-    } catch (err) {
+    } catch (err: any) {
       console.error(err);
-      toast.error("Güncelleme başarısız");
+      // İzin verilmeyen geçiş (ör. İptal Edildi → Kargolandı) 409 döner
+      toast.error(err.response?.data?.error || "Güncelleme başarısız");
     }
   };
 
@@ -249,36 +250,68 @@ export default function AdminOrdersPage() {
      Object map daha kısa ama karmaşık badge'ler için uygun değil.
 
   const statusMap = {
-    "Hazırlanıyor": { color: "secondary", icon: Clock },
+    "preparing": { color: "secondary", icon: Clock },
     ...
   }
+
+  Backend durum kodları döner (pending, paid, preparing...), etiketler burada.
   */
   const getStatusBadge = (status: string) => {
     switch (status) {
-      case "Hazırlanıyor":
+      case "pending":
+        return (
+          <Badge variant="outline">
+            <Clock className="w-3 h-3 mr-1" /> Ödeme Bekleniyor
+          </Badge>
+        );
+      case "pending_payment":
+        return (
+          <Badge variant="outline">
+            <Clock className="w-3 h-3 mr-1" /> 3D Secure Bekleniyor
+          </Badge>
+        );
+      case "payment_failed":
+        return (
+          <Badge className="bg-red-100 text-red-700 border-red-200">
+            <XCircle className="w-3 h-3 mr-1" /> Ödeme Alınamadı
+          </Badge>
+        );
+      case "paid":
+        return (
+          <Badge className="bg-indigo-100 text-indigo-700 border-indigo-200">
+            <CheckCircle className="w-3 h-3 mr-1" /> Ödendi
+          </Badge>
+        );
+      case "preparing":
         return (
           <Badge variant="secondary" className="bg-amber-100 text-amber-700 border-amber-200">
             <Clock className="w-3 h-3 mr-1" /> Hazırlanıyor
           </Badge>
         );
-      case "Kargolandı":
+      case "shipped":
         return (
           <Badge className="bg-blue-100 text-blue-700 border-blue-200">
             <Truck className="w-3 h-3 mr-1" /> Kargolandı
           </Badge>
         );
-      case "Teslim Edildi":
+      case "delivered":
         return (
           <Badge className="bg-green-100 text-green-700 border-green-200">
             <CheckCircle className="w-3 h-3 mr-1" /> Teslim Edildi
           </Badge>
         );
-      case "İptal Edildi":
+      case "cancelled":
         return (
           <Badge className="bg-red-100 text-red-700 border-red-200">
             <XCircle className="w-3 h-3 mr-1" /> İptal Edildi
           </Badge>
         );
+      case "refunded":
+        return (
+          <Badge className="bg-slate-100 text-slate-700 border-slate-200">
+            <XCircle className="w-3 h-3 mr-1" /> İade Edildi
+          </Badge>
+        );
       default:
         return <Badge variant="outline">{status}</Badge>;
     }
@@ -440,10 +473,13 @@ export default function AdminOrdersPage() {
           </SelectTrigger>
           <SelectContent>
             <SelectItem value="all">Tüm Durumlar</SelectItem>
-            <SelectItem value="Hazırlanıyor">Hazırlanıyor</SelectItem>
-            <SelectItem value="Kargolandı">Kargolandı</SelectItem>
-            <SelectItem value="Teslim Edildi">Teslim Edildi</SelectItem>
-            <SelectItem value="İptal Edildi">İptal Edildi</SelectItem>
+            <SelectItem value="pending">Ödeme Bekleniyor</SelectItem>
+            <SelectItem value="paid">Ödendi</SelectItem>
+            <SelectItem value="preparing">Hazırlanıyor</SelectItem>
+            <SelectItem value="shipped">Kargolandı</SelectItem>
+            <SelectItem value="delivered">Teslim Edildi</SelectItem>
+            <SelectItem value="cancelled">İptal Edildi</SelectItem>
+            <SelectItem value="refunded">İade Edildi</SelectItem>
           </SelectContent>
         </Select>
       </div>
@@ -547,10 +583,10 @@ export default function AdminOrdersPage() {
                       <SelectValue placeholder="Durum Seç" />
                     </SelectTrigger>
                     <SelectContent>
-                      <SelectItem value="Hazırlanıyor">Hazırlanıyor</SelectItem>
-                      <SelectItem value="Kargolandı">Kargolandı</SelectItem>
-                      <SelectItem value="Teslim Edildi">Teslim Edildi</SelectItem>
-                      <SelectItem value="İptal Edildi">İptal Edildi</SelectItem>
+                      <SelectItem value="preparing">Hazırlanıyor</SelectItem>
+                      <SelectItem value="shipped">Kargolandı</SelectItem>
+                      <SelectItem value="delivered">Teslim Edildi</SelectItem>
+                      <SelectItem value="cancelled">İptal Edildi</SelectItem>
                     </SelectContent>
                   </Select>
                 </TableCell>
@@ -0,0 +1,422 @@
+"use client";
+
+/*
+==============================================================================
+                    ADMIN ÖDEME MUTABAKATI SAYFASI
+==============================================================================
+
+📚 BU SAYFA NE YAPAR?
+   - payment-service tahsilatları ile siparişler arasındaki uyuşmazlıkları listeler
+     (reconciliation_issues tablosu)
+   - Tarih aralığı için yeni mutabakat başlatır (arka planda çalışır)
+   - Çalıştırmanın raporunu CSV / JSON olarak indirir
+   - Uyuşmazlığı not ile "çözüldü" olarak işaretler
+
+🏗️ UYUŞMAZLIK TÜRLERİ (order-service/reconciliation.go):
+   - payment_without_order → Para çekildi, sipariş yok / ödenmemiş
+   - order_without_capture → Sipariş ödendi görünüyor, tahsilat yok
+   - amount_mismatch       → Tahsil edilen ≠ sipariş toplamı
+   - refund_mismatch       → İade edilen ≠ beklenen iade
+
+   Her gece önceki gün otomatik kontrol edilir; bu sayfa sonuçları gösterir.
+
+==============================================================================
+*/
+
+import { useEffect, useState } from "react";
+import axios from "axios";
+import {
+  Table,
+  TableBody,
+  TableCell,
+  TableHead,
+  TableHeader,
+  TableRow,
+} from "@/components/ui/table";
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from "@/components/ui/select";
+import { Badge } from "@/components/ui/badge";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Card } from "@/components/ui/card";
+import { toast } from "sonner";
+import { CheckCircle, Download, Loader2, Play, RotateCcw, Scale } from "lucide-react";
+import Pagination from "@/components/ui/pagination";
+
+// ==============================================================================
+// TİP TANIMLARI
+// ==============================================================================
+
+type ReconciliationRun = {
+  id: number;
+  from: string;
+  to: string;
+  trigger: string;
+  status: string; // running | completed | failed
+  payments_checked: number;
+  orders_checked: number;
+  issue_count: number;
+  error?: string;
+  started_at: string;
+  finished_at: string | null;
+};
+
+type ReconciliationIssue = {
+  id: number;
+  run_id: number;
+  type: string;
+  order_id: number | null;
+  transaction_id: string;
+  order_status?: string;
+  payment_status?: string;
+  expected: number;
+  actual: number;
+  detail: string;
+  occurred_at: string;
+  status: string; // open | resolved | superseded
+  resolved_by?: string;
+  note?: string;
+};
+
+const API = "http://localhost:8080/api/orders/reconciliation";
+
+const ISSUE_LABELS: Record<string, string> = {
+  payment_without_order: "Siparişsiz Tahsilat",
+  order_without_capture: "Tahsilatsız Sipariş",
+  amount_mismatch: "Tutar Farkı",
+  refund_mismatch: "İade Farkı",
+};
+
+// Varsayılan aralık: dün (gece çalışan otomatik kontrolle aynı)
+const yesterday = () => {
+  const d = new Date();
+  d.setDate(d.getDate() - 1);
+  return d.toISOString().slice(0, 10);
+};
+
+// ==============================================================================
+// ANA COMPONENT
+// ==============================================================================
+
+export default function AdminReconciliationPage() {
+  const [issues, setIssues] = useState<ReconciliationIssue[]>([]);
+  const [runs, setRuns] = useState<ReconciliationRun[]>([]);
+  const [loading, setLoading] = useState(true);
+
+  // Filtreler + pagination
+  const [statusFilter, setStatusFilter] = useState("open");
+  const [typeFilter, setTypeFilter] = useState("all");
+  const [currentPage, setCurrentPage] = useState(1);
+  const [totalPages, setTotalPages] = useState(0);
+  const [totalItems, setTotalItems] = useState(0);
+
+  // Yeni çalıştırma
+  const [from, setFrom] = useState(yesterday());
+  const [to, setTo] = useState("");
+  const [starting, setStarting] = useState(false);
+
+  useEffect(() => {
+    fetchIssues();
+  }, [currentPage, statusFilter, typeFilter]);
+
+  useEffect(() => {
+    fetchRuns();
+  }, []);
+
+  // Çalışan bir mutabakat varsa bitene kadar birkaç saniyede bir yenile
+  useEffect(() => {
+    if (!runs.some((r) => r.status === "running")) return;
+    const timer = setTimeout(() => {
+      fetchRuns();
+      fetchIssues();
+    }, 3000);
+    return () => clearTimeout(timer);
+  }, [runs]);
+
+  const fetchIssues = async () => {
+    setLoading(true);
+    try {
+      const params = new URLSearchParams({ page: currentPage.toString(), limit: "50" });
+      if (statusFilter !== "all") params.append("status", statusFilter);
+      if (typeFilter !== "all") params.append("type", typeFilter);
+
+      const res = await axios.get(`${API}/issues?${params.toString()}`);
+      setIssues(res.data.issues || []);
+      setTotalPages(res.data.pagination.total_pages);
+      setTotalItems(res.data.pagination.total_items);
+    } catch (err) {
+      console.error(err);
+      toast.error("Uyuşmazlıklar yüklenemedi");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const fetchRuns = async () => {
+    try {
+      const res = await axios.get(`${API}/runs?limit=10`);
+      setRuns(res.data.runs || []);
+    } catch (err) {
+      console.error("Mutabakat çalıştırmaları çekilemedi:", err);
+    }
+  };
+
+  const startRun = async () => {
+    setStarting(true);
+    try {
+      await axios.post(`${API}/runs`, { from, to });
+      toast.success("Mutabakat başlatıldı");
+      fetchRuns();
+    } catch (err: any) {
+      toast.error(err.response?.data?.error || "Mutabakat başlatılamadı");
+    } finally {
+      setStarting(false);
+    }
+  };
+
+  // Rapor token gerektirdiği için link yerine axios ile indirilir
+  const downloadReport = async (run: ReconciliationRun, format: "csv" | "json") => {
+    try {
+      const res = await axios.get(`${API}/runs/${run.id}/report?format=${format}`, { responseType: "blob" });
+      const url = URL.createObjectURL(res.data);
+      const link = document.createElement("a");
+      link.href = url;
+      link.download = `reconciliation_run${run.id}.${format}`;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      toast.error("Rapor indirilemedi");
+    }
+  };
+
+  const setIssueStatus = async (issue: ReconciliationIssue, status: "resolved" | "open") => {
+    const note = status === "resolved" ? window.prompt("Çözüm notu (ör. manuel iade yapıldı):") : "";
+    if (note === null) return; // Vazgeçildi
+    try {
+      await axios.patch(`${API}/issues/${issue.id}`, { status, note });
+      toast.success(status === "resolved" ? "Çözüldü olarak işaretlendi" : "Tekrar açıldı");
+      fetchIssues();
+    } catch (err: any) {
+      toast.error(err.response?.data?.error || "Güncellenemedi");
+    }
+  };
+
+  const getRunBadge = (status: string) => {
+    switch (status) {
+      case "running":
+        return <Badge variant="outline"><Loader2 className="w-3 h-3 mr-1 animate-spin" /> Çalışıyor</Badge>;
+      case "completed":
+        return <Badge className="bg-green-100 text-green-700 border-green-200">Tamamlandı</Badge>;
+      default:
+        return <Badge className="bg-red-100 text-red-700 border-red-200">Başarısız</Badge>;
+    }
+  };
+
+  const getIssueBadge = (status: string) => {
+    switch (status) {
+      case "open":
+        return <Badge className="bg-amber-100 text-amber-700 border-amber-200">Açık</Badge>;
+      case "resolved":
+        return <Badge className="bg-green-100 text-green-700 border-green-200">Çözüldü</Badge>;
+      default:
+        return <Badge variant="outline">Yenilendi</Badge>;
+    }
+  };
+
+  const formatRange = (run: ReconciliationRun) =>
+    `${new Date(run.from).toLocaleString("tr-TR")} → ${new Date(run.to).toLocaleString("tr-TR")}`;
+
+  return (
+    <div className="p-6 space-y-6">
+      {/* Başlık */}
+      <div className="flex items-center justify-between">
+        <div>
+          <h1 className="text-2xl font-bold text-slate-900">Ödeme Mutabakatı</h1>
+          <p className="text-slate-500 mt-1">
+            Tahsilatlar ile siparişler arasındaki uyuşmazlıklar
+          </p>
+        </div>
+        <Badge variant="outline" className="text-lg px-4 py-2">
+          {totalItems} Uyuşmazlık
+        </Badge>
+      </div>
+
+      {/* ================================================================== */}
+      {/* YENİ ÇALIŞTIRMA + SON ÇALIŞTIRMALAR */}
+      {/* ================================================================== */}
+      <Card className="p-4 space-y-4">
+        <div className="flex flex-col sm:flex-row gap-4 sm:items-end">
+          <div>
+            <p className="text-sm text-slate-500 mb-1">Başlangıç</p>
+            <Input type="date" value={from} onChange={(e) => setFrom(e.target.value)} />
+          </div>
+          <div>
+            <p className="text-sm text-slate-500 mb-1">Bitiş (hariç, boşsa +1 gün)</p>
+            <Input type="date" value={to} onChange={(e) => setTo(e.target.value)} />
+          </div>
+          <Button onClick={startRun} disabled={starting || !from}>
+            {starting ? <Loader2 className="w-4 h-4 mr-2 animate-spin" /> : <Play className="w-4 h-4 mr-2" />}
+            Mutabakat Başlat
+          </Button>
+        </div>
+
+        {runs.length > 0 && (
+          <Table>
+            <TableHeader>
+              <TableRow className="bg-slate-50">
+                <TableHead>#</TableHead>
+                <TableHead>Aralık</TableHead>
+                <TableHead>Başlatan</TableHead>
+                <TableHead>Ödeme / Sipariş</TableHead>
+                <TableHead>Uyuşmazlık</TableHead>
+                <TableHead>Durum</TableHead>
+                <TableHead>Rapor</TableHead>
+              </TableRow>
+            </TableHeader>
+            <TableBody>
+              {runs.map((run) => (
+                <TableRow key={run.id}>
+                  <TableCell className="font-medium">#{run.id}</TableCell>
+                  <TableCell className="text-sm">{formatRange(run)}</TableCell>
+                  <TableCell className="text-sm text-slate-500">{run.trigger}</TableCell>
+                  <TableCell className="text-sm">{run.payments_checked} / {run.orders_checked}</TableCell>
+                  <TableCell>{run.issue_count}</TableCell>
+                  <TableCell title={run.error}>{getRunBadge(run.status)}</TableCell>
+                  <TableCell>
+                    {run.status === "completed" && (
+                      <div className="flex gap-2">
+                        <Button variant="outline" size="sm" onClick={() => downloadReport(run, "csv")}>
+                          <Download className="w-3 h-3 mr-1" /> CSV
+                        </Button>
+                        <Button variant="outline" size="sm" onClick={() => downloadReport(run, "json")}>
+                          <Download className="w-3 h-3 mr-1" /> JSON
+                        </Button>
+                      </div>
+                    )}
+                  </TableCell>
+                </TableRow>
+              ))}
+            </TableBody>
+          </Table>
+        )}
+      </Card>
+
+      {/* ================================================================== */}
+      {/* FİLTRELER */}
+      {/* ================================================================== */}
+      <div className="flex flex-col sm:flex-row gap-4">
+        <Select value={statusFilter} onValueChange={(v) => { setStatusFilter(v); setCurrentPage(1); }}>
+          <SelectTrigger className="w-[200px]">
+            <SelectValue placeholder="Durum" />
+          </SelectTrigger>
+          <SelectContent>
+            <SelectItem value="open">Açık</SelectItem>
+            <SelectItem value="resolved">Çözüldü</SelectItem>
+            <SelectItem value="superseded">Yenilendi</SelectItem>
+            <SelectItem value="all">Tümü</SelectItem>
+          </SelectContent>
+        </Select>
+        <Select value={typeFilter} onValueChange={(v) => { setTypeFilter(v); setCurrentPage(1); }}>
+          <SelectTrigger className="w-[220px]">
+            <SelectValue placeholder="Tür" />
+          </SelectTrigger>
+          <SelectContent>
+            <SelectItem value="all">Tüm Türler</SelectItem>
+            {Object.entries(ISSUE_LABELS).map(([value, label]) => (
+              <SelectItem key={value} value={value}>{label}</SelectItem>
+            ))}
+          </SelectContent>
+        </Select>
+      </div>
+
+      {/* ================================================================== */}
+      {/* UYUŞMAZLIK TABLOSU */}
+      {/* ================================================================== */}
+      <Card className="overflow-hidden">
+        <Table>
+          <TableHeader>
+            <TableRow className="bg-slate-50">
+              <TableHead>Tür</TableHead>
+              <TableHead>Sipariş</TableHead>
+              <TableHead>İşlem</TableHead>
+              <TableHead>Beklenen</TableHead>
+              <TableHead>Gerçekleşen</TableHead>
+              <TableHead>Açıklama</TableHead>
+              <TableHead>Tarih</TableHead>
+              <TableHead>Durum</TableHead>
+              <TableHead></TableHead>
+            </TableRow>
+          </TableHeader>
+          <TableBody>
+            {loading ? (
+              <TableRow>
+                <TableCell colSpan={9} className="text-center py-12">
+                  <Loader2 className="w-6 h-6 mx-auto animate-spin text-indigo-600" />
+                </TableCell>
+              </TableRow>
+            ) : issues.length === 0 ? (
+              <TableRow>
+                <TableCell colSpan={9} className="text-center py-12 text-slate-500">
+                  <Scale className="w-12 h-12 mx-auto mb-3 text-slate-300" />
+                  Uyuşmazlık bulunamadı
+                </TableCell>
+              </TableRow>
+            ) : (
+              issues.map((issue) => (
+                <TableRow key={issue.id}>
+                  <TableCell className="font-medium">{ISSUE_LABELS[issue.type] || issue.type}</TableCell>
+                  <TableCell>
+                    {issue.order_id ? `#${issue.order_id}` : "-"}
+                    {issue.order_status && <p className="text-xs text-slate-400">{issue.order_status}</p>}
+                  </TableCell>
+                  <TableCell className="font-mono text-xs">
+                    {issue.transaction_id || "-"}
+                    {issue.payment_status && <p className="text-slate-400">{issue.payment_status}</p>}
+                  </TableCell>
+                  <TableCell>{issue.expected.toLocaleString("tr-TR")} ₺</TableCell>
+                  <TableCell className="font-semibold">{issue.actual.toLocaleString("tr-TR")} ₺</TableCell>
+                  <TableCell className="text-sm max-w-xs">
+                    {issue.detail}
+                    {issue.note && <p className="text-xs text-slate-400 mt-1">📝 {issue.note} ({issue.resolved_by})</p>}
+                  </TableCell>
+                  <TableCell className="text-sm text-slate-500">
+                    {new Date(issue.occurred_at).toLocaleString("tr-TR")}
+                  </TableCell>
+                  <TableCell>{getIssueBadge(issue.status)}</TableCell>
+                  <TableCell>
+                    {issue.status === "open" && (
+                      <Button variant="outline" size="sm" onClick={() => setIssueStatus(issue, "resolved")}>
+                        <CheckCircle className="w-3 h-3 mr-1" /> Çözüldü
+                      </Button>
+                    )}
+                    {issue.status === "resolved" && (
+                      <Button variant="ghost" size="sm" onClick={() => setIssueStatus(issue, "open")}>
+                        <RotateCcw className="w-3 h-3 mr-1" /> Aç
+                      </Button>
+                    )}
+                  </TableCell>
+                </TableRow>
+              ))
+            )}
+          </TableBody>
+        </Table>
+      </Card>
+
+      {totalPages > 1 && (
+        <div className="flex justify-center">
+          <Pagination
+            currentPage={currentPage}
+            totalPages={totalPages}
+            onPageChange={setCurrentPage}
+            showInfo={true}
+          />
+        </div>
+      )}
+    </div>
+  );
+}
@@ -1,7 +1,7 @@
 "use client";
 
 
-import { useEffect, useState } from "react";
+import { useEffect, useRef, useState } from "react";
 import axios from "axios";
 import { Trash2, CreditCard, Minus, Plus, Tag, X, Check, Loader2 } from "lucide-react";
 import { Button } from "@/components/ui/button";
@@ -71,6 +71,14 @@ export default function CartPage() {
   const [cvv, setCvv] = useState("");
   const [isPaying, setIsPaying] = useState(false);
 
+  // Idempotency-Key: Aynı ödeme denemesi (ağ hatası sonrası tekrar) aynı anahtarla
+  // gönderilir, backend ikinci siparişi açmaz. Kesin bir yanıt gelince sıfırlanır.
+  const checkoutKey = useRef<string | null>(null);
+
+  // Kart bilgisi sadece payment-service'e gider; sipariş bu token ile verilir.
+  // Tekrar denemede aynı token kullanılır (body değişirse Idempotency-Key 409 döner).
+  const paymentToken = useRef<string | null>(null);
+
   // --- KUPON STATE'LERİ (YENİ) ---
   const [couponCode, setCouponCode] = useState("");           // Input değeri
   const [appliedCoupon, setAppliedCoupon] = useState<AppliedCoupon | null>(null);  // Uygulanan kupon
@@ -331,8 +339,7 @@ export default function CartPage() {
      1. Ürün detaylarını hazırla (ad, fiyat, resim - sipariş anındaki)
      2. Kupon bilgisini ekle
      3. Backend'e gönder
-     4. Kupon kullanımını kaydet
-     5. Başarılıysa sipariş detay sayfasına yönlendir
+     4. Başarılıysa sipariş detay sayfasına yönlendir
 
   💡 NEDEN ÜRÜN DETAYLARINI GÖNDERİYORUZ?
 
@@ -342,8 +349,10 @@ export default function CartPage() {
      Soru: Sipariş geçmişinde ne göstermeli?
      Cevap: "iPhone 15" ve 5000 TL (ALDIĞI ANDAKİ değerler)
 
-     Bu yüzden sipariş anındaki bilgileri Backend'e gönderiyoruz.
-     Backend bunları order_items tablosuna KOPYALIYOR.
+     Backend sipariş anındaki ad ve fiyatı order_items tablosuna KOPYALIYOR.
+     Ad ve fiyatı Product Service'ten kendisi alır, toplamı kendisi hesaplar;
+     gönderdiğimiz total_price sadece "ekranda gördüğümüz" tutardır.
+     Fiyat değiştiyse 409 + güncel tutarlar döner.
   */
   const handlePayment = async () => {
     setIsPaying(true);
@@ -377,7 +386,29 @@ export default function CartPage() {
     const subtotal = calculateSubtotal();
     const total = calculateTotal();
 
+    if (!checkoutKey.current) {
+      checkoutKey.current = crypto.randomUUID();
+    }
+
     try {
+      /*
+      Kart Tokenize Etme
+
+      Kart numarası ve CVV Order Service'e gönderilmez: önce payment-service
+      kartı doğrulayıp (Luhn, son kullanma, CVV) şifreli saklar ve kısa süreli
+      bir token döner. Sipariş isteğinde sadece bu token gider.
+      */
+      if (!paymentToken.current) {
+        const tokenResponse = await axios.post("http://localhost:8080/api/payments/tokens", {
+          card_number: cardNumber,
+          cvv: cvv,
+          expiry: expiry
+        }, {
+          headers: { Authorization: `Bearer ${localStorage.getItem("token")}` }
+        });
+        paymentToken.current = tokenResponse.data.token;
+      }
+
       /*
       Sipariş Oluşturma İsteği
 
@@ -398,30 +429,27 @@ export default function CartPage() {
         total_price: total,
         coupon_code: appliedCoupon?.code || "",
         coupon_discount: appliedCoupon?.discount || 0,
-        card_number: cardNumber,
-        cvv: cvv,
-        expiry: expiry,
+        payment_token: paymentToken.current,
         shipping_address: "" // TODO: Profildeki varsayılan adresi çek
+      }, {
+        headers: { "Idempotency-Key": checkoutKey.current }
       });
+      checkoutKey.current = null;
+      paymentToken.current = null;
 
       const orderId = orderResponse.data.order?.ID;
 
-      // Kupon kullanıldıysa kaydet (istatistik için)
-      if (appliedCoupon && orderId) {
-        try {
-          await axios.post("http://localhost:8080/api/coupons/use", {
-            coupon_id: appliedCoupon.couponId,
-            user_id: Number(userId),
-            order_id: orderId,
-            discount: appliedCoupon.discount
-          });
-        } catch (err) {
-          // Sipariş oluştu, kupon kaydı başarısız olsa bile devam et
-          console.error("Kupon kullanımı kaydedilemedi:", err);
-        }
-      }
+      // 3D Secure: sipariş ödeme doğrulaması bekliyor (202). Müşteri bankanın
+      // doğrulama sayfasına gider, sonuç gelince sipariş "paid" olur.
+      const paymentActionUrl = orderResponse.data.payment_action_url;
 
-      toast.success("Sipariş Oluşturuldu! 🎉");
+      // Kupon kullanımı sipariş ile birlikte backend'de kaydedilir (Order → Coupon Service)
+
+      if (paymentActionUrl) {
+        toast.info("Ödeme doğrulaması için bankanıza yönlendiriliyorsunuz...");
+      } else {
+        toast.success("Sipariş Oluşturuldu! 🎉");
+      }
       
       /*
       SEPETİ TEMİZLE (Redis'ten)
@@ -451,8 +479,10 @@ export default function CartPage() {
       // Navbar'daki sepet sayacını güncelle
       window.dispatchEvent(new Event("cart-updated"));
       
-      // Sipariş detay sayfasına yönlendir
-      if (orderId) {
+      // Sipariş detay sayfasına (3D Secure'da doğrulama sayfasına) yönlendir
+      if (paymentActionUrl) {
+        setTimeout(() => { window.location.href = paymentActionUrl; }, 1000);
+      } else if (orderId) {
         setTimeout(() => router.push(`/orders/${orderId}`), 1500);
       } else {
         setTimeout(() => router.push("/profile"), 1500);
@@ -460,7 +490,26 @@ export default function CartPage() {
 
     } catch (err: any) {
       console.error(err);
-      toast.error(err.response?.data?.error || "Ödeme başarısız.");
+      // Yanıt alınamadıysa (ağ hatası / 5xx) veya istek hâlâ işleniyorsa (409)
+      // tekrar denemede aynı anahtar kullanılır
+      const data = err.response?.data;
+      if (err.response && err.response.status < 500 && err.response.status !== 409) {
+        checkoutKey.current = null;
+        paymentToken.current = null; // Kart reddedildi / token geçersiz: kart tekrar tokenize edilir
+      }
+
+      // Fiyat değişti (409 + güncel tutarlar): sepeti güncel fiyatlarla göster,
+      // kullanıcı yeni toplamı görüp tekrar onaylasın (yeni istek → yeni anahtar)
+      if (data?.total_price !== undefined && Array.isArray(data.items)) {
+        checkoutKey.current = null;
+        paymentToken.current = null;
+        setProducts(prev => prev.map(p => {
+          const fresh = data.items.find((i: any) => i.product_id === p.ID);
+          return fresh ? { ...p, name: fresh.product_name, price: fresh.unit_price } : p;
+        }));
+        setAppliedCoupon(prev => prev ? { ...prev, discount: data.coupon_discount } : null);
+      }
+      toast.error(data?.error || "Ödeme başarısız.");
     } finally {
       setIsPaying(false);
     }
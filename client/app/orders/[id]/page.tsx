@@ -102,11 +102,25 @@ Her adım bir nesne:
 Sıralama önemli! Timeline bu sıraya göre çizilir.
 */
 const STATUS_STEPS = [
-  { key: "Hazırlanıyor", label: "Hazırlanıyor", icon: Clock },
-  { key: "Kargolandı", label: "Kargoya Verildi", icon: Truck },
-  { key: "Teslim Edildi", label: "Teslim Edildi", icon: CheckCircle },
+  { key: "paid", label: "Ödendi", icon: CheckCircle },
+  { key: "preparing", label: "Hazırlanıyor", icon: Clock },
+  { key: "shipped", label: "Kargoya Verildi", icon: Truck },
+  { key: "delivered", label: "Teslim Edildi", icon: CheckCircle },
 ];
 
+// Backend durum kodu → kullanıcıya gösterilen etiket
+const STATUS_LABELS: Record<string, string> = {
+  pending: "Ödeme Bekleniyor",
+  pending_payment: "Ödeme Doğrulaması Bekleniyor",
+  payment_failed: "Ödeme Alınamadı",
+  paid: "Ödendi",
+  preparing: "Hazırlanıyor",
+  shipped: "Kargoya Verildi",
+  delivered: "Teslim Edildi",
+  cancelled: "İptal Edildi",
+  refunded: "İade Edildi",
+};
+
 // ==============================================================================
 // ANA COMPONENT
 // ==============================================================================
@@ -124,6 +138,7 @@ export default function OrderDetailPage() {
 
   const [order, setOrder] = useState<Order | null>(null);
   const [loading, setLoading] = useState(true);
+  const [isCancelling, setIsCancelling] = useState(false);
 
   // ==========================================================================
   // VERİ ÇEKME
@@ -156,15 +171,37 @@ export default function OrderDetailPage() {
   getStatusIndex: Mevcut durumun timeline'daki index'ini döner
 
   Örnek:
-  - "Hazırlanıyor" → 0
-  - "Kargolandı" → 1
-  - "Teslim Edildi" → 2
-  - "İptal Edildi" → -1 (timeline'da yok)
+  - "paid" → 0
+  - "preparing" → 1
+  - "shipped" → 2
+  - "delivered" → 3
+  - "cancelled" / "refunded" → -1 (timeline'da yok)
   */
   const getStatusIndex = (status: string) => {
     return STATUS_STEPS.findIndex(step => step.key === status);
   };
 
+  /*
+  handleCancel: Siparişi iptal et (sadece kargoya verilmeden önce)
+
+  Backend stoğu geri ekler, kuponu serbest bırakır ve ödemeyi iade eder.
+  */
+  const handleCancel = async () => {
+    if (!order || !confirm("Siparişi iptal etmek istediğinize emin misiniz?")) return;
+
+    setIsCancelling(true);
+    try {
+      const res = await axios.post(`http://localhost:8080/api/orders/${order.ID}/cancel`, {});
+      setOrder({ ...order, status: res.data.order?.status || "cancelled" });
+      toast.success("Sipariş iptal edildi");
+    } catch (err: any) {
+      console.error(err);
+      toast.error(err.response?.data?.error || "Sipariş iptal edilemedi");
+    } finally {
+      setIsCancelling(false);
+    }
+  };
+
   const formatDate = (dateString: string) => {
     return new Date(dateString).toLocaleDateString("tr-TR", {
       day: "2-digit",
@@ -177,10 +214,15 @@ export default function OrderDetailPage() {
 
   const getStatusColor = (status: string) => {
     switch (status) {
-      case "Hazırlanıyor": return "bg-amber-100 text-amber-700 border-amber-200";
-      case "Kargolandı": return "bg-blue-100 text-blue-700 border-blue-200";
-      case "Teslim Edildi": return "bg-green-100 text-green-700 border-green-200";
-      case "İptal Edildi": return "bg-red-100 text-red-700 border-red-200";
+      case "pending": return "bg-slate-100 text-slate-700 border-slate-200";
+      case "pending_payment": return "bg-slate-100 text-slate-700 border-slate-200";
+      case "payment_failed": return "bg-red-100 text-red-700 border-red-200";
+      case "paid": return "bg-indigo-100 text-indigo-700 border-indigo-200";
+      case "preparing": return "bg-amber-100 text-amber-700 border-amber-200";
+      case "shipped": return "bg-blue-100 text-blue-700 border-blue-200";
+      case "delivered": return "bg-green-100 text-green-700 border-green-200";
+      case "cancelled": return "bg-red-100 text-red-700 border-red-200";
+      case "refunded": return "bg-slate-100 text-slate-700 border-slate-200";
       default: return "bg-slate-100 text-slate-700";
     }
   };
@@ -215,7 +257,9 @@ export default function OrderDetailPage() {
   }
 
   const currentStatusIndex = getStatusIndex(order.status);
-  const isCancelled = order.status === "İptal Edildi";
+  const isCancelled = order.status === "cancelled";
+  // Kargoya verilene kadar iptal edilebilir (ödemesi bekleyen sipariş dahil)
+  const canCancel = ["pending", "pending_payment", "paid", "preparing"].includes(order.status);
 
   return (
     <div className="container mx-auto px-4 py-10 max-w-4xl">
@@ -236,8 +280,14 @@ export default function OrderDetailPage() {
           </p>
         </div>
         <Badge className={`text-sm px-4 py-2 ${getStatusColor(order.status)}`}>
-          {order.status}
+          {STATUS_LABELS[order.status] || order.status}
         </Badge>
+        {canCancel && (
+          <Button variant="outline" onClick={handleCancel} disabled={isCancelling}
+            className="text-red-600 border-red-200 hover:bg-red-50">
+            {isCancelling ? <Loader2 className="w-4 h-4 animate-spin" /> : "Siparişi İptal Et"}
+          </Button>
+        )}
       </div>
 
       {/* ================================================================== */}
@@ -356,9 +356,15 @@ export default function ProfilePage() {
   // --- SİPARİŞ DURUMU BADGE ---
   const getStatusBadge = (status: string) => {
     switch (status) {
-      case "Hazırlanıyor": return <Badge className="bg-yellow-100 text-yellow-800">Hazırlanıyor</Badge>;
-      case "Kargolandı": return <Badge className="bg-blue-100 text-blue-800">Kargolandı</Badge>;
-      case "Teslim Edildi": return <Badge className="bg-green-100 text-green-800">Teslim Edildi</Badge>;
+      case "pending": return <Badge variant="outline">Ödeme Bekleniyor</Badge>;
+      case "pending_payment": return <Badge variant="outline">Ödeme Doğrulanıyor</Badge>;
+      case "payment_failed": return <Badge className="bg-red-100 text-red-800">Ödeme Alınamadı</Badge>;
+      case "paid": return <Badge className="bg-indigo-100 text-indigo-800">Ödendi</Badge>;
+      case "preparing": return <Badge className="bg-yellow-100 text-yellow-800">Hazırlanıyor</Badge>;
+      case "shipped": return <Badge className="bg-blue-100 text-blue-800">Kargolandı</Badge>;
+      case "delivered": return <Badge className="bg-green-100 text-green-800">Teslim Edildi</Badge>;
+      case "cancelled": return <Badge className="bg-red-100 text-red-800">İptal Edildi</Badge>;
+      case "refunded": return <Badge className="bg-slate-100 text-slate-800">İade Edildi</Badge>;
       default: return <Badge variant="outline">{status}</Badge>;
     }
   };
@@ -7,6 +7,9 @@ import (
 	"strings"
 	"time"
 
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/health"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"gorm.io/driver/postgres"
@@ -50,7 +53,14 @@ func initDatabase() {
 		log.Fatal("❌ Coupon Service PostgreSQL'e bağlanılamadı:", err)
 	}
 
-	DB.AutoMigrate(&Coupon{}, &CouponUsage{})
+	// idx_coupon_usage_user oluşturulamazsa kullanıcı başına tek kullanım
+	// garanti edilemez → servis başlamaz
+	if err := dedupeCouponUsages(); err != nil {
+		log.Fatal("❌ Yinelenen kupon kullanımları temizlenemedi:", err)
+	}
+	if err := DB.AutoMigrate(&Coupon{}, &CouponUsage{}); err != nil {
+		log.Fatal("❌ Coupon Service migration başarısız:", err)
+	}
 
 	fmt.Println("✅ Coupon Service Veritabanına Bağlandı!")
 
@@ -58,6 +68,46 @@ func initDatabase() {
 	seedCoupons()
 }
 
+/*
+dedupeCouponUsages: idx_coupon_usage_user index'inden önce çalışan migration
+
+Eski akış kuponu oku-artır-yaz ile kullanıyordu; eşzamanlı isteklerde aynı
+kullanıcının aynı kuponu birden çok kez kullandığı satırlar kalmış olabilir.
+Bu satırlar varken unique index oluşturulamaz. Her (coupon_id, user_id) için
+en eski kullanım kalır, diğerleri "released" işaretlenir (index'e dahil
+değildir). used_count değişmez: o kullanımlar gerçekten yapılmıştı.
+
+Index zaten varsa yinelenen satır olamaz, hiçbir şey yapılmaz.
+*/
+func dedupeCouponUsages() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&CouponUsage{}) || migrator.HasIndex(&CouponUsage{}, "idx_coupon_usage_user") {
+		return nil
+	}
+	// Status sütunu bu index ile birlikte geldi; eski tablolarda yoksa ekle (varsayılan: committed)
+	if !migrator.HasColumn(&CouponUsage{}, "Status") {
+		if err := migrator.AddColumn(&CouponUsage{}, "Status"); err != nil {
+			return err
+		}
+	}
+
+	result := DB.Exec(`
+		UPDATE coupon_usages SET status = ?
+		WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY coupon_id, user_id ORDER BY id) AS n
+				FROM coupon_usages WHERE status <> ?
+			) duplicates WHERE n > 1
+		)`, UsageReleased, UsageReleased)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("⚠️ %d yinelenen kupon kullanımı released işaretlendi", result.RowsAffected)
+	}
+	return nil
+}
+
 // ==============================================================================
 // VERİ MODELLERİ (DOMAIN ENTITIES)
 // ==============================================================================
@@ -76,12 +126,22 @@ type Coupon struct {
 	IsActive       bool      `json:"is_active" gorm:"default:true"`   // Aktif mi?
 }
 
+/*
+CouponUsage: Bir kullanıcının bir kuponu bir siparişte kullanması
+
+🔒 (coupon_id, user_id) UNIQUE: kullanıcı kuponu bir kez kullanabilir.
+
+	Bırakılan (released) kullanımlar index'e dahil değildir → sipariş
+	iptal edilirse kupon tekrar kullanılabilir. Detaylar redemptions.go'da.
+*/
 type CouponUsage struct {
 	gorm.Model
-	UserID   uint    `json:"user_id"`
-	CouponID uint    `json:"coupon_id"`
-	OrderID  uint    `json:"order_id"`
-	Discount float64 `json:"discount"` // Uygulanan indirim tutarı
+	ReservationID string  `json:"reservation_id" gorm:"uniqueIndex:idx_coupon_usage_reservation,where:reservation_id <> ''"` // Order Service saga ID
+	UserID        uint    `json:"user_id" gorm:"uniqueIndex:idx_coupon_usage_user,where:status <> 'released'"`
+	CouponID      uint    `json:"coupon_id" gorm:"uniqueIndex:idx_coupon_usage_user"`
+	OrderID       uint    `json:"order_id"`
+	Discount      float64 `json:"discount"`                              // Uygulanan indirim tutarı
+	Status        string  `json:"status" gorm:"index;default:committed"` // reserved | committed | released
 }
 
 // ==============================================================================
@@ -214,9 +274,10 @@ func validateCoupon(code string, userID uint, orderTotal float64) (*Coupon, stri
 		return nil, fmt.Sprintf("Bu kupon minimum %.0f TL alışverişlerde geçerlidir", coupon.MinOrderAmount), false
 	}
 
-	// 6. Bu kullanıcı daha önce kullandı mı?
+	// 6. Bu kullanıcı daha önce kullandı mı? (bırakılan kullanımlar sayılmaz)
+	// Kesin kontrol kullanımda unique index ile yapılır, bu sadece erken mesaj içindir.
 	var usage CouponUsage
-	if err := DB.Where("user_id = ? AND coupon_id = ?", userID, coupon.ID).First(&usage).Error; err == nil {
+	if err := DB.Where("user_id = ? AND coupon_id = ? AND status <> ?", userID, coupon.ID, UsageReleased).First(&usage).Error; err == nil {
 		return nil, "Bu kuponu daha önce kullandınız", false
 	}
 
@@ -268,36 +329,31 @@ func main() {
 	}))
 
 	// ==============================================================================
-	// HEALTH CHECK ENDPOINT
+	// HEALTH CHECK ENDPOINT'LERİ (/health, /livez, /readyz)
 	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		checks := make(map[string]interface{})
-		status := "healthy"
+	sqlDB, _ := DB.DB()
+	checker := health.NewHealthChecker("coupon-service")
+	checker.AddCheck("postgres", health.NewPostgresChecker(sqlDB))
+	checker.Register(app)
 
-		// PostgreSQL kontrolü
-		sqlDB, err := DB.DB()
-		if err != nil {
-			checks["postgres"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else if err := sqlDB.Ping(); err != nil {
-			checks["postgres"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else {
-			checks["postgres"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		statusCode := 200
-		if status != "healthy" {
-			statusCode = 503
-		}
+	// ==============================================================================
+	// JWT MIDDLEWARE - Kupon işlemleri giriş gerektirir
+	// ==============================================================================
+	/*
+	   🔐 Yetkiler (pkg/auth):
+	   - Kupon uygulama → giriş yapmış her kullanıcı
+	   - Listeleme, istatistik → coupons:read (admin)
+	   - Oluşturma, güncelleme, silme, elle kullanım kaydı → coupons:write (admin)
+	   - Sipariş kullanımları (redemptions) → service:call (Order Service)
+	*/
+	app.Use(auth.New(auth.Config{
+		JWKSURL:      getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		Denylist:     auth.DialRedisDenylist(fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379"))),
+		ServiceToken: getEnv("SERVICE_TOKEN", ""),
+	}))
 
-		return c.Status(statusCode).JSON(fiber.Map{
-			"status":    status,
-			"service":   "coupon-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks":    checks,
-		})
-	})
+	// --- KUPON KULLANIMI (Order Service saga'sı, servis anahtarı ister) ---
+	registerRedemptionRoutes(app)
 
 	// --- 1. TÜM KUPONLARI LİSTELE (Admin için) - PAGİNATİON ---
 	/*
@@ -305,7 +361,7 @@ func main() {
 	   GET /coupons?page=1&limit=20
 	   GET /coupons?page=1&limit=20&active=true (sadece aktifler)
 	*/
-	app.Get("/coupons", func(c *fiber.Ctx) error {
+	app.Get("/coupons", auth.RequirePermission(auth.PermCouponsRead), func(c *fiber.Ctx) error {
 		var coupons []Coupon
 		var totalItems int64
 
@@ -364,7 +420,7 @@ func main() {
 	})
 
 	// --- 3. YENİ KUPON OLUŞTUR (Admin) ---
-	app.Post("/coupons", func(c *fiber.Ctx) error {
+	app.Post("/coupons", auth.RequirePermission(auth.PermCouponsWrite), func(c *fiber.Ctx) error {
 		coupon := new(Coupon)
 		if err := c.BodyParser(coupon); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Geçersiz veri"})
@@ -388,7 +444,7 @@ func main() {
 	})
 
 	// --- 4. KUPON GÜNCELLE (Admin) ---
-	app.Put("/coupons/:id", func(c *fiber.Ctx) error {
+	app.Put("/coupons/:id", auth.RequirePermission(auth.PermCouponsWrite), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		var coupon Coupon
 		if err := DB.First(&coupon, id).Error; err != nil {
@@ -406,7 +462,7 @@ func main() {
 	})
 
 	// --- 5. KUPON SİL (Admin) ---
-	app.Delete("/coupons/:id", func(c *fiber.Ctx) error {
+	app.Delete("/coupons/:id", auth.RequirePermission(auth.PermCouponsWrite), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		var coupon Coupon
 		if err := DB.First(&coupon, id).Error; err != nil {
@@ -421,7 +477,8 @@ func main() {
 	/*
 
 	   NOT: Bu endpoint kuponu KULLANMAZ, sadece kontrol eder
-	   Kullanım, sipariş oluşturulunca /coupons/use ile yapılır
+	   Kullanım, sipariş oluşturulurken Order Service tarafından
+	   /coupons/redemptions ile yapılır
 	*/
 	app.Post("/coupons/apply", func(c *fiber.Ctx) error {
 		var req ApplyCouponRequest
@@ -429,6 +486,9 @@ func main() {
 			return c.Status(400).JSON(fiber.Map{"error": "Geçersiz veri"})
 		}
 
+		// 👤 Kupon token sahibi adına kontrol edilir (body'deki user_id'ye güvenilmez)
+		req.UserID, _ = auth.UserID(c)
+
 		// Validasyon
 		coupon, message, valid := validateCoupon(req.Code, req.UserID, req.OrderTotal)
 		if !valid {
@@ -454,25 +514,25 @@ func main() {
 		})
 	})
 
-	// --- 7. KUPON KULLANIMINI KAYDET ---
+	// --- 7. KUPON KULLANIMINI KAYDET (Admin) ---
 	/*
-	   Bu endpoint sipariş oluşturulduktan sonra çağrılır
-	   Order Service → Coupon Service
+	   Siparişten bağımsız, elle kullanım kaydı (admin düzeltmeleri).
+	   Sipariş akışı bunu ÇAĞIRMAZ: Order Service kuponu saga içinde
+	   /coupons/redemptions ile ayırıp onaylar.
 
-	   İşlem sırası:
-	   1. Sipariş oluştur (Order Service)
-	   2. Ödeme al (Payment Service)
-	   3. Kuponu kullanıldı olarak işaretle (Coupon Service)
+	   İndirim istemciden alınmaz, order_total üzerinden kuponun kendi
+	   kuralıyla hesaplanır. Limit ve kullanıcı başına tek kullanım kuralı
+	   redemptions ile aynı atomik yoldan geçer.
 	*/
-	app.Post("/coupons/use", func(c *fiber.Ctx) error {
+	app.Post("/coupons/use", auth.RequirePermission(auth.PermCouponsWrite), func(c *fiber.Ctx) error {
 		var req struct {
-			CouponID uint    `json:"coupon_id"`
-			UserID   uint    `json:"user_id"`
-			OrderID  uint    `json:"order_id"`
-			Discount float64 `json:"discount"`
+			CouponID   uint    `json:"coupon_id"`
+			UserID     uint    `json:"user_id"`
+			OrderID    uint    `json:"order_id"`
+			OrderTotal float64 `json:"order_total"`
 		}
 
-		if err := c.BodyParser(&req); err != nil {
+		if err := c.BodyParser(&req); err != nil || req.UserID == 0 || req.OrderTotal < 0 {
 			return c.Status(400).JSON(fiber.Map{"error": "Geçersiz veri"})
 		}
 
@@ -482,25 +542,28 @@ func main() {
 			return c.Status(404).JSON(fiber.Map{"error": "Kupon bulunamadı"})
 		}
 
-		// Kullanım kaydı oluştur
+		// Sayaç ve kullanım kaydı tek transaction'da, koşullu artırma ile
 		usage := CouponUsage{
 			UserID:   req.UserID,
 			CouponID: req.CouponID,
 			OrderID:  req.OrderID,
-			Discount: req.Discount,
+			Discount: calculateDiscount(&coupon, req.OrderTotal),
+			Status:   UsageCommitted,
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			return claimCoupon(tx, &usage)
+		})
+		if err != nil {
+			return redemptionError(c, err)
 		}
-		DB.Create(&usage)
-
-		// Kullanım sayısını artır
-		DB.Model(&coupon).Update("used_count", coupon.UsedCount+1)
 
 		fmt.Printf("✅ Kupon kullanıldı: %s (User: %d, Order: %d)\n", coupon.Code, req.UserID, req.OrderID)
 
-		return c.JSON(fiber.Map{"message": "Kupon kullanımı kaydedildi"})
+		return c.JSON(fiber.Map{"message": "Kupon kullanımı kaydedildi", "discount": usage.Discount})
 	})
 
 	// --- 8. KUPON İSTATİSTİKLERİ (Admin) ---
-	app.Get("/coupons/:id/stats", func(c *fiber.Ctx) error {
+	app.Get("/coupons/:id/stats", auth.RequirePermission(auth.PermCouponsRead), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		var coupon Coupon
 		if err := DB.First(&coupon, id).Error; err != nil {
@@ -509,7 +572,7 @@ func main() {
 
 		// Toplam indirim tutarını hesapla
 		var totalDiscount float64
-		DB.Model(&CouponUsage{}).Where("coupon_id = ?", id).Select("COALESCE(SUM(discount), 0)").Scan(&totalDiscount)
+		DB.Model(&CouponUsage{}).Where("coupon_id = ? AND status = ?", id, UsageCommitted).Select("COALESCE(SUM(discount), 0)").Scan(&totalDiscount)
 
 		// Kullanım yüzdesi
 		usagePercent := float64(coupon.UsedCount) / float64(coupon.MaxUses) * 100
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==============================================================================
+// KUPON KULLANIMI (Sipariş saga'sı ile birlikte)
+// ==============================================================================
+/*
+Eski akış: Sipariş oluştuktan sonra istemci /coupons/use çağırırdı (çağırmazsa
+kupon hiç sayılmazdı) ve sayaç oku-yaz ile artırılırdı:
+
+	DB.Model(&coupon).Update("used_count", coupon.UsedCount+1)
+
+Eşzamanlı iki sipariş aynı değeri okuyup MaxUses'ı aşabilir, aynı kullanıcı
+kuponu iki kez kullanabilirdi.
+
+Yeni akış: Order Service kuponu saga'nın bir adımı olarak kullanır:
+
+	POST   /coupons/redemptions             → Kuponu ayır (sipariş verilirken)
+	POST   /coupons/redemptions/:id/commit  → Sipariş kesinleşti
+	DELETE /coupons/redemptions/:id         → Bırak (saga telafisi / sipariş iptali)
+
+Durumlar:
+
+	reserved ──commit──► committed
+	    │                    │
+	    └──release──► released ◄──release
+
+Kurallar veritabanı seviyesinde ATOMİKTİR:
+  - MaxUses: UPDATE coupons SET used_count = used_count + 1
+    WHERE id = ? AND used_count < max_uses (satır güncellenmezse limit dolu)
+  - Kullanıcı başına tek kullanım: coupon_usages (coupon_id, user_id) üzerinde
+    UNIQUE index (bırakılan kullanımlar hariç). İkinci kayıt insert'te patlar,
+    transaction geri alınır ve sayaç da geri döner.
+
+Bırakılan kullanım sayacı bir azaltır; kupon tekrar kullanılabilir.
+Tüm işlemler reservation_id (Order Service'teki saga ID) ile idempotenttir.
+*/
+
+const (
+	UsageReserved  = "reserved"
+	UsageCommitted = "committed"
+	UsageReleased  = "released"
+)
+
+var (
+	errRedemptionNotFound = errors.New("Kupon kullanımı bulunamadı")
+	errRedemptionReleased = errors.New("Kupon kullanımı bırakılmış")
+	errCouponLimitReached = errors.New("Bu kupon kullanım limitine ulaştı")
+	errCouponAlreadyUsed  = errors.New("Bu kuponu daha önce kullandınız")
+)
+
+// errInvalidCoupon - validateCoupon'un kullanıcıya dönecek mesajı
+type errInvalidCoupon struct{ message string }
+
+func (e errInvalidCoupon) Error() string { return e.message }
+
+// RedeemCouponRequest - Order Service'ten gelen kupon ayırma isteği
+type RedeemCouponRequest struct {
+	ReservationID string  `json:"reservation_id"` // Saga ID
+	Code          string  `json:"code"`
+	UserID        uint    `json:"user_id"`
+	OrderTotal    float64 `json:"order_total"` // Kupon öncesi tutar (sunucuda hesaplanmış)
+}
+
+// redeemCoupon - Kuponu sipariş için atomik olarak ayırır (status: reserved)
+func redeemCoupon(req *RedeemCouponRequest) (*CouponUsage, error) {
+	var usage CouponUsage
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Aynı ID ile daha önce ayrıldıysa aynı sonucu dön (idempotent)
+		if tx.Where("reservation_id = ?", req.ReservationID).First(&usage).Error == nil {
+			if usage.Status == UsageReleased {
+				return errRedemptionReleased
+			}
+			return nil
+		}
+
+		// Tarih, aktiflik, minimum tutar (ve hızlı yol için limit) kontrolü
+		coupon, message, valid := validateCoupon(req.Code, req.UserID, req.OrderTotal)
+		if !valid {
+			return errInvalidCoupon{message}
+		}
+
+		usage = CouponUsage{
+			ReservationID: req.ReservationID,
+			UserID:        req.UserID,
+			CouponID:      coupon.ID,
+			Discount:      calculateDiscount(coupon, req.OrderTotal),
+			Status:        UsageReserved,
+		}
+		return claimCoupon(tx, &usage)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// claimCoupon - Sayacı artırır ve kullanım kaydını yazar (tx içinde çağrılmalı)
+func claimCoupon(tx *gorm.DB, usage *CouponUsage) error {
+	// MaxUses: koşullu artırma, limit doluysa satır güncellenmez
+	result := tx.Model(&Coupon{}).
+		Where("id = ? AND used_count < max_uses", usage.CouponID).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errCouponLimitReached
+	}
+
+	// Kullanıcı başına tek kullanım: unique index ihlali → transaction geri alınır
+	if err := tx.Create(usage).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "23505") {
+			return errCouponAlreadyUsed
+		}
+		return err
+	}
+	return nil
+}
+
+// commitRedemption - Sipariş kesinleşti, kullanım kalıcı olur
+func commitRedemption(reservationID string, orderID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var usage CouponUsage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("reservation_id = ?", reservationID).First(&usage).Error; err != nil {
+			return errRedemptionNotFound
+		}
+		switch usage.Status {
+		case UsageReleased:
+			return errRedemptionReleased
+		case UsageCommitted:
+			return nil
+		}
+		return tx.Model(&usage).Updates(map[string]any{"status": UsageCommitted, "order_id": orderID}).Error
+	})
+}
+
+// releaseRedemption - Kullanımı bırakır ve sayacı azaltır
+// Sayaç durum geçişi ile birlikte azaltılır → iki kez düşülmez.
+func releaseRedemption(reservationID string) (bool, error) {
+	released := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var usage CouponUsage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("reservation_id = ? AND status IN ?", reservationID, []string{UsageReserved, UsageCommitted}).
+			First(&usage).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // Hiç ayrılmamış veya zaten bırakılmış
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Coupon{}).Where("id = ? AND used_count > 0", usage.CouponID).
+			UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&usage).Update("status", UsageReleased).Error; err != nil {
+			return err
+		}
+		released = true
+		return nil
+	})
+	return released, err
+}
+
+// redemptionError - Kullanım hatasını HTTP yanıtına çevirir
+func redemptionError(c *fiber.Ctx, err error) error {
+	var invalid errInvalidCoupon
+	switch {
+	case errors.As(err, &invalid):
+		return c.Status(400).JSON(fiber.Map{"error": invalid.message})
+	case errors.Is(err, errCouponLimitReached), errors.Is(err, errCouponAlreadyUsed):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, errRedemptionNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, errRedemptionReleased):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": "Kupon kullanımı kaydedilemedi"})
+}
+
+// registerRedemptionRoutes - Order Service'in çağırdığı iç endpoint'ler
+// Gateway'de route'u yok; auth.New'den SONRA kaydedilir ve service:call ister.
+func registerRedemptionRoutes(app fiber.Router) {
+	internal := auth.RequirePermission(auth.PermServiceCall)
+
+	app.Post("/coupons/redemptions", internal, func(c *fiber.Ctx) error {
+		req := new(RedeemCouponRequest)
+		if err := c.BodyParser(req); err != nil || req.ReservationID == "" || req.Code == "" || req.UserID == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Geçersiz veri"})
+		}
+
+		usage, err := redeemCoupon(req)
+		if err != nil {
+			return redemptionError(c, err)
+		}
+
+		fmt.Printf("🎫 Kupon ayrıldı: %s (User: %d, Rezervasyon: %s)\n", req.Code, req.UserID, req.ReservationID)
+		return c.Status(201).JSON(usage)
+	})
+
+	app.Post("/coupons/redemptions/:id/commit", internal, func(c *fiber.Ctx) error {
+		var req struct {
+			OrderID uint `json:"order_id"`
+		}
+		c.BodyParser(&req)
+
+		id := c.Params("id")
+		if err := commitRedemption(id, req.OrderID); err != nil {
+			return redemptionError(c, err)
+		}
+		return c.JSON(fiber.Map{"reservation_id": id, "status": UsageCommitted})
+	})
+
+	app.Delete("/coupons/redemptions/:id", internal, func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		released, err := releaseRedemption(id)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Kupon kullanımı bırakılamadı"})
+		}
+		if released {
+			fmt.Printf("🔓 Kupon kullanımı bırakıldı: %s\n", id)
+		}
+		return c.JSON(fiber.Map{"reservation_id": id, "released": released})
+	})
+}
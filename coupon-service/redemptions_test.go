@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Limit ve kullanıcı başına tek kullanım kuralları veritabanında (koşullu
+// UPDATE + idx_coupon_usage_user partial unique index) uygulanır, bu yüzden
+// testler gerçek bir Postgres ister:
+//
+//	TEST_DATABASE_DSN="host=localhost user=user password=password dbname=ecommerce port=5432 sslmode=disable" go test ./coupon-service/
+//
+// Her çalıştırma kendi şemasını açar ve sonunda siler.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN tanımlı değil, Postgres testleri atlandı")
+	}
+
+	schema := fmt.Sprintf("coupon_test_%d", time.Now().UnixNano())
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("veritabanına bağlanılamadı: %v", err)
+	}
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("şema oluşturulamadı: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("veritabanına bağlanılamadı: %v", err)
+	}
+	if err := db.AutoMigrate(&Coupon{}, &CouponUsage{}); err != nil {
+		t.Fatalf("migration başarısız: %v", err)
+	}
+	DB = db
+}
+
+func createTestCoupon(t *testing.T, maxUses int) Coupon {
+	t.Helper()
+	coupon := Coupon{
+		Code:          fmt.Sprintf("TEST%d", time.Now().UnixNano()),
+		DiscountType:  "fixed",
+		DiscountValue: 50,
+		MaxUses:       maxUses,
+		StartDate:     time.Now().Add(-time.Hour),
+		EndDate:       time.Now().Add(time.Hour),
+		IsActive:      true,
+	}
+	if err := DB.Create(&coupon).Error; err != nil {
+		t.Fatalf("kupon oluşturulamadı: %v", err)
+	}
+	return coupon
+}
+
+func claim(couponID, userID uint, reservationID string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return claimCoupon(tx, &CouponUsage{
+			ReservationID: reservationID,
+			UserID:        userID,
+			CouponID:      couponID,
+			Status:        UsageReserved,
+		})
+	})
+}
+
+func TestClaimCoupon(t *testing.T) {
+	openTestDB(t)
+
+	// step: release doluysa o rezervasyon bırakılır, değilse user için claim yapılır
+	type step struct {
+		user    uint
+		release string
+		want    error
+	}
+
+	tests := []struct {
+		name      string
+		maxUses   int
+		steps     []step
+		wantCount int
+	}{
+		{
+			name:    "same user claims twice",
+			maxUses: 10,
+			steps: []step{
+				{user: 1},
+				{user: 1, want: errCouponAlreadyUsed},
+			},
+			wantCount: 1,
+		},
+		{
+			name:    "released usage can be claimed again",
+			maxUses: 10,
+			steps: []step{
+				{user: 1},
+				{release: "r0"},
+				{user: 1},
+			},
+			wantCount: 1,
+		},
+		{
+			name:    "claims beyond max uses",
+			maxUses: 2,
+			steps: []step{
+				{user: 1},
+				{user: 2},
+				{user: 3, want: errCouponLimitReached},
+			},
+			wantCount: 2,
+		},
+		{
+			name:    "duplicate claim does not consume the limit",
+			maxUses: 2,
+			steps: []step{
+				{user: 1},
+				{user: 1, want: errCouponAlreadyUsed},
+				{user: 2},
+			},
+			wantCount: 2,
+		},
+		{
+			name:    "release frees a slot at the limit",
+			maxUses: 1,
+			steps: []step{
+				{user: 1},
+				{user: 2, want: errCouponLimitReached},
+				{release: "r0"},
+				{user: 2},
+			},
+			wantCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coupon := createTestCoupon(t, tt.maxUses)
+			for i, s := range tt.steps {
+				reservation := fmt.Sprintf("%s-r%d", coupon.Code, i)
+				if s.release != "" {
+					if _, err := releaseRedemption(coupon.Code + "-" + s.release); err != nil {
+						t.Fatalf("adım %d: release: %v", i, err)
+					}
+					continue
+				}
+				if err := claim(coupon.ID, s.user, reservation); !errors.Is(err, s.want) {
+					t.Fatalf("adım %d: claim(user %d) = %v, want %v", i, s.user, err, s.want)
+				}
+			}
+
+			var got Coupon
+			DB.First(&got, coupon.ID)
+			if got.UsedCount != tt.wantCount {
+				t.Errorf("used_count = %d, want %d", got.UsedCount, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestClaimCouponConcurrentLimit(t *testing.T) {
+	openTestDB(t)
+
+	const maxUses, claims = 3, 20
+	coupon := createTestCoupon(t, maxUses)
+
+	var wg sync.WaitGroup
+	results := make(chan error, claims)
+	for i := range claims {
+		wg.Add(1)
+		go func(user uint) {
+			defer wg.Done()
+			results <- claim(coupon.ID, user, fmt.Sprintf("%s-c%d", coupon.Code, user))
+		}(uint(i + 1))
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, errCouponLimitReached):
+			t.Errorf("beklenmeyen hata: %v", err)
+		}
+	}
+	if succeeded != maxUses {
+		t.Errorf("%d kullanım başarılı, want %d", succeeded, maxUses)
+	}
+
+	var got Coupon
+	DB.First(&got, coupon.ID)
+	if got.UsedCount != maxUses {
+		t.Errorf("used_count = %d, want %d", got.UsedCount, maxUses)
+	}
+}
+
+// Eski oku-artır-yaz akışından kalan yinelenen kullanımlar index'i engellememeli
+func TestDedupeCouponUsages(t *testing.T) {
+	openTestDB(t)
+
+	coupon := createTestCoupon(t, 10)
+	if err := DB.Migrator().DropIndex(&CouponUsage{}, "idx_coupon_usage_user"); err != nil {
+		t.Fatalf("index silinemedi: %v", err)
+	}
+	usages := []CouponUsage{
+		{UserID: 1, CouponID: coupon.ID, Status: UsageCommitted},
+		{UserID: 1, CouponID: coupon.ID, Status: UsageCommitted},
+		{UserID: 1, CouponID: coupon.ID, Status: UsageCommitted},
+		{UserID: 2, CouponID: coupon.ID, Status: UsageCommitted},
+		{UserID: 2, CouponID: coupon.ID, Status: UsageReleased},
+	}
+	if err := DB.Create(&usages).Error; err != nil {
+		t.Fatalf("kullanımlar yazılamadı: %v", err)
+	}
+
+	if err := dedupeCouponUsages(); err != nil {
+		t.Fatalf("dedupeCouponUsages: %v", err)
+	}
+	if err := DB.AutoMigrate(&CouponUsage{}); err != nil {
+		t.Fatalf("index oluşturulamadı: %v", err)
+	}
+
+	want := []string{UsageCommitted, UsageReleased, UsageReleased, UsageCommitted, UsageReleased}
+	for i, u := range usages {
+		var got CouponUsage
+		DB.First(&got, u.ID)
+		if got.Status != want[i] {
+			t.Errorf("kullanım %d (user %d): status = %s, want %s", i, u.UserID, got.Status, want[i])
+		}
+	}
+	if err := claim(coupon.ID, 1, "after-dedupe"); !errors.Is(err, errCouponAlreadyUsed) {
+		t.Errorf("claim = %v, want %v", err, errCouponAlreadyUsed)
+	}
+}
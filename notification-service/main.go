@@ -7,6 +7,9 @@ import (
 	"os"
 	"time"
 
+	"ecommerce-backend/pkg/health"
+
+	"github.com/gofiber/fiber/v2"
 	"github.com/streadway/amqp"
 )
 
@@ -78,7 +81,19 @@ func main() {
 	)
 	failOnError(err, "Consumer başlatılamadı")
 
-	// 4. Sonsuz Döngüde Mesajları Okuma
+	// 4. Health Endpoint'leri (/health, /livez, /readyz)
+	// Bu servis HTTP API sunmaz; sadece Docker/Kubernetes probe'ları için dinler.
+	checker := health.NewHealthChecker("notification-service")
+	checker.AddCheck("rabbitmq", health.NewRabbitMQChecker(conn))
+	checker.AddCheck("rabbitmq_channel", health.NewCloseWatcher(ch.NotifyClose(make(chan *amqp.Error, 1))))
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	checker.Register(app)
+	go func() {
+		log.Fatal(app.Listen(":3007"))
+	}()
+
+	// 5. Sonsuz Döngüde Mesajları Okuma
 	// Go'da channel (kanal) ile bu iş çok temiz yapılır.
 	forever := make(chan bool)
 
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/pkg/outbox"
+
+	"gorm.io/gorm"
+)
+
+// ==============================================================================
+// SİPARİŞ İPTALİ (Müşteri veya admin)
+// ==============================================================================
+/*
+Sipariş "cancelled" olunca geri alınması gerekenler:
+
+	Yan etki         Nasıl                                       Idempotent çünkü
+	──────────────────────────────────────────────────────────────────────────────
+	Stok             "order.cancelled" eventi → product-service   Rezervasyon bir kez "released" olur
+	Kupon            DELETE /coupons/redemptions/:sagaID          Kullanım bir kez "released" olur
+	Ödeme            POST /void, tahsil edildiyse POST /refund    Zaten iptal/iade ise 200 döner
+
+Durum değişikliği, order_cancellations kaydı ve event AYNI transaction'da
+yazılır. Kupon ve ödeme adımları hemen denenir; başarısız olan adım
+kayıtta işaretsiz kalır ve resumeCancellations tarafından tekrar denenir.
+
+Sipariş kargoya verilene kadar (pending, pending_payment, paid, preparing)
+iptal edilebilir; kargodaki sipariş iade süreciyle geri alınır. Müşteri sadece
+kendi siparişini iptal edebilir, admin PATCH /orders/:id/status ile aynı akışı
+kullanır.
+
+pending / pending_payment siparişin saga'sı henüz bitmemiştir. İptal, durum
+değişikliğiyle aynı transaction'da saga'yı "compensating" olarak devralır:
+
+	awaiting_payment (3D Secure bekleniyor)  → her zaman devralınır
+	running (yarıda kalmış, sagaStaleAfter)  → devralınır
+	running (istek hâlâ işleniyor)           → 409, biraz sonra tekrar denenmeli
+
+Devralınan saga telafi edilir (provizyon iptali, kupon ve stok rezervasyonu
+bırakılır). Sonradan gelen ödeme eventi veya zaman aşımı saga artık
+beklemediği için siparişe dokunmaz.
+*/
+
+const (
+	orderCancelledRoutingKey = "order.cancelled"
+	cancellationRetryPeriod  = 30 * time.Second
+)
+
+// cancellable - İptal edilebilen durumlar
+var cancellable = map[string]bool{
+	StatusPending:        true,
+	StatusPendingPayment: true,
+	StatusPaid:           true,
+	StatusPreparing:      true,
+}
+
+// OrderCancellation - İptalin yan etkilerinin takibi
+type OrderCancellation struct {
+	OrderID         uint       `json:"order_id" gorm:"primaryKey;autoIncrement:false"`
+	SagaID          string     `json:"-"` // Rezervasyon / kupon / ödeme referansı
+	TransactionID   string     `json:"-"`
+	Actor           string     `json:"actor"`
+	Reason          string     `json:"reason"`
+	CouponReleased  bool       `json:"coupon_released"`
+	PaymentRefunded bool       `json:"payment_refunded"`
+	Attempts        int        `json:"attempts"`
+	LastError       string     `json:"last_error,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at" gorm:"index"` // nil → yan etkiler bekliyor
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// OrderCancelledEvent - "order.cancelled" eventi (product-service stoğu geri ekler)
+type OrderCancelledEvent struct {
+	OrderID       uint             `json:"order_id"`
+	UserID        uint             `json:"user_id"`
+	ReservationID string           `json:"reservation_id"` // Boşsa saga'sız (eski) sipariş
+	Items         []OrderEventItem `json:"items"`
+}
+
+var (
+	errNotCancellable  = errors.New("Sipariş bu aşamada iptal edilemez")
+	errOrderInProgress = errors.New("Sipariş şu an işleniyor, lütfen biraz sonra tekrar deneyin")
+	errOrderCancelled  = errors.New("Sipariş iptal edildi")
+)
+
+// cancelOrder - Siparişi iptal eder ve yan etkilerini başlatır
+// Zaten iptal edilmiş sipariş için tekrar çağrılırsa mevcut durumu döner.
+func cancelOrder(orderID uint, actor, reason string) (*Order, *OrderCancellation, error) {
+	var saga OrderSaga
+	DB.Where("order_id = ?", orderID).First(&saga)
+
+	var order *Order
+	cancellation := &OrderCancellation{
+		OrderID:       orderID,
+		SagaID:        saga.ID,
+		TransactionID: saga.TransactionID,
+		Actor:         actor,
+		Reason:        reason,
+		// Kupon yoksa bırakılacak bir şey yok
+		CouponReleased: saga.CouponCode == "",
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var current Order
+		if err := tx.Preload("Items").First(&current, orderID).Error; err != nil {
+			return errOrderNotFound
+		}
+		if current.Status == StatusCancelled {
+			order = &current
+			return nil // Zaten iptal: yan etkiler ilk iptalde başlatıldı
+		}
+		if !cancellable[current.Status] {
+			return errNotCancellable
+		}
+		// Saga bitmemiş (pending / pending_payment): ödeme eventi, zaman aşımı
+		// veya kurtarma siparişi ilerletmesin diye önce saga devralınır
+		if saga.ID != "" && saga.Status != SagaDone {
+			if !claimSagaForCancellation(tx, &saga) {
+				return errOrderInProgress
+			}
+		}
+
+		var err error
+		if order, err = transitionOrder(tx, orderID, StatusCancelled, actor, reason); err != nil {
+			return err
+		}
+		order.Items = current.Items
+
+		if err := tx.Create(cancellation).Error; err != nil {
+			return err
+		}
+
+		event := OrderCancelledEvent{OrderID: order.ID, UserID: order.UserID, ReservationID: saga.ID}
+		for _, item := range order.Items {
+			event.Items = append(event.Items, OrderEventItem{ProductID: int(item.ProductID), Quantity: item.Quantity})
+		}
+		msg, err := outbox.NewMessage("order", strconv.FormatUint(uint64(order.ID), 10),
+			orderEventsExchange, orderCancelledRoutingKey, event)
+		if err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, outboxSource, msg)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	relay.Notify()
+
+	if saga.Status == SagaCompensating {
+		// Provizyon iptali, kupon ve stok rezervasyonu; yarıda kalırsa resumeSagas tamamlar
+		compensate(&saga, errOrderCancelled)
+	}
+
+	// Önceden iptal edilmiş siparişin mevcut kaydını dön
+	if err := DB.First(cancellation, orderID).Error; err != nil {
+		return order, nil, nil
+	}
+	processCancellation(cancellation)
+	return order, cancellation, nil
+}
+
+// claimSagaForCancellation - Bitmemiş saga'yı "compensating" olarak devralır (tx içinde)
+// Ödeme bekleyen saga her zaman, "running" saga sadece yarıda kalmışsa devralınır.
+func claimSagaForCancellation(tx *gorm.DB, saga *OrderSaga) bool {
+	claim := tx.Model(&OrderSaga{}).
+		Where("id = ? AND (status = ? OR (status = ? AND updated_at < ?))",
+			saga.ID, SagaAwaitingPayment, SagaRunning, time.Now().Add(-sagaStaleAfter)).
+		Update("status", SagaCompensating)
+	if claim.Error != nil || claim.RowsAffected != 1 {
+		return false
+	}
+	saga.Status = SagaCompensating
+	return true
+}
+
+// processCancellation - Bekleyen yan etkileri çalıştırır (tekrar çağrılabilir)
+func processCancellation(c *OrderCancellation) {
+	if c.CompletedAt != nil {
+		return
+	}
+
+	var failed error
+	if !c.CouponReleased {
+		if err := releaseCoupon(c.SagaID); err != nil {
+			failed = errors.Join(failed, err)
+		} else {
+			c.CouponReleased = true
+		}
+	}
+	if !c.PaymentRefunded {
+		if c.SagaID == "" {
+			// Saga'sız (eski) sipariş: ödeme kaydı yok
+			c.PaymentRefunded = true
+		} else if err := reversePayment(c.TransactionID, c.SagaID); err != nil {
+			failed = errors.Join(failed, err)
+		} else {
+			c.PaymentRefunded = true
+		}
+	}
+
+	c.Attempts++
+	updates := map[string]any{
+		"coupon_released":  c.CouponReleased,
+		"payment_refunded": c.PaymentRefunded,
+		"attempts":         c.Attempts,
+		"last_error":       "",
+	}
+	if failed != nil {
+		c.LastError = failed.Error()
+		updates["last_error"] = c.LastError
+		log.Printf("❌ Sipariş #%d iptali tamamlanamadı, tekrar denenecek: %s", c.OrderID, failed)
+	} else {
+		now := time.Now()
+		c.CompletedAt, c.LastError = &now, ""
+		updates["completed_at"] = now
+		fmt.Printf("🚫 Sipariş #%d iptal edildi (kupon bırakıldı, ödeme iade edildi)\n", c.OrderID)
+	}
+	DB.Model(c).Updates(updates)
+}
+
+// resumeCancellations - Yan etkileri tamamlanmamış iptalleri periyodik olarak tekrar dener
+func resumeCancellations() {
+	for range time.Tick(cancellationRetryPeriod) {
+		var pending []OrderCancellation
+		DB.Where("completed_at IS NULL AND updated_at < ?", time.Now().Add(-cancellationRetryPeriod)).
+			Order("created_at").Find(&pending)
+		for i := range pending {
+			processCancellation(&pending[i])
+		}
+	}
+}
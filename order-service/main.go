@@ -1,16 +1,24 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/health"
+	"ecommerce-backend/pkg/idempotency"
+	"ecommerce-backend/pkg/outbox"
+
+	"github.com/gofiber/adaptor/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	"github.com/streadway/amqp"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -35,7 +43,11 @@ Order: Ana sipariş modeli
   - CouponCode: Kullanılan kupon kodu ("HOSGELDIN")
   - CouponDiscount: İndirim tutarı (75 TL)
   - ShippingAddress: Teslimat adresi
+  - TransactionID: Ödemenin payment-service'teki işlem ID'si (GET /payments/:id)
   - Items: İlişkili ürünler (GORM hasMany)
+  - Status: pending, paid, preparing... (geçişler status.go'da)
+  - History: Durum geçmişi (sadece detayda yüklenir)
+  - Returns / Refunds: İade talepleri ve para iadeleri (sadece detayda yüklenir)
 
 gorm.Model otomatik ekler:
   - ID (uint)
@@ -50,9 +62,17 @@ type Order struct {
 	CouponCode      string      `json:"coupon_code"`     // Kullanılan kupon: "HOSGELDIN"
 	CouponDiscount  float64     `json:"coupon_discount"` // İndirim tutarı: 75
 	TotalPrice      float64     `json:"total_price"`     // Kupon SONRASI tutar
-	Status          string      `json:"status" gorm:"default:'Hazırlanıyor'"`
+	Status          string      `json:"status" gorm:"default:'pending';index"`
 	ShippingAddress string      `json:"shipping_address"`                // Teslimat adresi
+	TransactionID   string      `json:"transaction_id" gorm:"index"`     // payment-service işlem ID'si
 	Items           []OrderItem `json:"items" gorm:"foreignKey:OrderID"` // İlişkili ürünler
+
+	// pending_payment: müşterinin yönlendirileceği 3D Secure sayfası (saklanmaz)
+	PaymentActionURL string `json:"payment_action_url,omitempty" gorm:"-"`
+
+	History []OrderStatusHistory `json:"history,omitempty" gorm:"foreignKey:OrderID"`
+	Returns []OrderReturn        `json:"returns,omitempty" gorm:"foreignKey:OrderID"`
+	Refunds []OrderRefund        `json:"refunds,omitempty" gorm:"foreignKey:OrderID"`
 }
 
 type OrderItem struct {
@@ -84,20 +104,21 @@ Neden ayrı?
 */
 
 // CreateOrderRequest: Frontend'den gelen sipariş isteği
+// 💰 SubTotal, CouponDiscount ve ürün fiyatları sunucuda yeniden hesaplanır
+// (priceOrder). İstemcinin TotalPrice'ı sadece beklenen toplam olarak kullanılır.
 type CreateOrderRequest struct {
 	UserID     uint             `json:"user_id"`
 	Items      []OrderItemInput `json:"items"`       // Sepetteki ürünler
 	SubTotal   float64          `json:"sub_total"`   // Kupon öncesi tutar
-	TotalPrice float64          `json:"total_price"` // Kupon sonrası tutar
+	TotalPrice float64          `json:"total_price"` // Kupon sonrası tutar (istemcinin gördüğü)
 
 	// Kupon bilgileri (opsiyonel - kupon kullanılmayabilir)
 	CouponCode     string  `json:"coupon_code"`
 	CouponDiscount float64 `json:"coupon_discount"`
 
-	// Ödeme bilgileri
-	CardNumber string `json:"card_number"`
-	CVV        string `json:"cvv"`
-	Expiry     string `json:"expiry"`
+	// Ödeme: payment-service'in verdiği kart token'ı (POST /api/payments/tokens)
+	// Kart numarası ve CVV bu servise hiç gelmez.
+	PaymentToken string `json:"payment_token"`
 
 	// Teslimat
 	ShippingAddress string `json:"shipping_address"`
@@ -112,21 +133,51 @@ type OrderItemInput struct {
 	Quantity     int     `json:"quantity"`
 }
 
+// CancelOrderRequest: Müşterinin iptal isteği
+type CancelOrderRequest struct {
+	Reason string `json:"reason"` // Opsiyonel: "Yanlış ürün seçtim"
+}
+
+// CreateReturnRequest: Müşterinin iade talebi (teslim edilen sipariş için)
+type CreateReturnRequest struct {
+	Reason string            `json:"reason"` // Zorunlu: "Beden uymadı"
+	Items  []ReturnItemInput `json:"items"`
+}
+
+type ReturnItemInput struct {
+	OrderItemID uint `json:"order_item_id"` // Siparişteki satırın ID'si (items[].ID)
+	Quantity    int  `json:"quantity"`
+}
+
+// ReviewReturnRequest: Admin'in iade kararı notu
+type ReviewReturnRequest struct {
+	Note string `json:"note"` // Opsiyonel: "Ürün kullanılmış"
+}
+
 // UpdateStatusRequest: Admin'den gelen durum güncelleme
 type UpdateStatusRequest struct {
-	Status string `json:"status"`
+	Status string `json:"status"` // "shipped" (eski "Kargolandı" da kabul edilir)
+	Reason string `json:"reason"` // Opsiyonel, durum geçmişine yazılır
 }
 
 // OrderEvent: RabbitMQ'ya gönderilecek stok düşürme eventi
+// ReservationID: Product Service bu rezervasyonu onaylayıp stoğu düşer
 type OrderEvent struct {
-	Items []struct {
-		ProductID int `json:"product_id"`
-		Quantity  int `json:"quantity"`
-	} `json:"items"`
+	ReservationID string           `json:"reservation_id"`
+	Items         []OrderEventItem `json:"items"`
+}
+
+type OrderEventItem struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
 }
 
 var DB *gorm.DB
 var ch *amqp.Channel
+var relay *outbox.Relay
+
+// outboxSource - Bu servisin outbox mesajlarının source değeri (relay sadece bunları yayınlar)
+const outboxSource = "order-service"
 
 // ==============================================================================
 // VERİTABANI BAĞLANTISI
@@ -167,7 +218,13 @@ func initDatabase() {
 
 	   Production'da: Flyway, Goose gibi migration tool'ları kullan
 	*/
-	DB.AutoMigrate(&Order{}, &OrderItem{})
+	DB.AutoMigrate(&Order{}, &OrderItem{}, &OrderStatusHistory{}, &OrderCancellation{}, &OrderReturn{}, &OrderReturnItem{}, &OrderRefund{}, &OrderSaga{}, &outbox.Message{},
+		&ReconciliationRun{}, &ReconciliationIssue{})
+	migrateLegacyStatuses()
+
+	// Alan eklenmeden önce oluşan siparişlerin işlem ID'si saga kaydında
+	DB.Exec(`UPDATE orders SET transaction_id = s.transaction_id FROM order_sagas s
+		WHERE s.order_id = orders.id AND COALESCE(orders.transaction_id, '') = '' AND s.transaction_id <> ''`)
 	fmt.Println("✅ Order Service Veritabanına Bağlandı!")
 }
 
@@ -178,6 +235,11 @@ func failOnError(err error, msg string) {
 }
 
 func main() {
+	// ./main reconcile ... → Sadece mutabakatı çalıştırır (reconciliation.go)
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		os.Exit(runReconcileCommand(os.Args[2:]))
+	}
+
 	initDatabase()
 
 	// RabbitMQ Bağlantısı (RETRY İLE)
@@ -216,168 +278,213 @@ func main() {
 	)
 	failOnError(err, "Exchange oluşturulamadı")
 
+	// Topic Exchange: sipariş yaşam döngüsü eventleri (order.status.changed)
+	err = ch.ExchangeDeclare(
+		orderEventsExchange,
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	failOnError(err, "Exchange oluşturulamadı")
+
+	// Topic Exchange: payment-service'in ödeme eventleri (3D Secure sonucu)
+	err = ch.ExchangeDeclare(paymentEventsExchange, "topic", true, false, false, false, nil)
+	failOnError(err, "Exchange oluşturulamadı")
+
+	qPayment, err := ch.QueueDeclare(paymentEventsQueue, true, false, false, false, nil)
+	failOnError(err, "Ödeme kuyruğu oluşturulamadı")
+	for _, key := range []string{paymentAuthorizedRoutingKey, paymentFailedRoutingKey} {
+		err = ch.QueueBind(qPayment.Name, key, paymentEventsExchange, false, nil)
+		failOnError(err, "Bind hatası")
+	}
+
+	// Manuel ack: event işlenmeden servis kapanırsa tekrar gelir (işleme idempotent)
+	paymentMsgs, err := ch.Consume(qPayment.Name, "", false, false, false, false, nil)
+	failOnError(err, "Consumer başlatılamadı")
+
+	// Outbox relay: order eventlerini publisher confirm ile RabbitMQ'ya taşır
+	outboxStore := outbox.NewGormStore(DB, outboxSource)
+	if adopted, err := outboxStore.AdoptLegacy(context.Background(), "order"); err != nil {
+		log.Printf("⚠️ Eski outbox mesajları devralınamadı: %s", err)
+	} else if adopted > 0 {
+		fmt.Printf("📦 %d eski outbox mesajı devralındı\n", adopted)
+	}
+	relay = outbox.NewRelay(outboxStore, outbox.NewAMQPPublisher(conn), outboxSource)
+	go relay.Run(context.Background())
+
+	// Çökme/yeniden başlatma sonrası yarıda kalan sipariş saga'larını sürdür
+	go resumeSagas()
+
+	// İptallerin yarıda kalan yan etkilerini (kupon, iade) tekrar dene
+	go resumeCancellations()
+
+	// Teslim alınan iadelerin yapılamayan para iadelerini tekrar dene
+	go resumeReturnRefunds()
+
+	// --- ARKA PLAN İŞÇİSİ: 3D SECURE SONUCU (payment_events.go) ---
+	go func() {
+		for d := range paymentMsgs {
+			if err := handlePaymentEvent(d.RoutingKey, d.Body); err != nil {
+				d.Nack(false, false) // Bozuk mesaj: tekrar denemenin anlamı yok
+				continue
+			}
+			d.Ack(false)
+		}
+	}()
+
+	// Doğrulaması PAYMENT_TIMEOUT içinde bitmeyen ödemeleri düşür
+	go expirePendingPayments()
+
+	// Günlük ödeme mutabakatı (önceki gün, RECONCILIATION_AT'ten sonra)
+	go scheduleReconciliation()
+
+	// Redis: token denylist + idempotency anahtarları
+	rdb := redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379")),
+	})
+
 	app := fiber.New()
 
+	// Prometheus (outbox_lag_seconds vb.)
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, Idempotency-Key",
 		AllowMethods: "GET, POST, HEAD, PUT, DELETE, PATCH, OPTIONS",
 	}))
 
 	// ==============================================================================
-	// HEALTH CHECK ENDPOINT
+	// HEALTH CHECK ENDPOINT'LERİ (/health, /livez, /readyz)
 	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		checks := make(map[string]interface{})
-		status := "healthy"
-
-		// PostgreSQL kontrolü
-		sqlDB, err := DB.DB()
-		if err != nil {
-			checks["postgres"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else if err := sqlDB.Ping(); err != nil {
-			checks["postgres"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else {
-			checks["postgres"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
+	// RabbitMQ kritik değil: eventler outbox'ta bekler, broker dönünce relay yayınlar
+	sqlDB, _ := DB.DB()
+	checker := health.NewHealthChecker("order-service")
+	checker.AddCheck("postgres", health.NewPostgresChecker(sqlDB))
+	checker.AddCheck("rabbitmq", health.NewRabbitMQChecker(conn), health.NonCritical())
+	checker.AddCheck("rabbitmq_channel", health.NewCloseWatcher(ch.NotifyClose(make(chan *amqp.Error, 1))), health.NonCritical())
+	// Redis yoksa Idempotency-Key'li istekler 503 alır, diğerleri çalışır
+	checker.AddCheck("redis", health.NewRedisChecker(rdb), health.NonCritical())
+	checker.Register(app)
 
-		// RabbitMQ kontrolü
-		if ch == nil {
-			checks["rabbitmq"] = map[string]string{"status": "unhealthy", "message": "channel is nil"}
-			status = "unhealthy"
-		} else {
-			checks["rabbitmq"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		statusCode := 200
-		if status != "healthy" {
-			statusCode = 503
-		}
+	// ==========================================================================
+	// JWT MIDDLEWARE - Health check hariç tüm endpoint'ler token ister
+	// ==========================================================================
+	/*
+	   🔐 Yetkiler (pkg/auth):
+	   - Sipariş oluşturma → orders:create (customer)
+	   - Tüm siparişler, istatistikler → orders:read_all (admin)
+	   - Durum güncelleme → orders:write_status (admin)
+	*/
+	app.Use(auth.New(auth.Config{
+		JWKSURL:  getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		Denylist: auth.NewRedisDenylist(rdb),
+	}))
 
-		return c.Status(statusCode).JSON(fiber.Map{
-			"status":    status,
-			"service":   "order-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks":    checks,
-		})
+	// Idempotency-Key: aynı sipariş isteği tekrar gelirse ikinci sipariş açılmaz.
+	// Anahtarlar kullanıcıya özeldir ve Redis'te tüm instance'lar arasında paylaşılır.
+	// Kilit süresi saga'nın en kötü süresinden (tüm servis çağrıları + retry) uzun olmalı.
+	orderIdempotency := idempotency.New(idempotency.Config{
+		Store: idempotency.NewRedisStore(rdb, "order"),
+		Scope: func(c *fiber.Ctx) string {
+			userID, _ := auth.UserID(c)
+			return strconv.FormatUint(uint64(userID), 10)
+		},
+		LockTTL: 5 * time.Minute,
 	})
 
 	// ==========================================================================
 	// ENDPOINT 1: SİPARİŞ OLUŞTUR (POST /orders)
 	// ==========================================================================
 	/*
-	   Bu endpoint en karmaşık olanı. Adım adım:
-
-	   1. Frontend'den veri al
-	   2. Stok kontrolü yap (Product Service'e sor)
-	   3. Ödeme al (Payment Service)
-	   4. Siparişi kaydet (Order + OrderItems)
-	   5. Stok düşür (RabbitMQ ile Product Service'e haber ver)
-
-	   💡 Transaction kullanmıyoruz ama production'da kullanmalısın!
-	      DB.Transaction(func(tx *gorm.DB) error { ... })
+	   Fiyatlar istemciden ALINMAZ: ürün fiyatları Product Service'ten, kupon
+	   indirimi Coupon Service'ten alınır ve toplam burada hesaplanır
+	   (pricing.go). Güncel toplam istemcinin gördüğünden farklıysa 409.
+
+	   Sipariş bir SAGA olarak oluşturulur (runOrderSaga, detaylar saga.go'da):
+
+	   1. Stok rezerve et (Product Service, stok anında ve atomik düşer)
+	   2. Kuponu ayır (Coupon Service, sadece kupon varsa)
+	   3. Ödeme provizyonu al (Payment Service)
+	   4. Siparişi ve ürünlerini TEK DB transaction'ında kaydet
+	   5. Stok rezervasyonunu onayla
+	   6. Kupon kullanımını onayla (sadece kupon varsa)
+	   7. Ödemeyi tahsil et (capture)
+	   8. Sipariş eventini outbox'a yaz (RabbitMQ), sipariş "paid" olur
+
+	   3D Secure: provizyon "pending" dönerse sipariş 4. adımda
+	   "pending_payment" olarak kaydedilir, saga "awaiting_payment" durumunda
+	   bekler ve istemciye 202 + doğrulama adresi döner. 5-8. adımlar ödeme
+	   eventi gelince çalışır (payment_events.go).
+
+	   Herhangi bir adım başarısız olursa önceki adımlar geri alınır
+	   (rezervasyon ve kupon bırakılır, provizyon iptal edilir / ödeme iade edilir).
+
+	   🔁 İstemci "Idempotency-Key" header'ı gönderirse, aynı anahtarla gelen
+	      tekrar istekler saga'yı yeniden çalıştırmaz, ilk yanıt döner.
 	*/
-	app.Post("/orders", func(c *fiber.Ctx) error {
-		productServiceURL := getEnv("PRODUCT_SERVICE_URL", "http://localhost:3001")
-		paymentServiceURL := getEnv("PAYMENT_SERVICE_URL", "http://localhost:3005")
-
+	app.Post("/orders", auth.RequirePermission(auth.PermOrdersCreate), orderIdempotency, func(c *fiber.Ctx) error {
 		req := new(CreateOrderRequest)
 		if err := c.BodyParser(req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Hatalı veri formatı"})
 		}
-
-		// 1. ADIM: STOK KONTROLÜ 🛑
-		stockCheckData := map[string]interface{}{
-			"items": req.Items,
+		if len(req.Items) == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Sipariş en az bir ürün içermeli"})
 		}
-		stockJSON, _ := json.Marshal(stockCheckData)
-
-		stockRes, err := http.Post(productServiceURL+"/products/validate", "application/json", bytes.NewBuffer(stockJSON))
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Ürün servisine ulaşılamadı"})
+		if req.PaymentToken == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Ödeme bilgisi eksik (payment_token)"})
 		}
-		defer stockRes.Body.Close()
-
-		// Stok yoksa işlemi durdur!
-		if stockRes.StatusCode != 200 {
-			var errBody map[string]interface{}
-			json.NewDecoder(stockRes.Body).Decode(&errBody)
-			return c.Status(400).JSON(errBody) // "Yetersiz Stok..." mesajını döner
+		for _, item := range req.Items {
+			if item.Quantity <= 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "Adet 0'dan büyük olmalı"})
+			}
 		}
 
-		// 2. ADIM: ÖDEME AL 💳
-		paymentData := map[string]interface{}{
-			"card_number": req.CardNumber,
-			"cvv":         req.CVV,
-			"expiry":      req.Expiry,
-			"amount":      req.TotalPrice,
-		}
-		paymentJSON, _ := json.Marshal(paymentData)
+		// 👤 Sipariş HER ZAMAN token sahibi adına oluşturulur (body'deki user_id'ye güvenilmez)
+		req.UserID, _ = auth.UserID(c)
 
-		paymentRes, err := http.Post(paymentServiceURL+"/pay", "application/json", bytes.NewBuffer(paymentJSON))
-		if err != nil || paymentRes.StatusCode != 200 {
-			return c.Status(400).JSON(fiber.Map{"error": "Ödeme reddedildi!"})
-		}
+		// 💰 Fiyatlar, indirim ve toplam sunucuda hesaplanır (pricing.go)
+		// İstemcinin total_price'ı sadece beklenen toplamdır.
+		err := priceOrder(req, c.Get(fiber.HeaderAuthorization))
 
-		// 3. ADIM: SİPARİŞİ KAYDET ✅
-		order := Order{
-			UserID:          req.UserID,
-			SubTotal:        req.SubTotal,
-			CouponCode:      req.CouponCode,
-			CouponDiscount:  req.CouponDiscount,
-			TotalPrice:      req.TotalPrice,
-			Status:          "Hazırlanıyor",
-			ShippingAddress: req.ShippingAddress,
+		var order *Order
+		if err == nil {
+			order, err = runOrderSaga(req)
 		}
 
-		// Önce ana siparişi kaydet (ID almak için)
-		if result := DB.Create(&order); result.Error != nil {
+		var changed *PriceChangedError
+		var rejected *SagaError
+		switch {
+		case errors.As(err, &changed):
+			return c.Status(409).JSON(fiber.Map{
+				"error":           "Fiyatlar değişti, lütfen sepetinizi kontrol edip tekrar deneyin",
+				"expected_total":  changed.Expected,
+				"sub_total":       req.SubTotal,
+				"coupon_discount": req.CouponDiscount,
+				"total_price":     changed.Actual,
+				"items":           req.Items,
+			})
+		case errors.As(err, &rejected):
+			return c.Status(rejected.Status).JSON(fiber.Map{"error": rejected.Message})
+		case err != nil:
 			return c.Status(500).JSON(fiber.Map{"error": "Sipariş kaydedilemedi"})
 		}
 
-		// 4. ADIM: SİPARİŞ ÜRÜNLERİNİ KAYDET 📦
-		/*
-		   Her ürün için OrderItem oluştur ve kaydet.
-		   Neden döngüde? Çünkü her ürünün detayını ayrı kaydetmemiz lazım.
-		*/
-		for _, item := range req.Items {
-			orderItem := OrderItem{
-				OrderID:      order.ID,
-				ProductID:    item.ProductID,
-				ProductName:  item.ProductName,
-				ProductImage: item.ProductImage,
-				UnitPrice:    item.UnitPrice,
-				Quantity:     item.Quantity,
-				SubTotal:     item.UnitPrice * float64(item.Quantity),
-			}
-			DB.Create(&orderItem)
-		}
-
-		// 5. ADIM: STOK DÜŞÜR (Event Gönder) 📢
-		eventItems := make([]struct {
-			ProductID int `json:"product_id"`
-			Quantity  int `json:"quantity"`
-		}, len(req.Items))
-
-		for i, item := range req.Items {
-			eventItems[i].ProductID = int(item.ProductID)
-			eventItems[i].Quantity = item.Quantity
-		}
-
-		eventData := OrderEvent{Items: eventItems}
-		messageBody, _ := json.Marshal(eventData)
-
-		ch.Publish("order_fanout", "", false, false, amqp.Publishing{
-			ContentType: "application/json",
-			Body:        messageBody,
-			Timestamp:   time.Now(),
-		})
-
 		fmt.Printf("✅ Sipariş oluşturuldu: #%d (Kupon: %s, İndirim: %.2f TL)\n",
 			order.ID, order.CouponCode, order.CouponDiscount)
 
+		if order.Status == StatusPendingPayment {
+			// 3D Secure: istemci payment_action_url'e yönlendirir, sipariş ödeme sonucunda "paid" olur
+			return c.Status(202).JSON(fiber.Map{
+				"message":            "Ödeme doğrulaması bekleniyor",
+				"order":              order,
+				"payment_action_url": order.PaymentActionURL,
+			})
+		}
+
 		return c.Status(201).JSON(fiber.Map{
 			"message": "Sipariş oluşturuldu",
 			"order":   order,
@@ -389,20 +496,15 @@ func main() {
 	// ==========================================================================
 	/*
 	   🔐 GÜVENLİK NOTU:
-	   Bu endpoint TÜM siparişleri döner. Production'da JWT role kontrolü gerekli.
-
-	   Bu endpoint TÜM siparişleri döner. Production'da:
-	   1. JWT'den role bilgisini al
-	   2. role == "admin" değilse 403 Forbidden dön
-
-	   Şimdilik basit tutuyoruz, ileride middleware ekleriz.
+	   Bu endpoint TÜM siparişleri döner. Sadece orders:read_all yetkisi
+	   olan (admin) kullanıcılar erişebilir, diğerleri 403 alır.
 
 	   💡 Preload("Items") ne yapar?
 	      - GORM'da "Eager Loading" (Hevesli Yükleme)
 	      - Order'ları çekerken, ilişkili OrderItem'ları da çeker
 	      - Tek sorguda tüm veriyi alır (N+1 problemini önler)
 	*/
-	app.Get("/orders", func(c *fiber.Ctx) error {
+	app.Get("/orders", auth.RequirePermission(auth.PermOrdersReadAll), func(c *fiber.Ctx) error {
 		var orders []Order
 		var totalItems int64
 
@@ -421,8 +523,9 @@ func main() {
 		// Base query oluştur (filtreler dahil)
 		baseQuery := DB.Model(&Order{})
 
-		// Durum filtresi: ?status=Hazırlanıyor
-		if status := c.Query("status"); status != "" {
+		// Durum filtresi: ?status=preparing
+		status, _ := parseOrderStatus(c.Query("status"))
+		if status != "" {
 			baseQuery = baseQuery.Where("status = ?", status)
 		}
 
@@ -438,7 +541,7 @@ func main() {
 		result := DB.Model(&Order{}).Preload("Items")
 
 		// Filtreleri tekrar uygula
-		if status := c.Query("status"); status != "" {
+		if status != "" {
 			result = result.Where("status = ?", status)
 		}
 		if userID := c.Query("user_id"); userID != "" {
@@ -478,7 +581,7 @@ func main() {
 
 	   Admin dashboard için istatistikler.
 	*/
-	app.Get("/orders/stats", func(c *fiber.Ctx) error {
+	app.Get("/orders/stats", auth.RequirePermission(auth.PermOrdersReadAll), func(c *fiber.Ctx) error {
 		var totalOrders int64
 		var totalRevenue float64
 		var totalDiscount float64
@@ -500,6 +603,12 @@ func main() {
 		})
 	})
 
+	// ==========================================================================
+	// ÖDEME MUTABAKATI - ADMIN (/orders/reconciliation/..., reconciliation.go)
+	// ==========================================================================
+	// 📌 /orders/:id'den ÖNCE: "reconciliation" bir ID olarak yorumlanmasın
+	registerReconciliationRoutes(app)
+
 	// ==========================================================================
 	// ENDPOINT 4: KULLANICININ SİPARİŞLERİ (GET /orders/user/:userid) - PAGİNATİON
 	// ==========================================================================
@@ -512,9 +621,12 @@ func main() {
 	   💡 Neden ayrı endpoint?
 	      - /orders/:id ile çakışmasın diye path farklı
 	      - Güvenlik: Kullanıcı sadece kendi siparişlerini görmeli
+	        (:userid token sahibi değilse 403, admin hariç)
+
+	   /orders/user/me → Token sahibinin siparişleri
 	*/
-	app.Get("/orders/user/:userid", func(c *fiber.Ctx) error {
-		userid := c.Params("userid")
+	app.Get("/orders/user/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userid := auth.ParamUserID(c, "userid")
 		var orders []Order
 		var totalItems int64
 
@@ -572,56 +684,136 @@ func main() {
 	   - First: Tek kayıt döner, yoksa hata verir
 
 	   Preload("Items"): Siparişteki ürünleri de getir
+	   Preload("History"): Durum geçmişi (eskiden yeniye)
+	   Preload("Returns.Items"), Preload("Refunds"): İade talepleri ve para iadeleri
+
+	   🔐 Sipariş sadece sahibine (veya admin'e) gösterilir.
 	*/
 	app.Get("/orders/:id", func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		var order Order
 
-		result := DB.Preload("Items").First(&order, id)
+		byID := func(db *gorm.DB) *gorm.DB { return db.Order("id") }
+		result := DB.Preload("Items").Preload("History", byID).
+			Preload("Returns", byID).Preload("Returns.Items").Preload("Refunds", byID).
+			First(&order, id)
 		if result.Error != nil {
 			return c.Status(404).JSON(fiber.Map{"error": "Sipariş bulunamadı"})
 		}
+		if !auth.IsSelfOrAdmin(c, order.UserID) {
+			return auth.Forbidden(c)
+		}
 
 		return c.JSON(order)
 	})
 
 	// ==========================================================================
-	// ENDPOINT 5: SİPARİŞ DURUMU GÜNCELLE - ADMIN (PATCH /orders/:id/status)
+	// ENDPOINT 6: SİPARİŞİ İPTAL ET - MÜŞTERİ (POST /orders/:id/cancel)
+	// ==========================================================================
+	/*
+	   Sipariş sahibi, sipariş kargoya verilmeden (pending / pending_payment /
+	   paid / preparing) iptal edebilir. Ödemesi bekleyen siparişin saga'sı
+	   telafi edilir: provizyon iptal edilir, para çekilmez.
+
+	   Geri alınanlar (detaylar cancellation.go'da):
+	   - Stok: "order.cancelled" eventi → Product Service rezervasyonu bırakır
+	   - Kupon: Coupon Service'te kullanım bırakılır
+	   - Ödeme: Payment Service'te iade edilir
+
+	   Tekrar çağrılırsa (çift tıklama, ağ hatası) aynı sonuç döner.
+	*/
+	app.Post("/orders/:id/cancel", func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Geçersiz sipariş ID"})
+		}
+
+		var existing Order
+		if err := DB.First(&existing, id).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Sipariş bulunamadı"})
+		}
+		if !auth.IsSelfOrAdmin(c, existing.UserID) {
+			return auth.Forbidden(c)
+		}
+
+		req := new(CancelOrderRequest)
+		c.BodyParser(req)
+
+		userID, _ := auth.UserID(c)
+		order, cancellation, err := cancelOrder(uint(id), actorFor("user", userID), req.Reason)
+		switch {
+		case errors.Is(err, errNotCancellable), errors.Is(err, errOrderInProgress):
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(500).JSON(fiber.Map{"error": "Sipariş iptal edilemedi"})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":      "Sipariş iptal edildi",
+			"order":        order,
+			"cancellation": cancellation,
+		})
+	})
+
+	// ==========================================================================
+	// ENDPOINT 7: SİPARİŞ DURUMU GÜNCELLE - ADMIN (PATCH /orders/:id/status)
 	// ==========================================================================
 	/*
 	   Admin panelinden sipariş durumunu günceller.
 
-	   Durumlar:
-	   - Hazırlanıyor: Sipariş alındı, paketleniyor
-	   - Kargolandı: Kargo firmasına teslim edildi
-	   - Teslim Edildi: Müşteriye ulaştı
-	   - İptal Edildi: Sipariş iptal edildi
+	   Durumlar ve izinli geçişler status.go'da:
+	   - paid → preparing → shipped → delivered
+	   - pending / pending_payment / paid / preparing → cancelled
 
-	   💡 SENIOR NOTU:
-	   Burada RabbitMQ'ya "order.status.changed" eventi atılabilir.
-	   Notification Service bu eventi dinleyip müşteriye email/SMS atabilir.
+	   "refunded" elle verilemez (400): parayı iade etmeyen bir durum değişikliği
+	   mutabakatta uyuşmazlık olurdu. Ödenmiş sipariş iptal ile, teslim edilmiş
+	   sipariş iade talebiyle (returns.go) geri alınır; tüm ürünler iade edilince
+	   sipariş kendiliğinden "refunded" olur.
 
-	   Örnek:
-	   ch.Publish("order_events", "order.status.changed", ...)
+	   İzin verilmeyen geçiş (ör. cancelled → shipped) 409 döner.
+	   Her geçiş order_status_histories'e yazılır ve "order.status.changed"
+	   eventi (order_events exchange'i) outbox üzerinden yayınlanır;
+	   Notification Service bunu dinleyip müşteriye email/SMS atabilir.
 	*/
-	app.Patch("/orders/:id/status", func(c *fiber.Ctx) error {
-		id := c.Params("id")
+	app.Patch("/orders/:id/status", auth.RequirePermission(auth.PermOrdersWriteStatus), func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Geçersiz sipariş ID"})
+		}
 
 		req := new(UpdateStatusRequest)
 		if err := c.BodyParser(req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Hatalı veri"})
 		}
-
-		var order Order
-		if err := DB.First(&order, id).Error; err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "Sipariş bulunamadı"})
+		status, ok := parseOrderStatus(req.Status)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"error": "Geçersiz sipariş durumu: " + req.Status})
+		}
+		if status == StatusRefunded {
+			return c.Status(400).JSON(fiber.Map{"error": "İade edildi durumu elle verilemez: siparişi iptal edin veya iade talebi oluşturun"})
 		}
 
-		oldStatus := order.Status
-		order.Status = req.Status
-		DB.Save(&order)
+		adminID, _ := auth.UserID(c)
+		actor := actorFor("admin", adminID)
+
+		// İptal, müşteri iptaliyle aynı yoldan geçer: stok, kupon ve ödeme geri alınır
+		var order *Order
+		if status == StatusCancelled {
+			order, _, err = cancelOrder(uint(id), actor, req.Reason)
+		} else {
+			order, err = setOrderStatus(uint(id), status, actor, req.Reason)
+		}
+		var invalid errInvalidTransition
+		switch {
+		case errors.Is(err, errOrderNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		case errors.As(err, &invalid), errors.Is(err, errNotCancellable), errors.Is(err, errOrderInProgress):
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(500).JSON(fiber.Map{"error": "Durum güncellenemedi"})
+		}
 
-		fmt.Printf("📦 Sipariş #%s durumu: %s → %s\n", id, oldStatus, req.Status)
+		fmt.Printf("📦 Sipariş #%d durumu: %s\n", id, order.Status)
 
 		return c.JSON(fiber.Map{
 			"message": "Durum güncellendi",
@@ -629,6 +821,102 @@ func main() {
 		})
 	})
 
+	// ==========================================================================
+	// ENDPOINT 8: İADE TALEBİ - MÜŞTERİ (POST /orders/:id/returns)
+	// ==========================================================================
+	/*
+	   Teslim edilen siparişin ürünleri (tamamı veya bir kısmı) iade edilebilir.
+	   Her ürün için iade edilecek adet, daha önceki (reddedilmemiş) taleplerle
+	   birlikte satın alınan adedi aşamaz.
+
+	   İade tutarı kupon payı düşülerek hesaplanır (detaylar returns.go'da).
+	*/
+	app.Post("/orders/:id/returns", func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Geçersiz sipariş ID"})
+		}
+
+		var existing Order
+		if err := DB.First(&existing, id).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Sipariş bulunamadı"})
+		}
+		if !auth.IsSelfOrAdmin(c, existing.UserID) {
+			return auth.Forbidden(c)
+		}
+
+		req := new(CreateReturnRequest)
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Hatalı veri"})
+		}
+
+		ret, err := createReturn(uint(id), existing.UserID, req)
+		var invalid errInvalidReturn
+		switch {
+		case errors.As(err, &invalid):
+			return c.Status(400).JSON(fiber.Map{"error": invalid.message})
+		case errors.Is(err, errNotReturnable):
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(500).JSON(fiber.Map{"error": "İade talebi oluşturulamadı"})
+		}
+
+		fmt.Printf("📮 İade talebi #%d: Sipariş #%d, %.2f TL\n", ret.ID, id, ret.RefundAmount)
+
+		return c.Status(201).JSON(fiber.Map{
+			"message": "İade talebi alındı",
+			"return":  ret,
+		})
+	})
+
+	// ==========================================================================
+	// ENDPOINT 9: İADE KARARI - ADMIN (POST /orders/:id/returns/:returnId/...)
+	// ==========================================================================
+	/*
+	   approve → Talep onaylandı, müşteri ürünü gönderebilir
+	   reject  → Talep reddedildi (adetler tekrar iade edilebilir hale gelir)
+	   receive → Ürünler depoya ulaştı: stok geri eklenir, para iade edilir
+
+	   Aynı karar tekrar gönderilirse aynı sonuç döner.
+	*/
+	reviewReturn := func(to string) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			id, err := c.ParamsInt("id")
+			if err != nil || id <= 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "Geçersiz sipariş ID"})
+			}
+			returnID, err := c.ParamsInt("returnId")
+			if err != nil || returnID <= 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "Geçersiz iade ID"})
+			}
+
+			req := new(ReviewReturnRequest)
+			c.BodyParser(req)
+
+			adminID, _ := auth.UserID(c)
+			ret, err := transitionReturn(uint(id), uint(returnID), to, actorFor("admin", adminID), req.Note)
+			var invalid errInvalidReturnTransition
+			switch {
+			case errors.Is(err, errReturnNotFound):
+				return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+			case errors.As(err, &invalid):
+				return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+			case err != nil:
+				return c.Status(500).JSON(fiber.Map{"error": "İade talebi güncellenemedi"})
+			}
+
+			fmt.Printf("📮 İade talebi #%d: %s\n", ret.ID, ret.Status)
+
+			return c.JSON(fiber.Map{
+				"message": "İade talebi güncellendi",
+				"return":  ret,
+			})
+		}
+	}
+	app.Post("/orders/:id/returns/:returnId/approve", auth.RequirePermission(auth.PermOrdersWriteStatus), reviewReturn(ReturnApproved))
+	app.Post("/orders/:id/returns/:returnId/reject", auth.RequirePermission(auth.PermOrdersWriteStatus), reviewReturn(ReturnRejected))
+	app.Post("/orders/:id/returns/:returnId/receive", auth.RequirePermission(auth.PermOrdersWriteStatus), reviewReturn(ReturnReceived))
+
 	log.Fatal(app.Listen(":3004"))
 }
 
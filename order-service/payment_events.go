@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ==============================================================================
+// ASENKRON ÖDEME SONUCU (payment_events)
+// ==============================================================================
+/*
+3D Secure gereken ödemede saga, sipariş "pending_payment" olarak kaydedildikten
+sonra "awaiting_payment" durumunda bekler (saga.go). Sonuç payment-service'in
+"payment_events" exchange'inden gelir:
+
+	payment.authorized → Saga devralınır, stok/kupon onayı + tahsilat → "paid"
+	payment.failed     → Sipariş "payment_failed", saga telafi edilir
+	                     (provizyon iptali, kupon ve stok bırakılır)
+
+PAYMENT_TIMEOUT (varsayılan 10m) içinde sonuç gelmeyen siparişler
+expirePendingPayments ile aynı şekilde "payment_failed" olur; provizyon iptal
+edildiği için müşteri doğrulamayı sonradan bitirse de para çekilmez.
+
+Event ile zaman aşımı (veya iki instance) aynı saga'yı işlemeye çalışabilir:
+saga koşullu UPDATE ile (status = awaiting_payment) devralınır, sadece biri
+kazanır. Tekrar gelen event saga artık beklemediği için yok sayılır.
+*/
+
+const (
+	paymentEventsExchange       = "payment_events"
+	paymentEventsQueue          = "order_payment_events"
+	paymentAuthorizedRoutingKey = "payment.authorized"
+	paymentFailedRoutingKey     = "payment.failed"
+	paymentExpiryInterval       = time.Minute
+)
+
+// PaymentEvent - payment-service'in "payment.<durum>" eventi (kullanılan alanlar)
+type PaymentEvent struct {
+	TransactionID  string `json:"transaction_id"`
+	Reference      string `json:"reference"` // Saga ID
+	Status         string `json:"status"`
+	FailureCode    string `json:"failure_code"`
+	FailureMessage string `json:"failure_message"`
+}
+
+var errInvalidPaymentEvent = errors.New("geçersiz ödeme eventi")
+
+// paymentTimeout - Ödeme doğrulaması için beklenecek süre
+// Stok rezervasyonunun süresinden (RESERVATION_TTL, 15m) kısa olmalı.
+func paymentTimeout() time.Duration {
+	timeout, err := time.ParseDuration(getEnv("PAYMENT_TIMEOUT", "10m"))
+	if err != nil || timeout <= 0 {
+		return 10 * time.Minute
+	}
+	return timeout
+}
+
+// claimAwaitingSaga - Ödeme bekleyen saga'yı "to" durumuna alarak devralır
+// Saga beklemiyorsa (senkron ödeme, zaten işlendi, süresi doldu) false döner.
+func claimAwaitingSaga(sagaID, to string) (*OrderSaga, bool) {
+	var saga OrderSaga
+	if err := DB.First(&saga, "id = ?", sagaID).Error; err != nil || saga.Status != SagaAwaitingPayment || saga.OrderID == nil {
+		return nil, false
+	}
+	claim := DB.Model(&OrderSaga{}).Where("id = ? AND status = ?", sagaID, SagaAwaitingPayment).Update("status", to)
+	if claim.Error != nil || claim.RowsAffected != 1 {
+		return nil, false
+	}
+	saga.Status = to
+	return &saga, true
+}
+
+// handlePaymentEvent - payment.authorized / payment.failed
+func handlePaymentEvent(routingKey string, body []byte) error {
+	var event PaymentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return errInvalidPaymentEvent
+	}
+	if event.Reference == "" {
+		return nil // Saga'sız ödeme (/pay)
+	}
+
+	switch routingKey {
+	case paymentAuthorizedRoutingKey:
+		saga, ok := claimAwaitingSaga(event.Reference, SagaRunning)
+		if !ok {
+			return nil
+		}
+		log.Printf("💳 Sipariş #%d ödemesi doğrulandı, saga devam ediyor", *saga.OrderID)
+		resumeSaga(saga) // Sipariş kaydedildi adımından: stok/kupon onayı, tahsilat, "paid"
+	case paymentFailedRoutingKey:
+		saga, ok := claimAwaitingSaga(event.Reference, SagaCompensating)
+		if !ok {
+			return nil
+		}
+		reason := "Ödeme doğrulanamadı"
+		if event.FailureMessage != "" {
+			reason += ": " + event.FailureMessage
+		}
+		failPendingPayment(saga, reason)
+	}
+	return nil
+}
+
+// failPendingPayment - Siparişi "payment_failed" yapar ve saga'yı telafi eder
+// Saga claimAwaitingSaga ile "compensating" olarak devralınmış olmalı: burada
+// çökülürse resumeSagas telafiyi tamamlar.
+func failPendingPayment(saga *OrderSaga, reason string) {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		_, err := transitionOrder(tx, *saga.OrderID, StatusPaymentFailed, ActorSystem, reason)
+		return err
+	})
+	var invalid errInvalidTransition
+	if err != nil && !errors.As(err, &invalid) {
+		log.Printf("❌ Sipariş #%d payment_failed yapılamadı: %s", *saga.OrderID, err)
+	}
+	relay.Notify()
+
+	log.Printf("💳 Sipariş #%d ödemesi alınamadı: %s", *saga.OrderID, reason)
+	compensate(saga, errors.New(reason))
+}
+
+// expirePendingPayments - PAYMENT_TIMEOUT içinde sonuçlanmayan ödemeleri düşürür
+func expirePendingPayments() {
+	for range time.Tick(paymentExpiryInterval) {
+		var sagas []OrderSaga
+		DB.Where("status = ? AND updated_at < ?", SagaAwaitingPayment, time.Now().Add(-paymentTimeout())).
+			Order("created_at").Find(&sagas)
+
+		for i := range sagas {
+			saga, ok := claimAwaitingSaga(sagas[i].ID, SagaCompensating)
+			if !ok {
+				continue // Bu arada ödeme eventi geldi
+			}
+			failPendingPayment(saga, "Ödeme süresi doldu")
+		}
+	}
+}
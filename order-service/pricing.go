@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ==============================================================================
+// FİYAT HESAPLAMA (Sunucu tarafı)
+// ==============================================================================
+/*
+Eski akış: unit_price, sub_total, coupon_discount ve total_price istemciden
+geldiği gibi kaydedilirdi → isteği elle düzenleyen biri her şeyi 1 TL'ye alabilirdi.
+
+Yeni akış: Sipariş güncel verilerden yeniden kurulur:
+
+	1. Ürün adı ve birim fiyatı  → Product Service (GET /products/:id)
+	2. Kupon geçerliliği/indirim → Coupon Service (POST /coupons/apply, kullanıcının token'ı ile)
+	3. Ara toplam, indirim ve toplam burada hesaplanır
+
+İstemcinin total_price'ı sadece "beklenen toplam"dır: ödeme ekranında
+gördüğü tutar. Güncel toplam bundan farklıysa (fiyat değişti, kupon artık
+geçersiz) sipariş 409 ile reddedilir ve güncel tutarlar döner; müşteri
+onaylamadığı bir tutarla ödeme yapmaz.
+
+product_image sadece görsel amaçlıdır (Product Service'te resim alanı yok),
+istemciden geldiği gibi saklanır.
+*/
+
+// priceTolerance - Kuruş yuvarlama farkları fiyat değişikliği sayılmaz
+const priceTolerance = 0.01
+
+// PriceChangedError - Beklenen toplam güncel toplamla uyuşmuyor
+type PriceChangedError struct {
+	Expected float64
+	Actual   float64
+}
+
+func (e *PriceChangedError) Error() string {
+	return fmt.Sprintf("Fiyatlar değişti: beklenen %.2f TL, güncel %.2f TL", e.Expected, e.Actual)
+}
+
+// productInfo - Product Service'in ürün yanıtından ihtiyacımız olan alanlar
+type productInfo struct {
+	ID    uint    `json:"ID"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// couponQuote - Coupon Service /coupons/apply yanıtı
+type couponQuote struct {
+	Valid    bool    `json:"valid"`
+	Message  string  `json:"message"`
+	Discount float64 `json:"discount"`
+	CouponID uint    `json:"coupon_id"`
+}
+
+// roundPrice - Kuruşa yuvarlar (float toplama hatalarını temizler)
+func roundPrice(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// priceOrder - req'in fiyat alanlarını güncel verilerle yeniden yazar
+// token: Kuponun kullanıcı adına doğrulanması için isteğin Authorization header'ı
+func priceOrder(req *CreateOrderRequest, token string) error {
+	productServiceURL := getEnv("PRODUCT_SERVICE_URL", "http://localhost:3001")
+	expected := req.TotalPrice
+
+	products := make(map[uint]productInfo)
+	subTotal := 0.0
+	for i := range req.Items {
+		item := &req.Items[i]
+
+		product, ok := products[item.ProductID]
+		if !ok {
+			status, err := callService(http.MethodGet, productServiceURL+"/products/"+strconv.FormatUint(uint64(item.ProductID), 10), nil, &product)
+			if err != nil {
+				return &SagaError{Status: 500, Message: "Ürün servisine ulaşılamadı"}
+			}
+			if status != 200 {
+				return &SagaError{Status: 400, Message: fmt.Sprintf("Ürün bulunamadı: ID %d", item.ProductID)}
+			}
+			products[item.ProductID] = product
+		}
+
+		item.ProductName = product.Name
+		item.UnitPrice = product.Price
+		subTotal += product.Price * float64(item.Quantity)
+	}
+	req.SubTotal = roundPrice(subTotal)
+
+	req.CouponDiscount = 0
+	if req.CouponCode != "" {
+		couponServiceURL := getEnv("COUPON_SERVICE_URL", "http://localhost:3010")
+
+		var quote couponQuote
+		status, err := callServiceAs(token, http.MethodPost, couponServiceURL+"/coupons/apply", map[string]any{
+			"code":        req.CouponCode,
+			"order_total": req.SubTotal,
+		}, &quote)
+		if err != nil || status != 200 {
+			return &SagaError{Status: 500, Message: "Kupon servisine ulaşılamadı"}
+		}
+		if !quote.Valid {
+			return &SagaError{Status: 400, Message: quote.Message} // "Bu kuponun süresi dolmuş" vb.
+		}
+		req.CouponCode = strings.ToUpper(req.CouponCode)
+		req.CouponDiscount = roundPrice(quote.Discount)
+	}
+
+	req.TotalPrice = roundPrice(req.SubTotal - req.CouponDiscount)
+	if math.Abs(expected-req.TotalPrice) >= priceTolerance {
+		return &PriceChangedError{Expected: expected, Actual: req.TotalPrice}
+	}
+	return nil
+}
@@ -0,0 +1,933 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ==============================================================================
+// ÖDEME MUTABAKATI (payment-service ↔ order-service)
+// ==============================================================================
+/*
+Tahsil edilen paranın siparişlerle tuttuğunu kontrol eden bir şey yoktu.
+Mutabakat bir tarih aralığındaki ödemeleri (payment-service GET /payments)
+siparişlerle karşılaştırır, uyuşmazlıkları reconciliation_issues tablosuna yazar:
+
+	Tür                     Anlamı
+	──────────────────────────────────────────────────────────────────────────────
+	payment_without_order   Para çekildi ama sipariş yok / sipariş ödenmemiş durumda
+	order_without_capture   Sipariş ödendi görünüyor ama tahsilat yok
+	amount_mismatch         Tahsil edilen tutar ≠ siparişin toplamı
+	refund_mismatch         İade edilen tutar ≠ beklenen (iptal/iade → tamamı,
+	                        diğerleri → order_refunds toplamı)
+
+Ödeme siparişe işlem ID'si (orders.transaction_id) ile eşlenir. Karşı kaydı
+aralığın dışında kalanlar (23:59'da alınan provizyon, 00:00'da kaydedilen
+sipariş) ayrıca çekilir; gün sınırı yanlış alarm üretmez. Bu ödemeler
+GET /payments?transaction_ids=... ile toplu (100'lük parçalar hâlinde) sorulur.
+
+Çalıştırma yolları:
+  - Zamanlanmış: her gün RECONCILIATION_AT'ten (varsayılan 02:00, boşsa kapalı)
+    sonra önceki gün için. run_key ("daily:2026-10-16") UNIQUE → birden fazla
+    instance varsa sadece biri çalıştırır; başarısız olursa 10 dakikada bir
+    tekrar denenir.
+  - Admin: POST /orders/reconciliation/runs {"from": "2026-10-01", "to": "2026-10-08"}
+  - Komut: ./main reconcile -from 2026-10-16 [-to 2026-10-17] [-out reports]
+
+Her çalıştırma raporu RECONCILIATION_REPORT_DIR'e (varsayılan "reports") CSV
+ve JSON olarak yazar; aynı rapor GET .../runs/:id/report?format=csv ile de
+indirilebilir.
+
+Uyuşmazlıklar çalıştırma başına kaydedilir. Aynı aralık tekrar çalıştırılınca
+önceki çalıştırmanın açık kayıtları "superseded" olur; admin'in çözüldü olarak
+işaretlediği uyuşmazlık (aynı tür + sipariş + işlem) tekrar bulunursa çözülmüş
+olarak gelir.
+
+Son RECONCILIATION_GRACE (varsayılan 30m) mutabakata alınmaz: saga'sı hâlâ
+çalışan sipariş (tahsilat sürüyor) yanlış alarm verirdi.
+*/
+
+const (
+	IssuePaymentWithoutOrder = "payment_without_order"
+	IssueOrderWithoutCapture = "order_without_capture"
+	IssueAmountMismatch      = "amount_mismatch"
+	IssueRefundMismatch      = "refund_mismatch"
+
+	IssueOpen       = "open"
+	IssueResolved   = "resolved"
+	IssueSuperseded = "superseded" // Aynı aralık tekrar çalıştırıldı
+
+	RunRunning   = "running"
+	RunCompleted = "completed"
+	RunFailed    = "failed"
+)
+
+const (
+	reconciliationMaxRange   = 31 * 24 * time.Hour
+	reconciliationRetryEvery = 10 * time.Minute
+	reconciliationStaleAfter = time.Hour // Bu süreden uzun "running" kalan çalıştırma yarıda kalmıştır
+	amountTolerance          = 0.005     // Yarım kuruş: float toplama hataları uyuşmazlık sayılmaz
+)
+
+var (
+	errInvalidRange  = errors.New("Geçersiz tarih aralığı (from/to: 2026-10-16 veya RFC3339)")
+	errRangeTooLong  = errors.New("Tarih aralığı en fazla 31 gün olabilir")
+	errRunNotFound   = errors.New("Mutabakat çalıştırması bulunamadı")
+	errIssueNotFound = errors.New("Uyuşmazlık bulunamadı")
+)
+
+// paidStatuses - Parası tahsil edilmiş olması gereken sipariş durumları
+var paidStatuses = map[string]bool{
+	StatusPaid:      true,
+	StatusPreparing: true,
+	StatusShipped:   true,
+	StatusDelivered: true,
+	StatusRefunded:  true,
+}
+
+// unpaidStatuses - Tahsilat OLMAMASI gereken durumlar (cancelled ikisi de olabilir)
+var unpaidStatuses = map[string]bool{
+	StatusPending:        true,
+	StatusPendingPayment: true,
+	StatusPaymentFailed:  true,
+}
+
+// ReconciliationRun - Bir mutabakat çalıştırması
+type ReconciliationRun struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	RunKey          string     `json:"-" gorm:"uniqueIndex:idx_reconciliation_run_key,where:run_key <> ''"` // Zamanlanmış: "daily:2026-10-16"
+	FromAt          time.Time  `json:"from"`
+	ToAt            time.Time  `json:"to"`      // Hariç
+	Trigger         string     `json:"trigger"` // "schedule", "cli", "admin:1"
+	Status          string     `json:"status" gorm:"index"`
+	PaymentsChecked int        `json:"payments_checked"`
+	OrdersChecked   int        `json:"orders_checked"`
+	IssueCount      int        `json:"issue_count"`
+	Error           string     `json:"error,omitempty"`
+	CreatedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+}
+
+// ReconciliationIssue - Ödeme ile sipariş arasındaki bir uyuşmazlık
+type ReconciliationIssue struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	RunID         uint       `json:"run_id" gorm:"index"`
+	Type          string     `json:"type" gorm:"index"`
+	Fingerprint   string     `json:"-" gorm:"index"` // tür:sipariş:işlem (çözüm bilgisi sonraki çalıştırmaya taşınır)
+	OrderID       *uint      `json:"order_id" gorm:"index"`
+	TransactionID string     `json:"transaction_id" gorm:"index"`
+	OrderStatus   string     `json:"order_status,omitempty"`
+	PaymentStatus string     `json:"payment_status,omitempty"`
+	Expected      float64    `json:"expected"` // Siparişe göre olması gereken tutar
+	Actual        float64    `json:"actual"`   // payment-service'teki tutar
+	Detail        string     `json:"detail"`
+	OccurredAt    time.Time  `json:"occurred_at"`         // Siparişin (yoksa ödemenin) tarihi
+	Status        string     `json:"status" gorm:"index"` // open | resolved | superseded
+	ResolvedBy    string     `json:"resolved_by,omitempty"`
+	Note          string     `json:"note,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// reconPayment - payment-service'teki ödeme (mutabakatta kullanılan alanlar)
+type reconPayment struct {
+	TransactionID  string    `json:"transaction_id"`
+	Reference      string    `json:"reference"`
+	OrderID        *uint     `json:"order_id"`
+	Amount         float64   `json:"amount"`
+	CapturedAmount float64   `json:"captured_amount"`
+	RefundedAmount float64   `json:"refunded_amount"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// net - Müşteriden çekilip iade edilmemiş tutar
+func (p *reconPayment) net() float64 { return p.CapturedAmount - p.RefundedAmount }
+
+func amountsDiffer(a, b float64) bool { return math.Abs(a-b) > amountTolerance }
+
+// reconciliationGrace - Henüz mutabakata alınmayacak son süre
+func reconciliationGrace() time.Duration {
+	grace, err := time.ParseDuration(getEnv("RECONCILIATION_GRACE", "30m"))
+	if err != nil || grace < 0 {
+		return 30 * time.Minute
+	}
+	return grace
+}
+
+// ==============================================================================
+// KARŞILAŞTIRMA
+// ==============================================================================
+
+// reconcile - Siparişleri ödemelerle karşılaştırır (DB'ye ve servislere dokunmaz)
+// refunds: sipariş başına order_refunds toplamı (ürün iadeleri).
+func reconcile(orders []Order, payments []reconPayment, refunds map[uint]float64) []ReconciliationIssue {
+	byTxn := make(map[string]*Order, len(orders))
+	byID := make(map[uint]*Order, len(orders))
+	for i := range orders {
+		byID[orders[i].ID] = &orders[i]
+		if orders[i].TransactionID != "" {
+			byTxn[orders[i].TransactionID] = &orders[i]
+		}
+	}
+
+	var issues []ReconciliationIssue
+	paymentOf := make(map[uint]*reconPayment, len(payments))
+	for i := range payments {
+		p := &payments[i]
+		if order := byTxn[p.TransactionID]; order != nil {
+			paymentOf[order.ID] = p
+			continue
+		}
+		if p.net() <= amountTolerance {
+			continue // Tahsil edilmemiş (veya tamamı iade edilmiş) ödeme: ortada para yok
+		}
+
+		issue := newIssue(IssuePaymentWithoutOrder, nil, p, 0, p.net())
+		issue.Detail = "Ödeme tahsil edilmiş ama siparişi yok"
+		if p.OrderID != nil {
+			if other := byID[*p.OrderID]; other != nil {
+				// Sipariş başka bir işleme bağlı: aynı sipariş için ikinci tahsilat
+				issue.OrderID, issue.OrderStatus, issue.OccurredAt = &other.ID, other.Status, other.CreatedAt
+				issue.Detail = fmt.Sprintf("Sipariş #%d başka bir ödemeye bağlı (%s): çift tahsilat", other.ID, other.TransactionID)
+			} else {
+				issue.Detail = fmt.Sprintf("Ödeme sipariş #%d'e bağlı ama sipariş bulunamadı", *p.OrderID)
+			}
+		}
+		issues = append(issues, issue)
+	}
+
+	for i := range orders {
+		o := &orders[i]
+		p := paymentOf[o.ID]
+
+		if p == nil || p.CapturedAmount <= amountTolerance {
+			if paidStatuses[o.Status] {
+				issue := newIssue(IssueOrderWithoutCapture, o, p, o.TotalPrice, 0)
+				issue.Detail = "Sipariş ödendi görünüyor ama ödeme kaydı yok"
+				if p != nil {
+					issue.Detail = fmt.Sprintf("Sipariş ödendi görünüyor ama ödeme %s durumunda", p.Status)
+				}
+				issues = append(issues, issue)
+			}
+			continue
+		}
+
+		if unpaidStatuses[o.Status] {
+			if p.net() > amountTolerance {
+				issue := newIssue(IssuePaymentWithoutOrder, o, p, 0, p.net())
+				issue.Detail = fmt.Sprintf("Ödeme tahsil edilmiş ama sipariş %s durumunda", o.Status)
+				issues = append(issues, issue)
+			}
+			continue
+		}
+
+		if amountsDiffer(p.CapturedAmount, o.TotalPrice) {
+			issue := newIssue(IssueAmountMismatch, o, p, o.TotalPrice, p.CapturedAmount)
+			issue.Detail = fmt.Sprintf("Tahsil edilen %.2f, sipariş toplamı %.2f", p.CapturedAmount, o.TotalPrice)
+			issues = append(issues, issue)
+		}
+
+		expectedRefund := refunds[o.ID]
+		if o.Status == StatusCancelled || o.Status == StatusRefunded {
+			expectedRefund = p.CapturedAmount // Para tamamen geri verilmiş olmalı
+		}
+		if amountsDiffer(p.RefundedAmount, expectedRefund) {
+			issue := newIssue(IssueRefundMismatch, o, p, expectedRefund, p.RefundedAmount)
+			issue.Detail = fmt.Sprintf("İade edilen %.2f, beklenen %.2f (sipariş %s)", p.RefundedAmount, expectedRefund, o.Status)
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// newIssue - Sipariş ve ödemenin ortak alanlarıyla uyuşmazlık kaydı
+func newIssue(typ string, o *Order, p *reconPayment, expected, actual float64) ReconciliationIssue {
+	issue := ReconciliationIssue{Type: typ, Expected: roundPrice(expected), Actual: roundPrice(actual), Status: IssueOpen}
+	if p != nil {
+		issue.TransactionID, issue.PaymentStatus, issue.OccurredAt = p.TransactionID, p.Status, p.CreatedAt
+	}
+	if o != nil {
+		id := o.ID
+		issue.OrderID, issue.OrderStatus, issue.OccurredAt = &id, o.Status, o.CreatedAt
+		if issue.TransactionID == "" {
+			issue.TransactionID = o.TransactionID
+		}
+	}
+	return issue
+}
+
+// fingerprint - Aynı uyuşmazlığı çalıştırmalar arasında tanımak için
+func (i *ReconciliationIssue) fingerprint() string {
+	orderID := ""
+	if i.OrderID != nil {
+		orderID = strconv.FormatUint(uint64(*i.OrderID), 10)
+	}
+	return i.Type + ":" + orderID + ":" + i.TransactionID
+}
+
+// ==============================================================================
+// VERİ YÜKLEME
+// ==============================================================================
+
+// loadReconciliation - [from, to) aralığındaki siparişleri ve ödemeleri,
+// aralık dışında kalan karşılıklarıyla birlikte yükler
+func loadReconciliation(from, to time.Time) ([]Order, []reconPayment, map[uint]float64, error) {
+	payments, err := fetchPayments(from, to)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var orders []Order
+	if err := DB.Where("created_at >= ? AND created_at < ?", from, to).Order("id").Find(&orders).Error; err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Ödemesi aralıkta, siparişi aralık dışında (veya başka işleme bağlı) olanlar
+	knownOrder := make(map[uint]bool, len(orders))
+	knownTxn := make(map[string]bool, len(orders))
+	for _, o := range orders {
+		knownOrder[o.ID], knownTxn[o.TransactionID] = true, true
+	}
+	var missingIDs []uint
+	var missingTxns []string
+	for _, p := range payments {
+		if knownTxn[p.TransactionID] {
+			continue
+		}
+		missingTxns = append(missingTxns, p.TransactionID)
+		if p.OrderID != nil && !knownOrder[*p.OrderID] {
+			missingIDs = append(missingIDs, *p.OrderID)
+		}
+	}
+	extra, err := ordersIn("transaction_id", missingTxns)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	byID, err := ordersIn("id", missingIDs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, o := range append(extra, byID...) {
+		if !knownOrder[o.ID] {
+			knownOrder[o.ID] = true
+			orders = append(orders, o)
+		}
+	}
+
+	// Siparişi aralıkta, ödemesi aralık dışında olanlar (toplu çekilir)
+	fetched := make(map[string]bool, len(payments))
+	for _, p := range payments {
+		fetched[p.TransactionID] = true
+	}
+	var outside []string
+	for _, o := range orders {
+		if o.TransactionID != "" && !fetched[o.TransactionID] {
+			fetched[o.TransactionID] = true
+			outside = append(outside, o.TransactionID)
+		}
+	}
+	more, err := fetchPaymentsByID(outside)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	payments = append(payments, more...)
+
+	ids := make([]uint, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+	refunds, err := refundTotals(ids)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return orders, payments, refunds, nil
+}
+
+// reconQueryChunk - IN listelerinin parça boyutu (PostgreSQL parametre limiti)
+const reconQueryChunk = 1000
+
+// ordersIn - column IN values siparişleri, parça parça
+func ordersIn[T any](column string, values []T) ([]Order, error) {
+	var orders []Order
+	for chunk := range slices.Chunk(values, reconQueryChunk) {
+		var found []Order
+		if err := DB.Where(column+" IN ?", chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		orders = append(orders, found...)
+	}
+	return orders, nil
+}
+
+// refundTotals - Sipariş başına ürün iadesi toplamları (order_refunds)
+func refundTotals(orderIDs []uint) (map[uint]float64, error) {
+	totals := make(map[uint]float64)
+	for chunk := range slices.Chunk(orderIDs, reconQueryChunk) {
+		var rows []struct {
+			OrderID uint
+			Total   float64
+		}
+		err := DB.Model(&OrderRefund{}).Select("order_id, COALESCE(SUM(amount), 0) AS total").
+			Where("order_id IN ?", chunk).
+			Group("order_id").Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			totals[r.OrderID] = r.Total
+		}
+	}
+	return totals, nil
+}
+
+// fetchPayments - payment-service'ten aralıktaki tüm ödemeler (sayfa sayfa)
+func fetchPayments(from, to time.Time) ([]reconPayment, error) {
+	paymentServiceURL := getEnv("PAYMENT_SERVICE_URL", "http://localhost:3005")
+
+	var payments []reconPayment
+	for page := 1; ; page++ {
+		query := url.Values{
+			"from":  {from.Format(time.RFC3339)},
+			"to":    {to.Format(time.RFC3339)},
+			"page":  {strconv.Itoa(page)},
+			"limit": {"500"},
+		}
+		var body struct {
+			Payments []reconPayment `json:"payments"`
+			HasNext  bool           `json:"has_next"`
+		}
+		status, err := callService(http.MethodGet, paymentServiceURL+"/payments?"+query.Encode(), nil, &body)
+		if err != nil || status != 200 {
+			return nil, fmt.Errorf("ödemeler çekilemedi: status %d, %v", status, err)
+		}
+		payments = append(payments, body.Payments...)
+		if !body.HasNext {
+			return payments, nil
+		}
+	}
+}
+
+// paymentLookupChunk - Tek istekte sorulan işlem sayısı (URL uzunluğu sınırlı kalsın)
+const paymentLookupChunk = 100
+
+// fetchPaymentsByID - İşlem ID'leriyle ödemeler, parça başına tek istek
+// payment-service'te kaydı olmayan işlemler sonuçta yer almaz.
+func fetchPaymentsByID(transactionIDs []string) ([]reconPayment, error) {
+	paymentServiceURL := getEnv("PAYMENT_SERVICE_URL", "http://localhost:3005")
+
+	var payments []reconPayment
+	for chunk := range slices.Chunk(transactionIDs, paymentLookupChunk) {
+		query := url.Values{"transaction_ids": {strings.Join(chunk, ",")}}
+		var body struct {
+			Payments []reconPayment `json:"payments"`
+		}
+		status, err := callService(http.MethodGet, paymentServiceURL+"/payments?"+query.Encode(), nil, &body)
+		if err != nil || status != 200 {
+			return nil, fmt.Errorf("ödemeler çekilemedi (%d işlem): status %d, %v", len(chunk), status, err)
+		}
+		payments = append(payments, body.Payments...)
+	}
+	return payments, nil
+}
+
+// ==============================================================================
+// ÇALIŞTIRMA
+// ==============================================================================
+
+// parseReconciliationTime - "2026-10-16" (yerel gün başı) veya RFC3339
+func parseReconciliationTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// reconciliationRange - [from, to) aralığını doğrular; to boşsa from + 1 gün
+// Bitiş, son RECONCILIATION_GRACE'in öncesine çekilir.
+func reconciliationRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	from, err := parseReconciliationTime(fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, errInvalidRange
+	}
+	to := from.AddDate(0, 0, 1)
+	if toStr != "" {
+		if to, err = parseReconciliationTime(toStr); err != nil {
+			return time.Time{}, time.Time{}, errInvalidRange
+		}
+	}
+	if settled := time.Now().Add(-reconciliationGrace()).Truncate(time.Minute); to.After(settled) {
+		to = settled
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errInvalidRange
+	}
+	if to.Sub(from) > reconciliationMaxRange {
+		return time.Time{}, time.Time{}, errRangeTooLong
+	}
+	return from, to, nil
+}
+
+// runReconciliation - Çalıştırmayı yürütür, sonucu kaydeder ve raporu reportDir'e yazar
+// (reportDir boşsa dosya yazılmaz). Yazılan dosyaların yollarını döner.
+func runReconciliation(run *ReconciliationRun, reportDir string) ([]ReconciliationIssue, []string, error) {
+	orders, payments, refunds, err := loadReconciliation(run.FromAt, run.ToAt)
+	if err != nil {
+		failRun(run, err)
+		return nil, nil, err
+	}
+	issues := reconcile(orders, payments, refunds)
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		fingerprints := make([]string, len(issues))
+		for i := range issues {
+			issues[i].RunID = run.ID
+			issues[i].Fingerprint = issues[i].fingerprint()
+			fingerprints[i] = issues[i].Fingerprint
+		}
+
+		// Admin'in önceden çözdüğü uyuşmazlık çözülmüş olarak gelir (en son çözüm)
+		if len(issues) > 0 {
+			var resolved []ReconciliationIssue
+			if err := tx.Where("fingerprint IN ? AND status = ?", fingerprints, IssueResolved).Order("id").Find(&resolved).Error; err != nil {
+				return err
+			}
+			previous := make(map[string]ReconciliationIssue, len(resolved))
+			for _, r := range resolved {
+				previous[r.Fingerprint] = r
+			}
+			for i := range issues {
+				if r, ok := previous[issues[i].Fingerprint]; ok {
+					issues[i].Status, issues[i].ResolvedBy, issues[i].Note, issues[i].ResolvedAt = IssueResolved, r.ResolvedBy, r.Note, r.ResolvedAt
+				}
+			}
+			if err := tx.CreateInBatches(issues, 200).Error; err != nil {
+				return err
+			}
+		}
+
+		// Bu aralığın içinde kalan önceki çalıştırmaların açık kayıtları artık geçersiz
+		covered := tx.Model(&ReconciliationRun{}).Select("id").
+			Where("id <> ? AND from_at >= ? AND to_at <= ?", run.ID, run.FromAt, run.ToAt)
+		if err := tx.Model(&ReconciliationIssue{}).Where("status = ? AND run_id IN (?)", IssueOpen, covered).
+			Update("status", IssueSuperseded).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		run.Status, run.FinishedAt = RunCompleted, &now
+		run.PaymentsChecked, run.OrdersChecked, run.IssueCount = len(payments), len(orders), len(issues)
+		return tx.Model(run).Updates(map[string]any{
+			"status":           run.Status,
+			"payments_checked": run.PaymentsChecked,
+			"orders_checked":   run.OrdersChecked,
+			"issue_count":      run.IssueCount,
+			"finished_at":      now,
+		}).Error
+	})
+	if err != nil {
+		failRun(run, err)
+		return nil, nil, err
+	}
+
+	var files []string
+	if reportDir != "" {
+		if files, err = writeReportFiles(reportDir, run, issues); err != nil {
+			// Sonuç tabloda; rapor API'den de indirilebilir
+			log.Printf("⚠️ Mutabakat raporu yazılamadı (#%d): %s", run.ID, err)
+		}
+	}
+	log.Printf("🧾 Mutabakat #%d tamamlandı (%s → %s): %d ödeme, %d sipariş, %d uyuşmazlık",
+		run.ID, run.FromAt.Format(time.DateTime), run.ToAt.Format(time.DateTime), run.PaymentsChecked, run.OrdersChecked, run.IssueCount)
+	return issues, files, nil
+}
+
+// failRun - Çalıştırmayı başarısız işaretler
+// run_key bırakılır: zamanlanmış çalıştırma sonraki turda tekrar denenir.
+func failRun(run *ReconciliationRun, cause error) {
+	now := time.Now()
+	run.Status, run.Error, run.FinishedAt, run.RunKey = RunFailed, cause.Error(), &now, ""
+	DB.Model(run).Updates(map[string]any{"status": RunFailed, "error": run.Error, "finished_at": now, "run_key": ""})
+	log.Printf("❌ Mutabakat #%d başarısız: %s", run.ID, cause)
+}
+
+// reportDir - Raporların yazılacağı klasör (boşsa dosya yazılmaz)
+func reportDir() string {
+	return getEnv("RECONCILIATION_REPORT_DIR", "reports")
+}
+
+// scheduleReconciliation - Her gün RECONCILIATION_AT'ten sonra önceki günün mutabakatı
+func scheduleReconciliation() {
+	at := getEnv("RECONCILIATION_AT", "02:00")
+	if at == "" {
+		fmt.Println("ℹ️ Zamanlanmış mutabakat kapalı (RECONCILIATION_AT boş)")
+		return
+	}
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		log.Printf("❌ RECONCILIATION_AT geçersiz (%q), zamanlanmış mutabakat kapalı", at)
+		return
+	}
+
+	for range time.Tick(reconciliationRetryEvery) {
+		// Çöken instance'ın yarıda bıraktığı çalıştırma bu turda tekrar alınabilsin
+		DB.Model(&ReconciliationRun{}).
+			Where("status = ? AND created_at < ?", RunRunning, time.Now().Add(-reconciliationStaleAfter)).
+			Updates(map[string]any{"status": RunFailed, "error": "Çalıştırma yarıda kaldı", "run_key": ""})
+
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		if now.Before(today.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)) {
+			continue
+		}
+
+		yesterday := today.AddDate(0, 0, -1)
+		key := "daily:" + yesterday.Format(time.DateOnly)
+		var existing int64
+		if DB.Model(&ReconciliationRun{}).Where("run_key = ?", key).Count(&existing); existing > 0 {
+			continue // Bu gün için çalıştırma var (tamamlandı veya başka instance'ta sürüyor)
+		}
+		run := &ReconciliationRun{
+			RunKey:  key,
+			FromAt:  yesterday,
+			ToAt:    today,
+			Trigger: "schedule",
+			Status:  RunRunning,
+		}
+		// UNIQUE run_key: aynı anda başka instance oluşturduysa o çalıştırır
+		if DB.Create(run).Error != nil {
+			continue
+		}
+		runReconciliation(run, reportDir())
+	}
+}
+
+// runReconcileCommand - ./main reconcile [-from 2026-10-16] [-to 2026-10-17] [-out reports]
+// Sunucu başlatılmaz: sadece veritabanına ve payment-service'e bağlanır. Çıkış kodu:
+// 0 başarılı (uyuşmazlık olsa da), 1 çalıştırma hatası, 2 hatalı parametre.
+func runReconcileCommand(args []string) int {
+	now := time.Now()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.Local)
+
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	from := flags.String("from", yesterday.Format(time.DateOnly), "Başlangıç (dahil): 2026-10-16 veya RFC3339")
+	to := flags.String("to", "", "Bitiş (hariç), boşsa from + 1 gün")
+	out := flags.String("out", reportDir(), "Raporların yazılacağı klasör")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	fromAt, toAt, err := reconciliationRange(*from, *to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌", err)
+		return 2
+	}
+
+	initDatabase()
+	run := &ReconciliationRun{FromAt: fromAt, ToAt: toAt, Trigger: "cli", Status: RunRunning}
+	if err := DB.Create(run).Error; err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Mutabakat başlatılamadı:", err)
+		return 1
+	}
+	issues, files, err := runReconciliation(run, *out)
+	if err != nil {
+		return 1
+	}
+
+	counts := countIssues(issues)
+	for _, typ := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Printf("   %-22s %d\n", typ, counts[typ])
+	}
+	for _, file := range files {
+		fmt.Println("📄", file)
+	}
+	return 0
+}
+
+// countIssues - Türe göre uyuşmazlık sayıları
+func countIssues(issues []ReconciliationIssue) map[string]int {
+	counts := make(map[string]int)
+	for _, issue := range issues {
+		counts[issue.Type]++
+	}
+	return counts
+}
+
+// ==============================================================================
+// RAPOR (CSV / JSON)
+// ==============================================================================
+
+// ReconciliationReport - JSON raporu
+type ReconciliationReport struct {
+	Run     *ReconciliationRun    `json:"run"`
+	Summary map[string]int        `json:"summary"` // Türe göre sayılar
+	Issues  []ReconciliationIssue `json:"issues"`
+}
+
+var reportCSVHeader = []string{
+	"id", "type", "status", "order_id", "transaction_id", "order_status", "payment_status",
+	"expected", "actual", "difference", "detail", "occurred_at",
+}
+
+func writeReportCSV(w io.Writer, issues []ReconciliationIssue) error {
+	out := csv.NewWriter(w)
+	out.Write(reportCSVHeader)
+	for _, issue := range issues {
+		orderID := ""
+		if issue.OrderID != nil {
+			orderID = strconv.FormatUint(uint64(*issue.OrderID), 10)
+		}
+		out.Write([]string{
+			strconv.FormatUint(uint64(issue.ID), 10),
+			issue.Type,
+			issue.Status,
+			orderID,
+			issue.TransactionID,
+			issue.OrderStatus,
+			issue.PaymentStatus,
+			strconv.FormatFloat(issue.Expected, 'f', 2, 64),
+			strconv.FormatFloat(issue.Actual, 'f', 2, 64),
+			strconv.FormatFloat(issue.Actual-issue.Expected, 'f', 2, 64),
+			issue.Detail,
+			issue.OccurredAt.Format(time.RFC3339),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeReportJSON(w io.Writer, run *ReconciliationRun, issues []ReconciliationIssue) error {
+	if issues == nil {
+		issues = []ReconciliationIssue{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ReconciliationReport{Run: run, Summary: countIssues(issues), Issues: issues})
+}
+
+// reportName - "reconciliation_20261016-0000_20261017-0000_run12"
+func reportName(run *ReconciliationRun) string {
+	return fmt.Sprintf("reconciliation_%s_%s_run%d",
+		run.FromAt.Format("20060102-1504"), run.ToAt.Format("20060102-1504"), run.ID)
+}
+
+// writeReportFiles - Raporu dir'e CSV ve JSON olarak yazar
+func writeReportFiles(dir string, run *ReconciliationRun, issues []ReconciliationIssue) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, reportName(run))
+
+	var csvBuf, jsonBuf bytes.Buffer
+	if err := writeReportCSV(&csvBuf, issues); err != nil {
+		return nil, err
+	}
+	if err := writeReportJSON(&jsonBuf, run, issues); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(base+".csv", csvBuf.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(base+".json", jsonBuf.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	return []string{base + ".csv", base + ".json"}, nil
+}
+
+// ==============================================================================
+// ADMIN ENDPOINT'LERİ
+// ==============================================================================
+
+// registerReconciliationRoutes - /orders/reconciliation/... (admin)
+//
+//	POST  /orders/reconciliation/runs             → Aralık için çalıştır (arka planda, 202)
+//	GET   /orders/reconciliation/runs             → Çalıştırmalar (yeniden eskiye)
+//	GET   /orders/reconciliation/runs/:id         → Çalıştırma + türe göre sayılar
+//	GET   /orders/reconciliation/runs/:id/report  → ?format=csv|json
+//	GET   /orders/reconciliation/issues           → ?status=open&type=&run_id=&order_id=&page=&limit=
+//	PATCH /orders/reconciliation/issues/:id       → {"status": "resolved", "note": "..."}
+func registerReconciliationRoutes(app fiber.Router) {
+	read := auth.RequirePermission(auth.PermOrdersReadAll)
+	write := auth.RequirePermission(auth.PermOrdersWriteStatus)
+
+	app.Post("/orders/reconciliation/runs", write, func(c *fiber.Ctx) error {
+		var req struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Hatalı veri formatı"})
+		}
+		from, to, err := reconciliationRange(req.From, req.To)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		adminID, _ := auth.UserID(c)
+		run := &ReconciliationRun{FromAt: from, ToAt: to, Trigger: actorFor("admin", adminID), Status: RunRunning}
+		if err := DB.Create(run).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Mutabakat başlatılamadı"})
+		}
+		// Büyük aralıkta gateway timeout'unu aşabilir: sonuç GET .../runs/:id ile izlenir
+		go runReconciliation(run, reportDir())
+		return c.Status(fiber.StatusAccepted).JSON(run)
+	})
+
+	app.Get("/orders/reconciliation/runs", read, func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", 20)
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		var runs []ReconciliationRun
+		if err := DB.Order("id desc").Limit(limit).Find(&runs).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Mutabakat çalıştırmaları çekilemedi"})
+		}
+		return c.JSON(fiber.Map{"runs": runs})
+	})
+
+	app.Get("/orders/reconciliation/runs/:id", read, func(c *fiber.Ctx) error {
+		run, err := findRun(c.Params("id"))
+		if err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		var rows []struct {
+			Type  string
+			Count int
+		}
+		DB.Model(&ReconciliationIssue{}).Select("type, COUNT(*) AS count").
+			Where("run_id = ?", run.ID).Group("type").Scan(&rows)
+		summary := make(map[string]int, len(rows))
+		for _, r := range rows {
+			summary[r.Type] = r.Count
+		}
+		return c.JSON(fiber.Map{"run": run, "summary": summary})
+	})
+
+	app.Get("/orders/reconciliation/runs/:id/report", read, func(c *fiber.Ctx) error {
+		run, err := findRun(c.Params("id"))
+		if err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		var issues []ReconciliationIssue
+		if err := DB.Where("run_id = ?", run.ID).Order("id").Find(&issues).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Rapor oluşturulamadı"})
+		}
+
+		var buf bytes.Buffer
+		format := c.Query("format", "json")
+		switch format {
+		case "csv":
+			err = writeReportCSV(&buf, issues)
+		case "json":
+			err = writeReportJSON(&buf, run, issues)
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "format csv veya json olmalı"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Rapor oluşturulamadı"})
+		}
+		c.Attachment(reportName(run) + "." + format)
+		return c.Send(buf.Bytes())
+	})
+
+	app.Get("/orders/reconciliation/issues", read, func(c *fiber.Ctx) error {
+		page := c.QueryInt("page", 1)
+		limit := c.QueryInt("limit", 50)
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 200 {
+			limit = 50
+		}
+
+		query := DB.Model(&ReconciliationIssue{})
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if typ := c.Query("type"); typ != "" {
+			query = query.Where("type = ?", typ)
+		}
+		if runID := c.QueryInt("run_id"); runID > 0 {
+			query = query.Where("run_id = ?", runID)
+		}
+		if orderID := c.QueryInt("order_id"); orderID > 0 {
+			query = query.Where("order_id = ?", orderID)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var issues []ReconciliationIssue
+		if err := query.Order("occurred_at desc, id desc").Offset((page - 1) * limit).Limit(limit).Find(&issues).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Uyuşmazlıklar çekilemedi"})
+		}
+
+		totalPages := (total + int64(limit) - 1) / int64(limit)
+		return c.JSON(fiber.Map{
+			"issues": issues,
+			"pagination": fiber.Map{
+				"current_page": page,
+				"per_page":     limit,
+				"total_items":  total,
+				"total_pages":  totalPages,
+				"has_next":     int64(page) < totalPages,
+				"has_prev":     page > 1,
+			},
+		})
+	})
+
+	app.Patch("/orders/reconciliation/issues/:id", write, func(c *fiber.Ctx) error {
+		var req struct {
+			Status string `json:"status"`
+			Note   string `json:"note"`
+		}
+		if err := c.BodyParser(&req); err != nil || (req.Status != IssueResolved && req.Status != IssueOpen) {
+			return c.Status(400).JSON(fiber.Map{"error": "Durum resolved veya open olmalı"})
+		}
+
+		var issue ReconciliationIssue
+		if err := DB.First(&issue, "id = ?", c.Params("id")).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": errIssueNotFound.Error()})
+		}
+
+		updates := map[string]any{"status": req.Status, "note": req.Note, "resolved_by": "", "resolved_at": nil}
+		if req.Status == IssueResolved {
+			adminID, _ := auth.UserID(c)
+			updates["resolved_by"], updates["resolved_at"] = actorFor("admin", adminID), time.Now()
+		}
+		if err := DB.Model(&issue).Updates(updates).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Uyuşmazlık güncellenemedi"})
+		}
+		DB.First(&issue, issue.ID)
+		return c.JSON(issue)
+	})
+}
+
+// findRun - Path'teki ID ile çalıştırma
+func findRun(id string) (*ReconciliationRun, error) {
+	var run ReconciliationRun
+	if err := DB.First(&run, "id = ?", id).Error; err != nil {
+		return nil, errRunNotFound
+	}
+	return &run, nil
+}
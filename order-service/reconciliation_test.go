@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func reconOrder(id uint, status, txn string, total float64) Order {
+	o := Order{Status: status, TransactionID: txn, SubTotal: total, TotalPrice: total}
+	o.ID = id
+	return o
+}
+
+func reconPay(txn string, orderID uint, status string, captured, refunded float64) reconPayment {
+	p := reconPayment{TransactionID: txn, Reference: "saga-" + txn, Amount: captured, CapturedAmount: captured, RefundedAmount: refunded, Status: status}
+	if orderID != 0 {
+		p.OrderID = &orderID
+	}
+	return p
+}
+
+func TestReconcile(t *testing.T) {
+	type wantIssue struct {
+		typ      string
+		orderID  uint // 0 → siparişsiz
+		txn      string
+		expected float64
+		actual   float64
+		detail   string // Detail içinde geçmeli (boşsa kontrol edilmez)
+	}
+
+	tests := []struct {
+		name     string
+		orders   []Order
+		payments []reconPayment
+		refunds  map[uint]float64
+		want     []wantIssue
+	}{
+		{
+			name:     "matching paid order",
+			orders:   []Order{reconOrder(1, StatusPaid, "TXN_1", 100)},
+			payments: []reconPayment{reconPay("TXN_1", 1, "captured", 100, 0)},
+		},
+		{
+			name: "float noise within tolerance",
+			orders: []Order{
+				reconOrder(1, StatusDelivered, "TXN_1", 100),
+				reconOrder(2, StatusCancelled, "TXN_2", 59.99),
+			},
+			payments: []reconPayment{
+				reconPay("TXN_1", 1, "captured", 100.004, 0),
+				reconPay("TXN_2", 2, "refunded", 59.99, 59.994),
+			},
+		},
+		{
+			name: "uncaptured or fully refunded payments without order are ignored",
+			payments: []reconPayment{
+				reconPay("TXN_V", 0, "voided", 0, 0),
+				reconPay("TXN_F", 0, "failed", 0, 0),
+				reconPay("TXN_R", 0, "refunded", 80, 80),
+			},
+		},
+		{
+			name:     "captured payment without order",
+			payments: []reconPayment{reconPay("TXN_9", 0, "captured", 75, 0)},
+			want:     []wantIssue{{typ: IssuePaymentWithoutOrder, txn: "TXN_9", actual: 75, detail: "siparişi yok"}},
+		},
+		{
+			name:     "payment points to a missing order",
+			payments: []reconPayment{reconPay("TXN_9", 42, "captured", 75, 0)},
+			want:     []wantIssue{{typ: IssuePaymentWithoutOrder, txn: "TXN_9", actual: 75, detail: "#42"}},
+		},
+		{
+			name:   "second capture for the same order",
+			orders: []Order{reconOrder(1, StatusPaid, "TXN_1", 100)},
+			payments: []reconPayment{
+				reconPay("TXN_1", 1, "captured", 100, 0),
+				reconPay("TXN_2", 1, "captured", 100, 0),
+			},
+			want: []wantIssue{{typ: IssuePaymentWithoutOrder, orderID: 1, txn: "TXN_2", actual: 100, detail: "çift tahsilat"}},
+		},
+		{
+			name:     "captured payment on an unpaid order",
+			orders:   []Order{reconOrder(3, StatusPaymentFailed, "TXN_3", 50)},
+			payments: []reconPayment{reconPay("TXN_3", 3, "captured", 50, 0)},
+			want:     []wantIssue{{typ: IssuePaymentWithoutOrder, orderID: 3, txn: "TXN_3", actual: 50, detail: StatusPaymentFailed}},
+		},
+		{
+			name:   "paid order without payment",
+			orders: []Order{reconOrder(4, StatusShipped, "TXN_4", 120)},
+			want:   []wantIssue{{typ: IssueOrderWithoutCapture, orderID: 4, txn: "TXN_4", expected: 120, detail: "kaydı yok"}},
+		},
+		{
+			name:     "paid order with an uncaptured authorization",
+			orders:   []Order{reconOrder(5, StatusPaid, "TXN_5", 80)},
+			payments: []reconPayment{{TransactionID: "TXN_5", Amount: 80, Status: "authorized"}},
+			want:     []wantIssue{{typ: IssueOrderWithoutCapture, orderID: 5, txn: "TXN_5", expected: 80, detail: "authorized"}},
+		},
+		{
+			name:     "pending order without capture is fine",
+			orders:   []Order{reconOrder(6, StatusPendingPayment, "TXN_6", 80)},
+			payments: []reconPayment{{TransactionID: "TXN_6", Amount: 80, Status: "pending"}},
+		},
+		{
+			name:     "captured amount differs from order total",
+			orders:   []Order{reconOrder(7, StatusPaid, "TXN_7", 100)},
+			payments: []reconPayment{reconPay("TXN_7", 7, "captured", 90, 0)},
+			want:     []wantIssue{{typ: IssueAmountMismatch, orderID: 7, txn: "TXN_7", expected: 100, actual: 90}},
+		},
+		{
+			name:     "cancelled order not refunded",
+			orders:   []Order{reconOrder(8, StatusCancelled, "TXN_8", 100)},
+			payments: []reconPayment{reconPay("TXN_8", 8, "captured", 100, 0)},
+			want:     []wantIssue{{typ: IssueRefundMismatch, orderID: 8, txn: "TXN_8", expected: 100, actual: 0, detail: StatusCancelled}},
+		},
+		{
+			name:     "item refund recorded but not paid out",
+			orders:   []Order{reconOrder(9, StatusDelivered, "TXN_9", 100)},
+			payments: []reconPayment{reconPay("TXN_9", 9, "captured", 100, 0)},
+			refunds:  map[uint]float64{9: 30},
+			want:     []wantIssue{{typ: IssueRefundMismatch, orderID: 9, txn: "TXN_9", expected: 30, actual: 0}},
+		},
+		{
+			name:     "item refund matches payment refund",
+			orders:   []Order{reconOrder(9, StatusDelivered, "TXN_9", 100)},
+			payments: []reconPayment{reconPay("TXN_9", 9, "captured", 100, 30)},
+			refunds:  map[uint]float64{9: 30},
+		},
+		{
+			name:     "refund paid out without an order refund",
+			orders:   []Order{reconOrder(10, StatusDelivered, "TXN_10", 100)},
+			payments: []reconPayment{reconPay("TXN_10", 10, "captured", 100, 25)},
+			want:     []wantIssue{{typ: IssueRefundMismatch, orderID: 10, txn: "TXN_10", expected: 0, actual: 25}},
+		},
+		{
+			name:     "amount and refund mismatch on the same order",
+			orders:   []Order{reconOrder(11, StatusRefunded, "TXN_11", 100)},
+			payments: []reconPayment{reconPay("TXN_11", 11, "captured", 110, 100)},
+			want: []wantIssue{
+				{typ: IssueAmountMismatch, orderID: 11, txn: "TXN_11", expected: 100, actual: 110},
+				{typ: IssueRefundMismatch, orderID: 11, txn: "TXN_11", expected: 110, actual: 100},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reconcile(tt.orders, tt.payments, tt.refunds)
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d uyuşmazlık, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				issue := got[i]
+				if issue.Type != want.typ {
+					t.Errorf("[%d] Type = %s, want %s", i, issue.Type, want.typ)
+				}
+				switch {
+				case want.orderID == 0 && issue.OrderID != nil:
+					t.Errorf("[%d] OrderID = %d, want nil", i, *issue.OrderID)
+				case want.orderID != 0 && (issue.OrderID == nil || *issue.OrderID != want.orderID):
+					t.Errorf("[%d] OrderID = %v, want %d", i, issue.OrderID, want.orderID)
+				}
+				if issue.TransactionID != want.txn {
+					t.Errorf("[%d] TransactionID = %s, want %s", i, issue.TransactionID, want.txn)
+				}
+				if issue.Expected != want.expected || issue.Actual != want.actual {
+					t.Errorf("[%d] expected/actual = %.2f/%.2f, want %.2f/%.2f", i, issue.Expected, issue.Actual, want.expected, want.actual)
+				}
+				if want.detail != "" && !strings.Contains(issue.Detail, want.detail) {
+					t.Errorf("[%d] Detail = %q, %q içermeli", i, issue.Detail, want.detail)
+				}
+				if issue.Status != IssueOpen {
+					t.Errorf("[%d] Status = %s, want %s", i, issue.Status, IssueOpen)
+				}
+			}
+		})
+	}
+}
+
+func TestIssueFingerprint(t *testing.T) {
+	orderID := uint(7)
+	tests := []struct {
+		name  string
+		issue ReconciliationIssue
+		want  string
+	}{
+		{"with order", ReconciliationIssue{Type: IssueAmountMismatch, OrderID: &orderID, TransactionID: "TXN_7"}, "amount_mismatch:7:TXN_7"},
+		{"without order", ReconciliationIssue{Type: IssuePaymentWithoutOrder, TransactionID: "TXN_9"}, "payment_without_order::TXN_9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.issue.fingerprint(); got != tt.want {
+				t.Errorf("fingerprint = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchPaymentsByID(t *testing.T) {
+	tests := []struct {
+		name         string
+		count        int
+		status       int
+		wantRequests int
+		wantErr      bool
+	}{
+		{"nothing to fetch", 0, 200, 0, false},
+		{"single batch", 3, 200, 1, false},
+		{"split into batches", 2*paymentLookupChunk + 1, 200, 3, false},
+		{"lookup rejected", 3, 400, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ids := strings.Split(r.URL.Query().Get("transaction_ids"), ",")
+				requests = append(requests, len(ids))
+				if r.URL.Path != "/payments" || tt.status != 200 {
+					w.WriteHeader(tt.status)
+					return
+				}
+				payments := make([]reconPayment, 0, len(ids))
+				for _, id := range ids {
+					if id != "TXN_MISSING" {
+						payments = append(payments, reconPayment{TransactionID: id})
+					}
+				}
+				json.NewEncoder(w).Encode(map[string]any{"payments": payments})
+			}))
+			defer server.Close()
+			t.Setenv("PAYMENT_SERVICE_URL", server.URL)
+
+			ids := make([]string, tt.count)
+			for i := range ids {
+				ids[i] = fmt.Sprintf("TXN_%d", i)
+			}
+			if tt.count > 0 {
+				ids[0] = "TXN_MISSING" // payment-service'te kaydı yok → sonuçta olmamalı
+			}
+
+			payments, err := fetchPaymentsByID(ids)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(requests) != tt.wantRequests {
+				t.Errorf("%d istek, want %d", len(requests), tt.wantRequests)
+			}
+			for _, n := range requests {
+				if n > paymentLookupChunk {
+					t.Errorf("istek başına %d işlem, en fazla %d olmalı", n, paymentLookupChunk)
+				}
+			}
+			if !tt.wantErr && tt.count > 0 && len(payments) != tt.count-1 {
+				t.Errorf("%d ödeme, want %d", len(payments), tt.count-1)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/pkg/outbox"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==============================================================================
+// ÜRÜN İADESİ (RMA) VE KISMİ PARA İADESİ
+// ==============================================================================
+/*
+Teslim edilen siparişin ürünlerinin tamamı veya bir kısmı (ürün + adet)
+iade edilebilir:
+
+	POST /orders/:id/returns                     → Müşteri iade talebi açar (sebep zorunlu)
+	POST /orders/:id/returns/:returnId/approve   → Admin onaylar
+	POST /orders/:id/returns/:returnId/reject    → Admin reddeder
+	POST /orders/:id/returns/:returnId/receive   → Ürünler depoya ulaştı
+
+Durumlar:
+
+	requested ──approve──► approved ──receive──► received ──(para iadesi)──► refunded
+	    │
+	    └──reject──► rejected
+
+"received" olunca:
+  - Stok: "order.return.received" eventi → product-service stoğu geri ekler
+    (iade ID'si ile idempotent, event tekrar gelse de stok iki kez eklenmez)
+  - Para: payment-service'te kısmi iade (POST /refund, refund_id = "return-<id>")
+    Başarısız olursa talep "received" kalır, resumeReturnRefunds tekrar dener.
+
+İade tutarı kupon payı düşülerek hesaplanır:
+
+	iade = birim fiyat × adet × (1 − kupon indirimi / ara toplam)
+
+Siparişin kalan son ürünleri iade edildiğinde kuruş farkı kalmasın diye tutar
+"ödenen toplam − önceki iadeler" olarak alınır; iadelerin toplamı siparişin
+ödenen tutarını hiçbir zaman aşmaz. Tüm ürünlerin parası iade edildiğinde
+sipariş "refunded" olur.
+*/
+
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+	ReturnReceived  = "received"
+	ReturnRefunded  = "refunded"
+)
+
+// returnTransitions - Admin'in yapabileceği geçişler (received → refunded sistemindir)
+var returnTransitions = map[string][]string{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+}
+
+const (
+	orderReturnReceivedRoutingKey = "order.return.received"
+	returnRetryPeriod             = 30 * time.Second
+)
+
+// OrderReturn - Bir iade talebi
+type OrderReturn struct {
+	ID           uint              `json:"id" gorm:"primaryKey"`
+	OrderID      uint              `json:"order_id" gorm:"index"`
+	UserID       uint              `json:"user_id"`
+	Status       string            `json:"status" gorm:"index"`
+	Reason       string            `json:"reason"`
+	AdminNote    string            `json:"admin_note"`
+	ReviewedBy   string            `json:"reviewed_by"`   // "admin:1"
+	RefundAmount float64           `json:"refund_amount"` // Kupon payı düşülmüş tutar
+	Items        []OrderReturnItem `json:"items" gorm:"foreignKey:ReturnID"`
+	Attempts     int               `json:"attempts"`
+	LastError    string            `json:"last_error,omitempty"`
+	RefundedAt   *time.Time        `json:"refunded_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// OrderReturnItem - İade edilen ürün ve adet
+type OrderReturnItem struct {
+	ID           uint    `json:"id" gorm:"primaryKey"`
+	ReturnID     uint    `json:"return_id" gorm:"index"`
+	OrderItemID  uint    `json:"order_item_id"`
+	ProductID    uint    `json:"product_id"`
+	ProductName  string  `json:"product_name"`
+	Quantity     int     `json:"quantity"`
+	RefundAmount float64 `json:"refund_amount"`
+}
+
+// OrderRefund - payment-service'te yapılmış bir para iadesi
+type OrderRefund struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	OrderID   uint      `json:"order_id" gorm:"index"`
+	ReturnID  uint      `json:"return_id" gorm:"index"`
+	Amount    float64   `json:"amount"`
+	RefundID  string    `json:"refund_id"` // payment-service iade ID'si
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrderReturnReceivedEvent - "order.return.received" eventi (product-service stoğu geri ekler)
+type OrderReturnReceivedEvent struct {
+	OrderID  uint             `json:"order_id"`
+	ReturnID uint             `json:"return_id"`
+	Items    []OrderEventItem `json:"items"`
+}
+
+var (
+	errNotReturnable  = errors.New("Sadece teslim edilen siparişler iade edilebilir")
+	errReturnNotFound = errors.New("İade talebi bulunamadı")
+)
+
+// errInvalidReturn - Talepteki ürün/adet hatası (400)
+type errInvalidReturn struct{ message string }
+
+func (e errInvalidReturn) Error() string { return e.message }
+
+// errInvalidReturnTransition - İzin verilmeyen iade geçişi (409)
+type errInvalidReturnTransition struct{ from, to string }
+
+func (e errInvalidReturnTransition) Error() string {
+	return fmt.Sprintf("İade talebi %s → %s olarak değiştirilemez", e.from, e.to)
+}
+
+// couponShare - Ara toplamın kupona düşen oranı (0-1)
+func couponShare(order *Order) float64 {
+	if order.SubTotal <= 0 || order.CouponDiscount <= 0 {
+		return 0
+	}
+	return math.Min(order.CouponDiscount/order.SubTotal, 1)
+}
+
+// createReturn - İade talebi açar; adetler daha önce iade edilenlerle birlikte kontrol edilir
+func createReturn(orderID, userID uint, req *CreateReturnRequest) (*OrderReturn, error) {
+	if len(req.Items) == 0 {
+		return nil, errInvalidReturn{"İade edilecek en az bir ürün seçilmeli"}
+	}
+	if req.Reason == "" {
+		return nil, errInvalidReturn{"İade sebebi zorunlu"}
+	}
+
+	var ret *OrderReturn
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Sipariş satırı kilitlenir → aynı anda açılan iki talep aynı adedi iade edemez
+		var order Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&order, orderID).Error; err != nil {
+			return errOrderNotFound
+		}
+		if order.Status != StatusDelivered {
+			return errNotReturnable
+		}
+
+		// Reddedilmemiş talepler adetleri ve tutarı ayırmış sayılır
+		var prior []OrderReturn
+		if err := tx.Preload("Items").Where("order_id = ? AND status <> ?", orderID, ReturnRejected).Find(&prior).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if ret, err = buildReturn(&order, prior, userID, req); err != nil {
+			return err
+		}
+		return tx.Create(ret).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// buildReturn - Talebin adetlerini kontrol eder ve iade tutarlarını hesaplar
+// prior: Siparişin reddedilmemiş önceki talepleri (adetleri ve tutarı ayırmış sayılır).
+// Her ürünün tutarı kupon payı düşülerek (couponShare) kuruşa yuvarlanır.
+func buildReturn(order *Order, prior []OrderReturn, userID uint, req *CreateReturnRequest) (*OrderReturn, error) {
+	returned := make(map[uint]int)
+	claimed := 0.0
+	for _, r := range prior {
+		claimed += r.RefundAmount
+		for _, item := range r.Items {
+			returned[item.OrderItemID] += item.Quantity
+		}
+	}
+
+	items := make(map[uint]OrderItem)
+	for _, item := range order.Items {
+		items[item.ID] = item
+	}
+
+	share := couponShare(order)
+	ret := &OrderReturn{OrderID: order.ID, UserID: userID, Status: ReturnRequested, Reason: req.Reason}
+	for _, in := range req.Items {
+		item, ok := items[in.OrderItemID]
+		if !ok {
+			return nil, errInvalidReturn{fmt.Sprintf("Ürün bu siparişte yok: %d", in.OrderItemID)}
+		}
+		if in.Quantity <= 0 {
+			return nil, errInvalidReturn{"Adet 0'dan büyük olmalı"}
+		}
+		if returned[item.ID]+in.Quantity > item.Quantity {
+			return nil, errInvalidReturn{fmt.Sprintf("%s için en fazla %d adet iade edilebilir",
+				item.ProductName, item.Quantity-returned[item.ID])}
+		}
+		returned[item.ID] += in.Quantity
+
+		amount := roundPrice(item.UnitPrice * float64(in.Quantity) * (1 - share))
+		ret.Items = append(ret.Items, OrderReturnItem{
+			OrderItemID:  item.ID,
+			ProductID:    item.ProductID,
+			ProductName:  item.ProductName,
+			Quantity:     in.Quantity,
+			RefundAmount: amount,
+		})
+		ret.RefundAmount += amount
+	}
+
+	// Son ürünler de iade ediliyorsa kalan tutarın tamamı (kuruş farkları dahil)
+	remaining := roundPrice(order.TotalPrice - claimed)
+	allReturned := true
+	for _, item := range order.Items {
+		if returned[item.ID] < item.Quantity {
+			allReturned = false
+		}
+	}
+	ret.RefundAmount = roundPrice(ret.RefundAmount)
+	if allReturned || ret.RefundAmount > remaining {
+		ret.RefundAmount = math.Max(remaining, 0)
+	}
+	return ret, nil
+}
+
+// transitionReturn - Admin kararı: approve / reject / receive
+// Talep zaten hedef durumdaysa hiçbir şey yapmaz (çift tıklama).
+func transitionReturn(orderID, returnID uint, to, actor, note string) (*OrderReturn, error) {
+	var ret OrderReturn
+	changed := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").
+			Where("id = ? AND order_id = ?", returnID, orderID).First(&ret).Error; err != nil {
+			return errReturnNotFound
+		}
+		if ret.Status == to {
+			return nil
+		}
+		allowed := false
+		for _, next := range returnTransitions[ret.Status] {
+			allowed = allowed || next == to
+		}
+		if !allowed {
+			return errInvalidReturnTransition{ret.Status, to}
+		}
+
+		updates := map[string]any{"status": to, "reviewed_by": actor}
+		if note != "" {
+			updates["admin_note"] = note
+		}
+		if err := tx.Model(&ret).Updates(updates).Error; err != nil {
+			return err
+		}
+		changed = true
+
+		if to != ReturnReceived {
+			return nil
+		}
+		// Ürünler depoda: stok product-service'te geri eklenir
+		event := OrderReturnReceivedEvent{OrderID: orderID, ReturnID: ret.ID}
+		for _, item := range ret.Items {
+			event.Items = append(event.Items, OrderEventItem{ProductID: int(item.ProductID), Quantity: item.Quantity})
+		}
+		msg, err := outbox.NewMessage("order", strconv.FormatUint(uint64(orderID), 10),
+			orderEventsExchange, orderReturnReceivedRoutingKey, event)
+		if err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, outboxSource, msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if changed && to == ReturnReceived {
+		relay.Notify()
+		processReturnRefund(&ret)
+	}
+	return &ret, nil
+}
+
+// refundPayment - Tahsil edilmiş ödemenin bir kısmını iade eder
+// refundID payment-service'te idempotency anahtarıdır: tekrar denemede para iki kez iade edilmez.
+func refundPayment(transactionID, reference string, amount float64, refundID string) (string, error) {
+	paymentServiceURL := getEnv("PAYMENT_SERVICE_URL", "http://localhost:3005")
+
+	var resp struct {
+		Refund *struct {
+			ID string `json:"id"`
+		} `json:"refund"`
+	}
+	status, err := callService(http.MethodPost, paymentServiceURL+"/refund", fiber.Map{
+		"transaction_id": transactionID,
+		"reference":      reference,
+		"amount":         amount,
+		"refund_id":      refundID,
+	}, &resp)
+	if err != nil || status != 200 || resp.Refund == nil {
+		return "", fmt.Errorf("ödeme iade edilemedi: status %d, %v", status, err)
+	}
+	return resp.Refund.ID, nil
+}
+
+// processReturnRefund - Teslim alınan iadenin parasını öder (tekrar çağrılabilir)
+func processReturnRefund(r *OrderReturn) {
+	if r.Status != ReturnReceived {
+		return
+	}
+
+	var saga OrderSaga
+	DB.Where("order_id = ?", r.OrderID).First(&saga)
+
+	var refundID string
+	var err error
+	switch {
+	case r.RefundAmount <= 0:
+		// İade edilecek tutar yok (ör. tamamı kuponla ödenmiş)
+	case saga.ID == "":
+		// Saga'sız (eski) sipariş: payment-service'te işlem kaydı yok
+		log.Printf("⚠️ İade #%d: siparişin ödeme kaydı yok, %.2f TL elle iade edilmeli", r.ID, r.RefundAmount)
+	default:
+		refundID, err = refundPayment(saga.TransactionID, saga.ID, r.RefundAmount, "return-"+strconv.FormatUint(uint64(r.ID), 10))
+	}
+
+	r.Attempts++
+	if err != nil {
+		r.LastError = err.Error()
+		DB.Model(r).Updates(map[string]any{"attempts": r.Attempts, "last_error": r.LastError})
+		log.Printf("❌ İade #%d ödemesi yapılamadı, tekrar denenecek: %s", r.ID, err)
+		return
+	}
+
+	now := time.Now()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&OrderReturn{}).Where("id = ? AND status = ?", r.ID, ReturnReceived).Updates(map[string]any{
+			"status":      ReturnRefunded,
+			"attempts":    r.Attempts,
+			"last_error":  "",
+			"refunded_at": now,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error // Başka bir deneme tamamlamış
+		}
+		if refundID != "" {
+			if err := tx.Create(&OrderRefund{OrderID: r.OrderID, ReturnID: r.ID, Amount: r.RefundAmount, RefundID: refundID}).Error; err != nil {
+				return err
+			}
+		}
+		return refundOrderIfFullyReturned(tx, r.OrderID)
+	})
+	if err != nil {
+		log.Printf("❌ İade #%d kaydedilemedi: %s", r.ID, err)
+		return
+	}
+	relay.Notify()
+
+	r.Status, r.LastError, r.RefundedAt = ReturnRefunded, "", &now
+	fmt.Printf("↩️ İade #%d tamamlandı: Sipariş #%d, %.2f TL\n", r.ID, r.OrderID, r.RefundAmount)
+}
+
+// refundOrderIfFullyReturned - Tüm ürünlerin parası iade edildiyse sipariş "refunded" olur
+func refundOrderIfFullyReturned(tx *gorm.DB, orderID uint) error {
+	var ordered, refunded int64
+	tx.Model(&OrderItem{}).Where("order_id = ?", orderID).Select("COALESCE(SUM(quantity), 0)").Scan(&ordered)
+	tx.Model(&OrderReturnItem{}).
+		Joins("JOIN order_returns ON order_returns.id = order_return_items.return_id").
+		Where("order_returns.order_id = ? AND order_returns.status = ?", orderID, ReturnRefunded).
+		Select("COALESCE(SUM(order_return_items.quantity), 0)").Scan(&refunded)
+	if ordered == 0 || refunded < ordered {
+		return nil
+	}
+
+	_, err := transitionOrder(tx, orderID, StatusRefunded, ActorSystem, "Tüm ürünler iade edildi")
+	var invalid errInvalidTransition
+	if errors.As(err, &invalid) {
+		return nil // Admin durumu elle değiştirmiş
+	}
+	return err
+}
+
+// resumeReturnRefunds - Parası iade edilemeyen talepleri periyodik olarak tekrar dener
+func resumeReturnRefunds() {
+	for range time.Tick(returnRetryPeriod) {
+		var pending []OrderReturn
+		DB.Where("status = ? AND updated_at < ?", ReturnReceived, time.Now().Add(-returnRetryPeriod)).
+			Order("created_at").Find(&pending)
+		for i := range pending {
+			processReturnRefund(&pending[i])
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestCouponShare(t *testing.T) {
+	tests := []struct {
+		name     string
+		subTotal float64
+		discount float64
+		want     float64
+	}{
+		{"no coupon", 200, 0, 0},
+		{"ten percent", 200, 20, 0.1},
+		{"fixed discount", 300, 50, 50.0 / 300},
+		{"discount covers the whole order", 40, 40, 1},
+		{"discount larger than subtotal is capped", 40, 50, 1},
+		{"zero subtotal", 0, 10, 0},
+		{"negative discount ignored", 100, -5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := couponShare(&Order{SubTotal: tt.subTotal, CouponDiscount: tt.discount})
+			if got != tt.want {
+				t.Errorf("couponShare = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// returnTestOrder - 3 x 33.33 + 1 x 100 = 199.99, kupon 20 TL → 179.99
+func returnTestOrder(discount float64) *Order {
+	order := &Order{
+		SubTotal:       199.99,
+		CouponDiscount: discount,
+		TotalPrice:     roundPrice(199.99 - discount),
+		Status:         StatusDelivered,
+		Items: []OrderItem{
+			{Model: gorm.Model{ID: 1}, ProductID: 10, ProductName: "Kalem", UnitPrice: 33.33, Quantity: 3},
+			{Model: gorm.Model{ID: 2}, ProductID: 20, ProductName: "Defter", UnitPrice: 100, Quantity: 1},
+		},
+	}
+	order.ID = 5
+	return order
+}
+
+func priorReturn(amount float64, items ...OrderReturnItem) OrderReturn {
+	return OrderReturn{Status: ReturnApproved, RefundAmount: amount, Items: items}
+}
+
+func TestBuildReturnAmounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		discount  float64
+		prior     []OrderReturn
+		items     []ReturnItemInput
+		want      float64
+		wantItems []float64
+	}{
+		{
+			name:      "no coupon full price",
+			items:     []ReturnItemInput{{OrderItemID: 1, Quantity: 1}},
+			want:      33.33,
+			wantItems: []float64{33.33},
+		},
+		{
+			// 33.33 x (1 - 20/199.99) = 29.9968... → 30.00
+			name:      "coupon share is deducted and rounded",
+			discount:  20,
+			items:     []ReturnItemInput{{OrderItemID: 1, Quantity: 1}},
+			want:      30,
+			wantItems: []float64{30},
+		},
+		{
+			name:      "multiple items are summed",
+			discount:  20,
+			items:     []ReturnItemInput{{OrderItemID: 1, Quantity: 2}, {OrderItemID: 2, Quantity: 1}},
+			want:      149.99, // 59.99 + 90.00
+			wantItems: []float64{59.99, 90},
+		},
+		{
+			// Tüm ürünler iade edilince tutar satır toplamı değil, kalan tutarın tamamıdır
+			name:      "returning everything refunds the exact total",
+			discount:  20,
+			items:     []ReturnItemInput{{OrderItemID: 1, Quantity: 3}, {OrderItemID: 2, Quantity: 1}},
+			want:      179.99,
+			wantItems: []float64{89.99, 90},
+		},
+		{
+			// Önceki iadeler 30 + 60 = 90 (gerçek pay 89.99): kuruş farkı son iadeden düşülür
+			name:     "last items get what is left after earlier returns",
+			discount: 20,
+			prior: []OrderReturn{
+				priorReturn(30, OrderReturnItem{OrderItemID: 1, Quantity: 1}),
+				priorReturn(60, OrderReturnItem{OrderItemID: 1, Quantity: 2}),
+			},
+			items:     []ReturnItemInput{{OrderItemID: 2, Quantity: 1}},
+			want:      89.99,
+			wantItems: []float64{90},
+		},
+		{
+			name:      "refund never exceeds what is left",
+			discount:  20,
+			prior:     []OrderReturn{priorReturn(170, OrderReturnItem{OrderItemID: 2, Quantity: 1})},
+			items:     []ReturnItemInput{{OrderItemID: 1, Quantity: 1}},
+			want:      9.99,
+			wantItems: []float64{30},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ret, err := buildReturn(returnTestOrder(tt.discount), tt.prior, 7, &CreateReturnRequest{Reason: "Beden uymadı", Items: tt.items})
+			if err != nil {
+				t.Fatalf("buildReturn: %v", err)
+			}
+			if ret.RefundAmount != tt.want {
+				t.Errorf("RefundAmount = %.2f, want %.2f", ret.RefundAmount, tt.want)
+			}
+			if len(ret.Items) != len(tt.wantItems) {
+				t.Fatalf("%d satır, want %d", len(ret.Items), len(tt.wantItems))
+			}
+			for i, want := range tt.wantItems {
+				if ret.Items[i].RefundAmount != want {
+					t.Errorf("satır %d: RefundAmount = %.2f, want %.2f", i, ret.Items[i].RefundAmount, want)
+				}
+			}
+			if ret.OrderID != 5 || ret.UserID != 7 || ret.Status != ReturnRequested {
+				t.Errorf("talep alanları hatalı: %+v", ret)
+			}
+		})
+	}
+}
+
+func TestBuildReturnRemainingQuantity(t *testing.T) {
+	tests := []struct {
+		name    string
+		prior   []OrderReturn
+		items   []ReturnItemInput
+		wantErr bool
+	}{
+		{"all units of an item", nil, []ReturnItemInput{{OrderItemID: 1, Quantity: 3}}, false},
+		{"more than ordered", nil, []ReturnItemInput{{OrderItemID: 1, Quantity: 4}}, true},
+		{
+			"remaining after an earlier return",
+			[]OrderReturn{priorReturn(33.33, OrderReturnItem{OrderItemID: 1, Quantity: 1})},
+			[]ReturnItemInput{{OrderItemID: 1, Quantity: 2}},
+			false,
+		},
+		{
+			"more than remaining after an earlier return",
+			[]OrderReturn{priorReturn(66.66, OrderReturnItem{OrderItemID: 1, Quantity: 2})},
+			[]ReturnItemInput{{OrderItemID: 1, Quantity: 2}},
+			true,
+		},
+		{
+			"item already fully returned",
+			[]OrderReturn{priorReturn(100, OrderReturnItem{OrderItemID: 2, Quantity: 1})},
+			[]ReturnItemInput{{OrderItemID: 2, Quantity: 1}},
+			true,
+		},
+		{"same item twice in one request", nil, []ReturnItemInput{{OrderItemID: 1, Quantity: 2}, {OrderItemID: 1, Quantity: 2}}, true},
+		{"zero quantity", nil, []ReturnItemInput{{OrderItemID: 1, Quantity: 0}}, true},
+		{"negative quantity", nil, []ReturnItemInput{{OrderItemID: 1, Quantity: -1}}, true},
+		{"item not in order", nil, []ReturnItemInput{{OrderItemID: 99, Quantity: 1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildReturn(returnTestOrder(0), tt.prior, 7, &CreateReturnRequest{Reason: "Hasarlı", Items: tt.items})
+			var invalid errInvalidReturn
+			if tt.wantErr && !errors.As(err, &invalid) {
+				t.Fatalf("err = %v, want errInvalidReturn", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/outbox"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ==============================================================================
+// SİPARİŞ SAGA'SI (Stok → Kupon → Ödeme → Kayıt → Tahsilat)
+// ==============================================================================
+/*
+Sipariş oluşturmak birden fazla servise dokunur; hepsini kapsayan bir DB transaction'ı
+olamayacağı için her adımın bir TELAFİ (compensation) adımı vardır:
+
+	Adım                    Servis              Telafi
+	───────────────────────────────────────────────────────────────────
+	1. stock_reserved       product-service     Rezervasyonu bırak (stok geri eklenir)
+	2. coupon_reserved      coupon-service      Kupon kullanımını bırak (sayaç azalır)
+	3. payment_authorized   payment-service     Provizyonu iptal et (void)
+	   (payment_pending)
+	4. order_persisted      order DB (tek tx)   Siparişi "cancelled" yap
+	5. stock_confirmed      product-service     (1. adımın telafisi kapsar)
+	6. coupon_committed     coupon-service      (2. adımın telafisi kapsar)
+	7. payment_captured     payment-service     İade et (refund)
+	8. completed            outbox eventi       (telafi yok), sipariş "paid" olur
+
+Kupon adımları sadece siparişte kupon varsa çalışır.
+
+Stok ve kupon, tahsilattan ÖNCE onaylanır: rezervasyonun süresi dolduysa
+(RESERVATION_TTL) para çekilmeden sipariş iptal edilir.
+
+Bir adım başarısız olursa tamamlanmış adımlar tersten geri alınır.
+
+3D Secure (asenkron ödeme): payment-service provizyonu "pending" dönerse
+(payment_pending) sipariş "pending_payment" olarak kaydedilir ve saga
+"awaiting_payment" durumunda bekler; istemciye 202 + doğrulama adresi döner.
+Ödeme sonucu "payment_events" eventiyle gelir (payment_events.go):
+  - payment.authorized → saga 5. adımdan devam eder, sipariş "paid" olur
+  - payment.failed     → sipariş "payment_failed" olur, saga telafi edilir
+PAYMENT_TIMEOUT içinde sonuç gelmezse provizyon iptal edilir ve sipariş
+"payment_failed" olur (stok rezervasyonu bu süreden uzun tutulmalı).
+
+Saga durumu order_sagas tablosunda tutulur ve her adımdan sonra güncellenir.
+Servis çökerse resumeSagas yarıda kalan saga'ları bulur:
+  - Sipariş kaydedilmeden kalmışsa → telafi edilir (ödeme token'ı saklanmadığı
+    için ödeme tekrar alınamaz; müşteri zaten hata/timeout görmüştür)
+  - Sipariş kaydedildiyse → stok/kupon onayı, tahsilat ve event ileri doğru tamamlanır
+  - Telafi yarıda kaldıysa → telafi tekrar denenir
+
+Saga ID, product-service'te rezervasyon ID'si, coupon-service'te kupon
+kullanım ID'si ve payment-service'te provizyon referansıdır; üç servis de
+bu ID ile idempotenttir, bu yüzden her adım güvenle tekrar edilebilir.
+*/
+
+const (
+	SagaStarted           = "started"
+	SagaStockReserved     = "stock_reserved"
+	SagaCouponReserved    = "coupon_reserved"
+	SagaPaymentPending    = "payment_pending" // 3D Secure: sonuç eventle gelecek
+	SagaPaymentAuthorized = "payment_authorized"
+	SagaOrderPersisted    = "order_persisted"
+	SagaStockConfirmed    = "stock_confirmed"
+	SagaCouponCommitted   = "coupon_committed"
+	SagaPaymentCaptured   = "payment_captured"
+	SagaCompleted         = "completed"
+
+	SagaRunning         = "running"
+	SagaAwaitingPayment = "awaiting_payment" // Sipariş kaydedildi, ödeme sonucu bekleniyor
+	SagaCompensating    = "compensating"
+	SagaCompensated     = "compensated"
+	SagaDone            = "done"
+)
+
+const (
+	serviceCallRetries = 3
+	sagaResumeInterval = 30 * time.Second
+	sagaStaleAfter     = 2 * time.Minute // İstek hâlâ işleniyor olabilir, bu süreden önce dokunma
+)
+
+// OrderSaga - Bir sipariş oluşturma sürecinin kalıcı durumu
+type OrderSaga struct {
+	ID            string    `json:"id" gorm:"primaryKey"`
+	UserID        uint      `json:"user_id"`
+	Step          string    `json:"step"`                // Son tamamlanan adım
+	Status        string    `json:"status" gorm:"index"` // running | awaiting_payment | compensating | compensated | done
+	Payload       string    `json:"payload"`             // CreateOrderRequest (kart bilgisi HARİÇ)
+	TotalPrice    float64   `json:"total_price"`         // Provizyon tutarı
+	CouponCode    string    `json:"coupon_code"`         // Boşsa kupon adımları atlanır
+	TransactionID string    `json:"transaction_id"`      // payment-service işlem ID'si
+	OrderID       *uint     `json:"order_id"`            // Kayıt adımından sonra dolu
+	ActionURL     string    `json:"-" gorm:"-"`          // 3D Secure sayfası (sadece istemciye döner)
+	Error         string    `json:"error"`               // Telafiyi başlatan hata
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// SagaError - İstemciye olduğu gibi dönecek iş hatası (yetersiz stok, ödeme reddi)
+type SagaError struct {
+	Status  int
+	Message string
+}
+
+func (e *SagaError) Error() string { return e.Message }
+
+var serviceClient = &http.Client{Timeout: 10 * time.Second}
+
+func newSagaID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("order: rastgele ID üretilemedi: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// callService - JSON isteği atar, yanıtı out'a çözer
+// Bağlantı hatası ve 5xx'te tekrar dener: saga çağrılarının hepsi idempotenttir.
+// İstek servis kimliğiyle (X-Service-Token) gider: iç endpoint'ler service:call ister.
+func callService(method, url string, payload, out any) (int, error) {
+	return callServiceAs("", method, url, payload, out)
+}
+
+// callServiceAs - callService, kullanıcı adına (Authorization header'ı ile)
+func callServiceAs(token, method, url string, payload, out any) (int, error) {
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+
+	var lastErr error
+	for attempt := range serviceCallRetries {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		} else if serviceToken := getEnv("SERVICE_TOKEN", ""); serviceToken != "" {
+			req.Header.Set(auth.HeaderServiceToken, serviceToken)
+		}
+
+		resp, err := serviceClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode >= 500 {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s %s: status %d", method, url, resp.StatusCode)
+			continue
+		}
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	return 0, lastErr
+}
+
+// advance - Adımı kaydeder; extra ile aynı güncellemede başka alanlar da yazılır
+func (s *OrderSaga) advance(tx *gorm.DB, step string, extra map[string]any) error {
+	updates := map[string]any{"step": step}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	if err := tx.Model(s).Updates(updates).Error; err != nil {
+		return err
+	}
+	s.Step = step
+	return nil
+}
+
+// ==============================================================================
+// SAGA'YI ÇALIŞTIR (POST /orders)
+// ==============================================================================
+
+func runOrderSaga(req *CreateOrderRequest) (*Order, error) {
+	// Ödeme token'ı veritabanına YAZILMAZ (provizyondan sonra zaten geçersizdir)
+	stored := *req
+	stored.PaymentToken = ""
+	payload, _ := json.Marshal(stored)
+
+	saga := &OrderSaga{
+		ID:         newSagaID(),
+		UserID:     req.UserID,
+		Step:       SagaStarted,
+		Status:     SagaRunning,
+		Payload:    string(payload),
+		TotalPrice: req.TotalPrice,
+		CouponCode: req.CouponCode,
+	}
+	if err := DB.Create(saga).Error; err != nil {
+		return nil, err
+	}
+
+	if err := reserveStock(saga, req); err != nil {
+		return nil, compensate(saga, err)
+	}
+	if err := reserveCoupon(saga, req); err != nil {
+		return nil, compensate(saga, err)
+	}
+	if err := authorizePayment(saga, req); err != nil {
+		return nil, compensate(saga, err)
+	}
+	order, err := persistOrder(saga, req)
+	if err != nil {
+		return nil, compensate(saga, err)
+	}
+	if saga.Status == SagaAwaitingPayment {
+		// 3D Secure: saga ödeme eventiyle (veya zaman aşımıyla) devam eder
+		return order, nil
+	}
+	if err := confirmStock(saga); err != nil {
+		return nil, compensate(saga, err)
+	}
+	if err := commitCoupon(saga); err != nil {
+		return nil, compensate(saga, err)
+	}
+	if err := capturePayment(saga); err != nil {
+		return nil, compensate(saga, err)
+	}
+
+	// Buradan sonra sipariş kesinleşti: event kaydedilemezse resumeSagas tekrar dener
+	if err := completeSaga(saga, order); err != nil {
+		log.Printf("⚠️ Saga %s: event kaydedilemedi, tekrar denenecek: %s", saga.ID, err)
+	}
+	return order, nil
+}
+
+// 1. ADIM: STOK REZERVASYONU 🔒
+func reserveStock(saga *OrderSaga, req *CreateOrderRequest) error {
+	productServiceURL := getEnv("PRODUCT_SERVICE_URL", "http://localhost:3001")
+
+	var body map[string]any
+	status, err := callService(http.MethodPost, productServiceURL+"/products/reservations", fiber.Map{
+		"reservation_id": saga.ID,
+		"items":          req.Items,
+	}, &body)
+	if err != nil {
+		return &SagaError{Status: 500, Message: "Ürün servisine ulaşılamadı"}
+	}
+	if status >= 300 {
+		message, _ := body["error"].(string)
+		return &SagaError{Status: 400, Message: message} // "Yetersiz Stok..." mesajı
+	}
+	return saga.advance(DB, SagaStockReserved, nil)
+}
+
+// 2. ADIM: KUPON KULLANIMI 🎫
+// MaxUses ve kullanıcı başına tek kullanım coupon-service'te atomik olarak uygulanır.
+func reserveCoupon(saga *OrderSaga, req *CreateOrderRequest) error {
+	if saga.CouponCode == "" {
+		return nil
+	}
+	couponServiceURL := getEnv("COUPON_SERVICE_URL", "http://localhost:3010")
+
+	var body struct {
+		Discount float64 `json:"discount"`
+		Error    string  `json:"error"`
+	}
+	status, err := callService(http.MethodPost, couponServiceURL+"/coupons/redemptions", fiber.Map{
+		"reservation_id": saga.ID,
+		"code":           req.CouponCode,
+		"user_id":        req.UserID,
+		"order_total":    req.SubTotal,
+	}, &body)
+	if err != nil {
+		return &SagaError{Status: 500, Message: "Kupon servisine ulaşılamadı"}
+	}
+	if status >= 300 {
+		return &SagaError{Status: 400, Message: body.Error} // "Bu kupon kullanım limitine ulaştı" vb.
+	}
+
+	// Fiyatlandırma ile kullanım arasında kupon değiştiyse müşteri yeni tutarı onaylamalı
+	if discount := roundPrice(body.Discount); discount != req.CouponDiscount {
+		req.CouponDiscount = discount
+		return &PriceChangedError{Expected: req.TotalPrice, Actual: roundPrice(req.SubTotal - discount)}
+	}
+	return saga.advance(DB, SagaCouponReserved, nil)
+}
+
+// 3. ADIM: ÖDEME PROVİZYONU 💳
+func authorizePayment(saga *OrderSaga, req *CreateOrderRequest) error {
+	paymentServiceURL := getEnv("PAYMENT_SERVICE_URL", "http://localhost:3005")
+
+	var body struct {
+		TransactionID string `json:"transaction_id"`
+		ActionURL     string `json:"action_url"` // 202: 3D Secure doğrulama sayfası
+		Error         string `json:"error"`      // "Yetersiz bakiye", "Kart reddedildi"...
+	}
+	status, err := callService(http.MethodPost, paymentServiceURL+"/authorize", fiber.Map{
+		"payment_token": req.PaymentToken,
+		"amount":        req.TotalPrice,
+		"reference":     saga.ID,
+		"return_url":    getEnv("PAYMENT_RETURN_URL", "http://localhost:3000/profile"),
+	}, &body)
+	if err != nil {
+		// Ödeme sağlayıcısı yanıt vermedi (retry'lar da dahil). Provizyon alınmış ama
+		// yanıt kaybolmuş olabilir: işlem ID'si bilinmediği için referansla iptal edilir.
+		if err := reversePayment("", saga.ID); err != nil {
+			log.Printf("⚠️ Saga %s: yanıtsız provizyon iptal edilemedi: %s", saga.ID, err)
+		}
+		return &SagaError{Status: 502, Message: "Ödeme şu anda alınamıyor, lütfen tekrar deneyin"}
+	}
+	if status == 400 && body.Error != "" {
+		// Token geçersiz / süresi dolmuş: istemci kartı tekrar tokenize etmeli
+		return &SagaError{Status: 400, Message: body.Error}
+	}
+	if status == http.StatusAccepted && body.TransactionID != "" {
+		// Müşteri doğrulamayı bitirince payment.authorized / payment.failed gelir
+		saga.TransactionID, saga.ActionURL = body.TransactionID, body.ActionURL
+		return saga.advance(DB, SagaPaymentPending, map[string]any{"transaction_id": body.TransactionID})
+	}
+	if status != 200 || body.TransactionID == "" {
+		message := "Ödeme reddedildi!"
+		if body.Error != "" {
+			message = "Ödeme reddedildi: " + body.Error
+		}
+		return &SagaError{Status: 400, Message: message}
+	}
+	saga.TransactionID = body.TransactionID
+	return saga.advance(DB, SagaPaymentAuthorized, map[string]any{"transaction_id": body.TransactionID})
+}
+
+// 4. ADIM: SİPARİŞ + ÜRÜNLER + SAGA DURUMU TEK TRANSACTION'DA ✅
+func persistOrder(saga *OrderSaga, req *CreateOrderRequest) (*Order, error) {
+	// Tahsilattan sonra "paid" olur; 3D Secure bekleniyorsa önce ödeme sonucu gelmeli
+	status, sagaStatus := StatusPending, SagaRunning
+	if saga.Step == SagaPaymentPending {
+		status, sagaStatus = StatusPendingPayment, SagaAwaitingPayment
+	}
+
+	order := &Order{
+		UserID:           req.UserID,
+		SubTotal:         req.SubTotal,
+		CouponCode:       req.CouponCode,
+		CouponDiscount:   req.CouponDiscount,
+		TotalPrice:       req.TotalPrice,
+		Status:           status,
+		ShippingAddress:  req.ShippingAddress,
+		TransactionID:    saga.TransactionID,
+		PaymentActionURL: saga.ActionURL,
+	}
+	for _, item := range req.Items {
+		order.Items = append(order.Items, OrderItem{
+			ProductID:    item.ProductID,
+			ProductName:  item.ProductName,
+			ProductImage: item.ProductImage,
+			UnitPrice:    item.UnitPrice,
+			Quantity:     item.Quantity,
+			SubTotal:     item.UnitPrice * float64(item.Quantity),
+		})
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Items ilişkisi aynı transaction içinde kaydedilir
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&OrderStatusHistory{
+			OrderID:  order.ID,
+			ToStatus: status,
+			Actor:    actorFor("user", req.UserID),
+			Reason:   "Sipariş oluşturuldu",
+		}).Error; err != nil {
+			return err
+		}
+		return saga.advance(tx, SagaOrderPersisted, map[string]any{"order_id": order.ID, "status": sagaStatus})
+	})
+	if err != nil {
+		return nil, err
+	}
+	saga.OrderID, saga.Status = &order.ID, sagaStatus
+	return order, nil
+}
+
+// 5. ADIM: STOK ONAYI 📦
+// Rezervasyon süresi dolup bırakıldıysa (409) sipariş tamamlanamaz.
+func confirmStock(saga *OrderSaga) error {
+	productServiceURL := getEnv("PRODUCT_SERVICE_URL", "http://localhost:3001")
+
+	status, err := callService(http.MethodPost, productServiceURL+"/products/reservations/"+saga.ID+"/confirm", nil, nil)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return &SagaError{Status: 409, Message: "Stok rezervasyonunun süresi doldu, lütfen tekrar deneyin"}
+	}
+	return saga.advance(DB, SagaStockConfirmed, nil)
+}
+
+// 6. ADIM: KUPON ONAYI 🎫
+func commitCoupon(saga *OrderSaga) error {
+	if saga.CouponCode == "" {
+		return nil
+	}
+	couponServiceURL := getEnv("COUPON_SERVICE_URL", "http://localhost:3010")
+
+	status, err := callService(http.MethodPost, couponServiceURL+"/coupons/redemptions/"+saga.ID+"/commit", fiber.Map{
+		"order_id": saga.OrderID,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return &SagaError{Status: 409, Message: "Kupon kullanımı onaylanamadı, lütfen tekrar deneyin"}
+	}
+	return saga.advance(DB, SagaCouponCommitted, nil)
+}
+
+// 7. ADIM: TAHSİLAT 💰
+func capturePayment(saga *OrderSaga) error {
+	paymentServiceURL := getEnv("PAYMENT_SERVICE_URL", "http://localhost:3005")
+
+	// order_id: ödeme payment-service'te siparişe bağlanır (mutabakat için)
+	status, err := callService(http.MethodPost, paymentServiceURL+"/payments/"+saga.TransactionID+"/capture", fiber.Map{
+		"order_id": saga.OrderID,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return &SagaError{Status: 400, Message: "Ödeme tahsil edilemedi"}
+	}
+	return saga.advance(DB, SagaPaymentCaptured, nil)
+}
+
+// 8. ADIM: SİPARİŞ EVENTİ 📢
+// Event outbox'a saga'yı tamamlayan transaction'la birlikte yazılır, relay yayınlar.
+// Sipariş aynı transaction'da "paid" olur (order.status.changed eventi de outbox'a gider).
+// Product Service reservation_id ile rezervasyonu onaylar (4. adımda onaylandıysa değişiklik olmaz).
+func completeSaga(saga *OrderSaga, order *Order) error {
+	event := OrderEvent{ReservationID: saga.ID}
+	for _, item := range order.Items {
+		event.Items = append(event.Items, OrderEventItem{
+			ProductID: int(item.ProductID),
+			Quantity:  item.Quantity,
+		})
+	}
+	msg, err := outbox.NewMessage("order", strconv.FormatUint(uint64(order.ID), 10), "order_fanout", "", event)
+	if err != nil {
+		return err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := outbox.Enqueue(tx, outboxSource, msg); err != nil {
+			return err
+		}
+		if _, err := transitionOrder(tx, order.ID, StatusPaid, ActorSystem, "Ödeme tahsil edildi"); err != nil {
+			return err
+		}
+		return saga.advance(tx, SagaCompleted, map[string]any{"status": SagaDone})
+	})
+	if err != nil {
+		return err
+	}
+	saga.Status = SagaDone
+	order.Status = StatusPaid
+	relay.Notify()
+	return nil
+}
+
+// ==============================================================================
+// TELAFİ (COMPENSATION)
+// ==============================================================================
+
+// compensate - Tamamlanan adımları tersten geri alır, cause'u geri döner
+// Bir telafi çağrısı başarısız olursa saga "compensating" kalır ve
+// resumeSagas daha sonra tekrar dener (tüm çağrılar idempotent).
+func compensate(saga *OrderSaga, cause error) error {
+	if cause != nil {
+		saga.Error = cause.Error()
+	}
+	saga.Status = SagaCompensating
+	DB.Model(saga).Updates(map[string]any{"status": saga.Status, "error": saga.Error})
+	log.Printf("↩️ Saga %s telafi ediliyor (adım: %s): %s", saga.ID, saga.Step, saga.Error)
+
+	productServiceURL := getEnv("PRODUCT_SERVICE_URL", "http://localhost:3001")
+
+	var failed error
+
+	// Ödeme: işlem ID'si yoksa provizyon alınmamıştır, dokunulmaz
+	// (yanıtı kaybolan provizyonu authorizePayment referansla iptal eder)
+	if saga.TransactionID != "" {
+		if err := reversePayment(saga.TransactionID, saga.ID); err != nil {
+			failed = errors.Join(failed, err)
+		}
+	}
+
+	// Sipariş kaydedildiyse iptal edilmiş olarak kalır (kayıt silinmez)
+	if saga.OrderID != nil {
+		var invalid errInvalidTransition
+		if _, err := setOrderStatus(*saga.OrderID, StatusCancelled, ActorSystem, saga.Error); err != nil && !errors.As(err, &invalid) {
+			failed = errors.Join(failed, err)
+		}
+	}
+
+	// Kupon: kullanım bırakılır, sayaç azalır; hiç ayrılmadıysa çağrı zararsız
+	if saga.CouponCode != "" {
+		if err := releaseCoupon(saga.ID); err != nil {
+			failed = errors.Join(failed, err)
+		}
+	}
+
+	// Stok: ayrılan/onaylanan adetler geri eklenir; rezervasyon hiç oluşmadıysa da çağrı zararsız
+	status, err := callService(http.MethodDelete, productServiceURL+"/products/reservations/"+saga.ID, nil, nil)
+	if err != nil || status != 200 {
+		failed = errors.Join(failed, fmt.Errorf("rezervasyon bırakılamadı: status %d, %v", status, err))
+	}
+
+	if failed != nil {
+		log.Printf("❌ Saga %s telafisi tamamlanamadı, tekrar denenecek: %s", saga.ID, failed)
+	} else {
+		saga.Status = SagaCompensated
+		DB.Model(saga).Update("status", saga.Status)
+	}
+	return cause
+}
+
+// reversePayment - Provizyonu iptal eder; tahsil edilmişse (void → 409) iade eder
+// İşlem ID'si kaydedilmeden çökülmüş olabilir → saga ID (referans) ile de bulunur.
+// Hiç provizyon alınmadıysa (404) veya zaten iptal/iade edildiyse başarılı sayılır.
+func reversePayment(transactionID, reference string) error {
+	paymentServiceURL := getEnv("PAYMENT_SERVICE_URL", "http://localhost:3005")
+
+	txn := fiber.Map{"transaction_id": transactionID, "reference": reference}
+	status, err := callService(http.MethodPost, paymentServiceURL+"/void", txn, nil)
+	if err == nil && status == http.StatusConflict {
+		status, err = callService(http.MethodPost, paymentServiceURL+"/refund", txn, nil)
+	}
+	if err != nil || (status != 200 && status != http.StatusNotFound) {
+		return fmt.Errorf("ödeme geri alınamadı: status %d, %v", status, err)
+	}
+	return nil
+}
+
+// releaseCoupon - Kupon kullanımını bırakır (saga telafisi / sipariş iptali)
+func releaseCoupon(sagaID string) error {
+	couponServiceURL := getEnv("COUPON_SERVICE_URL", "http://localhost:3010")
+
+	status, err := callService(http.MethodDelete, couponServiceURL+"/coupons/redemptions/"+sagaID, nil, nil)
+	if err != nil || status != 200 {
+		return fmt.Errorf("kupon kullanımı bırakılamadı: status %d, %v", status, err)
+	}
+	return nil
+}
+
+// ==============================================================================
+// ÇÖKME SONRASI KURTARMA
+// ==============================================================================
+
+// resumeSagas - Açılışta ve periyodik olarak yarıda kalan saga'ları sürdürür
+func resumeSagas() {
+	recoverSagas()
+	for range time.Tick(sagaResumeInterval) {
+		recoverSagas()
+	}
+}
+
+func recoverSagas() {
+	var sagas []OrderSaga
+	DB.Where("status IN ? AND updated_at < ?", []string{SagaRunning, SagaCompensating}, time.Now().Add(-sagaStaleAfter)).
+		Order("created_at").Find(&sagas)
+
+	for i := range sagas {
+		saga := &sagas[i]
+
+		// Birden fazla instance varsa saga'yı sadece biri devralır
+		claim := DB.Model(&OrderSaga{}).Where("id = ? AND updated_at = ?", saga.ID, saga.UpdatedAt).Update("updated_at", time.Now())
+		if claim.RowsAffected != 1 {
+			continue
+		}
+
+		log.Printf("🔁 Saga %s sürdürülüyor (adım: %s, durum: %s)", saga.ID, saga.Step, saga.Status)
+		resumeSaga(saga)
+	}
+}
+
+func resumeSaga(saga *OrderSaga) {
+	if saga.Status == SagaCompensating {
+		compensate(saga, nil)
+		return
+	}
+
+	switch saga.Step {
+	case SagaOrderPersisted:
+		if err := confirmStock(saga); err != nil {
+			compensate(saga, err)
+			return
+		}
+		fallthrough
+	case SagaStockConfirmed:
+		if err := commitCoupon(saga); err != nil {
+			compensate(saga, err)
+			return
+		}
+		fallthrough
+	case SagaCouponCommitted:
+		if err := capturePayment(saga); err != nil {
+			compensate(saga, err)
+			return
+		}
+		fallthrough
+	case SagaPaymentCaptured:
+		var order Order
+		if err := DB.Preload("Items").First(&order, saga.OrderID).Error; err != nil {
+			log.Printf("❌ Saga %s: sipariş bulunamadı: %s", saga.ID, err)
+			return
+		}
+		if err := completeSaga(saga, &order); err != nil {
+			log.Printf("⚠️ Saga %s: event kaydedilemedi: %s", saga.ID, err)
+		}
+	default:
+		// Sipariş kaydedilmeden kalmış: kart bilgisi olmadan ilerlenemez
+		compensate(saga, errors.New("saga yarıda kaldı (servis yeniden başladı)"))
+	}
+}
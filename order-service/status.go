@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/pkg/outbox"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==============================================================================
+// SİPARİŞ DURUMU (State Machine)
+// ==============================================================================
+/*
+Eski akış: PATCH /orders/:id/status her string'i kabul edip Status'ün üzerine
+yazardı → "İptal Edildi" bir sipariş tekrar "Kargolandı" olabilirdi.
+
+Yeni akış: Durumlar ve izinli geçişler burada tanımlıdır:
+
+	pending ──► paid ──► preparing ──► shipped ──► delivered
+	              ▲                                   │
+	pending_payment ──► payment_failed                ▼
+	                                              refunded
+	pending, pending_payment, paid, preparing ──► cancelled
+
+  - pending:         Sipariş kaydedildi, ödeme henüz tahsil edilmedi (saga)
+  - pending_payment: Müşterinin ödeme doğrulaması (3D Secure) bekleniyor
+  - payment_failed:  Doğrulama başarısız veya süresi doldu (son durum)
+  - paid:            Ödeme tahsil edildi
+  - preparing:       Paketleniyor
+  - shipped:         Kargoya verildi
+  - delivered:       Müşteriye ulaştı
+  - cancelled:       İptal edildi (son durum)
+  - refunded:        Tüm ürünler iade edildi (son durum, sadece iade süreci verir)
+
+Her geçiş:
+  - order_status_histories tablosuna kimin (actor) ve neden (reason) yaptığıyla yazılır
+  - "order_events" exchange'ine "order.status.changed" eventi olarak gider
+    (outbox ile, durum değişikliğiyle aynı transaction'da)
+*/
+
+const (
+	StatusPending        = "pending"
+	StatusPendingPayment = "pending_payment"
+	StatusPaymentFailed  = "payment_failed"
+	StatusPaid           = "paid"
+	StatusPreparing      = "preparing"
+	StatusShipped        = "shipped"
+	StatusDelivered      = "delivered"
+	StatusCancelled      = "cancelled"
+	StatusRefunded       = "refunded"
+)
+
+// orderTransitions - Her durumdan gidilebilecek durumlar
+var orderTransitions = map[string][]string{
+	StatusPending:        {StatusPaid, StatusCancelled},
+	StatusPendingPayment: {StatusPaid, StatusPaymentFailed, StatusCancelled},
+	StatusPaymentFailed:  {},
+	StatusPaid:           {StatusPreparing, StatusCancelled},
+	StatusPreparing:      {StatusShipped, StatusCancelled},
+	StatusShipped:        {StatusDelivered},
+	StatusDelivered:      {StatusRefunded},
+	StatusCancelled:      {},
+	StatusRefunded:       {},
+}
+
+// legacyStatuses - Eski (Türkçe) durum değerleri; PATCH isteklerinde de kabul edilir
+var legacyStatuses = map[string]string{
+	"Hazırlanıyor":  StatusPreparing,
+	"Kargolandı":    StatusShipped,
+	"Teslim Edildi": StatusDelivered,
+	"İptal Edildi":  StatusCancelled,
+}
+
+const (
+	orderEventsExchange          = "order_events"
+	orderStatusChangedRoutingKey = "order.status.changed"
+)
+
+// ActorSystem - Kullanıcı değil servisin kendisi yaptı (saga, zamanlayıcı)
+const ActorSystem = "system"
+
+// OrderStatusHistory - Bir siparişin durum geçmişi
+type OrderStatusHistory struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	OrderID    uint      `json:"order_id" gorm:"index"`
+	FromStatus string    `json:"from_status"` // İlk kayıtta boş
+	ToStatus   string    `json:"to_status"`
+	Actor      string    `json:"actor"` // "admin:1", "user:5", "system"
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OrderStatusChangedEvent - "order.status.changed" eventinin içeriği
+type OrderStatusChangedEvent struct {
+	OrderID   uint      `json:"order_id"`
+	UserID    uint      `json:"user_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// errInvalidTransition - İzin verilmeyen geçiş (409)
+type errInvalidTransition struct{ from, to string }
+
+func (e errInvalidTransition) Error() string {
+	return fmt.Sprintf("Sipariş durumu %s → %s olarak değiştirilemez", e.from, e.to)
+}
+
+var errOrderNotFound = errors.New("Sipariş bulunamadı")
+
+// parseOrderStatus - Geçerli durum kodunu döner (eski Türkçe değerler de kabul edilir)
+func parseOrderStatus(s string) (string, bool) {
+	if status, ok := legacyStatuses[s]; ok {
+		return status, true
+	}
+	_, ok := orderTransitions[s]
+	return s, ok
+}
+
+func canTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// actorFor - Geçmiş kaydındaki "kim yaptı" değeri
+func actorFor(role string, userID uint) string {
+	return role + ":" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// transitionOrder - Siparişin durumunu tx içinde değiştirir, geçmişe ve outbox'a yazar
+// Sipariş zaten hedef durumdaysa hiçbir şey yapmaz (saga tekrarları için idempotent).
+func transitionOrder(tx *gorm.DB, orderID uint, to, actor, reason string) (*Order, error) {
+	var order Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return nil, errOrderNotFound
+	}
+	from := order.Status
+	if from == to {
+		return &order, nil
+	}
+	if !canTransition(from, to) {
+		return nil, errInvalidTransition{from, to}
+	}
+
+	if err := tx.Model(&order).Update("status", to).Error; err != nil {
+		return nil, err
+	}
+	order.Status = to
+	if err := tx.Create(&OrderStatusHistory{OrderID: order.ID, FromStatus: from, ToStatus: to, Actor: actor, Reason: reason}).Error; err != nil {
+		return nil, err
+	}
+
+	msg, err := outbox.NewMessage("order", strconv.FormatUint(uint64(order.ID), 10),
+		orderEventsExchange, orderStatusChangedRoutingKey, OrderStatusChangedEvent{
+			OrderID:   order.ID,
+			UserID:    order.UserID,
+			From:      from,
+			To:        to,
+			Actor:     actor,
+			Reason:    reason,
+			ChangedAt: time.Now(),
+		})
+	if err != nil {
+		return nil, err
+	}
+	if err := outbox.Enqueue(tx, outboxSource, msg); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// setOrderStatus - transitionOrder'ı kendi transaction'ında çalıştırır
+func setOrderStatus(orderID uint, to, actor, reason string) (*Order, error) {
+	var order *Order
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = transitionOrder(tx, orderID, to, actor, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	relay.Notify()
+	return order, nil
+}
+
+// migrateLegacyStatuses - Eski Türkçe durum değerlerini kodlara çevirir (açılışta bir kez)
+func migrateLegacyStatuses() {
+	for legacy, status := range legacyStatuses {
+		DB.Model(&Order{}).Where("status = ?", legacy).Update("status", status)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     bool
+	}{
+		{"pending to paid", StatusPending, StatusPaid, true},
+		{"pending to cancelled", StatusPending, StatusCancelled, true},
+		{"pending payment to paid", StatusPendingPayment, StatusPaid, true},
+		{"pending payment to failed", StatusPendingPayment, StatusPaymentFailed, true},
+		{"pending payment to cancelled", StatusPendingPayment, StatusCancelled, true},
+		{"paid to preparing", StatusPaid, StatusPreparing, true},
+		{"preparing to shipped", StatusPreparing, StatusShipped, true},
+		{"shipped to delivered", StatusShipped, StatusDelivered, true},
+		{"delivered to refunded", StatusDelivered, StatusRefunded, true},
+
+		{"pending skips to shipped", StatusPending, StatusShipped, false},
+		{"pending to failed without 3ds", StatusPending, StatusPaymentFailed, false},
+		{"paid back to pending", StatusPaid, StatusPending, false},
+		{"paid to refunded by hand", StatusPaid, StatusRefunded, false},
+		{"preparing to refunded by hand", StatusPreparing, StatusRefunded, false},
+		{"shipped to cancelled", StatusShipped, StatusCancelled, false},
+		{"delivered to cancelled", StatusDelivered, StatusCancelled, false},
+		{"cancelled to shipped", StatusCancelled, StatusShipped, false},
+		{"cancelled to cancelled", StatusCancelled, StatusCancelled, false},
+		{"payment failed to paid", StatusPaymentFailed, StatusPaid, false},
+		{"refunded to delivered", StatusRefunded, StatusDelivered, false},
+		{"unknown source status", "Hazırlanıyor", StatusShipped, false},
+		{"unknown target status", StatusPaid, "lost", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("canTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOrderStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{"canonical status", "shipped", StatusShipped, true},
+		{"pending payment", "pending_payment", StatusPendingPayment, true},
+		{"refunded is a known status", "refunded", StatusRefunded, true},
+		{"legacy preparing", "Hazırlanıyor", StatusPreparing, true},
+		{"legacy shipped", "Kargolandı", StatusShipped, true},
+		{"legacy delivered", "Teslim Edildi", StatusDelivered, true},
+		{"legacy cancelled", "İptal Edildi", StatusCancelled, true},
+		{"wrong case", "Shipped", "", false},
+		{"unknown legacy value", "Beklemede", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseOrderStatus(tt.input)
+			if ok != tt.wantOK {
+				t.Fatalf("parseOrderStatus(%q) ok = %v, want %v", tt.input, ok, tt.wantOK)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parseOrderStatus(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// Her durum geçiş tablosunda olmalı: yoksa parseOrderStatus onu reddeder
+func TestOrderTransitionsCoverAllStatuses(t *testing.T) {
+	statuses := []string{
+		StatusPending, StatusPendingPayment, StatusPaymentFailed, StatusPaid, StatusPreparing,
+		StatusShipped, StatusDelivered, StatusCancelled, StatusRefunded,
+	}
+	for _, s := range statuses {
+		if _, ok := orderTransitions[s]; !ok {
+			t.Errorf("%s geçiş tablosunda yok", s)
+		}
+	}
+	for from, targets := range orderTransitions {
+		for _, to := range targets {
+			if _, ok := orderTransitions[to]; !ok {
+				t.Errorf("%s → %s: hedef durum tabloda yok", from, to)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"ecommerce-backend/pkg/outbox"
+	"ecommerce-backend/pkg/payment"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ==============================================================================
+// ASENKRON ÖDEME: EVENTLER VE WEBHOOK'LAR
+// ==============================================================================
+/*
+3D Secure gerektiren kartta provizyon hemen sonuçlanmaz:
+
+	1. Order Service /authorize'a "return_url" ile gelir
+	   → Ödeme "pending" kaydedilir, yanıt 202 + "action_url"
+	2. Müşteri action_url'deki doğrulama sayfasına yönlendirilir
+	3. Sağlayıcı sonucu HMAC imzalı webhook ile bildirir:
+	     POST /webhooks/provider   (X-Payment-Signature, PAYMENT_WEBHOOK_SECRET)
+	   Simülatörde doğrulama sayfası bu serviste: GET/POST /simulator/3ds/:ref
+	4. Ödeme "authorized" veya "failed" olur, müşteri return_url'e döner
+
+Her durum değişikliği "payment_events" (topic) exchange'ine outbox ile yazılır:
+
+	payment.pending | payment.authorized | payment.failed |
+	payment.captured | payment.voided | payment.refunded
+
+Order Service "payment.authorized" ve "payment.failed" ile bekleyen siparişi
+ilerletir. Eventler "en az bir kez" teslim edilir; consumer'lar idempotenttir.
+*/
+
+const paymentEventsExchange = "payment_events"
+
+// PaymentEvent - "payment.<durum>" eventinin içeriği
+type PaymentEvent struct {
+	TransactionID  string    `json:"transaction_id"`
+	Reference      string    `json:"reference"` // Order Service saga ID'si
+	OrderID        *uint     `json:"order_id"`
+	Status         string    `json:"status"`
+	Amount         float64   `json:"amount"`
+	CapturedAmount float64   `json:"captured_amount"`
+	RefundedAmount float64   `json:"refunded_amount"`
+	Currency       string    `json:"currency"`
+	FailureCode    string    `json:"failure_code,omitempty"`
+	FailureMessage string    `json:"failure_message,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// enqueuePaymentEvent - Durum değişikliğiyle aynı transaction'da outbox'a yazar
+func enqueuePaymentEvent(tx *gorm.DB, p *Payment, event string) error {
+	msg, err := outbox.NewMessage("payment", p.ID, paymentEventsExchange, "payment."+event, PaymentEvent{
+		TransactionID:  p.ID,
+		Reference:      p.Reference,
+		OrderID:        p.OrderID,
+		Status:         p.Status,
+		Amount:         p.Amount,
+		CapturedAmount: p.CapturedAmount,
+		RefundedAmount: p.RefundedAmount,
+		Currency:       p.Currency,
+		FailureCode:    p.FailureCode,
+		FailureMessage: p.FailureMessage,
+		OccurredAt:     time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return outbox.Enqueue(tx, outboxSource, msg)
+}
+
+// completePayment - Webhook ve simülatör sayfası ortak: sonucu işler ve loglar
+func completePayment(store *PaymentStore, result *payment.Result) (*Payment, error) {
+	p, err := store.Complete(result)
+	if err != nil {
+		return p, err
+	}
+	log.Printf("💳 3D Secure sonucu: %s → %s", p.ID, p.Status)
+	return p, nil
+}
+
+// registerWebhookRoutes - Sağlayıcının sonuç bildirimi (gateway: POST /api/payments/webhooks)
+// PAYMENT_WEBHOOK_SECRET tanımlı değilse endpoint kapalıdır.
+func registerWebhookRoutes(app fiber.Router, store *PaymentStore) {
+	secret := []byte(getEnv("PAYMENT_WEBHOOK_SECRET", ""))
+
+	app.Post("/webhooks/provider", func(c *fiber.Ctx) error {
+		if len(secret) == 0 {
+			return c.Status(404).JSON(fiber.Map{"error": "Webhook tanımlı değil"})
+		}
+		if err := payment.VerifyWebhook(secret, c.Get(payment.SignatureHeader), c.Body(), time.Now(), payment.DefaultSignatureTolerance); err != nil {
+			log.Printf("⚠️ Geçersiz webhook imzası (%s): %s", c.IP(), err)
+			return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		var result payment.Result
+		if err := c.BodyParser(&result); err != nil || result.ProviderRef == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Veri formatı hatalı"})
+		}
+
+		_, err := completePayment(store, &result)
+		switch {
+		case errors.Is(err, ErrTxnNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, ErrInvalidTxnStep):
+			// Ödeme bu arada iptal edildi (süre doldu): sağlayıcı tekrar göndermesin
+			log.Printf("⚠️ Webhook yok sayıldı, işlem artık bekleyen durumda değil: %s", result.ProviderRef)
+			return c.JSON(fiber.Map{"received": true})
+		case err != nil:
+			return c.Status(500).JSON(fiber.Map{"error": "Webhook işlenemedi"}) // Sağlayıcı tekrar dener
+		}
+		return c.JSON(fiber.Map{"received": true})
+	})
+}
+
+// registerSimulatorRoutes - Sanal 3D Secure sayfası (sadece PAYMENT_PROVIDER=simulator)
+// Gerçek sağlayıcıda bu sayfa bankadadır; burada kimlik doğrulaması yoktur.
+func registerSimulatorRoutes(app fiber.Router, sim *payment.Simulator, store *PaymentStore) {
+	app.Get("/simulator/3ds/:ref", func(c *fiber.Ctx) error {
+		ref := html.EscapeString(c.Params("ref"))
+		c.Type("html", "utf-8")
+		return c.SendString(fmt.Sprintf(`<!doctype html>
+<html lang="tr"><head><meta charset="utf-8"><title>3D Secure</title></head>
+<body style="font-family:sans-serif;max-width:420px;margin:60px auto;text-align:center">
+<h2>3D Secure Doğrulama (Simülatör)</h2>
+<p>İşlem: <code>%s</code></p>
+<form method="post"><button name="result" value="approve">Onayla</button>
+<button name="result" value="fail">Başarısız</button></form>
+</body></html>`, ref))
+	})
+
+	app.Post("/simulator/3ds/:ref", func(c *fiber.Ctx) error {
+		ctx, cancel := providerContext()
+		defer cancel()
+
+		result, err := sim.CompleteChallenge(ctx, c.Params("ref"), c.FormValue("result") == "approve")
+		switch {
+		case errors.Is(err, payment.ErrNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": ErrTxnNotFound.Error()})
+		case errors.Is(err, payment.ErrInvalidState):
+			return c.Status(409).JSON(fiber.Map{"error": "İşlem artık doğrulama beklemiyor", "status": result.Status})
+		case err != nil:
+			return providerError(c, err)
+		}
+		p, err := completePayment(store, result)
+		if err != nil && !errors.Is(err, ErrInvalidTxnStep) {
+			return c.Status(500).JSON(fiber.Map{"error": "Doğrulama sonucu kaydedilemedi"})
+		}
+		if p != nil && p.ReturnURL != "" {
+			return c.Redirect(p.ReturnURL, fiber.StatusSeeOther)
+		}
+		return c.JSON(result)
+	})
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"ecommerce-backend/pkg/payment"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==============================================================================
+// ÖDEME KAYITLARI: AUTHORIZE → CAPTURE / VOID → REFUND
+// ==============================================================================
+/*
+Order Service saga'sı ödemeyi iki adımda alır:
+
+	POST /authorize               → Tutar karttan bloke edilir (henüz çekilmez)
+	POST /payments/:id/capture    → Sipariş kaydedildi, bloke tutar çekilir
+	POST /payments/:id/void       → Sipariş oluşmadı, bloke kaldırılır
+	POST /payments/:id/refund     → Çekilmiş tutar iade edilir (tamamı veya bir kısmı)
+	GET  /payments/:id            → Ödeme ve iadeleri
+	GET  /payments?from=&to=      → Tarih aralığındaki ödemeler (Order Service mutabakatı)
+
+(/capture, /void, /refund da çalışır: işlem ID'si body'de, bilinmiyorsa
+"reference" ile bulunur.)
+
+Para hareketini ödeme sağlayıcısı (payment.PaymentProvider) yapar; her işlem
+"payments" tablosunda sağlayıcı referansı (provider_ref), sipariş, tutar,
+para birimi ve durumla saklanır:
+
+	                  ┌──(webhook: başarısız)──► failed
+	pending (3DS) ────┤
+	    │             └──(webhook: onay)──┐
+	    └──void (süre doldu)──► voided     ▼
+	                                 authorized ──capture──► captured ──refund (tamamı)──► refunded
+	                                     │
+	                                     └──void──► voided
+
+	(sağlayıcı reddetti) ──► failed
+
+Her durum değişikliği "payment_events" exchange'ine "payment.<durum>"
+eventi olarak gider (outbox ile, değişiklikle aynı transaction'da; events.go).
+
+authorize isteğindeki "reference" (saga ID) idempotency anahtarıdır (UNIQUE):
+aynı referansla tekrar gelen istek yeni işlem açmaz, ilk sonucu döner.
+Reddedilen ödeme aynı hatayla döner; sadece zaman aşımı / sağlayıcı hatası
+ile "failed" olan ödeme aynı referansla tekrar denenir. Referans sağlayıcıya
+da iletilir; saga aynı adımı tekrarlasa da kart iki kez bloke edilmez.
+
+Kısmi iade (ürün iadesi): refund isteğinde "amount" ve "refund_id" gönderilir.
+refund_id idempotency anahtarıdır (UNIQUE); aynı ID ile tekrar gelen istek
+ikinci kez para iade etmez. İade edilen toplam tahsil edilen tutarı aşamaz.
+Tutar gönderilmezse kalan tutarın tamamı iade edilir; tamamı iade edilen
+ödeme "refunded" olur, kısmi iadede "captured" kalır (refunded_amount artar).
+
+Sağlayıcı çağrıları sırasında satır kilidi tutulmaz (yavaş bir sağlayıcı DB
+bağlantılarını bekletmesin). Durum, "WHERE status = <beklenen>" koşullu
+UPDATE ile değişir; sağlayıcı işlemleri idempotent olduğu için aynı ödeme
+üzerinde eşzamanlı iki istek para hareketini ikilemez.
+*/
+
+const (
+	PaymentPending    = "pending" // Müşteri doğrulaması (3D Secure) bekleniyor
+	PaymentAuthorized = "authorized"
+	PaymentCaptured   = "captured"
+	PaymentVoided     = "voided"
+	PaymentRefunded   = "refunded"
+	PaymentFailed     = "failed"
+)
+
+var (
+	ErrTxnNotFound    = errors.New("İşlem bulunamadı")
+	ErrInvalidTxnStep = errors.New("İşlem bu durumda değiştirilemez")
+	ErrRefundExceeds  = errors.New("İade tutarı tahsil edilen tutarı aşıyor")
+	ErrInvalidAmount  = errors.New("Geçersiz iade tutarı")
+)
+
+// Payment - Bir kart işlemi
+type Payment struct {
+	ID             string          `json:"transaction_id" gorm:"primaryKey;size:40"` // "TXN_..."
+	Reference      string          `json:"reference" gorm:"uniqueIndex:idx_payment_reference,where:reference <> ''"`
+	OrderID        *uint           `json:"order_id" gorm:"index"` // Tahsilatta Order Service'ten gelir
+	Amount         float64         `json:"amount"`
+	CapturedAmount float64         `json:"captured_amount"`
+	RefundedAmount float64         `json:"refunded_amount"`
+	Currency       string          `json:"currency" gorm:"size:3;default:'TRY'"`
+	Status         string          `json:"status" gorm:"index"`
+	ProviderRef    string          `json:"provider_ref" gorm:"index"` // Sağlayıcıdaki işlem ID'si
+	CardBrand      string          `json:"card_brand,omitempty"`      // "visa", "mastercard"...
+	CardLast4      string          `json:"card_last4,omitempty"`      // Kart numarasının tamamı saklanmaz
+	ActionURL      string          `json:"action_url,omitempty"`      // pending: müşterinin doğrulama sayfası
+	ReturnURL      string          `json:"-"`                         // Doğrulamadan sonra müşterinin döneceği adres
+	FailureCode    string          `json:"failure_code,omitempty"`    // "declined", "timeout"...
+	FailureMessage string          `json:"failure_message,omitempty"`
+	Refunds        []PaymentRefund `json:"refunds,omitempty" gorm:"foreignKey:PaymentID"`
+	CreatedAt      time.Time       `json:"created_at" gorm:"index"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// PaymentRefund - Tahsil edilmiş bir ödemeden yapılan (kısmi) iade
+type PaymentRefund struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:40"` // "RFD_..."
+	PaymentID string    `json:"transaction_id" gorm:"index;size:40"`
+	RefundID  string    `json:"refund_id" gorm:"uniqueIndex:idx_payment_refund_key,where:refund_id <> ''"` // İstemcinin idempotency anahtarı
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PaymentStore - Ödeme kayıtları + sağlayıcı + kart token'ları
+type PaymentStore struct {
+	db       *gorm.DB
+	provider payment.PaymentProvider
+	vault    *payment.Vault
+}
+
+func NewPaymentStore(db *gorm.DB, provider payment.PaymentProvider, vault *payment.Vault) *PaymentStore {
+	return &PaymentStore{db: db, provider: provider, vault: vault}
+}
+
+func newID(prefix string) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic("payment: rastgele ID üretilemedi: " + err.Error())
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+func newTransactionID() string { return newID("TXN_") }
+
+// roundAmount - Kuruşa yuvarlar
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// isDuplicate - Unique index ihlali (eşzamanlı aynı istek)
+func isDuplicate(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || (err != nil && strings.Contains(err.Error(), "23505"))
+}
+
+// retryable - Bu hatayla "failed" olan ödeme aynı referansla tekrar denenebilir
+func retryable(code string) bool {
+	return code == payment.ErrTimeout.Code || code == payment.ErrProvider.Code
+}
+
+// Get - Ödemeyi iadeleriyle birlikte döner
+func (s *PaymentStore) Get(id string) (*Payment, error) {
+	var p Payment
+	if err := s.db.Preload("Refunds", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	}).First(&p, "id = ?", id).Error; err != nil {
+		return nil, ErrTxnNotFound
+	}
+	return &p, nil
+}
+
+// List - [from, to) aralığında oluşturulan ödemeler (iadeler hariç, refunded_amount yeterli)
+// Bir sonraki sayfa olup olmadığını limit+1 satır çekerek anlar.
+func (s *PaymentStore) List(from, to time.Time, offset, limit int) ([]Payment, bool, error) {
+	var payments []Payment
+	err := s.db.Where("created_at >= ? AND created_at < ?", from, to).
+		Order("created_at, id").Offset(offset).Limit(limit + 1).Find(&payments).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(payments) > limit {
+		return payments[:limit], true, nil
+	}
+	return payments, false, nil
+}
+
+// maxPaymentLookup - ListByID'nin tek istekte kabul ettiği işlem sayısı
+const maxPaymentLookup = 500
+
+// ListByID - Verilen işlem ID'lerinin ödemeleri (bulunamayanlar atlanır)
+func (s *PaymentStore) ListByID(ids []string) ([]Payment, error) {
+	var payments []Payment
+	err := s.db.Where("id IN ?", ids).Order("created_at, id").Find(&payments).Error
+	return payments, err
+}
+
+// find - Ödemeyi ID'siyle, yoksa referansla bulur
+func (s *PaymentStore) find(req TransactionRequest) (*Payment, error) {
+	var p Payment
+	if req.TransactionID != "" && s.db.First(&p, "id = ?", req.TransactionID).Error == nil {
+		return &p, nil
+	}
+	if req.Reference != "" && s.db.First(&p, "reference = ?", req.Reference).Error == nil {
+		return &p, nil
+	}
+	return nil, ErrTxnNotFound
+}
+
+// Authorize - Tutarı bloke eder; aynı referans ikinci kez gelirse ilk sonuç döner
+func (s *PaymentStore) Authorize(ctx context.Context, req PaymentRequest) (*Payment, error) {
+	var existing *Payment
+	if req.Reference != "" {
+		if p, err := s.find(TransactionRequest{Reference: req.Reference}); err == nil {
+			switch {
+			case p.Status != PaymentFailed:
+				return p, nil
+			case !retryable(p.FailureCode):
+				return p, &payment.Error{Code: p.FailureCode, Message: p.FailureMessage}
+			}
+			existing = p // Zaman aşımı: sağlayıcıya aynı referansla tekrar sor
+		}
+	}
+	if req.Amount <= 0 {
+		return nil, payment.ErrInvalidCard
+	}
+	card, brand, err := s.card(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = payment.DefaultCurrency
+	}
+
+	p := existing
+	if p == nil {
+		p = &Payment{ID: newTransactionID(), Reference: req.Reference, OrderID: req.OrderID}
+	}
+	p.Amount, p.Currency = roundAmount(req.Amount), currency
+	p.CardBrand, p.CardLast4, p.ReturnURL = brand, payment.Last4(card.Number), req.ReturnURL
+
+	result, err := s.provider.Authorize(ctx, payment.AuthorizeRequest{
+		Reference: req.Reference,
+		Amount:    p.Amount,
+		Currency:  currency,
+		Card:      card,
+		ReturnURL: req.ReturnURL,
+	})
+	if err != nil {
+		var providerErr *payment.Error
+		if !errors.As(err, &providerErr) {
+			providerErr = &payment.Error{Code: payment.ErrProvider.Code, Message: err.Error()}
+		}
+		// Sağlayıcı hata mesajında kart numarasını tekrarlamış olabilir
+		p.Status, p.FailureCode, p.FailureMessage = PaymentFailed, providerErr.Code, payment.RedactPAN(providerErr.Message)
+		if saveErr := s.save(p, existing == nil); saveErr != nil {
+			return nil, saveErr
+		}
+		return p, err
+	}
+
+	p.Status, p.ProviderRef, p.FailureCode, p.FailureMessage = PaymentAuthorized, result.ProviderRef, "", ""
+	if result.Status == payment.StatusPending {
+		// 3D Secure: sonuç sağlayıcıdan webhook ile gelecek (Complete)
+		p.Status, p.ActionURL = PaymentPending, result.ActionURL
+	}
+	if err := s.save(p, existing == nil); err != nil {
+		return nil, err
+	}
+	if req.PaymentToken != "" {
+		s.vault.Delete(ctx, req.PaymentToken) // Token tek kullanımlık; silinemezse TTL ile düşer
+	}
+	return p, nil
+}
+
+// card - İstekteki token'ı çözer (kart /tokens'ta doğrulanmıştır)
+// Token yoksa veya geçersizse sağlayıcıya istek gitmez.
+func (s *PaymentStore) card(ctx context.Context, req PaymentRequest) (payment.Card, string, error) {
+	if req.PaymentToken != "" {
+		card, info, err := s.vault.Resolve(ctx, req.PaymentToken)
+		if err != nil {
+			return payment.Card{}, "", err
+		}
+		return card, info.Brand, nil
+	}
+	// Ham kart bilgisi handler'da reddedilir, token yoksa kart da yok
+	return payment.Card{}, "", payment.ErrInvalidCard
+}
+
+// save - Yeni ödemeyi yazar veya tekrar denenen ödemeyi günceller (eventiyle birlikte)
+// Aynı referansla eşzamanlı gelen istek önce yazdıysa onun kaydı döner.
+func (s *PaymentStore) save(p *Payment, create bool) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		write := tx.Save
+		if create {
+			write = tx.Create
+		}
+		if err := write(p).Error; err != nil {
+			return err
+		}
+		return enqueuePaymentEvent(tx, p, p.Status)
+	})
+	if err == nil {
+		relay.Notify()
+	}
+	if create && isDuplicate(err) {
+		winner, findErr := s.find(TransactionRequest{Reference: p.Reference})
+		if findErr != nil {
+			return err
+		}
+		*p = *winner
+		return nil
+	}
+	return err
+}
+
+// Capture - Bloke tutarı çeker (amount 0 → tamamı)
+// orderID doluysa ödeme siparişe bağlanır.
+func (s *PaymentStore) Capture(ctx context.Context, req TransactionRequest) (*Payment, error) {
+	return s.transition(req, []string{PaymentAuthorized}, PaymentCaptured, func(p *Payment) (map[string]any, error) {
+		result, err := s.provider.Capture(ctx, p.ProviderRef, req.Amount)
+		if err != nil {
+			return nil, err
+		}
+		updates := map[string]any{"captured_amount": result.CapturedAmount}
+		if req.OrderID != nil {
+			updates["order_id"] = *req.OrderID
+		}
+		return updates, nil
+	})
+}
+
+// Void - Blokeyi kaldırır; bekleyen (3DS) ödemede doğrulamayı geçersiz kılar
+// Başarısız ödemede kaldırılacak bloke yoktur, hata dönmez (telafi adımları için).
+func (s *PaymentStore) Void(ctx context.Context, req TransactionRequest) (*Payment, error) {
+	if p, err := s.find(req); err == nil && p.Status == PaymentFailed {
+		return p, nil
+	}
+	return s.transition(req, []string{PaymentAuthorized, PaymentPending}, PaymentVoided, func(p *Payment) (map[string]any, error) {
+		_, err := s.provider.Void(ctx, p.ProviderRef)
+		return map[string]any{"action_url": ""}, err
+	})
+}
+
+// Complete - Bekleyen (3DS) ödemenin sağlayıcıdan gelen sonucunu işler
+// Sonuç zaten işlendiyse mevcut kayıt döner (webhook tekrar gelebilir).
+func (s *PaymentStore) Complete(result *payment.Result) (*Payment, error) {
+	var p Payment
+	if err := s.db.First(&p, "provider_ref = ?", result.ProviderRef).Error; err != nil {
+		return nil, ErrTxnNotFound
+	}
+	req := TransactionRequest{TransactionID: p.ID}
+	pending := []string{PaymentPending}
+
+	switch result.Status {
+	case payment.StatusAuthorized:
+		return s.transition(req, pending, PaymentAuthorized, func(*Payment) (map[string]any, error) {
+			return map[string]any{"action_url": ""}, nil
+		})
+	case payment.StatusFailed:
+		code, message := result.FailureCode, result.FailureMessage
+		if code == "" {
+			code, message = payment.ErrAuthenticationFailed.Code, payment.ErrAuthenticationFailed.Message
+		}
+		return s.transition(req, pending, PaymentFailed, func(*Payment) (map[string]any, error) {
+			return map[string]any{"action_url": "", "failure_code": code, "failure_message": payment.RedactPAN(message)}, nil
+		})
+	}
+	return &p, nil // Diğer durumları (capture, void, refund) bu servis başlatır
+}
+
+// transition - Ödemeyi "from" durumlarından birinden "to" durumuna sağlayıcıda taşır
+// Ödeme zaten "to" durumundaysa hata dönmez (telafi adımları tekrar edilebilir).
+func (s *PaymentStore) transition(req TransactionRequest, from []string, to string, call func(p *Payment) (map[string]any, error)) (*Payment, error) {
+	p, err := s.find(req)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == to {
+		return p, nil
+	}
+	if !slices.Contains(from, p.Status) {
+		return p, ErrInvalidTxnStep
+	}
+
+	updates, err := call(p)
+	if errors.Is(err, payment.ErrInvalidState) {
+		return p, ErrInvalidTxnStep
+	}
+	if err != nil {
+		return p, err
+	}
+
+	if updates == nil {
+		updates = map[string]any{}
+	}
+	updates["status"] = to
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Eşzamanlı istek önce değiştirdiyse satır güncellenmez (event de yazılmaz)
+		changed := tx.Model(&Payment{}).Where("id = ? AND status = ?", p.ID, p.Status).Updates(updates)
+		if changed.Error != nil || changed.RowsAffected == 0 {
+			return changed.Error
+		}
+		if err := tx.First(p, "id = ?", p.ID).Error; err != nil {
+			return err
+		}
+		return enqueuePaymentEvent(tx, p, to)
+	})
+	if err != nil {
+		return p, err
+	}
+	relay.Notify()
+	return s.Get(p.ID)
+}
+
+// Refund - Tahsil edilmiş tutarın tamamını veya bir kısmını iade eder
+// Aynı refund_id ile tekrar çağrılırsa ilk iade döner (para iki kez iade edilmez).
+// Tutar 0 ise kalan tutarın tamamı iade edilir; tamamen iade edilmiş ödeme için
+// tekrar tam iade istenirse hata dönmez (telafi adımları tekrar edilebilir).
+func (s *PaymentStore) Refund(ctx context.Context, req TransactionRequest) (*Payment, *PaymentRefund, error) {
+	p, err := s.find(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.RefundID != "" {
+		var existing PaymentRefund
+		if s.db.First(&existing, "refund_id = ?", req.RefundID).Error == nil {
+			return p, &existing, nil
+		}
+	}
+
+	// Kontrol yuvarlanmış tutarla yapılır: 10.004 gibi bir istek kalan 10.00'ı aşmış sayılmasın
+	amount := roundAmount(req.Amount)
+	remaining := roundAmount(p.CapturedAmount - p.RefundedAmount)
+	switch {
+	case p.Status == PaymentRefunded && req.Amount == 0:
+		return p, nil, nil
+	case p.Status != PaymentCaptured:
+		return p, nil, ErrInvalidTxnStep
+	case req.Amount < 0 || (req.Amount > 0 && amount == 0):
+		// 0.004 → 0 "kalanın tamamı" anlamına gelmemeli
+		return p, nil, ErrInvalidAmount
+	case amount > remaining:
+		return p, nil, ErrRefundExceeds
+	}
+	if amount == 0 {
+		amount = remaining
+	}
+
+	// refund_id yoksa sağlayıcı tarafında da idempotent olsun diye üretilir
+	refund := &PaymentRefund{ID: newID("RFD_"), PaymentID: p.ID, RefundID: req.RefundID, Amount: amount}
+	providerRefundID := req.RefundID
+	if providerRefundID == "" {
+		providerRefundID = refund.ID
+	}
+	result, err := s.provider.Refund(ctx, p.ProviderRef, payment.RefundRequest{Amount: refund.Amount, RefundID: providerRefundID})
+	switch {
+	case errors.Is(err, payment.ErrAmountExceeded):
+		return p, nil, ErrRefundExceeds
+	case errors.Is(err, payment.ErrInvalidState):
+		return p, nil, ErrInvalidTxnStep
+	case err != nil:
+		return p, nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(refund)
+		if created.Error != nil {
+			return created.Error
+		}
+		if created.RowsAffected == 0 {
+			// Aynı refund_id ile eşzamanlı istek önce yazdı
+			return tx.First(refund, "refund_id = ?", req.RefundID).Error
+		}
+
+		status := p.Status
+		if result.Status == payment.StatusRefunded {
+			status = PaymentRefunded
+		}
+		if err := tx.Model(&Payment{}).Where("id = ?", p.ID).Updates(map[string]any{
+			"refunded_amount": result.RefundedAmount, // Sağlayıcıdaki toplam (kaynak o)
+			"status":          status,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.First(p, "id = ?", p.ID).Error; err != nil {
+			return err
+		}
+		// Kısmi iadede de "payment.refunded" (ödeme "captured" kalsa da)
+		return enqueuePaymentEvent(tx, p, PaymentRefunded)
+	})
+	if err != nil {
+		return p, nil, err
+	}
+	relay.Notify()
+
+	p, err = s.Get(p.ID)
+	return p, refund, err
+}
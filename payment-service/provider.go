@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"ecommerce-backend/pkg/payment"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ==============================================================================
+// ÖDEME SAĞLAYICISI SEÇİMİ
+// ==============================================================================
+/*
+	PAYMENT_PROVIDER=simulator   (varsayılan) Süreç içi sanal banka
+	  PAYMENT_SIMULATOR_SCENARIOS="kart=senaryo,..."  Ek test kartları
+	  PAYMENT_SIMULATOR_CHALLENGE_URL  Sanal 3D Secure sayfasının tarayıcıdan erişilen adresi
+	PAYMENT_PROVIDER=http        REST API'li sağlayıcı
+	  PAYMENT_PROVIDER_URL, PAYMENT_PROVIDER_API_KEY
+	  PAYMENT_WEBHOOK_SECRET      3D Secure sonuç webhook'larının HMAC anahtarı
+
+PAYMENT_PROVIDER_TIMEOUT (varsayılan 8s) Order Service'in istek zaman
+aşımından (10s) kısa olmalı: sağlayıcı yanıt vermezse Order Service'e
+zaman aşımı yerine anlamlı bir hata döner.
+*/
+
+func providerTimeout() time.Duration {
+	timeout, err := time.ParseDuration(getEnv("PAYMENT_PROVIDER_TIMEOUT", "8s"))
+	if err != nil || timeout <= 0 {
+		return 8 * time.Second
+	}
+	return timeout
+}
+
+// newProvider - Ortam değişkenlerine göre sağlayıcıyı kurar; ayar hatalıysa servis başlamaz
+func newProvider() payment.PaymentProvider {
+	switch name := getEnv("PAYMENT_PROVIDER", "simulator"); name {
+	case "simulator":
+		scenarios, err := payment.ParseScenarios(getEnv("PAYMENT_SIMULATOR_SCENARIOS", ""))
+		if err != nil {
+			log.Fatalf("❌ PAYMENT_SIMULATOR_SCENARIOS: %s", err)
+		}
+		log.Printf("💳 Ödeme sağlayıcısı: simülatör (%d özel senaryo)", len(scenarios))
+		return payment.NewSimulator(payment.SimulatorConfig{
+			Scenarios:    scenarios,
+			ChallengeURL: getEnv("PAYMENT_SIMULATOR_CHALLENGE_URL", "http://localhost:8080/api/payments/3ds/"),
+		})
+	case "http":
+		url := getEnv("PAYMENT_PROVIDER_URL", "")
+		if url == "" {
+			log.Fatal("❌ PAYMENT_PROVIDER=http için PAYMENT_PROVIDER_URL gerekli")
+		}
+		log.Printf("💳 Ödeme sağlayıcısı: %s", url)
+		return payment.NewHTTPProvider(url, getEnv("PAYMENT_PROVIDER_API_KEY", ""), providerTimeout())
+	default:
+		log.Fatalf("❌ Bilinmeyen PAYMENT_PROVIDER: %s", name)
+		return nil
+	}
+}
+
+// providerContext - Sağlayıcı çağrısının süresi (HTTP isteğinden bağımsız)
+func providerContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), providerTimeout())
+}
+
+// invalidCardErrors - Sağlayıcıya gitmeden yakalanan kart / token hataları (400)
+var invalidCardErrors = []*payment.Error{
+	payment.ErrInvalidCard,
+	payment.ErrInvalidCardNumber,
+	payment.ErrInvalidExpiry,
+	payment.ErrCardExpired,
+	payment.ErrInvalidCVV,
+	payment.ErrInvalidToken,
+}
+
+// providerError - Sağlayıcı hatasını HTTP yanıtına çevirir
+//
+//	Kart / token doğrulanamadı                       → 400 + "code"
+//	Kart reddi (declined, insufficient_funds, 3DS...) → 402 + "code"
+//	Sağlayıcı zaman aşımı                            → 504
+//	Sağlayıcıya ulaşılamadı / 5xx                    → 502
+//
+// Mesajlar kart numarası içeremez (RedactPAN).
+func providerError(c *fiber.Ctx, err error) error {
+	for _, invalid := range invalidCardErrors {
+		if errors.Is(err, invalid) {
+			return c.Status(400).JSON(fiber.Map{"status": "failed", "code": invalid.Code, "error": invalid.Message})
+		}
+	}
+
+	var providerErr *payment.Error
+	switch {
+	case errors.Is(err, payment.ErrTimeout):
+		return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"status": "failed", "code": payment.ErrTimeout.Code, "error": payment.RedactPAN(err.Error())})
+	case errors.Is(err, payment.ErrProvider):
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"status": "failed", "code": payment.ErrProvider.Code, "error": payment.ErrProvider.Message})
+	case errors.As(err, &providerErr):
+		return c.Status(fiber.StatusPaymentRequired).JSON(fiber.Map{"status": "failed", "code": providerErr.Code, "error": payment.RedactPAN(providerErr.Message)})
+	}
+	return c.Status(500).JSON(fiber.Map{"status": "failed", "error": "Ödeme işlenemedi"})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"ecommerce-backend/pkg/payment"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// ==============================================================================
+// KART TOKEN'LARI (POST /tokens)
+// ==============================================================================
+/*
+Kart bilgisi sadece bu servise girer. İstemci kartı gateway üzerinden
+POST /api/payments/tokens ile gönderir ve bir "payment_token" alır; siparişi
+bu token ile verir. order-service kart numarası veya CVV görmez, sadece
+token'ı /authorize'a iletir.
+
+	PAYMENT_TOKEN_KEY   Kart kayıtlarının şifreleme anahtarı (64 hex = 32 byte)
+	                    Boşsa her açılışta rastgele üretilir: servis yeniden
+	                    başlayınca bekleyen token'lar çözülemez ve birden fazla
+	                    instance token paylaşamaz (sadece geliştirme için)
+	PAYMENT_TOKEN_TTL   Token'ın geçerlilik süresi (varsayılan 15m)
+
+Token'lar Redis'te şifreli tutulur ve provizyon alındıktan sonra silinir.
+*/
+
+// TokenizeRequest - İstemcinin kart bilgisi
+type TokenizeRequest struct {
+	CardNumber string `json:"card_number"`
+	CVV        string `json:"cvv"`
+	Expiry     string `json:"expiry"`
+}
+
+func tokenKey() []byte {
+	if value := getEnv("PAYMENT_TOKEN_KEY", ""); value != "" {
+		key, err := hex.DecodeString(value)
+		if err != nil || len(key) != 32 {
+			log.Fatal("❌ PAYMENT_TOKEN_KEY 64 karakterlik hex (32 byte) olmalı")
+		}
+		return key
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal("❌ Token anahtarı üretilemedi:", err)
+	}
+	log.Println("⚠️  PAYMENT_TOKEN_KEY tanımlı değil, geçici anahtar üretildi (yeniden başlatınca token'lar geçersiz olur)")
+	return key
+}
+
+func tokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(getEnv("PAYMENT_TOKEN_TTL", "15m"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+// newVault - Token kasası (Redis, tüm instance'lar arasında paylaşılır)
+func newVault(rdb *redis.Client) *payment.Vault {
+	vault, err := payment.NewVault(payment.NewRedisVaultStore(rdb), tokenKey(), tokenTTL())
+	if err != nil {
+		log.Fatal("❌ Token kasası kurulamadı:", err)
+	}
+	return vault
+}
+
+// registerTokenRoutes - POST /tokens (gateway: POST /api/payments/tokens)
+func registerTokenRoutes(app fiber.Router, vault *payment.Vault) {
+	app.Post("/tokens", func(c *fiber.Ctx) error {
+		var req TokenizeRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Veri formatı hatalı"})
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		token, err := vault.Tokenize(ctx, payment.Card{Number: req.CardNumber, CVV: req.CVV, Expiry: req.Expiry})
+		var invalid *payment.Error
+		switch {
+		case errors.As(err, &invalid):
+			return c.Status(400).JSON(fiber.Map{"code": invalid.Code, "error": invalid.Message})
+		case err != nil:
+			log.Printf("❌ Kart tokenize edilemedi: %s", payment.RedactPAN(err.Error()))
+			return c.Status(500).JSON(fiber.Map{"error": "Kart bilgisi kaydedilemedi"})
+		}
+
+		log.Printf("🔐 Kart tokenize edildi: %s ****%s", token.Brand, token.Last4)
+		return c.Status(201).JSON(token)
+	})
+}
@@ -0,0 +1,345 @@
+/*
+Package auth - Mikroservisler için ortak JWT yetkilendirme modülü
+
+Bu paket, Auth Service'in ürettiği token'ları doğrular ve token içindeki
+rol (roles) ve yetki (permissions) claim'lerine göre route bazında
+erişim kontrolü yapar. Her Fiber servisi aynı middleware'i kullanır,
+böylece "admin mi?" kontrolü servis servis yeniden yazılmaz.
+
+Kullanım:
+
+	app.Use(auth.New(auth.Config{JWKSURL: getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL)}))
+
+	app.Get("/orders", auth.RequireRole(auth.RoleAdmin), listOrders)
+	app.Post("/coupons", auth.RequirePermission(auth.PermCouponsWrite), createCoupon)
+
+Token içeriği (Auth Service tarafından üretilir):
+
+	{
+	  "sub": 5,
+	  "jti": "9f86d081884c7d65...",   // Token kimliği (iptal için)
+	  "sid": "2c26b46b68ffc68f...",   // Oturum (cihaz) kimliği
+	  "exp": 1735689600,
+	  "iat": 1735603200,
+	  "roles": ["customer"],
+	  "permissions": ["orders:create", "orders:read_own", ...]
+	}
+*/
+package auth
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	jwtware "github.com/gofiber/contrib/jwt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ==============================================================================
+// ROLLER VE YETKİLER
+// ==============================================================================
+
+const (
+	RoleAdmin    = "admin"
+	RoleCustomer = "customer"
+	RoleService  = "service" // Servisler arası çağrılar (bkz. service.go)
+)
+
+const (
+	PermOrdersCreate      = "orders:create"
+	PermOrdersReadOwn     = "orders:read_own"
+	PermOrdersReadAll     = "orders:read_all"
+	PermOrdersWriteStatus = "orders:write_status"
+	PermProductsWrite     = "products:write"
+	PermCouponsRead       = "coupons:read"
+	PermCouponsWrite      = "coupons:write"
+	PermReviewsWrite      = "reviews:write"
+	PermSearchAdmin       = "search:admin"
+	PermUsersAdmin        = "users:admin"
+	PermServiceCall       = "service:call"
+)
+
+// RolePermissions - Her rolün sahip olduğu yetkiler
+// Admin, customer yetkilerini de kapsar (RolesFor ile iki rol birden verilir).
+var RolePermissions = map[string][]string{
+	RoleCustomer: {
+		PermOrdersCreate,
+		PermOrdersReadOwn,
+		PermReviewsWrite,
+	},
+	RoleAdmin: {
+		PermOrdersReadAll,
+		PermOrdersWriteStatus,
+		PermProductsWrite,
+		PermCouponsRead,
+		PermCouponsWrite,
+		PermSearchAdmin,
+		PermUsersAdmin,
+	},
+	// Kullanıcı token'larında bulunmaz, sadece X-Service-Token ile verilir
+	RoleService: {
+		PermServiceCall,
+	},
+}
+
+// RolesFor - Kullanıcının rollerini belirler
+// Admin kullanıcılar aynı zamanda müşteridir (kendi sepeti, siparişi olabilir).
+func RolesFor(isAdmin bool) []string {
+	if isAdmin {
+		return []string{RoleCustomer, RoleAdmin}
+	}
+	return []string{RoleCustomer}
+}
+
+// PermissionsFor - Rollerden yetki listesini çıkarır (tekrarsız)
+func PermissionsFor(roles []string) []string {
+	seen := make(map[string]bool)
+	perms := []string{}
+	for _, role := range roles {
+		for _, perm := range RolePermissions[role] {
+			if !seen[perm] {
+				seen[perm] = true
+				perms = append(perms, perm)
+			}
+		}
+	}
+	return perms
+}
+
+// NewClaims - Auth Service'in token'a yazacağı claim'leri oluşturur
+// Her token'a benzersiz bir "jti" verilir (denylist ile iptal edilebilsin diye).
+func NewClaims(userID uint, roles []string, ttl time.Duration) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":         userID,
+		"jti":         NewTokenID(),
+		"iat":         now.Unix(),
+		"exp":         now.Add(ttl).Unix(),
+		"roles":       roles,
+		"permissions": PermissionsFor(roles),
+	}
+}
+
+// ==============================================================================
+// MIDDLEWARE
+// ==============================================================================
+
+// Config - Token doğrulama ayarları
+type Config struct {
+	// JWKSURL - Auth Service'in public anahtarları yayınladığı adres
+	// Anahtarlar çekilir, cache'lenir ve rotasyonda otomatik yenilenir.
+	JWKSURL string
+
+	// KeyFunc - Anahtarı doğrudan veren fonksiyon (JWKSURL yerine)
+	// Auth Service kendi KeySet'i ile doğrular: auth.Config{KeyFunc: keys.Keyfunc}
+	KeyFunc jwt.Keyfunc
+
+	// UnauthorizedMessage - Token yoksa/geçersizse dönecek mesaj
+	// Optional. Default: "Giriş yapmanız gerekiyor!"
+	UnauthorizedMessage string
+
+	// Denylist - İptal edilmiş token/oturum kontrolü (logout sonrası 401)
+	// Optional. Verilmezse sadece imza ve süre kontrol edilir.
+	Denylist Denylist
+
+	// ServiceToken - Servisler arası çağrıların ortak anahtarı (X-Service-Token)
+	// Optional. Boşsa servis anahtarı kabul edilmez, sadece JWT geçerlidir.
+	ServiceToken string
+}
+
+const contextKey = "user"
+
+// denylistTimeout - Denylist kontrolü en fazla bu kadar bekletir
+// Redis erişilemezse istek reddedilmez (fail-open): access token'lar kısa
+// ömürlü olduğu için risk sınırlıdır, ama tüm servislerin durması kabul edilemez.
+const denylistTimeout = 200 * time.Millisecond
+
+// New - Token'ı doğrular ve c.Locals("user") içine koyar
+// Geçersiz token veya servis anahtarı → 401
+func New(cfg Config) fiber.Handler {
+	message := cfg.UnauthorizedMessage
+	if message == "" {
+		message = "Giriş yapmanız gerekiyor!"
+	}
+
+	verifier, err := NewVerifier(cfg)
+	if err != nil {
+		panic("auth: JWKS yüklenemedi: " + err.Error())
+	}
+
+	jwtHandler := jwtware.New(jwtware.Config{
+		KeyFunc:    verifier.keyFunc,
+		ContextKey: contextKey,
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": message})
+		},
+		SuccessHandler: func(c *fiber.Ctx) error {
+			revoked, err := isRevoked(c, cfg.Denylist)
+			if err != nil {
+				log.Printf("⚠️ Token denylist kontrol edilemedi: %v", err)
+				return c.Next()
+			}
+			if revoked {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Oturumunuz sonlandırıldı, lütfen tekrar giriş yapın!"})
+			}
+			return c.Next()
+		},
+	})
+
+	return func(c *fiber.Ctx) error {
+		if handled, ok := authenticateService(c, cfg.ServiceToken); handled {
+			if !ok {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": message})
+			}
+			return c.Next()
+		}
+		return jwtHandler(c)
+	}
+}
+
+// RequireRole - Kullanıcının verilen rollerden EN AZ BİRİNE sahip olmasını ister
+// Token yok → 401, rol yok → 403
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := Claims(c)
+		if !ok {
+			return unauthorized(c)
+		}
+		userRoles := stringSlice(claims["roles"])
+		for _, role := range roles {
+			if contains(userRoles, role) {
+				return c.Next()
+			}
+		}
+		return forbidden(c)
+	}
+}
+
+// RequirePermission - Kullanıcının verilen yetkilerin HEPSİNE sahip olmasını ister
+// Token yok → 401, yetki eksik → 403
+func RequirePermission(perms ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := Claims(c)
+		if !ok {
+			return unauthorized(c)
+		}
+		userPerms := stringSlice(claims["permissions"])
+		for _, perm := range perms {
+			if !contains(userPerms, perm) {
+				return forbidden(c)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// ==============================================================================
+// YARDIMCI FONKSİYONLAR (Handler'lar içinde kullanılır)
+// ==============================================================================
+
+// Claims - Doğrulanmış token'ın claim'lerini döner
+func Claims(c *fiber.Ctx) (jwt.MapClaims, bool) {
+	token, ok := c.Locals(contextKey).(*jwt.Token)
+	if !ok || token == nil {
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
+
+// UserID - Token'daki "sub" claim'ini kullanıcı ID'si olarak döner
+// JSON'da sayılar float64 olarak çözülür, string sub de desteklenir.
+func UserID(c *fiber.Ctx) (uint, bool) {
+	claims, ok := Claims(c)
+	if !ok {
+		return 0, false
+	}
+	switch sub := claims["sub"].(type) {
+	case float64:
+		if sub <= 0 {
+			return 0, false
+		}
+		return uint(sub), true
+	case string:
+		id, err := strconv.ParseUint(sub, 10, 64)
+		if err != nil || id == 0 {
+			return 0, false
+		}
+		return uint(id), true
+	}
+	return 0, false
+}
+
+// TokenID - Token'daki "jti" claim'i (yoksa boş)
+func TokenID(c *fiber.Ctx) string {
+	claims, _ := Claims(c)
+	jti, _ := claims["jti"].(string)
+	return jti
+}
+
+// SessionID - Token'daki "sid" claim'i, yani oturum (cihaz) kimliği (yoksa boş)
+func SessionID(c *fiber.Ctx) string {
+	claims, _ := Claims(c)
+	sid, _ := claims["sid"].(string)
+	return sid
+}
+
+// ExpiresAt - Token'ın son geçerlilik zamanı
+func ExpiresAt(c *fiber.Ctx) time.Time {
+	claims, _ := Claims(c)
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}
+	}
+	return exp.Time
+}
+
+// Roles - Token'daki rolleri döner
+func Roles(c *fiber.Ctx) []string {
+	claims, ok := Claims(c)
+	if !ok {
+		return nil
+	}
+	return stringSlice(claims["roles"])
+}
+
+// HasRole - Kullanıcı verilen role sahip mi?
+func HasRole(c *fiber.Ctx, role string) bool {
+	return contains(Roles(c), role)
+}
+
+func unauthorized(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Giriş yapmanız gerekiyor!"})
+}
+
+func forbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Bu işlem için yetkiniz yok!"})
+}
+
+// stringSlice - JSON'dan gelen []interface{} değerini []string'e çevirir
+func stringSlice(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testKeys = mustKeySet("test-key")
+
+func mustKeySet(kid string) *KeySet {
+	key, err := NewEd25519Key(kid)
+	if err != nil {
+		panic(err)
+	}
+	return NewKeySet(kid, key)
+}
+
+func signToken(t *testing.T, keys *KeySet, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := keys.Sign(claims)
+	if err != nil {
+		t.Fatalf("token imzalanamadı: %v", err)
+	}
+	return token
+}
+
+const testServiceToken = "test-service-token"
+
+func newTestApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/public", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	app.Use(New(Config{KeyFunc: testKeys.Keyfunc, ServiceToken: testServiceToken}))
+
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/orders", RequireRole(RoleAdmin), ok)
+	app.Get("/orders/stats", RequirePermission(PermOrdersReadAll), ok)
+	app.Patch("/orders/:id/status", RequirePermission(PermOrdersWriteStatus), ok)
+	app.Post("/coupons", RequirePermission(PermCouponsWrite), ok)
+	app.Delete("/products/:id", RequireRole(RoleAdmin), ok)
+	app.Post("/orders", RequireRole(RoleCustomer), ok)
+	app.Get("/payments", RequirePermission(PermServiceCall), ok)
+	app.Post("/payments/:id/refund", RequirePermission(PermServiceCall), ok)
+	app.Post("/authorize", RequirePermission(PermServiceCall), ok)
+	app.Get("/me", func(c *fiber.Ctx) error {
+		id, ok := UserID(c)
+		if !ok {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		return c.JSON(fiber.Map{"id": id, "admin": HasRole(c, RoleAdmin)})
+	})
+	return app
+}
+
+func TestRoleEnforcement(t *testing.T) {
+	app := newTestApp()
+
+	customer := signToken(t, testKeys, NewClaims(7, RolesFor(false), time.Hour))
+	admin := signToken(t, testKeys, NewClaims(1, RolesFor(true), time.Hour))
+	forged := signToken(t, mustKeySet("test-key"), NewClaims(7, RolesFor(true), time.Hour))
+	expired := signToken(t, testKeys, NewClaims(1, RolesFor(true), -time.Minute))
+	legacy := signToken(t, testKeys, jwt.MapClaims{"sub": 7, "exp": time.Now().Add(time.Hour).Unix()})
+	hmac, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, NewClaims(1, RolesFor(true), time.Hour)).
+		SignedString([]byte("benim_cok_gizli_anahtarim_senior_oluyorum"))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"public route without token", "GET", "/public", "", 200},
+		{"admin route without token", "GET", "/orders", "", 401},
+
+		{"customer lists all orders", "GET", "/orders", customer, 403},
+		{"customer reads order stats", "GET", "/orders/stats", customer, 403},
+		{"customer changes order status", "PATCH", "/orders/5/status", customer, 403},
+		{"customer creates coupon", "POST", "/coupons", customer, 403},
+		{"customer deletes product", "DELETE", "/products/3", customer, 403},
+		{"customer places order", "POST", "/orders", customer, 200},
+		{"customer lists payments", "GET", "/payments", customer, 403},
+		{"customer refunds payment", "POST", "/payments/9/refund", customer, 403},
+		{"customer authorizes payment", "POST", "/authorize", customer, 403},
+
+		{"admin lists all orders", "GET", "/orders", admin, 200},
+		{"admin reads order stats", "GET", "/orders/stats", admin, 200},
+		{"admin changes order status", "PATCH", "/orders/5/status", admin, 200},
+		{"admin creates coupon", "POST", "/coupons", admin, 200},
+		{"admin deletes product", "DELETE", "/products/3", admin, 200},
+		{"admin places order", "POST", "/orders", admin, 200},
+		{"admin lists payments", "GET", "/payments", admin, 403},
+		{"admin refunds payment", "POST", "/payments/9/refund", admin, 403},
+		{"admin authorizes payment", "POST", "/authorize", admin, 403},
+		{"payment route without token", "POST", "/payments/9/refund", "", 401},
+
+		{"token signed with wrong key", "GET", "/orders", forged, 401},
+		{"expired token", "GET", "/orders", expired, 401},
+		{"token without roles claim", "GET", "/orders", legacy, 403},
+		{"HS256 token with shared secret", "GET", "/orders", hmac, 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("istek başarısız: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceToken(t *testing.T) {
+	app := newTestApp()
+	customer := signToken(t, testKeys, NewClaims(7, RolesFor(false), time.Hour))
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		service string
+		token   string
+		want    int
+	}{
+		{"service lists payments", "GET", "/payments", testServiceToken, "", 200},
+		{"service refunds payment", "POST", "/payments/9/refund", testServiceToken, "", 200},
+		{"service authorizes payment", "POST", "/authorize", testServiceToken, "", 200},
+		{"wrong service token", "POST", "/payments/9/refund", "guessed", "", 401},
+		{"wrong service token with valid jwt", "POST", "/orders", "guessed", customer, 401},
+		{"service cannot use admin routes", "GET", "/orders", testServiceToken, "", 403},
+		{"service has no user id", "GET", "/me", testServiceToken, "", 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(HeaderServiceToken, tt.service)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("istek başarısız: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// Anahtar tanımlı değilse header kabul edilmez
+	strict := fiber.New()
+	strict.Use(New(Config{KeyFunc: testKeys.Keyfunc}))
+	strict.Get("/payments", RequirePermission(PermServiceCall), func(c *fiber.Ctx) error { return c.SendString("ok") })
+	req := httptest.NewRequest("GET", "/payments", nil)
+	req.Header.Set(HeaderServiceToken, "anything")
+	resp, err := strict.Test(req)
+	if err != nil {
+		t.Fatalf("istek başarısız: %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("ServiceToken boşken status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestUserIDFromClaims(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{"numeric sub", jwt.MapClaims{"sub": 42, "exp": time.Now().Add(time.Hour).Unix()}, 200},
+		{"string sub", jwt.MapClaims{"sub": "42", "exp": time.Now().Add(time.Hour).Unix()}, 200},
+		{"missing sub", jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()}, 401},
+		{"non-numeric sub", jwt.MapClaims{"sub": "abc", "exp": time.Now().Add(time.Hour).Unix()}, 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+signToken(t, testKeys, tt.claims))
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("istek başarısız: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermissionsFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []string
+		has     []string
+		missing []string
+	}{
+		{"customer", RolesFor(false), []string{PermOrdersCreate, PermOrdersReadOwn}, []string{PermOrdersReadAll, PermCouponsWrite}},
+		{"admin", RolesFor(true), []string{PermOrdersCreate, PermOrdersReadAll, PermCouponsWrite, PermProductsWrite}, nil},
+		{"unknown role", []string{"guest"}, nil, []string{PermOrdersCreate}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perms := PermissionsFor(tt.roles)
+			for _, p := range tt.has {
+				if !contains(perms, p) {
+					t.Errorf("%s yetkisi eksik: %v", p, perms)
+				}
+			}
+			for _, p := range tt.missing {
+				if contains(perms, p) {
+					t.Errorf("%s yetkisi olmamalıydı: %v", p, perms)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireSelfOrAdmin(t *testing.T) {
+	app := fiber.New()
+	app.Use(New(Config{KeyFunc: testKeys.Keyfunc}))
+	app.Get("/cart/:userid", RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		return c.SendString(ParamUserID(c, "userid"))
+	})
+
+	customer := signToken(t, testKeys, NewClaims(7, RolesFor(false), time.Hour))
+	admin := signToken(t, testKeys, NewClaims(1, RolesFor(true), time.Hour))
+
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		want     int
+		wantBody string
+	}{
+		{"owner reads own cart", "/cart/7", customer, 200, "7"},
+		{"owner uses me alias", "/cart/me", customer, 200, "7"},
+		{"customer reads other cart", "/cart/8", customer, 403, ""},
+		{"admin reads other cart", "/cart/8", admin, 200, "8"},
+		{"admin me alias resolves to admin", "/cart/me", admin, 200, "1"},
+		{"invalid user id", "/cart/abc", customer, 400, ""},
+		{"no token", "/cart/7", "", 401, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("istek başarısız: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.wantBody != "" {
+				body, _ := io.ReadAll(resp.Body)
+				if string(body) != tt.wantBody {
+					t.Errorf("body = %q, want %q", body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ==============================================================================
+// İMZALAMA ANAHTARLARI (KeySet)
+// ==============================================================================
+/*
+Token'lar artık paylaşılan bir secret ile değil, Auth Service'e ait özel
+anahtarla (RS256 veya EdDSA) imzalanır. Diğer servisler sadece public
+anahtarları görür (JWKS endpoint'i), token üretemezler.
+
+Anahtar dizini (JWT_KEYS_DIR):
+
+	keys/
+	├── 2025-01.pem   # "PRIVATE KEY" (PKCS8: RSA veya Ed25519) veya "RSA PRIVATE KEY"
+	└── 2024-07.pem   # "PUBLIC KEY" → sadece doğrulama (emekliye ayrılmış anahtar)
+
+Dosya adı = kid. Token header'ındaki "kid" ile hangi anahtarın
+kullanılacağı seçilir.
+
+🔄 Anahtar rotasyonu (kimseyi logout etmeden):
+ 1. Yeni anahtarı dizine ekle, JWT_ACTIVE_KID'i yeni kid yap
+ 2. Eski anahtar JWKS'te kalmaya devam eder → eski token'lar geçerli
+ 3. En uzun token ömrü dolunca eski dosyayı sil (veya PUBLIC KEY'e çevir)
+
+Servisler bilinmeyen bir kid görünce JWKS'i kendiliğinden yeniler.
+*/
+
+// SigningKey - Tek bir anahtar (Private nil ise sadece doğrulama için kullanılır)
+type SigningKey struct {
+	KID     string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet - Auth Service'in anahtarları ve aktif (imzalayan) kid
+type KeySet struct {
+	keys   map[string]*SigningKey
+	active string
+}
+
+// LoadKeySet - Dizindeki *.pem dosyalarını yükler
+// activeKID boşsa, private key'i olan en son (alfabetik) kid aktif olur.
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s okunamadı: %w", file, err)
+		}
+		key, err := parsePEMKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		ks.keys[kid] = key
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, fmt.Errorf("%s dizininde anahtar bulunamadı", dir)
+	}
+
+	if activeKID == "" {
+		for _, kid := range ks.KIDs() {
+			if ks.keys[kid].Private != nil {
+				activeKID = kid
+			}
+		}
+	}
+	active, ok := ks.keys[activeKID]
+	if !ok || active.Private == nil {
+		return nil, fmt.Errorf("aktif anahtar %q için private key yok", activeKID)
+	}
+	ks.active = activeKID
+
+	return ks, nil
+}
+
+// GenerateKeyFile - Yeni bir Ed25519 anahtarı üretip dizine <kid>.pem olarak yazar
+// Geliştirme ortamında anahtar dizini boşsa kullanılır.
+func GenerateKeyFile(dir, kid string) error {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600)
+}
+
+// NewKeySet - Bellekteki anahtarlardan KeySet oluşturur (testler için)
+func NewKeySet(active string, keys ...*SigningKey) *KeySet {
+	ks := &KeySet{keys: make(map[string]*SigningKey), active: active}
+	for _, key := range keys {
+		ks.keys[key.KID] = key
+	}
+	return ks
+}
+
+// NewEd25519Key - Rastgele Ed25519 anahtarı üretir
+func NewEd25519Key(kid string) (*SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{KID: kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}, nil
+}
+
+// ActiveKID - Yeni token'ları imzalayan anahtarın kid'i
+func (ks *KeySet) ActiveKID() string {
+	return ks.active
+}
+
+// KIDs - Tüm anahtarların kid listesi (sıralı)
+func (ks *KeySet) KIDs() []string {
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+// Sign - Claim'leri aktif anahtarla imzalar (header'a kid yazılır)
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := ks.keys[ks.active]
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc - Token'ı yerel anahtarlarla doğrular (Auth Service kendi token'ları için)
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("bilinmeyen kid: %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("beklenmeyen algoritma: %s", token.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// ==============================================================================
+// JWKS (JSON Web Key Set) - RFC 7517
+// ==============================================================================
+
+// DefaultJWKSURL - Servislerin public anahtarları çektiği varsayılan adres
+const DefaultJWKSURL = "http://localhost:3002/.well-known/jwks.json"
+
+// JWK - Tek bir public anahtarın JSON gösterimi
+type JWK struct {
+	KID string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 (OKP)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet - /.well-known/jwks.json yanıtı
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS - Tüm public anahtarları JWKS formatında döner
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, kid := range ks.KIDs() {
+		key := ks.keys[kid]
+		jwk := JWK{KID: kid, Alg: key.Method.Alg(), Use: "sig"}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// JWKSHandler - GET /.well-known/jwks.json handler'ı
+func (ks *KeySet) JWKSHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Public anahtarlar gizli değildir; kısa süreli cache'lenebilir
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(ks.JWKS())
+	}
+}
+
+// ==============================================================================
+// JWKS İSTEMCİSİ (Diğer servisler ve gateway)
+// ==============================================================================
+
+var (
+	jwksMu    sync.Mutex
+	jwksCache = make(map[string]*keyfunc.JWKS)
+)
+
+// RemoteKeyfunc - JWKS URL'inden anahtarları çekip cache'leyen doğrulayıcı
+/*
+   - Aynı URL için tek bir JWKS nesnesi paylaşılır (servis başına tek fetch)
+   - Saatte bir arka planda yenilenir
+   - Bilinmeyen kid gelirse hemen yenilenir (rotasyon), en fazla 5 dakikada bir
+   - Auth Service henüz ayakta değilse servis yine başlar, ilk istekte tekrar denenir
+*/
+func RemoteKeyfunc(url string) (jwt.Keyfunc, error) {
+	jwksMu.Lock()
+	defer jwksMu.Unlock()
+
+	if jwks, ok := jwksCache[url]; ok {
+		return jwks.Keyfunc, nil
+	}
+
+	jwks, err := keyfunc.Get(url, keyfunc.Options{
+		RefreshErrorHandler: func(err error) {
+			fmt.Printf("⚠️ JWKS yenilenemedi (%s): %v\n", url, err)
+		},
+		RefreshInterval:             time.Hour,
+		RefreshRateLimit:            5 * time.Minute,
+		RefreshTimeout:              10 * time.Second,
+		RefreshUnknownKID:           true,
+		TolerateInitialJWKHTTPError: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	jwksCache[url] = jwks
+	return jwks.Keyfunc, nil
+}
+
+// ==============================================================================
+// PEM AYRIŞTIRMA
+// ==============================================================================
+
+func parsePEMKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM bloğu bulunamadı")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("desteklenmeyen PEM tipi: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodRS256, Private: key, Public: &key.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodEdDSA, Private: key, Public: key.Public()}, nil
+	case *rsa.PublicKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodRS256, Public: key}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodEdDSA, Public: key}, nil
+	}
+	return nil, fmt.Errorf("desteklenmeyen anahtar tipi: %T (RSA veya Ed25519 olmalı)", parsed)
+}
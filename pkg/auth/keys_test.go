@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLoadKeySetFromDir(t *testing.T) {
+	dir := t.TempDir()
+
+	// Eski anahtar: RSA (PKCS1), yeni anahtar: Ed25519 (üretilmiş)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	if err := os.WriteFile(filepath.Join(dir, "2024-07.pem"), rsaPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKeyFile(dir, "2025-01"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		activeKID string
+		wantKID   string
+		wantAlg   string
+		wantErr   bool
+	}{
+		{"latest kid is active by default", "", "2025-01", "EdDSA", false},
+		{"explicit RSA kid", "2024-07", "2024-07", "RS256", false},
+		{"unknown kid", "2023-01", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := LoadKeySet(dir, tt.activeKID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("hata bekleniyordu")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ks.ActiveKID() != tt.wantKID {
+				t.Errorf("active kid = %q, want %q", ks.ActiveKID(), tt.wantKID)
+			}
+			if got := ks.keys[ks.ActiveKID()].Method.Alg(); got != tt.wantAlg {
+				t.Errorf("alg = %q, want %q", got, tt.wantAlg)
+			}
+			if len(ks.JWKS().Keys) != 2 {
+				t.Errorf("JWKS iki anahtarı da yayınlamalı: %+v", ks.JWKS())
+			}
+		})
+	}
+
+	if _, err := LoadKeySet(t.TempDir(), ""); err == nil {
+		t.Error("boş dizin hata vermeli")
+	}
+}
+
+func TestRemoteJWKSAndRotation(t *testing.T) {
+	oldKey, _ := NewEd25519Key("old")
+	newKey, _ := NewEd25519Key("new")
+	unknownKey, _ := NewEd25519Key("unknown")
+
+	oldSigner := NewKeySet("old", oldKey)
+	rotated := NewKeySet("new", oldKey, newKey)
+	stranger := NewKeySet("unknown", unknownKey)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rotated.JWKS())
+	}))
+	defer srv.Close()
+
+	app := fiber.New()
+	app.Use(New(Config{JWKSURL: srv.URL}))
+	app.Get("/me", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	claims := NewClaims(3, RolesFor(false), time.Hour)
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"token signed with new active key", signToken(t, rotated, claims), 200},
+		{"token signed before rotation", signToken(t, oldSigner, claims), 200},
+		{"token signed with unpublished key", signToken(t, stranger, claims), 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
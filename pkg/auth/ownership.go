@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ==============================================================================
+// KAYNAK SAHİPLİĞİ (Ownership)
+// ==============================================================================
+/*
+Kullanıcıya ait route'larda (/cart/:userid, /orders/user/:userid ...) path'teki
+ID'ye güvenilmez; token'daki "sub" ile karşılaştırılır. Admin herkesin
+kaynağına erişebilir.
+
+"me" takma adı: /cart/me, /wishlist/me, /profile/me gibi istekler token
+sahibinin ID'si ile çözülür. Böylece client kullanıcı ID'si taşımak zorunda kalmaz.
+
+	app.Get("/cart/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userid := auth.ParamUserID(c, "userid") // "me" → "5"
+		...
+	})
+*/
+
+// MeAlias - Path'te kullanıcı ID'si yerine yazılabilen takma ad
+const MeAlias = "me"
+
+// RequireSelfOrAdmin - Path parametresindeki kullanıcı ID'si token sahibine ait olmalı
+// Token yok → 401, ID geçersiz → 400, başkasının kaynağı (admin değilse) → 403
+func RequireSelfOrAdmin(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := UserID(c)
+		if !ok {
+			return unauthorized(c)
+		}
+
+		value := c.Params(param)
+		if value == MeAlias {
+			return c.Next()
+		}
+
+		pathID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || pathID == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Geçersiz kullanıcı ID"})
+		}
+
+		if uint(pathID) == userID || HasRole(c, RoleAdmin) {
+			return c.Next()
+		}
+		return forbidden(c)
+	}
+}
+
+// ParamUserID - Path parametresindeki kullanıcı ID'sini döner ("me" → token sahibinin ID'si)
+// RequireSelfOrAdmin'den sonra çağrılmalıdır.
+func ParamUserID(c *fiber.Ctx, param string) string {
+	value := c.Params(param)
+	if value != MeAlias {
+		return value
+	}
+	userID, ok := UserID(c)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatUint(uint64(userID), 10)
+}
+
+// IsSelfOrAdmin - Kaynağın sahibi token sahibi mi, ya da kullanıcı admin mi?
+// Path'te kullanıcı ID'si olmayan route'lar için (ör. /addresses/:id, /orders/:id)
+// kaynak DB'den okunduktan sonra kullanılır.
+func IsSelfOrAdmin(c *fiber.Ctx, ownerID uint) bool {
+	userID, ok := UserID(c)
+	if !ok {
+		return false
+	}
+	return userID == ownerID || HasRole(c, RoleAdmin)
+}
+
+// Forbidden - Handler içinden sahiplik reddi için standart 403 yanıtı
+func Forbidden(c *fiber.Ctx) error {
+	return forbidden(c)
+}
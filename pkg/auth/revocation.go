@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==============================================================================
+// TOKEN İPTALİ (Denylist)
+// ==============================================================================
+/*
+Access token'lar kısa ömürlüdür (varsayılan 15 dk) ve imzası geçerli olduğu
+sürece kabul edilir. Logout veya "bu cihazdan çıkış yap" sonrası token'ın
+ömrü dolmadan reddedilmesi için Auth Service iptal edilenleri bir denylist'e
+yazar, servisler her istekte buna bakar.
+
+İki tür anahtar tutulur:
+
+	jti:<token id>    → Tek bir access token iptal edildi
+	sid:<session id>  → Oturumun (cihazın) TÜM access token'ları iptal edildi
+
+Kayıtlar token ömrü kadar (TTL) tutulur, sonra kendiliğinden silinir;
+denylist hiçbir zaman büyümez. Redis'te kontrol tek bir EXISTS komutudur.
+
+	app.Use(auth.New(auth.Config{
+		JWKSURL:  getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		Denylist: auth.NewRedisDenylist(rdb),
+	}))
+*/
+
+// Denylist - İptal edilen token/oturum kimliklerinin tutulduğu yer
+type Denylist interface {
+	// Add - Anahtarı ttl süresince iptal edilmiş olarak işaretler
+	Add(ctx context.Context, key string, ttl time.Duration) error
+	// Contains - Anahtarlardan herhangi biri iptal edilmiş mi?
+	Contains(ctx context.Context, keys ...string) (bool, error)
+}
+
+// TokenKey - Tek bir access token için denylist anahtarı
+func TokenKey(jti string) string { return "jti:" + jti }
+
+// SessionKey - Bir oturumun tüm access token'ları için denylist anahtarı
+func SessionKey(sid string) string { return "sid:" + sid }
+
+// RevokeToken - Token'ı exp zamanına kadar denylist'e ekler
+// Süresi zaten dolmuş token için bir şey yapmaz.
+func RevokeToken(ctx context.Context, d Denylist, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return d.Add(ctx, TokenKey(jti), ttl)
+}
+
+// RevokeSession - Oturumu denylist'e ekler
+// ttl en az access token ömrü kadar olmalı; o süreden sonra oturuma ait
+// access token kalmaz, refresh zaten Auth Service'te engellenir.
+func RevokeSession(ctx context.Context, d Denylist, sid string, ttl time.Duration) error {
+	if sid == "" {
+		return nil
+	}
+	return d.Add(ctx, SessionKey(sid), ttl)
+}
+
+// NewTokenID - jti ve oturum ID'leri için rastgele kimlik (32 hex karakter)
+func NewTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("auth: rastgele ID üretilemedi: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// ==============================================================================
+// REDIS DENYLIST (Production)
+// ==============================================================================
+
+const redisDenylistPrefix = "auth:denylist:"
+
+// RedisDenylist - Tüm servislerin paylaştığı Redis tabanlı denylist
+type RedisDenylist struct {
+	client *redis.Client
+}
+
+// NewRedisDenylist - Mevcut Redis client'ı ile denylist oluşturur
+func NewRedisDenylist(client *redis.Client) *RedisDenylist {
+	return &RedisDenylist{client: client}
+}
+
+// DialRedisDenylist - Kendi Redis bağlantısı olmayan servisler için
+// Bağlantı ilk kontrolde kurulur; Redis geç açılsa da servis başlar.
+func DialRedisDenylist(addr string) *RedisDenylist {
+	return NewRedisDenylist(redis.NewClient(&redis.Options{Addr: addr}))
+}
+
+func (d *RedisDenylist) Add(ctx context.Context, key string, ttl time.Duration) error {
+	return d.client.Set(ctx, redisDenylistPrefix+key, 1, ttl).Err()
+}
+
+func (d *RedisDenylist) Contains(ctx context.Context, keys ...string) (bool, error) {
+	if len(keys) == 0 {
+		return false, nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = redisDenylistPrefix + key
+	}
+	n, err := d.client.Exists(ctx, prefixed...).Result()
+	if err != nil {
+		return false, fmt.Errorf("denylist okunamadı: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ==============================================================================
+// BELLEK İÇİ DENYLIST (Tek instance / testler)
+// ==============================================================================
+
+// MemoryDenylist - Süreç içi denylist (servisler arasında paylaşılmaz!)
+type MemoryDenylist struct {
+	mu    sync.Mutex
+	items map[string]time.Time
+}
+
+// NewMemoryDenylist - Boş bellek içi denylist
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{items: make(map[string]time.Time)}
+}
+
+func (d *MemoryDenylist) Add(ctx context.Context, key string, ttl time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items[key] = time.Now().Add(ttl)
+	return nil
+}
+
+func (d *MemoryDenylist) Contains(ctx context.Context, keys ...string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for _, key := range keys {
+		expiresAt, ok := d.items[key]
+		if !ok {
+			continue
+		}
+		if now.After(expiresAt) {
+			delete(d.items, key)
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
+}
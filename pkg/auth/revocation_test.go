@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type brokenDenylist struct{}
+
+func (brokenDenylist) Add(ctx context.Context, key string, ttl time.Duration) error {
+	return errors.New("redis kapalı")
+}
+
+func (brokenDenylist) Contains(ctx context.Context, keys ...string) (bool, error) {
+	return false, errors.New("redis kapalı")
+}
+
+func sessionToken(t *testing.T, sid string) (string, jwt.MapClaims) {
+	t.Helper()
+	claims := NewClaims(7, RolesFor(false), time.Hour)
+	claims["sid"] = sid
+	return signToken(t, testKeys, claims), claims
+}
+
+func TestDenylist(t *testing.T) {
+	ctx := context.Background()
+	denylist := NewMemoryDenylist()
+
+	app := fiber.New()
+	app.Use(New(Config{KeyFunc: testKeys.Keyfunc, Denylist: denylist}))
+	app.Get("/me", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	active, _ := sessionToken(t, "laptop")
+	loggedOut, loggedOutClaims := sessionToken(t, "phone")
+	otherOnRevokedDevice, _ := sessionToken(t, "tablet")
+	sameDevice, _ := sessionToken(t, "tablet")
+	legacy := signToken(t, testKeys, jwt.MapClaims{"sub": 7, "exp": time.Now().Add(time.Hour).Unix()})
+
+	if err := RevokeToken(ctx, denylist, loggedOutClaims["jti"].(string), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeSession(ctx, denylist, "tablet", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"token of active session", active, 200},
+		{"revoked token", loggedOut, 401},
+		{"token of revoked session", otherOnRevokedDevice, 401},
+		{"another token of revoked session", sameDevice, 401},
+		{"token without jti or sid", legacy, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestDenylistExpiryAndFailOpen(t *testing.T) {
+	ctx := context.Background()
+	denylist := NewMemoryDenylist()
+
+	denylist.Add(ctx, TokenKey("short"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if revoked, _ := denylist.Contains(ctx, TokenKey("short")); revoked {
+		t.Error("TTL dolan kayıt silinmeliydi")
+	}
+
+	// Süresi geçmiş token denylist'e yazılmaz
+	RevokeToken(ctx, denylist, "old", time.Now().Add(-time.Minute))
+	if revoked, _ := denylist.Contains(ctx, TokenKey("old")); revoked {
+		t.Error("süresi geçmiş token eklenmemeliydi")
+	}
+
+	// Denylist erişilemezse istek reddedilmez
+	app := fiber.New()
+	app.Use(New(Config{KeyFunc: testKeys.Keyfunc, Denylist: brokenDenylist{}}))
+	app.Get("/me", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	token, _ := sessionToken(t, "laptop")
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
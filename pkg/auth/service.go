@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ==============================================================================
+// SERVİSLER ARASI KİMLİK (Service token)
+// ==============================================================================
+/*
+Order Service saga'sının çağırdığı iç endpoint'ler (stok rezervasyonu, kupon
+kullanımı, ödeme provizyonu/tahsilatı/iadesi) bir kullanıcı adına değil servis
+adına çağrılır. Bu çağrılar ortak bir anahtarla yapılır:
+
+	X-Service-Token: <SERVICE_TOKEN>
+
+Config.ServiceToken doluysa New() bu header'ı kabul eder ve isteği "service"
+rolüyle doğrulanmış sayar; iç endpoint'ler servis yetkisini ister:
+
+	app.Post("/products/reservations", auth.RequirePermission(auth.PermServiceCall), reserve)
+
+ServiceToken boşsa header hiç kabul edilmez. API Gateway bu header'ı client
+isteklerinden siler, yani anahtar dışarıdan gönderilse bile servise ulaşmaz.
+*/
+
+// HeaderServiceToken - Servisler arası çağrıların anahtar header'ı
+const HeaderServiceToken = "X-Service-Token"
+
+// ServiceSubject - Servis kimliğinin "sub" claim'i (kullanıcı ID'si değildir)
+const ServiceSubject = "service"
+
+// serviceToken - Geçerli servis anahtarı için sahte (imzasız) token
+// UserID() "service" sub'ını kullanıcıya çevirmez, yani sahiplik kontrolleri geçilemez.
+func serviceToken() *jwt.Token {
+	roles := []string{RoleService}
+	return &jwt.Token{
+		Valid: true,
+		Claims: jwt.MapClaims{
+			"sub":         ServiceSubject,
+			"roles":       roles,
+			"permissions": PermissionsFor(roles),
+		},
+	}
+}
+
+// authenticateService - X-Service-Token header'ını doğrular
+// Header yoksa handled=false (JWT doğrulamasına devam edilir).
+func authenticateService(c *fiber.Ctx, expected string) (handled bool, ok bool) {
+	presented := c.Get(HeaderServiceToken)
+	if presented == "" {
+		return false, false
+	}
+	if expected == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(expected)) != 1 {
+		return true, false
+	}
+	c.Locals(contextKey, serviceToken())
+	return true, true
+}
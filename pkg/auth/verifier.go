@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ==============================================================================
+// VERIFIER (Middleware dışında token doğrulama)
+// ==============================================================================
+/*
+New() her isteğin token taşımasını zorunlu kılar. API Gateway ise route'a göre
+karar verir: public route'ta token opsiyoneldir, diğerlerinde zorunludur.
+Verifier aynı doğrulamayı (imza, süre, denylist) yapar ama kararı çağırana bırakır.
+
+	verifier, _ := auth.NewVerifier(auth.Config{JWKSURL: ..., Denylist: ...})
+	ok, err := verifier.Authenticate(c) // token yoksa ok=false, err=nil
+	if ok { auth.UserID(c), auth.Roles(c) ... }
+*/
+
+// Doğrulama hataları
+var (
+	ErrInvalidToken = errors.New("auth: geçersiz token")
+	ErrRevoked      = errors.New("auth: token iptal edilmiş")
+)
+
+// Kimlik header'ları - Gateway doğruladığı kullanıcıyı servislere bunlarla iletir
+// Client'tan gelen aynı isimli header'lar gateway'de SİLİNİR (sahte kimlik olmasın).
+const (
+	HeaderUserID          = "X-User-ID"
+	HeaderUserRoles       = "X-User-Roles"
+	HeaderUserPermissions = "X-User-Permissions"
+	HeaderSessionID       = "X-Session-ID"
+)
+
+// Verifier - Token doğrulayıcı
+type Verifier struct {
+	keyFunc  jwt.Keyfunc
+	denylist Denylist
+}
+
+// NewVerifier - Config'ten doğrulayıcı oluşturur (KeyFunc veya JWKSURL)
+func NewVerifier(cfg Config) (*Verifier, error) {
+	keyFunc := cfg.KeyFunc
+	if keyFunc == nil {
+		var err error
+		keyFunc, err = RemoteKeyfunc(cfg.JWKSURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Verifier{keyFunc: keyFunc, denylist: cfg.Denylist}, nil
+}
+
+// Authenticate - Authorization header'ındaki Bearer token'ı doğrular
+// Token yoksa (false, nil); geçerliyse c.Locals("user") doldurulur ve (true, nil).
+// Auth helper'ları (UserID, Roles, HasRole ...) bundan sonra kullanılabilir.
+func (v *Verifier) Authenticate(c *fiber.Ctx) (bool, error) {
+	header := c.Get(fiber.HeaderAuthorization)
+	if header == "" {
+		return false, nil
+	}
+	raw, found := strings.CutPrefix(header, "Bearer ")
+	if !found || raw == "" {
+		return false, ErrInvalidToken
+	}
+
+	token, err := jwt.Parse(raw, v.keyFunc, jwt.WithValidMethods([]string{"RS256", "EdDSA"}))
+	if err != nil || !token.Valid {
+		return false, ErrInvalidToken
+	}
+	c.Locals(contextKey, token)
+
+	revoked, err := isRevoked(c, v.denylist)
+	if err != nil {
+		// Fail-open: bkz. denylistTimeout
+		log.Printf("⚠️ Token denylist kontrol edilemedi: %v", err)
+		return true, nil
+	}
+	if revoked {
+		c.Locals(contextKey, nil)
+		return false, ErrRevoked
+	}
+	return true, nil
+}
+
+// isRevoked - Token'ın jti'si veya oturumu (sid) denylist'te mi?
+func isRevoked(c *fiber.Ctx, d Denylist) (bool, error) {
+	if d == nil {
+		return false, nil
+	}
+	var keys []string
+	if jti := TokenID(c); jti != "" {
+		keys = append(keys, TokenKey(jti))
+	}
+	if sid := SessionID(c); sid != "" {
+		keys = append(keys, SessionKey(sid))
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), denylistTimeout)
+	defer cancel()
+	return d.Contains(ctx, keys...)
+}
+
+// Permissions - Token'daki yetkileri döner
+func Permissions(c *fiber.Ctx) []string {
+	claims, ok := Claims(c)
+	if !ok {
+		return nil
+	}
+	return stringSlice(claims["permissions"])
+}
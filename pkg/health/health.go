@@ -7,12 +7,26 @@ probe'ları için kullanılır.
 
 Kullanım:
 
-	checker := health.NewHealthChecker()
-	checker.AddCheck("postgres", health.NewPostgresChecker(db))
-	checker.AddCheck("rabbitmq", health.NewRabbitMQChecker(conn))
-
-	result := checker.Check()
-	// result.Status = "healthy" veya "unhealthy"
+	checker := health.NewHealthChecker("order-service")
+	checker.AddCheck("postgres", health.NewPostgresChecker(sqlDB))
+	checker.AddCheck("rabbitmq", health.NewRabbitMQChecker(conn), health.NonCritical())
+	checker.Register(app) // GET /health, /livez, /readyz
+
+Endpoint'ler:
+
+	GET /livez  → Süreç ayakta mı? Bağımlılıklara BAKMAZ (DB çöktü diye
+	              pod yeniden başlatılmamalı). Her zaman 200.
+	GET /readyz → Trafik alabilir mi? Kritik bir kontrol başarısızsa 503.
+	GET /health → Tüm kontrollerin detaylı raporu (readyz ile aynı durum kodu)
+
+Durum:
+  - healthy   → Tüm kontroller başarılı
+  - degraded  → Sadece kritik OLMAYAN kontroller başarısız (200)
+  - unhealthy → En az bir kritik kontrol başarısız (503)
+
+Kontroller paralel çalışır, her birinin kendi timeout'u vardır (varsayılan
+2s) ve sonuçlar kısa süre (varsayılan 5s) önbellekte tutulur: saniyede
+onlarca probe gelse de veritabanına en fazla bir ping gider.
 */
 package health
 
@@ -21,10 +35,10 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -33,11 +47,24 @@ import (
 // TİP TANIMLARI
 // ==============================================================================
 
+// Durum değerleri
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded" // Çalışıyor ama kritik olmayan bir sorun var
+	StatusUnhealthy = "unhealthy"
+)
+
+const (
+	DefaultCheckTimeout = 2 * time.Second
+	DefaultCacheTTL     = 5 * time.Second
+)
+
 // CheckResult - Tek bir health check sonucu
 type CheckResult struct {
-	Status   string        `json:"status"`   // "healthy" veya "unhealthy"
-	Message  string        `json:"message"`  // Detay mesajı
-	Duration time.Duration `json:"duration"` // Kontrol süresi
+	Status   string        `json:"status"`             // "healthy" veya "unhealthy"
+	Message  string        `json:"message"`            // Detay mesajı
+	Duration time.Duration `json:"duration"`           // Kontrol süresi
+	Critical bool          `json:"critical,omitempty"` // Başarısızsa servis unhealthy mi olur
 }
 
 // HealthResponse - Tüm kontrollerin toplu sonucu
@@ -53,10 +80,41 @@ type Checker interface {
 	Check(ctx context.Context) CheckResult
 }
 
+// CheckFunc - Basit kontroller için fonksiyon adaptörü
+type CheckFunc func(ctx context.Context) CheckResult
+
+func (f CheckFunc) Check(ctx context.Context) CheckResult { return f(ctx) }
+
+// registeredCheck - Checker + ayarları
+type registeredCheck struct {
+	checker  Checker
+	critical bool
+	timeout  time.Duration
+}
+
+// CheckOption - AddCheck ayarları
+type CheckOption func(*registeredCheck)
+
+// NonCritical - Başarısız olursa servis "degraded" olur ama trafik almaya devam eder
+// (ör. product-service için RabbitMQ: ürünler listelenebilir, sadece stok eventleri gecikir)
+func NonCritical() CheckOption {
+	return func(r *registeredCheck) { r.critical = false }
+}
+
+// WithTimeout - Kontrolün kendi timeout'u (varsayılan 2s)
+func WithTimeout(d time.Duration) CheckOption {
+	return func(r *registeredCheck) { r.timeout = d }
+}
+
 // HealthChecker - Ana health check yöneticisi
 type HealthChecker struct {
 	serviceName string
-	checkers    map[string]Checker
+	checkers    map[string]registeredCheck
+	cacheTTL    time.Duration
+
+	mu       sync.Mutex // Aynı anda tek kontrol turu (diğerleri sonucu bekler)
+	cached   HealthResponse
+	cachedAt time.Time
 }
 
 // ==============================================================================
@@ -67,50 +125,135 @@ type HealthChecker struct {
 func NewHealthChecker(serviceName string) *HealthChecker {
 	return &HealthChecker{
 		serviceName: serviceName,
-		checkers:    make(map[string]Checker),
+		checkers:    make(map[string]registeredCheck),
+		cacheTTL:    DefaultCacheTTL,
+	}
+}
+
+// AddCheck - Yeni bir kontrol ekler (varsayılan: kritik, 2s timeout)
+func (h *HealthChecker) AddCheck(name string, checker Checker, opts ...CheckOption) {
+	check := registeredCheck{checker: checker, critical: true, timeout: DefaultCheckTimeout}
+	for _, opt := range opts {
+		opt(&check)
 	}
+	h.checkers[name] = check
 }
 
-// AddCheck - Yeni bir kontrol ekler
-func (h *HealthChecker) AddCheck(name string, checker Checker) {
-	h.checkers[name] = checker
+// SetCacheTTL - Sonuçların önbellekte tutulma süresi (0 → her istekte kontrol)
+func (h *HealthChecker) SetCacheTTL(ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cacheTTL = ttl
 }
 
-// Check - Tüm kontrolleri çalıştırır
+// Check - Tüm kontrolleri çalıştırır (önbellek taze ise onu döner)
 func (h *HealthChecker) Check() HealthResponse {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.cachedAt.IsZero() && time.Since(h.cachedAt) < h.cacheTTL {
+		return h.cached
+	}
+	h.cached = h.run()
+	h.cachedAt = time.Now()
+	return h.cached
+}
 
+// run - Kontrolleri paralel çalıştırır, her biri kendi timeout'u ile
+func (h *HealthChecker) run() HealthResponse {
 	response := HealthResponse{
-		Status:    "healthy",
+		Status:    StatusHealthy,
 		Timestamp: time.Now(),
 		Service:   h.serviceName,
-		Checks:    make(map[string]CheckResult),
+		Checks:    make(map[string]CheckResult, len(h.checkers)),
 	}
 
-	for name, checker := range h.checkers {
-		result := checker.Check(ctx)
-		response.Checks[name] = result
-		if result.Status != "healthy" {
-			response.Status = "unhealthy"
-		}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range h.checkers {
+		wg.Add(1)
+		go func(name string, check registeredCheck) {
+			defer wg.Done()
+			result := runCheck(check)
+			result.Critical = check.critical
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[name] = result
+			if result.Status == StatusHealthy {
+				return
+			}
+			if check.critical {
+				response.Status = StatusUnhealthy
+			} else if response.Status == StatusHealthy {
+				response.Status = StatusDegraded
+			}
+		}(name, check)
 	}
+	wg.Wait()
 
 	return response
 }
 
+// runCheck - Context'i dinlemeyen checker'lar da timeout'ta bırakılır
+func runCheck(check registeredCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(context.Background(), check.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan CheckResult, 1)
+	go func() { done <- check.checker.Check(ctx) }()
+
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return CheckResult{
+			Status:   StatusUnhealthy,
+			Message:  fmt.Sprintf("check timed out after %s", check.timeout),
+			Duration: time.Since(start),
+		}
+	}
+}
+
 // FiberHandler - Fiber için /health endpoint handler'ı
 func (h *HealthChecker) FiberHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		result := h.Check()
 		statusCode := http.StatusOK
-		if result.Status != "healthy" {
+		if result.Status == StatusUnhealthy {
 			statusCode = http.StatusServiceUnavailable
 		}
 		return c.Status(statusCode).JSON(result)
 	}
 }
 
+// LivenessHandler - GET /livez (bağımlılıklara bakmaz)
+func (h *HealthChecker) LivenessHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": StatusHealthy, "service": h.serviceName})
+	}
+}
+
+// ReadinessHandler - GET /readyz (kritik kontrol başarısızsa 503)
+func (h *HealthChecker) ReadinessHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		result := h.Check()
+		statusCode := http.StatusOK
+		if result.Status == StatusUnhealthy {
+			statusCode = http.StatusServiceUnavailable
+		}
+		return c.Status(statusCode).JSON(fiber.Map{"status": result.Status, "service": h.serviceName})
+	}
+}
+
+// Register - /health, /livez ve /readyz endpoint'lerini ekler
+func (h *HealthChecker) Register(router fiber.Router) {
+	router.Get("/health", h.FiberHandler())
+	router.Get("/livez", h.LivenessHandler())
+	router.Get("/readyz", h.ReadinessHandler())
+}
+
 // ==============================================================================
 // POSTGRES CHECKER
 // ==============================================================================
@@ -194,11 +337,17 @@ func (r *RedisChecker) Check(ctx context.Context) CheckResult {
 // RABBITMQ CHECKER
 // ==============================================================================
 
+// AMQPConnection - streadway/amqp ve amqp091-go bağlantılarının ortak metodu
+type AMQPConnection interface {
+	IsClosed() bool
+}
+
 type RabbitMQChecker struct {
-	conn *amqp.Connection
+	conn AMQPConnection
 }
 
-func NewRabbitMQChecker(conn *amqp.Connection) *RabbitMQChecker {
+// NewRabbitMQChecker - Bağlantı kurulduktan SONRA çağrılmalı (nil pointer verilmemeli)
+func NewRabbitMQChecker(conn AMQPConnection) *RabbitMQChecker {
 	return &RabbitMQChecker{conn: conn}
 }
 
@@ -334,3 +483,45 @@ func (s *SelfChecker) Check(ctx context.Context) CheckResult {
 		Duration: 0,
 	}
 }
+
+// ==============================================================================
+// CLOSE WATCHER (RabbitMQ channel'ı gibi NotifyClose veren kaynaklar)
+// ==============================================================================
+/*
+Bağlantı açık olsa da channel kapanmış olabilir (ör. yanlış exchange'e
+publish → broker channel'ı kapatır). Channel'ın IsClosed metodu her
+kütüphanede yok, ama NotifyClose hepsinde var:
+
+	checker.AddCheck("rabbitmq_channel", health.NewCloseWatcher(ch.NotifyClose(make(chan *amqp.Error, 1))))
+*/
+
+type CloseWatcher struct {
+	mu     sync.Mutex
+	reason string
+	closed bool
+}
+
+// NewCloseWatcher - closed kanalına değer gelince veya kanal kapanınca unhealthy olur
+func NewCloseWatcher[T any](closed <-chan T) *CloseWatcher {
+	w := &CloseWatcher{}
+	go func() {
+		v, ok := <-closed
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.closed = true
+		w.reason = "closed"
+		if ok {
+			w.reason = fmt.Sprintf("closed: %v", v)
+		}
+	}()
+	return w
+}
+
+func (w *CloseWatcher) Check(ctx context.Context) CheckResult {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return CheckResult{Status: "unhealthy", Message: w.reason}
+	}
+	return CheckResult{Status: "healthy", Message: "open"}
+}
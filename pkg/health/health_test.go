@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func staticCheck(status string) CheckFunc {
+	return func(ctx context.Context) CheckResult {
+		return CheckResult{Status: status}
+	}
+}
+
+func TestHealthCheckerStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		critical    string
+		nonCritical string
+		want        string
+		wantCode    int
+	}{
+		{"all healthy", "healthy", "healthy", StatusHealthy, 200},
+		{"non-critical down", "healthy", "unhealthy", StatusDegraded, 200},
+		{"critical down", "unhealthy", "healthy", StatusUnhealthy, 503},
+		{"both down", "unhealthy", "unhealthy", StatusUnhealthy, 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthChecker("svc")
+			h.AddCheck("postgres", staticCheck(tt.critical))
+			h.AddCheck("rabbitmq", staticCheck(tt.nonCritical), NonCritical())
+
+			result := h.Check()
+			if result.Status != tt.want {
+				t.Errorf("status = %s, want %s", result.Status, tt.want)
+			}
+			if !result.Checks["postgres"].Critical || result.Checks["rabbitmq"].Critical {
+				t.Errorf("critical işaretleri yanlış: %+v", result.Checks)
+			}
+
+			app := fiber.New()
+			h.Register(app)
+			for path, want := range map[string]int{"/health": tt.wantCode, "/readyz": tt.wantCode, "/livez": 200} {
+				resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != want {
+					t.Errorf("%s = %d, want %d", path, resp.StatusCode, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthCheckerConcurrentWithTimeouts(t *testing.T) {
+	h := NewHealthChecker("svc")
+	slow := func(d time.Duration) CheckFunc {
+		return func(ctx context.Context) CheckResult {
+			time.Sleep(d) // Context'i dinlemeyen checker
+			return CheckResult{Status: StatusHealthy}
+		}
+	}
+	h.AddCheck("a", slow(100*time.Millisecond))
+	h.AddCheck("b", slow(100*time.Millisecond))
+	h.AddCheck("hung", slow(time.Second), WithTimeout(150*time.Millisecond), NonCritical())
+
+	start := time.Now()
+	result := h.Check()
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("kontroller paralel çalışmadı veya timeout uygulanmadı: %s", elapsed)
+	}
+	if result.Checks["a"].Status != StatusHealthy || result.Checks["b"].Status != StatusHealthy {
+		t.Errorf("hızlı kontroller başarılı olmalı: %+v", result.Checks)
+	}
+	if result.Checks["hung"].Status != StatusUnhealthy || result.Status != StatusDegraded {
+		t.Errorf("timeout'a düşen kontrol: %+v, genel durum %s", result.Checks["hung"], result.Status)
+	}
+}
+
+func TestHealthCheckerCache(t *testing.T) {
+	var calls atomic.Int32
+	h := NewHealthChecker("svc")
+	h.AddCheck("db", CheckFunc(func(ctx context.Context) CheckResult {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return CheckResult{Status: StatusHealthy}
+	}))
+
+	// Aynı anda gelen 20 probe → veritabanına tek ping
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Check()
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("kontrol %d kez çalıştı, want 1", calls.Load())
+	}
+
+	// Önbellek süresi dolunca tekrar çalışır
+	h.SetCacheTTL(0)
+	h.Check()
+	if calls.Load() != 2 {
+		t.Errorf("kontrol %d kez çalıştı, want 2", calls.Load())
+	}
+}
+
+type fakeConn struct{ closed bool }
+
+func (f *fakeConn) IsClosed() bool { return f.closed }
+
+func TestRabbitMQCheckers(t *testing.T) {
+	conn := &fakeConn{}
+	checker := NewRabbitMQChecker(conn)
+	if got := checker.Check(context.Background()).Status; got != StatusHealthy {
+		t.Errorf("açık bağlantı = %s", got)
+	}
+	conn.closed = true
+	if got := checker.Check(context.Background()).Status; got != StatusUnhealthy {
+		t.Errorf("kapalı bağlantı = %s", got)
+	}
+
+	closed := make(chan error, 1)
+	watcher := NewCloseWatcher(closed)
+	if got := watcher.Check(context.Background()).Status; got != StatusHealthy {
+		t.Errorf("açık channel = %s", got)
+	}
+	closed <- context.Canceled
+	time.Sleep(10 * time.Millisecond)
+	if got := watcher.Check(context.Background()); got.Status != StatusUnhealthy || got.Message != "closed: context canceled" {
+		t.Errorf("kapanan channel = %+v", got)
+	}
+}
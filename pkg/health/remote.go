@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ==============================================================================
+// REMOTE (HTTP) CHECKER - Başka bir servisin /health endpoint'i
+// ==============================================================================
+/*
+API Gateway, upstream instance'larını bu checker ile yoklar. Yanıt bu paketin
+HealthResponse formatında olmalıdır:
+
+	GET http://product-service:3001/health
+	→ 200 {"status": "healthy", "service": "product-service", "checks": {...}}
+*/
+
+// FetchRemote - Uzak servisin health yanıtını okur
+// 200 dışındaki yanıtlar hata döner; gövde okunabildiyse response da doludur.
+func FetchRemote(ctx context.Context, client *http.Client, url string) (HealthResponse, error) {
+	var response HealthResponse
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return response, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+
+	decodeErr := json.NewDecoder(resp.Body).Decode(&response)
+	if resp.StatusCode != http.StatusOK {
+		return response, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	if decodeErr != nil || response.Status == "" {
+		return response, fmt.Errorf("invalid health response: %v", decodeErr)
+	}
+	return response, nil
+}
+
+type HTTPChecker struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPChecker - url: health endpoint'inin tam adresi (ör. http://host:3001/health)
+func NewHTTPChecker(url string) *HTTPChecker {
+	return &HTTPChecker{url: url, client: &http.Client{Timeout: 3 * time.Second}}
+}
+
+func (h *HTTPChecker) Check(ctx context.Context) CheckResult {
+	start := time.Now()
+
+	response, err := FetchRemote(ctx, h.client, h.url)
+	if err != nil {
+		return CheckResult{
+			Status:   StatusUnhealthy,
+			Message:  fmt.Sprintf("request failed: %v", err),
+			Duration: time.Since(start),
+		}
+	}
+	switch response.Status {
+	case StatusHealthy:
+		return CheckResult{
+			Status:   StatusHealthy,
+			Message:  "service OK",
+			Duration: time.Since(start),
+		}
+	case StatusDegraded:
+		// Servis istek alabilir, sadece kritik olmayan bir bağımlılığı sorunlu
+		return CheckResult{
+			Status:   StatusDegraded,
+			Message:  "service reported degraded",
+			Duration: time.Since(start),
+		}
+	default:
+		return CheckResult{
+			Status:   StatusUnhealthy,
+			Message:  fmt.Sprintf("service reported %s", response.Status),
+			Duration: time.Since(start),
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPChecker(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"healthy", 200, `{"status": "healthy", "service": "svc"}`, "healthy"},
+		{"degraded", 200, `{"status": "degraded", "service": "svc"}`, "degraded"},
+		{"reports unhealthy", 503, `{"status": "unhealthy", "service": "svc"}`, "unhealthy"},
+		{"200 but unhealthy body", 200, `{"status": "unhealthy"}`, "unhealthy"},
+		{"not a health response", 200, `OK`, "unhealthy"},
+		{"server error", 500, ``, "unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			if got := NewHTTPChecker(srv.URL + "/health").Check(context.Background()); got.Status != tt.want {
+				t.Errorf("status = %s (%s), want %s", got.Status, got.Message, tt.want)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		if got := NewHTTPChecker(srv.URL).Check(context.Background()); got.Status != "unhealthy" {
+			t.Errorf("status = %s, want unhealthy", got.Status)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+// Package idempotency - Idempotency-Key header'ı ile tekrar eden POST
+// isteklerinin ikinci kez işlenmesini engelleyen Fiber middleware'i.
+//
+// İstemci her "mantıksal" işlem için benzersiz bir anahtar üretir ve ağ hatası
+// sonrası isteği AYNI anahtarla tekrar gönderir:
+//
+//	POST /orders
+//	Idempotency-Key: 6f1c1a4e-0d3b-4b8e-9a57-2f0c3b1d9e11
+//
+// Davranış:
+//   - İlk istek işlenir, yanıtı (status + body) saklanır
+//   - Aynı anahtar + aynı body → saklanan yanıt tekrar döner
+//     (Idempotent-Replayed: true), handler ÇALIŞMAZ
+//   - Aynı anahtar + farklı body → 409
+//   - İlk istek hâlâ işleniyorsa → 409 (Retry-After ile)
+//   - 5xx yanıtlar saklanmaz: istemci aynı anahtarla tekrar deneyebilir
+//   - Kayıtlar TTL (varsayılan 24 saat) sonunda silinir
+//
+// Header yoksa istek normal işlenir. Kayıtlar Redis'te tutulur, böylece
+// servisin tüm instance'ları aynı anahtarları görür.
+//
+//	app.Post("/orders", auth.RequirePermission(...), idempotency.New(idempotency.Config{
+//	    Store: idempotency.NewRedisStore(rdb, "order"),
+//	    Scope: func(c *fiber.Ctx) string { id, _ := auth.UserID(c); return fmt.Sprint(id) },
+//	}), handler)
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	DefaultTTL     = 24 * time.Hour
+	DefaultLockTTL = time.Minute
+	maxKeyLength   = 255
+)
+
+// Record - Bir anahtar için saklanan istek parmak izi ve yanıt
+// Status 0 ise ilk istek hâlâ işleniyor demektir.
+type Record struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Store - Anahtarların paylaşıldığı yer (Redis / bellek)
+type Store interface {
+	// Begin - Anahtar yoksa "işleniyor" olarak kilitler (lockTTL süreli) ve
+	// started=true döner. Varsa mevcut kaydı döner.
+	Begin(ctx context.Context, key string, fingerprint string, lockTTL time.Duration) (existing *Record, started bool, err error)
+	// Complete - Yanıtı ttl süresince saklar
+	Complete(ctx context.Context, key string, record Record, ttl time.Duration) error
+	// Abort - Kilidi kaldırır (istek tekrar denenebilir)
+	Abort(ctx context.Context, key string) error
+}
+
+type Config struct {
+	Store Store
+
+	// Scope - Anahtarın kime ait olduğu (ör. kullanıcı ID). Farklı kullanıcılar
+	// aynı anahtarı gönderse de birbirinin yanıtını göremez. Boşsa path kullanılır.
+	Scope func(c *fiber.Ctx) string
+
+	TTL     time.Duration // Yanıtın saklanma süresi (boşsa 24 saat)
+	LockTTL time.Duration // İşlenirken çöken isteğin kilidi (boşsa 1 dk)
+}
+
+// Fingerprint - Aynı istek mi? (method + path + body)
+func Fingerprint(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Path()))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func New(cfg Config) fiber.Handler {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = DefaultLockTTL
+	}
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderKey)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxKeyLength {
+			return c.Status(400).JSON(fiber.Map{"error": "Idempotency-Key en fazla 255 karakter olabilir"})
+		}
+
+		scope := c.Path()
+		if cfg.Scope != nil {
+			scope = cfg.Scope(c) + ":" + scope
+		}
+		storeKey := scope + ":" + key
+		fingerprint := Fingerprint(c)
+
+		ctx := c.UserContext()
+		existing, started, err := cfg.Store.Begin(ctx, storeKey, fingerprint, cfg.LockTTL)
+		if err != nil {
+			// Kontrol edilemiyorsa işleme: çift sipariş/çift ödeme riskine girilmez
+			return c.Status(503).JSON(fiber.Map{"error": "İstek şu an işlenemiyor, lütfen tekrar deneyin"})
+		}
+
+		if !started {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				return c.Status(409).JSON(fiber.Map{"error": "Bu Idempotency-Key farklı bir istek için kullanılmış"})
+			case existing.Status == 0:
+				c.Set(fiber.HeaderRetryAfter, "1")
+				return c.Status(409).JSON(fiber.Map{"error": "Aynı Idempotency-Key ile gönderilen istek hâlâ işleniyor"})
+			}
+			c.Set(HeaderReplayed, "true")
+			if existing.ContentType != "" {
+				c.Set(fiber.HeaderContentType, existing.ContentType)
+			}
+			return c.Status(existing.Status).Send(existing.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			cfg.Store.Abort(context.Background(), storeKey)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= 500 {
+			cfg.Store.Abort(context.Background(), storeKey)
+			return nil
+		}
+
+		record := Record{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+		if err := cfg.Store.Complete(context.Background(), storeKey, record, cfg.TTL); err != nil {
+			// Yanıt yine de döner; kilit LockTTL sonunda kalkar
+			log.Printf("⚠️ Idempotency yanıtı saklanamadı (%s): %s", key, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type brokenStore struct{}
+
+func (brokenStore) Begin(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*Record, bool, error) {
+	return nil, false, errors.New("redis kapalı")
+}
+func (brokenStore) Complete(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	return nil
+}
+func (brokenStore) Abort(ctx context.Context, key string) error { return nil }
+
+func testApp(store Store, calls *int) *fiber.App {
+	app := fiber.New()
+	app.Post("/orders", New(Config{
+		Store: store,
+		Scope: func(c *fiber.Ctx) string { return c.Get("X-User-ID") },
+		TTL:   time.Hour,
+	}), func(c *fiber.Ctx) error {
+		*calls++
+		if strings.Contains(string(c.Body()), "fail") {
+			return c.Status(502).JSON(fiber.Map{"error": "ödeme servisi yok"})
+		}
+		return c.Status(201).JSON(fiber.Map{"order_id": *calls})
+	})
+	return app
+}
+
+func send(t *testing.T, app *fiber.App, user, key, body string) (int, string, string) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", user)
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), resp.Header.Get(HeaderReplayed)
+}
+
+func TestIdempotency(t *testing.T) {
+	store := NewMemoryStore()
+	calls := 0
+	app := testApp(store, &calls)
+
+	tests := []struct {
+		name      string
+		user, key string
+		body      string
+		wantCode  int
+		wantBody  string
+		wantCalls int
+		replayed  bool
+	}{
+		{"first request", "1", "k1", `{"total": 100}`, 201, `{"order_id":1}`, 1, false},
+		{"retry with same body is replayed", "1", "k1", `{"total": 100}`, 201, `{"order_id":1}`, 1, true},
+		{"same key different body", "1", "k1", `{"total": 1}`, 409, "", 1, false},
+		{"same key other user", "2", "k1", `{"total": 100}`, 201, `{"order_id":2}`, 2, false},
+		{"no key", "1", "", `{"total": 100}`, 201, `{"order_id":3}`, 3, false},
+		{"no key again", "1", "", `{"total": 100}`, 201, `{"order_id":4}`, 4, false},
+		{"5xx is not stored", "1", "k2", `{"fail": true}`, 502, "", 5, false},
+		{"retry after 5xx runs again", "1", "k2", `{"fail": true}`, 502, "", 6, false},
+		{"key too long", "1", strings.Repeat("x", 256), `{}`, 400, "", 6, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body, replayed := send(t, app, tt.user, tt.key, tt.body)
+			if code != tt.wantCode {
+				t.Errorf("status = %d, want %d (%s)", code, tt.wantCode, body)
+			}
+			if tt.wantBody != "" && body != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler %d kez çalıştı, want %d", calls, tt.wantCalls)
+			}
+			if (replayed == "true") != tt.replayed {
+				t.Errorf("%s = %q, want replayed=%v", HeaderReplayed, replayed, tt.replayed)
+			}
+		})
+	}
+}
+
+func fingerprintOf(t *testing.T, body string) string {
+	t.Helper()
+	var fp string
+	app := fiber.New()
+	app.Post("/orders", func(c *fiber.Ctx) error { fp = Fingerprint(c); return nil })
+	if _, err := app.Test(httptest.NewRequest("POST", "/orders", strings.NewReader(body))); err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+func TestIdempotencyInFlightAndExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	calls := 0
+	app := testApp(store, &calls)
+
+	// Başka bir instance aynı isteği işliyor
+	body := `{"total": 100}`
+	store.Begin(context.Background(), "1:/orders:k1", fingerprintOf(t, body), time.Minute)
+
+	if code, _, _ := send(t, app, "1", "k1", body); code != 409 || calls != 0 {
+		t.Errorf("işlenmekte olan istek: status %d, handler %d kez", code, calls)
+	}
+
+	// Kilit süresi dolunca (instance çöktü) istek işlenir
+	now = now.Add(2 * time.Minute)
+	if code, _, _ := send(t, app, "1", "k1", body); code != 201 || calls != 1 {
+		t.Errorf("kilit dolduktan sonra: status %d, handler %d kez", code, calls)
+	}
+
+	// Saklanan yanıtın süresi dolunca anahtar yeniden kullanılabilir
+	now = now.Add(2 * time.Hour)
+	if code, _, replayed := send(t, app, "1", "k1", body); code != 201 || calls != 2 || replayed == "true" {
+		t.Errorf("TTL sonrası: status %d, handler %d kez, replayed %q", code, calls, replayed)
+	}
+}
+
+func TestIdempotencyStoreDown(t *testing.T) {
+	calls := 0
+	app := testApp(brokenStore{}, &calls)
+
+	// Anahtar kontrol edilemiyorsa istek işlenmez (çift ödeme riski)
+	if code, _, _ := send(t, app, "1", "k1", `{}`); code != 503 || calls != 0 {
+		t.Errorf("store kapalıyken: status %d, handler %d kez", code, calls)
+	}
+	// Header'sız istekler etkilenmez
+	if code, _, _ := send(t, app, "1", "", `{}`); code != 201 {
+		t.Errorf("header'sız istek: status %d", code)
+	}
+}
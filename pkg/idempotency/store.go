@@ -0,0 +1,113 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==============================================================================
+// REDIS STORE (Production - instance'lar arası paylaşılır)
+// ==============================================================================
+
+const redisKeyPrefix = "idempotency:"
+
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore - service: anahtar ön eki (ör. "order" → idempotency:order:...)
+func NewRedisStore(client *redis.Client, service string) *RedisStore {
+	return &RedisStore{client: client, prefix: redisKeyPrefix + service + ":"}
+}
+
+func (s *RedisStore) Begin(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*Record, bool, error) {
+	lock, _ := json.Marshal(Record{Fingerprint: fingerprint})
+
+	// SETNX ile GET arasında kilit süresi dolabilir → bir kez daha dene
+	for range 2 {
+		ok, err := s.client.SetNX(ctx, s.prefix+key, lock, lockTTL).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return nil, true, nil
+		}
+
+		data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, false, err
+		}
+		return &record, false, nil
+	}
+	return nil, false, errors.New("idempotency: anahtar kilitlenemedi")
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, data, ttl).Err()
+}
+
+func (s *RedisStore) Abort(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// ==============================================================================
+// MEMORY STORE (Tek instance / test)
+// ==============================================================================
+
+type memoryEntry struct {
+	record    Record
+	expiresAt time.Time
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+func (s *MemoryStore) Begin(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && s.now().Before(entry.expiresAt) {
+		record := entry.record
+		return &record, false, nil
+	}
+	s.entries[key] = memoryEntry{record: Record{Fingerprint: fingerprint}, expiresAt: s.now().Add(lockTTL)}
+	return nil, true, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryEntry{record: record, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Abort(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
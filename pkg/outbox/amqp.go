@@ -0,0 +1,107 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// ==============================================================================
+// AMQP PUBLISHER (Publisher Confirms)
+// ==============================================================================
+/*
+Relay kendi channel'ını confirm moduna alır: her publish'ten sonra broker'ın
+ack'i beklenir. Nack, timeout veya kapanan channel hata sayılır ve mesaj
+tekrar denenir. Hata sonrası channel atılır, sonraki publish yenisini açar
+(geç gelen bir ack'in yanlış mesaja yazılmaması için).
+*/
+
+const DefaultConfirmTimeout = 5 * time.Second
+
+var ErrNacked = errors.New("broker mesajı reddetti (nack)")
+
+type AMQPPublisher struct {
+	conn    *amqp.Connection
+	timeout time.Duration
+
+	mu       sync.Mutex
+	ch       *amqp.Channel
+	confirms chan amqp.Confirmation
+}
+
+func NewAMQPPublisher(conn *amqp.Connection) *AMQPPublisher {
+	return &AMQPPublisher{conn: conn, timeout: DefaultConfirmTimeout}
+}
+
+func (p *AMQPPublisher) open() error {
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel açılamadı: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return fmt.Errorf("confirm modu açılamadı: %w", err)
+	}
+	p.ch = ch
+	p.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return nil
+}
+
+func (p *AMQPPublisher) reset() {
+	if p.ch != nil {
+		p.ch.Close()
+	}
+	p.ch, p.confirms = nil, nil
+}
+
+func (p *AMQPPublisher) Publish(ctx context.Context, m Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch == nil {
+		if err := p.open(); err != nil {
+			return err
+		}
+	}
+
+	err := p.ch.Publish(m.Exchange, m.RoutingKey, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    strconv.FormatUint(m.ID, 10),
+		Timestamp:    m.CreatedAt,
+		Headers: amqp.Table{
+			"aggregate_type": m.AggregateType,
+			"aggregate_id":   m.AggregateID,
+		},
+		Body: m.Payload,
+	})
+	if err != nil {
+		p.reset()
+		return err
+	}
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	select {
+	case confirm, ok := <-p.confirms:
+		if !ok {
+			p.reset()
+			return errors.New("channel kapandı")
+		}
+		if !confirm.Ack {
+			return ErrNacked
+		}
+		return nil
+	case <-timer.C:
+		p.reset()
+		return errors.New("broker onayı zaman aşımına uğradı")
+	case <-ctx.Done():
+		p.reset()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,285 @@
+// Package outbox - Transactional outbox: veritabanı değişikliği ve eventi
+// aynı transaction'da yazılır, relay eventleri RabbitMQ'ya taşır.
+//
+// Sorun: ch.Publish veritabanı yazımından SONRA çağrılırsa
+//   - publish hata verirse event kaybolur (sipariş var, stok düşmez)
+//   - transaction geri alınırsa olmayan bir kayıt için event gitmiş olur
+//
+// Çözüm: Event önce outbox_messages tablosuna, iş verisiyle AYNI
+// transaction'da yazılır. Relay goroutine'i bekleyen satırları sırayla
+// yayınlar ve broker onayından (publisher confirm) sonra "published" işaretler.
+//
+//	err := DB.Transaction(func(tx *gorm.DB) error {
+//	    if err := tx.Create(&product).Error; err != nil {
+//	        return err
+//	    }
+//	    msg, _ := outbox.NewMessage("product", id, "", "product_created", product)
+//	    return outbox.Enqueue(tx, "product-service", msg)
+//	})
+//
+//	relay := outbox.NewRelay(outbox.NewGormStore(DB, "product-service"), publisher, "product-service")
+//	go relay.Run(ctx)
+//
+// Servisler aynı veritabanını (ve tabloyu) paylaşabilir: her mesaj yazan
+// servisin adını (source) taşır, her relay sadece kendi mesajlarını yayınlar
+// ve kilidi de servis bazındadır.
+//
+// Teslim garantisi "en az bir kez"dir: publish başarılı olup işaretleme
+// başarısız olursa mesaj tekrar gönderilir, consumer'lar idempotent olmalıdır.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Message - outbox_messages tablosunun bir satırı
+// Aynı aggregate'in (ör. order/42) mesajları ID sırasıyla yayınlanır.
+type Message struct {
+	ID            uint64     `json:"id" gorm:"primaryKey"`
+	Source        string     `json:"source" gorm:"size:64;index"` // Mesajı yazan servis ("order-service")
+	AggregateType string     `json:"aggregate_type" gorm:"size:64;index:idx_outbox_aggregate"`
+	AggregateID   string     `json:"aggregate_id" gorm:"size:64;index:idx_outbox_aggregate"`
+	Exchange      string     `json:"exchange"`
+	RoutingKey    string     `json:"routing_key"`
+	Payload       []byte     `json:"payload"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"` // Başarısız publish sonrası bekleme
+	CreatedAt     time.Time  `json:"created_at"`
+	PublishedAt   *time.Time `json:"published_at" gorm:"index"` // nil → bekliyor
+}
+
+func (Message) TableName() string { return "outbox_messages" }
+
+// Aggregate - Sıralama anahtarı ("order/42")
+func (m Message) Aggregate() string { return m.AggregateType + "/" + m.AggregateID }
+
+// NewMessage - payload'ı JSON'a çevirip mesaj oluşturur
+func NewMessage(aggregateType, aggregateID, exchange, routingKey string, payload any) (Message, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Exchange:      exchange,
+		RoutingKey:    routingKey,
+		Payload:       body,
+	}, nil
+}
+
+// Enqueue - Mesajları verilen transaction içinde outbox'a yazar
+// source: mesajı yayınlayacak relay'in servisi (NewGormStore ile aynı ad)
+func Enqueue(tx *gorm.DB, source string, messages ...Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	for i := range messages {
+		messages[i].Source = source
+	}
+	return tx.CreateInBatches(&messages, 500).Error
+}
+
+// ==============================================================================
+// STORE - Relay'in outbox'a erişimi
+// ==============================================================================
+
+// Store - Bekleyen mesajları okur ve sonuçlarını yazar
+type Store interface {
+	// Lock - Aynı anda tek relay çalışsın (birden fazla instance varken
+	// sıralama bozulmasın). ok=false ise başka bir instance çalışıyordur.
+	Lock(ctx context.Context) (release func(), ok bool, err error)
+	// Pending - Yayınlanmamış mesajlar, ID sırasıyla
+	Pending(ctx context.Context, limit int) ([]Message, error)
+	MarkPublished(ctx context.Context, id uint64, at time.Time) error
+	MarkFailed(ctx context.Context, id uint64, attempts int, lastErr string, next time.Time) error
+	// Stats - Bekleyen mesaj sayısı ve en eskisinin oluşturulma zamanı
+	Stats(ctx context.Context) (pending int64, oldest time.Time, err error)
+}
+
+// GormStore - PostgreSQL (GORM) tabanlı store
+// Sadece source'u kendi servisi olan mesajları görür.
+type GormStore struct {
+	db      *gorm.DB
+	source  string
+	lockKey int64
+}
+
+// NewGormStore - source: servis adı; kilit de servis bazındadır, yani aynı
+// veritabanını paylaşan servislerin relay'leri birbirini beklemez.
+func NewGormStore(db *gorm.DB, source string) *GormStore {
+	return &GormStore{db: db, source: source, lockKey: lockKey(source)}
+}
+
+// lockKey - Advisory lock anahtarı: tablo adı + servis adı
+func lockKey(source string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(Message{}.TableName() + "/" + source))
+	return int64(h.Sum64())
+}
+
+// AdoptLegacy - source sütunu eklenmeden önce yazılmış, henüz yayınlanmamış
+// mesajları aggregate tipine göre bu servise atar (yoksa hiçbir relay görmez)
+func (s *GormStore) AdoptLegacy(ctx context.Context, aggregateTypes ...string) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&Message{}).
+		Where("source = '' AND published_at IS NULL AND aggregate_type IN ?", aggregateTypes).
+		Update("source", s.source)
+	return result.RowsAffected, result.Error
+}
+
+// Lock - PostgreSQL advisory lock (oturum bazlı, ayrı bir bağlantıda tutulur)
+func (s *GormStore) Lock(ctx context.Context) (func(), bool, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", s.lockKey).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+	release := func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", s.lockKey)
+		conn.Close()
+	}
+	return release, true, nil
+}
+
+func (s *GormStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	var messages []Message
+	err := s.db.WithContext(ctx).Where("source = ? AND published_at IS NULL", s.source).
+		Order("id").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+func (s *GormStore) MarkPublished(ctx context.Context, id uint64, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&Message{}).Where("id = ?", id).
+		Updates(map[string]any{"published_at": at, "last_error": ""}).Error
+}
+
+func (s *GormStore) MarkFailed(ctx context.Context, id uint64, attempts int, lastErr string, next time.Time) error {
+	return s.db.WithContext(ctx).Model(&Message{}).Where("id = ?", id).
+		Updates(map[string]any{"attempts": attempts, "last_error": lastErr, "next_attempt_at": next}).Error
+}
+
+func (s *GormStore) Stats(ctx context.Context) (int64, time.Time, error) {
+	var stats struct {
+		Pending int64
+		Oldest  *time.Time
+	}
+	err := s.db.WithContext(ctx).Model(&Message{}).Where("source = ? AND published_at IS NULL", s.source).
+		Select("COUNT(*) AS pending, MIN(created_at) AS oldest").Scan(&stats).Error
+	if err != nil || stats.Oldest == nil {
+		return stats.Pending, time.Time{}, err
+	}
+	return stats.Pending, *stats.Oldest, nil
+}
+
+// ==============================================================================
+// MEMORY STORE (Test / geliştirme)
+// ==============================================================================
+
+type MemoryStore struct {
+	lock     sync.Mutex
+	mu       sync.Mutex
+	messages []Message
+	nextID   uint64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add - Transaction'sız ekleme (Enqueue'nun karşılığı)
+func (s *MemoryStore) Add(messages ...Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range messages {
+		s.nextID++
+		m.ID = s.nextID
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = time.Now()
+		}
+		s.messages = append(s.messages, m)
+	}
+}
+
+// Get - ID ile mesajın güncel hali
+func (s *MemoryStore) Get(id uint64) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Message{}, false
+}
+
+func (s *MemoryStore) Lock(ctx context.Context) (func(), bool, error) {
+	if !s.lock.TryLock() {
+		return nil, false, nil
+	}
+	return s.lock.Unlock, true, nil
+}
+
+func (s *MemoryStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pending []Message
+	for _, m := range s.messages {
+		if m.PublishedAt == nil && len(pending) < limit {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+func (s *MemoryStore) update(id uint64, fn func(m *Message)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.messages {
+		if s.messages[i].ID == id {
+			fn(&s.messages[i])
+		}
+	}
+}
+
+func (s *MemoryStore) MarkPublished(ctx context.Context, id uint64, at time.Time) error {
+	s.update(id, func(m *Message) { m.PublishedAt, m.LastError = &at, "" })
+	return nil
+}
+
+func (s *MemoryStore) MarkFailed(ctx context.Context, id uint64, attempts int, lastErr string, next time.Time) error {
+	s.update(id, func(m *Message) { m.Attempts, m.LastError, m.NextAttemptAt = attempts, lastErr, next })
+	return nil
+}
+
+func (s *MemoryStore) Stats(ctx context.Context) (int64, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pending int64
+	var oldest time.Time
+	for _, m := range s.messages {
+		if m.PublishedAt != nil {
+			continue
+		}
+		if pending == 0 || m.CreatedAt.Before(oldest) {
+			oldest = m.CreatedAt
+		}
+		pending++
+	}
+	return pending, oldest, nil
+}
@@ -0,0 +1,189 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ==============================================================================
+// RELAY - Bekleyen mesajları broker'a taşır
+// ==============================================================================
+/*
+Her turda:
+  1. Advisory lock alınır (tek relay çalışır)
+  2. Bekleyen mesajlar ID sırasıyla okunur
+  3. Her mesaj yayınlanır, broker onaylayınca "published" işaretlenir
+  4. Yayınlanamayan mesaj üstel beklemeyle tekrar denenir; bu sürede
+     AYNI aggregate'in sonraki mesajları da bekletilir (sıralama bozulmaz),
+     diğer aggregate'ler etkilenmez.
+
+Metrikler (service etiketiyle):
+  outbox_lag_seconds          → En eski bekleyen mesajın yaşı
+  outbox_pending_messages     → Bekleyen mesaj sayısı
+  outbox_published_total      → Yayınlanan mesajlar
+  outbox_publish_failures_total → Başarısız publish denemeleri
+*/
+
+const (
+	DefaultInterval    = time.Second
+	DefaultBatchSize   = 100
+	DefaultBaseBackoff = time.Second
+	DefaultMaxBackoff  = 5 * time.Minute
+)
+
+var (
+	lagSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "outbox_lag_seconds",
+		Help: "En eski yayınlanmamış outbox mesajının yaşı (saniye)",
+	}, []string{"service"})
+	pendingMessages = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "outbox_pending_messages",
+		Help: "Yayınlanmayı bekleyen outbox mesajı sayısı",
+	}, []string{"service"})
+	publishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_published_total",
+		Help: "Broker'a yayınlanan outbox mesajları",
+	}, []string{"service"})
+	publishFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_publish_failures_total",
+		Help: "Başarısız outbox publish denemeleri",
+	}, []string{"service"})
+)
+
+// Publisher - Mesajı yayınlar; broker onaylamadan dönmemelidir
+type Publisher interface {
+	Publish(ctx context.Context, m Message) error
+}
+
+type Relay struct {
+	store     Store
+	publisher Publisher
+	service   string
+
+	Interval    time.Duration
+	BatchSize   int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	now    func() time.Time
+	wakeup chan struct{}
+}
+
+// NewRelay - service: metrik etiketi ve loglar için servis adı
+func NewRelay(store Store, publisher Publisher, service string) *Relay {
+	return &Relay{
+		store:       store,
+		publisher:   publisher,
+		service:     service,
+		Interval:    DefaultInterval,
+		BatchSize:   DefaultBatchSize,
+		BaseBackoff: DefaultBaseBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		now:         time.Now,
+		wakeup:      make(chan struct{}, 1),
+	}
+}
+
+// Notify - Transaction commit edildikten sonra çağrılırsa relay bir sonraki
+// turu beklemeden çalışır (çağrılmasa da mesaj Interval içinde gider)
+func (r *Relay) Notify() {
+	select {
+	case r.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Run - ctx iptal edilene kadar outbox'ı boşaltır
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RelayOnce(ctx); err != nil {
+			log.Printf("⚠️ Outbox relay (%s): %s", r.service, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wakeup:
+		}
+	}
+}
+
+// RelayOnce - Tek tur; yayınlanan mesaj sayısını döner
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	release, ok, err := r.store.Lock(ctx)
+	if err != nil || !ok {
+		return 0, err
+	}
+	defer release()
+	defer r.updateStats(ctx)
+
+	messages, err := r.store.Pending(ctx, r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := make(map[string]bool) // Sırası bozulmasın diye bu turda atlanan aggregate'ler
+	for _, m := range messages {
+		if ctx.Err() != nil {
+			return published, ctx.Err()
+		}
+		key := m.Aggregate()
+		if blocked[key] {
+			continue
+		}
+		if m.NextAttemptAt.After(r.now()) {
+			blocked[key] = true
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, m); err != nil {
+			blocked[key] = true
+			attempts := m.Attempts + 1
+			publishFailuresTotal.WithLabelValues(r.service).Inc()
+			log.Printf("❌ Outbox mesajı #%d (%s) yayınlanamadı (deneme %d): %s", m.ID, key, attempts, err)
+			if err := r.store.MarkFailed(ctx, m.ID, attempts, err.Error(), r.now().Add(r.backoff(attempts))); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := r.store.MarkPublished(ctx, m.ID, r.now()); err != nil {
+			return published, err // Mesaj tekrar gönderilecek (en az bir kez)
+		}
+		publishedTotal.WithLabelValues(r.service).Inc()
+		published++
+	}
+	return published, nil
+}
+
+// backoff - BaseBackoff * 2^(deneme-1), MaxBackoff ile sınırlı, %20 jitter
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.BaseBackoff
+	for i := 1; i < attempts && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, r.MaxBackoff)
+	return d - time.Duration(rand.Int64N(int64(d)/5+1))
+}
+
+func (r *Relay) updateStats(ctx context.Context) {
+	pending, oldest, err := r.store.Stats(ctx)
+	if err != nil {
+		return
+	}
+	lag := 0.0
+	if pending > 0 {
+		lag = r.now().Sub(oldest).Seconds()
+	}
+	pendingMessages.WithLabelValues(r.service).Set(float64(pending))
+	lagSeconds.WithLabelValues(r.service).Set(lag)
+}
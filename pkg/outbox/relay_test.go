@@ -0,0 +1,142 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakePublisher - failures: aggregate → kaç kez daha hata verileceği
+type fakePublisher struct {
+	mu        sync.Mutex
+	failures  map[string]int
+	published []string // "aggregate:payload"
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, m Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures[m.Aggregate()] > 0 {
+		p.failures[m.Aggregate()]--
+		return errors.New("broker kapalı")
+	}
+	p.published = append(p.published, m.Aggregate()+":"+string(m.Payload))
+	return nil
+}
+
+func message(t *testing.T, aggregateID, payload string) Message {
+	t.Helper()
+	m, err := NewMessage("order", aggregateID, "order_fanout", "", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRelayOrderingAndRetry(t *testing.T) {
+	store := NewMemoryStore()
+	store.Add(
+		message(t, "1", "created"),
+		message(t, "2", "created"),
+		message(t, "1", "paid"),
+		message(t, "2", "paid"),
+	)
+	publisher := &fakePublisher{failures: map[string]int{"order/1": 2}}
+
+	now := time.Now()
+	relay := NewRelay(store, publisher, "test-ordering")
+	relay.now = func() time.Time { return now }
+	relay.BaseBackoff = 10 * time.Second
+
+	// 1. tur: order/1 başarısız → "paid" de bekletilir, order/2 etkilenmez
+	if n, err := relay.RelayOnce(t.Context()); err != nil || n != 2 {
+		t.Fatalf("RelayOnce = %d, %v; want 2", n, err)
+	}
+	first, _ := store.Get(1)
+	if first.Attempts != 1 || first.LastError == "" || !first.NextAttemptAt.After(now) {
+		t.Errorf("başarısız mesaj işaretlenmedi: %+v", first)
+	}
+
+	// Bekleme süresi dolmadan tekrar denenmez
+	if n, _ := relay.RelayOnce(t.Context()); n != 0 || publisher.failures["order/1"] != 1 {
+		t.Errorf("backoff süresinde publish denendi (n=%d)", n)
+	}
+
+	// Backoff artar: 2. hata → ~20s
+	now = now.Add(10 * time.Second)
+	relay.RelayOnce(t.Context())
+	second, _ := store.Get(1)
+	if wait := second.NextAttemptAt.Sub(now); second.Attempts != 2 || wait < 16*time.Second || wait > 20*time.Second {
+		t.Errorf("2. deneme sonrası bekleme = %s (deneme %d), want ~20s", wait, second.Attempts)
+	}
+
+	now = now.Add(20 * time.Second)
+	if n, err := relay.RelayOnce(t.Context()); err != nil || n != 2 {
+		t.Fatalf("RelayOnce = %d, %v; want 2", n, err)
+	}
+
+	want := []string{`order/2:"created"`, `order/2:"paid"`, `order/1:"created"`, `order/1:"paid"`}
+	if len(publisher.published) != len(want) {
+		t.Fatalf("published = %v, want %v", publisher.published, want)
+	}
+	for i := range want {
+		if publisher.published[i] != want[i] {
+			t.Errorf("published[%d] = %s, want %s", i, publisher.published[i], want[i])
+		}
+	}
+	if pending, _, _ := store.Stats(t.Context()); pending != 0 {
+		t.Errorf("bekleyen mesaj = %d, want 0", pending)
+	}
+}
+
+func TestRelayLagMetric(t *testing.T) {
+	store := NewMemoryStore()
+	created := time.Now().Add(-30 * time.Second)
+	m := message(t, "1", "created")
+	m.CreatedAt = created
+	store.Add(m, message(t, "2", "created"))
+
+	publisher := &fakePublisher{failures: map[string]int{"order/1": 1}}
+	relay := NewRelay(store, publisher, "test-lag")
+	relay.RelayOnce(t.Context())
+
+	if got := testutil.ToFloat64(pendingMessages.WithLabelValues("test-lag")); got != 1 {
+		t.Errorf("outbox_pending_messages = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(lagSeconds.WithLabelValues("test-lag")); got < 30 || got > 35 {
+		t.Errorf("outbox_lag_seconds = %v, want ~30", got)
+	}
+	if got := testutil.ToFloat64(publishFailuresTotal.WithLabelValues("test-lag")); got != 1 {
+		t.Errorf("outbox_publish_failures_total = %v, want 1", got)
+	}
+
+	// Kuyruk boşalınca lag sıfırlanır
+	relay.now = func() time.Time { return time.Now().Add(time.Hour) }
+	relay.RelayOnce(t.Context())
+	if got := testutil.ToFloat64(lagSeconds.WithLabelValues("test-lag")); got != 0 {
+		t.Errorf("boş outbox'ta lag = %v, want 0", got)
+	}
+}
+
+func TestRelaySingleInstance(t *testing.T) {
+	store := NewMemoryStore()
+	store.Add(message(t, "1", "created"))
+
+	release, ok, _ := store.Lock(t.Context())
+	if !ok {
+		t.Fatal("lock alınamadı")
+	}
+	relay := NewRelay(store, &fakePublisher{}, "test-lock")
+	if n, err := relay.RelayOnce(t.Context()); n != 0 || err != nil {
+		t.Errorf("lock başkasındayken RelayOnce = %d, %v", n, err)
+	}
+
+	release()
+	if n, _ := relay.RelayOnce(t.Context()); n != 1 {
+		t.Errorf("lock bırakılınca RelayOnce = %d, want 1", n)
+	}
+}
@@ -0,0 +1,170 @@
+package payment
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// KART DOĞRULAMA VE MASKELEME
+// ==============================================================================
+/*
+Kart sağlayıcıya gitmeden önce burada doğrulanır; bariz hatalı kart için
+sağlayıcıya istek (ve ücret) gitmez:
+
+  - Numara: 12-19 hane, Luhn (mod 10) kontrolü
+  - Marka:  BIN (ilk haneler) ile tespit edilir
+  - SKT:    "AA/YY" veya "AA/YYYY"; ayın son gününe kadar geçerli
+  - CVV:    3 hane (American Express: 4 hane)
+
+PCI DSS: Kart numarası (PAN) log'a ve hata mesajına asla tam yazılmaz
+(MaskPAN / RedactPAN), CVV hiçbir yere yazılmaz.
+*/
+
+// Kart markaları
+const (
+	BrandVisa       = "visa"
+	BrandMastercard = "mastercard"
+	BrandAmex       = "amex"
+	BrandTroy       = "troy"
+	BrandDiscover   = "discover"
+	BrandUnknown    = "unknown"
+)
+
+var (
+	ErrInvalidCardNumber = &Error{Code: "invalid_card_number", Message: "Kart numarası geçersiz"}
+	ErrInvalidExpiry     = &Error{Code: "invalid_expiry", Message: "Son kullanma tarihi geçersiz"}
+	ErrCardExpired       = &Error{Code: "expired_card", Message: "Kartın süresi dolmuş"}
+	ErrInvalidCVV        = &Error{Code: "invalid_cvv", Message: "CVV geçersiz"}
+)
+
+// NormalizePAN - Boşluk ve tireleri atar ("4111 1111-1111 1111" → "4111111111111111")
+func NormalizePAN(pan string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, pan)
+}
+
+// Luhn - Mod 10 kontrolü (sadece rakam kabul edilir)
+func Luhn(pan string) bool {
+	if pan == "" {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(pan) - 1; i >= 0; i-- {
+		d := int(pan[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// DetectBrand - BIN aralıklarına göre kart markası
+func DetectBrand(pan string) string {
+	prefix := func(n int) int {
+		if len(pan) < n {
+			return -1
+		}
+		v, _ := strconv.Atoi(pan[:n])
+		return v
+	}
+	switch {
+	case prefix(4) == 9792:
+		return BrandTroy
+	case prefix(1) == 4:
+		return BrandVisa
+	case prefix(2) >= 51 && prefix(2) <= 55, prefix(4) >= 2221 && prefix(4) <= 2720:
+		return BrandMastercard
+	case prefix(2) == 34, prefix(2) == 37:
+		return BrandAmex
+	case prefix(4) == 6011, prefix(2) == 65:
+		return BrandDiscover
+	}
+	return BrandUnknown
+}
+
+// ParseExpiry - "AA/YY" veya "AA/YYYY" → ay, yıl (4 haneli)
+func ParseExpiry(expiry string) (month, year int, err error) {
+	mm, yy, ok := strings.Cut(strings.ReplaceAll(expiry, " ", ""), "/")
+	if !ok || len(mm) < 1 || len(mm) > 2 || (len(yy) != 2 && len(yy) != 4) {
+		return 0, 0, ErrInvalidExpiry
+	}
+	month, err1 := strconv.Atoi(mm)
+	year, err2 := strconv.Atoi(yy)
+	if err1 != nil || err2 != nil || month < 1 || month > 12 {
+		return 0, 0, ErrInvalidExpiry
+	}
+	if len(yy) == 2 {
+		year += 2000
+	}
+	return month, year, nil
+}
+
+// ValidateCard - Kartı doğrular, normalize edilmiş numarayı ve markayı döner
+// Hata mesajları kart bilgisi içermez.
+func ValidateCard(card Card, now time.Time) (Card, string, error) {
+	card.Number = NormalizePAN(card.Number)
+	if len(card.Number) < 12 || len(card.Number) > 19 || !Luhn(card.Number) {
+		return card, "", ErrInvalidCardNumber
+	}
+	brand := DetectBrand(card.Number)
+
+	month, year, err := ParseExpiry(card.Expiry)
+	if err != nil {
+		return card, brand, err
+	}
+	// Kart, son kullanma ayının son gününe kadar geçerlidir
+	if !now.Before(time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC)) {
+		return card, brand, ErrCardExpired
+	}
+
+	cvvLength := 3
+	if brand == BrandAmex {
+		cvvLength = 4
+	}
+	if len(card.CVV) != cvvLength || strings.Trim(card.CVV, "0123456789") != "" {
+		return card, brand, ErrInvalidCVV
+	}
+	return card, brand, nil
+}
+
+// Last4 - Kartın son 4 hanesi
+func Last4(pan string) string {
+	pan = NormalizePAN(pan)
+	if len(pan) < 4 {
+		return ""
+	}
+	return pan[len(pan)-4:]
+}
+
+// MaskPAN - "4111111111111111" → "411111******1111" (ilk 6 + son 4, PCI DSS)
+func MaskPAN(pan string) string {
+	pan = NormalizePAN(pan)
+	if len(pan) < 12 {
+		return strings.Repeat("*", len(pan))
+	}
+	return pan[:6] + strings.Repeat("*", len(pan)-10) + pan[len(pan)-4:]
+}
+
+// panPattern - Metin içinde kart numarasına benzeyen (12-19 hane, boşluk/tire olabilir) diziler
+var panPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){11,18}\b`)
+
+// RedactPAN - Metindeki kart numaralarını maskeler (log ve hata mesajları için)
+func RedactPAN(s string) string {
+	return panPattern.ReplaceAllStringFunc(s, MaskPAN)
+}
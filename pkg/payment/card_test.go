@@ -0,0 +1,69 @@
+package payment
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateCard(t *testing.T) {
+	now := time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		card      Card
+		wantBrand string
+		wantErr   error
+	}{
+		{"visa", Card{"4111 1111 1111 1111", "123", "12/28"}, BrandVisa, nil},
+		{"mastercard 2-series", Card{"2223003122003222", "123", "05/2026"}, BrandMastercard, nil},
+		{"mastercard", Card{"5555-5555-5555-4444", "999", "1/30"}, BrandMastercard, nil},
+		{"amex", Card{"378282246310005", "1234", "12/28"}, BrandAmex, nil},
+		{"troy", Card{"9792030394440796", "123", "12/28"}, BrandTroy, nil},
+		{"luhn fails", Card{"4111111111111112", "123", "12/28"}, "", ErrInvalidCardNumber},
+		{"too short", Card{"4111111", "123", "12/28"}, "", ErrInvalidCardNumber},
+		{"letters", Card{"4111abcd11111111", "123", "12/28"}, "", ErrInvalidCardNumber},
+		{"empty", Card{}, "", ErrInvalidCardNumber},
+		{"expired", Card{"4111111111111111", "123", "04/26"}, BrandVisa, ErrCardExpired},
+		{"month 13", Card{"4111111111111111", "123", "13/28"}, BrandVisa, ErrInvalidExpiry},
+		{"bad expiry", Card{"4111111111111111", "123", "1228"}, BrandVisa, ErrInvalidExpiry},
+		{"amex needs 4 digit cvv", Card{"378282246310005", "123", "12/28"}, BrandAmex, ErrInvalidCVV},
+		{"cvv letters", Card{"4111111111111111", "12a", "12/28"}, BrandVisa, ErrInvalidCVV},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, brand, err := ValidateCard(tt.card, now)
+			if !errors.Is(err, tt.wantErr) || brand != tt.wantBrand {
+				t.Fatalf("got %q, %v; want %q, %v", brand, err, tt.wantBrand, tt.wantErr)
+			}
+			if err == nil && card.Number != NormalizePAN(tt.card.Number) {
+				t.Errorf("number = %q", card.Number)
+			}
+			if err != nil && tt.card.Number != "" && strings.Contains(err.Error(), NormalizePAN(tt.card.Number)) {
+				t.Errorf("hata mesajında kart numarası var: %s", err)
+			}
+		})
+	}
+}
+
+func TestMaskAndRedactPAN(t *testing.T) {
+	if got := MaskPAN("4111 1111 1111 1111"); got != "411111******1111" {
+		t.Errorf("MaskPAN = %s", got)
+	}
+	if got := Last4("4111-1111-1111-1234"); got != "1234" {
+		t.Errorf("Last4 = %s", got)
+	}
+
+	tests := []struct{ in, want string }{
+		{"kart 4111111111111111 reddedildi", "kart 411111******1111 reddedildi"},
+		{"card=4111 1111 1111 1111;", "card=411111******1111;"},
+		{"sipariş #12345 tutar 150.00", "sipariş #12345 tutar 150.00"},
+		{"TXN_0123456789abcdef", "TXN_0123456789abcdef"},
+	}
+	for _, tt := range tests {
+		if got := RedactPAN(tt.in); got != tt.want {
+			t.Errorf("RedactPAN(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
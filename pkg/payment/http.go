@@ -0,0 +1,129 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// HTTP SAĞLAYICI ADAPTÖRÜ
+// ==============================================================================
+/*
+REST API'li bir ödeme sağlayıcısına bağlanır. Beklenen API:
+
+	POST {base}/authorizations                → AuthorizeRequest   → Result
+	POST {base}/authorizations/{id}/capture   → {"amount": 10.5}   → Result
+	POST {base}/authorizations/{id}/void                           → Result
+	POST {base}/authorizations/{id}/refunds   → RefundRequest      → Result
+	GET  {base}/authorizations/{id}                                → Result
+
+Hatalar 4xx + {"code": "declined", "message": "..."} olarak döner; kod
+buradaki Err* değerleriyle eşleşir. 5xx ve okunamayan yanıtlar ErrProvider,
+zaman aşımı ErrTimeout olur.
+
+Authorize'da Reference, Refund'da RefundID "Idempotency-Key" header'ı olarak
+gönderilir: ağ hatasından sonra tekrar denenen istek ikinci kez para çekmez.
+*/
+
+// HTTPProvider - REST API'li ödeme sağlayıcısı
+type HTTPProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewHTTPProvider - baseURL: sağlayıcı API kökü (ör. https://api.psp.example/v1)
+func NewHTTPProvider(baseURL, apiKey string, timeout time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *HTTPProvider) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	if req.Currency == "" {
+		req.Currency = DefaultCurrency
+	}
+	return p.do(ctx, http.MethodPost, "/authorizations", req, req.Reference)
+}
+
+func (p *HTTPProvider) Capture(ctx context.Context, providerRef string, amount float64) (*Result, error) {
+	return p.do(ctx, http.MethodPost, p.path(providerRef, "capture"), map[string]float64{"amount": amount}, "")
+}
+
+func (p *HTTPProvider) Void(ctx context.Context, providerRef string) (*Result, error) {
+	return p.do(ctx, http.MethodPost, p.path(providerRef, "void"), nil, "")
+}
+
+func (p *HTTPProvider) Refund(ctx context.Context, providerRef string, req RefundRequest) (*Result, error) {
+	return p.do(ctx, http.MethodPost, p.path(providerRef, "refunds"), req, req.RefundID)
+}
+
+func (p *HTTPProvider) Status(ctx context.Context, providerRef string) (*Result, error) {
+	return p.do(ctx, http.MethodGet, p.path(providerRef, ""), nil, "")
+}
+
+func (p *HTTPProvider) path(providerRef, action string) string {
+	path := "/authorizations/" + url.PathEscape(providerRef)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+// do - İsteği atar; 2xx → Result, 4xx → *Error, diğerleri → ErrProvider / ErrTimeout
+func (p *HTTPProvider) do(ctx context.Context, method, path string, payload any, idempotencyKey string) (*Result, error) {
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return nil, ErrTimeout
+		}
+		return nil, &Error{Code: ErrProvider.Code, Message: fmt.Sprintf("%s: %v", ErrProvider.Message, err)}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		var result Result
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.ProviderRef == "" {
+			return nil, &Error{Code: ErrProvider.Code, Message: ErrProvider.Message + ": geçersiz yanıt"}
+		}
+		return &result, nil
+	case resp.StatusCode == http.StatusGatewayTimeout:
+		return nil, ErrTimeout
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		var providerErr Error
+		if err := json.NewDecoder(resp.Body).Decode(&providerErr); err != nil || providerErr.Code == "" {
+			return nil, &Error{Code: ErrProvider.Code, Message: fmt.Sprintf("%s: status %d", ErrProvider.Message, resp.StatusCode)}
+		}
+		return nil, &providerErr
+	}
+	return nil, &Error{Code: ErrProvider.Code, Message: fmt.Sprintf("%s: status %d", ErrProvider.Message, resp.StatusCode)}
+}
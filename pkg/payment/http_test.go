@@ -0,0 +1,139 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProviderServer - HTTPProvider'ın beklediği API'yi Simulator ile sunar
+func fakeProviderServer(t *testing.T, sim *Simulator, seen *http.Header) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if seen != nil {
+			*seen = r.Header.Clone()
+		}
+		ctx := r.Context()
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		var result *Result
+		var err error
+		switch {
+		case r.Method == http.MethodPost && len(parts) == 1:
+			var req AuthorizeRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			result, err = sim.Authorize(ctx, req)
+		case r.Method == http.MethodGet && len(parts) == 2:
+			result, err = sim.Status(ctx, parts[1])
+		case len(parts) == 3 && parts[2] == "capture":
+			var req struct{ Amount float64 }
+			json.NewDecoder(r.Body).Decode(&req)
+			result, err = sim.Capture(ctx, parts[1], req.Amount)
+		case len(parts) == 3 && parts[2] == "void":
+			result, err = sim.Void(ctx, parts[1])
+		case len(parts) == 3 && parts[2] == "refunds":
+			var req RefundRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			result, err = sim.Refund(ctx, parts[1], req)
+		default:
+			t.Errorf("beklenmeyen istek: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var providerErr *Error
+		switch {
+		case errors.As(err, &providerErr) && providerErr.Code == ErrTimeout.Code:
+			w.WriteHeader(http.StatusGatewayTimeout)
+		case errors.As(err, &providerErr):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(providerErr)
+		default:
+			json.NewEncoder(w).Encode(result)
+		}
+	}))
+}
+
+func TestHTTPProviderAgainstFakeServer(t *testing.T) {
+	ctx := context.Background()
+	var headers http.Header
+	srv := fakeProviderServer(t, NewSimulator(SimulatorConfig{}), &headers)
+	defer srv.Close()
+
+	p := NewHTTPProvider(srv.URL+"/", "secret", time.Second)
+
+	auth, err := p.Authorize(ctx, AuthorizeRequest{Reference: "saga-1", Amount: 50, Card: Card{Number: "5555555555554444"}})
+	if err != nil || auth.Status != StatusAuthorized || auth.Currency != DefaultCurrency {
+		t.Fatalf("authorize = %+v, %v", auth, err)
+	}
+	if headers.Get("Idempotency-Key") != "saga-1" || headers.Get("Authorization") != "Bearer secret" {
+		t.Errorf("header'lar eksik: %v", headers)
+	}
+
+	if got, err := p.Capture(ctx, auth.ProviderRef, 0); err != nil || got.Status != StatusCaptured {
+		t.Fatalf("capture = %+v, %v", got, err)
+	}
+	if _, err := p.Void(ctx, auth.ProviderRef); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("void err = %v, want invalid_state", err)
+	}
+	if got, err := p.Refund(ctx, auth.ProviderRef, RefundRequest{Amount: 20, RefundID: "r1"}); err != nil || got.RefundedAmount != 20 {
+		t.Fatalf("refund = %+v, %v", got, err)
+	}
+	if headers.Get("Idempotency-Key") != "r1" {
+		t.Errorf("refund Idempotency-Key = %q", headers.Get("Idempotency-Key"))
+	}
+	if got, err := p.Status(ctx, auth.ProviderRef); err != nil || got.Status != StatusCaptured || got.RefundedAmount != 20 {
+		t.Errorf("status = %+v, %v", got, err)
+	}
+	if _, err := p.Status(ctx, "SIM_999999"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("status err = %v, want not_found", err)
+	}
+
+	// Senaryolar HTTP üzerinden de aynı hatayı verir
+	for card, want := range map[string]error{
+		"4000000000000002": ErrDeclined,
+		"4000000000009995": ErrInsufficientFunds,
+		"4000000000000119": ErrTimeout,
+		"4000000000003220": ErrAuthenticationRequired,
+	} {
+		if _, err := p.Authorize(ctx, AuthorizeRequest{Amount: 10, Card: Card{Number: card}}); !errors.Is(err, want) {
+			t.Errorf("%s: err = %v, want %v", card, err, want)
+		}
+	}
+}
+
+func TestHTTPProviderFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    error
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(500) }, ErrProvider},
+		{"invalid body", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("OK")) }, ErrProvider},
+		{"4xx without code", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(400) }, ErrProvider},
+		{"slow", func(w http.ResponseWriter, r *http.Request) { time.Sleep(200 * time.Millisecond) }, ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			p := NewHTTPProvider(srv.URL, "", 50*time.Millisecond)
+			if _, err := p.Status(context.Background(), "X"); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		if _, err := NewHTTPProvider(srv.URL, "", time.Second).Void(context.Background(), "X"); !errors.Is(err, ErrProvider) {
+			t.Errorf("err = %v, want provider_error", err)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package payment
+
+import (
+	"context"
+	"math"
+)
+
+// ==============================================================================
+// ÖDEME SAĞLAYICISI (PaymentProvider)
+// ==============================================================================
+/*
+payment-service kartı kendisi çekmez; işlemi bir ödeme sağlayıcısına (banka,
+PSP) iletir. Sağlayıcıya özel kod bu arayüzün arkasında kalır:
+
+	Authorize → Tutarı karttan bloke et
+	Capture   → Bloke tutarı çek (tamamı veya bir kısmı)
+	Void      → Blokeyi kaldır (henüz çekilmediyse)
+	Refund    → Çekilmiş tutarı iade et (tamamı veya bir kısmı)
+	Status    → İşlemin sağlayıcıdaki güncel durumu
+
+Uygulamalar:
+  - Simulator:    Süreç içi, deterministik sanal banka (geliştirme ve test)
+  - HTTPProvider: REST API'li gerçek bir sağlayıcıya bağlanır
+
+Asenkron ödeme (3D Secure): AuthorizeRequest.ReturnURL gönderilirse sağlayıcı
+doğrulama gerektiren kart için hata yerine "pending" durumunda bir işlem ve
+müşterinin yönlendirileceği ActionURL döner. Müşteri doğrulamayı bitirince
+sonuç ("authorized" veya "failed") sağlayıcıdan webhook ile gelir (webhook.go).
+ReturnURL yoksa doğrulama gerektiren kart ErrAuthenticationRequired ile reddedilir.
+
+Tüm işlemler idempotent olmalıdır: Authorize Reference ile, Refund RefundID
+ile tekrar edildiğinde ikinci kez para hareketi olmaz; zaten çekilmiş işlemi
+tekrar Capture, zaten iptal edilmiş işlemi tekrar Void hata vermez.
+*/
+
+// PaymentProvider - Ödeme sağlayıcısı ile konuşan katman
+type PaymentProvider interface {
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, providerRef string, amount float64) (*Result, error)
+	Void(ctx context.Context, providerRef string) (*Result, error)
+	Refund(ctx context.Context, providerRef string, req RefundRequest) (*Result, error)
+	Status(ctx context.Context, providerRef string) (*Result, error)
+}
+
+// Sağlayıcıdaki işlem durumları
+const (
+	StatusPending    = "pending" // Müşteri doğrulaması (3D Secure) bekleniyor
+	StatusFailed     = "failed"  // Doğrulama başarısız / süresi doldu
+	StatusAuthorized = "authorized"
+	StatusCaptured   = "captured"
+	StatusVoided     = "voided"
+	StatusRefunded   = "refunded"
+)
+
+// DefaultCurrency - İstekte para birimi yoksa
+const DefaultCurrency = "TRY"
+
+// Card - Kart bilgileri (sadece sağlayıcıya iletilir, saklanmaz)
+type Card struct {
+	Number string `json:"number"`
+	CVV    string `json:"cvv"`
+	Expiry string `json:"expiry"` // "AA/YY"
+}
+
+// AuthorizeRequest - Provizyon isteği
+type AuthorizeRequest struct {
+	Reference string  `json:"reference"` // Idempotency anahtarı (ör. saga ID)
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	Card      Card    `json:"card"`
+
+	// Boş değilse asenkron akış desteklenir: 3D Secure gerekiyorsa işlem
+	// "pending" döner, doğrulamadan sonra müşteri bu adrese döner
+	ReturnURL string `json:"return_url,omitempty"`
+}
+
+// RefundRequest - İade isteği; Amount 0 ise kalan tutarın tamamı
+type RefundRequest struct {
+	Amount   float64 `json:"amount"`
+	RefundID string  `json:"refund_id"` // Idempotency anahtarı
+}
+
+// Result - İşlemin sağlayıcıdaki hali
+type Result struct {
+	ProviderRef    string  `json:"id"`
+	Status         string  `json:"status"`
+	Amount         float64 `json:"amount"`
+	Currency       string  `json:"currency"`
+	CapturedAmount float64 `json:"captured_amount"`
+	RefundedAmount float64 `json:"refunded_amount"`
+
+	ActionURL      string `json:"action_url,omitempty"`      // pending: müşterinin doğrulama sayfası
+	FailureCode    string `json:"failure_code,omitempty"`    // failed: "authentication_failed"...
+	FailureMessage string `json:"failure_message,omitempty"` // failed
+}
+
+// ==============================================================================
+// HATALAR
+// ==============================================================================
+
+// Error - Sağlayıcının döndüğü iş hatası
+// errors.Is koda göre karşılaştırır: errors.Is(err, payment.ErrDeclined)
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrDeclined               = &Error{Code: "declined", Message: "Kart reddedildi"}
+	ErrInsufficientFunds      = &Error{Code: "insufficient_funds", Message: "Yetersiz bakiye"}
+	ErrTimeout                = &Error{Code: "timeout", Message: "Ödeme sağlayıcısı zamanında yanıt vermedi"}
+	ErrAuthenticationRequired = &Error{Code: "authentication_required", Message: "3D Secure doğrulaması gerekli"}
+	ErrAuthenticationFailed   = &Error{Code: "authentication_failed", Message: "3D Secure doğrulaması başarısız"}
+	ErrInvalidCard            = &Error{Code: "invalid_card", Message: "Kart bilgileri geçersiz"}
+	ErrNotFound               = &Error{Code: "not_found", Message: "İşlem bulunamadı"}
+	ErrInvalidState           = &Error{Code: "invalid_state", Message: "İşlem bu durumda değiştirilemez"}
+	ErrAmountExceeded         = &Error{Code: "amount_exceeded", Message: "Tutar işlem tutarını aşıyor"}
+	ErrProvider               = &Error{Code: "provider_error", Message: "Ödeme sağlayıcısı hatası"}
+)
+
+// roundAmount - Kuruşa yuvarlar
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
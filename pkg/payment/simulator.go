@@ -0,0 +1,303 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================================================================
+// SİMÜLATÖR (Süreç içi sanal banka)
+// ==============================================================================
+/*
+Gerçek para hareketi olmadan ödeme akışlarını denemek için. Sonuç sadece kart
+numarasına bağlıdır (rastgelelik yok), işlem ID'leri sırayla verilir:
+
+	Kart numarası         Senaryo
+	─────────────────────────────────────────────
+	4000000000000002      declined
+	4000000000009995      insufficient_funds
+	4000000000000119      timeout
+	4000000000003220      3ds_required
+	(diğerleri)           Fallback, o da yoksa approve
+
+3ds_required kartı, istekte ReturnURL varsa "pending" işlem açar ve
+ActionURL olarak ChallengeURL + işlem ID'sini döner; sonuç CompleteChallenge
+ile (payment-service'teki sanal 3D Secure sayfası) belirlenir.
+
+Senaryolar SimulatorConfig.Scenarios ile eklenebilir veya ezilebilir; ortam
+değişkeninden ParseScenarios ile okunur:
+
+	PAYMENT_SIMULATOR_SCENARIOS="4111111111111111=declined,5555555555554444=timeout"
+*/
+
+// Scenario - Simülatörün provizyon isteğine vereceği yanıt
+type Scenario string
+
+const (
+	ScenarioApprove           Scenario = "approve"
+	ScenarioDeclined          Scenario = "declined"
+	ScenarioInsufficientFunds Scenario = "insufficient_funds"
+	ScenarioTimeout           Scenario = "timeout"
+	Scenario3DSRequired       Scenario = "3ds_required"
+)
+
+var scenarioErrors = map[Scenario]error{
+	ScenarioApprove:           nil,
+	ScenarioDeclined:          ErrDeclined,
+	ScenarioInsufficientFunds: ErrInsufficientFunds,
+	ScenarioTimeout:           ErrTimeout,
+	Scenario3DSRequired:       ErrAuthenticationRequired,
+}
+
+// DefaultScenarios - Hazır test kartları
+var DefaultScenarios = map[string]Scenario{
+	"4000000000000002": ScenarioDeclined,
+	"4000000000009995": ScenarioInsufficientFunds,
+	"4000000000000119": ScenarioTimeout,
+	"4000000000003220": Scenario3DSRequired,
+}
+
+// ParseScenarios - "kart=senaryo,kart=senaryo" formatını okur
+func ParseScenarios(s string) (map[string]Scenario, error) {
+	scenarios := make(map[string]Scenario)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		card, name, ok := strings.Cut(pair, "=")
+		scenario := Scenario(strings.TrimSpace(name))
+		if _, known := scenarioErrors[scenario]; !ok || !known {
+			return nil, fmt.Errorf("geçersiz senaryo: %q", pair)
+		}
+		scenarios[strings.TrimSpace(card)] = scenario
+	}
+	return scenarios, nil
+}
+
+// SimulatorConfig - Simülatör ayarları
+type SimulatorConfig struct {
+	// Kart numarası → senaryo (DefaultScenarios ile birleştirilir, çakışırsa bu kazanır)
+	Scenarios map[string]Scenario
+	// Listede olmayan kartlar için; nil ise hepsi onaylanır
+	Fallback func(cardNumber string) Scenario
+	// timeout senaryosunda hata dönmeden önce beklenecek süre (ctx iptal edilirse erken döner)
+	TimeoutDelay time.Duration
+	// Sanal 3D Secure sayfasının adresi; pending işlemin ActionURL'i bu + işlem ID'si
+	ChallengeURL string
+}
+
+// Simulator - Bellekte çalışan deterministik PaymentProvider
+type Simulator struct {
+	config    SimulatorConfig
+	scenarios map[string]Scenario
+
+	mu          sync.Mutex
+	seq         int
+	txns        map[string]*Result
+	byReference map[string]*Result
+	refunds     map[string]bool // refund_id → uygulandı
+}
+
+func NewSimulator(config SimulatorConfig) *Simulator {
+	scenarios := make(map[string]Scenario, len(DefaultScenarios)+len(config.Scenarios))
+	for card, s := range DefaultScenarios {
+		scenarios[card] = s
+	}
+	for card, s := range config.Scenarios {
+		scenarios[card] = s
+	}
+	return &Simulator{
+		config:      config,
+		scenarios:   scenarios,
+		txns:        make(map[string]*Result),
+		byReference: make(map[string]*Result),
+		refunds:     make(map[string]bool),
+	}
+}
+
+// scenarioFor - Kart numarasının senaryosu
+func (s *Simulator) scenarioFor(cardNumber string) Scenario {
+	if scenario, ok := s.scenarios[cardNumber]; ok {
+		return scenario
+	}
+	if s.config.Fallback != nil {
+		return s.config.Fallback(cardNumber)
+	}
+	return ScenarioApprove
+}
+
+func (s *Simulator) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	if req.Card.Number == "" || req.Amount <= 0 {
+		return nil, ErrInvalidCard
+	}
+
+	s.mu.Lock()
+	if req.Reference != "" {
+		if txn, ok := s.byReference[req.Reference]; ok {
+			s.mu.Unlock()
+			return copyResult(txn), nil
+		}
+	}
+	s.mu.Unlock()
+
+	scenario := s.scenarioFor(req.Card.Number)
+	if scenario == ScenarioTimeout && s.config.TimeoutDelay > 0 {
+		select {
+		case <-time.After(s.config.TimeoutDelay):
+		case <-ctx.Done():
+		}
+	}
+	pending := scenario == Scenario3DSRequired && req.ReturnURL != ""
+	if err := scenarioErrors[scenario]; err != nil && !pending {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Reference != "" {
+		if txn, ok := s.byReference[req.Reference]; ok {
+			return copyResult(txn), nil // Eşzamanlı aynı istek
+		}
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	s.seq++
+	txn := &Result{
+		ProviderRef: fmt.Sprintf("SIM_%06d", s.seq),
+		Status:      StatusAuthorized,
+		Amount:      roundAmount(req.Amount),
+		Currency:    currency,
+	}
+	if pending {
+		txn.Status, txn.ActionURL = StatusPending, s.config.ChallengeURL+txn.ProviderRef
+	}
+	s.txns[txn.ProviderRef] = txn
+	if req.Reference != "" {
+		s.byReference[req.Reference] = txn
+	}
+	return copyResult(txn), nil
+}
+
+// Capture - amount 0 ise tutarın tamamı çekilir
+func (s *Simulator) Capture(ctx context.Context, providerRef string, amount float64) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn, ok := s.txns[providerRef]
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case txn.Status == StatusCaptured:
+		return copyResult(txn), nil
+	case txn.Status != StatusAuthorized:
+		return copyResult(txn), ErrInvalidState
+	case amount < 0 || amount > txn.Amount:
+		return copyResult(txn), ErrAmountExceeded
+	}
+	if amount == 0 {
+		amount = txn.Amount
+	}
+	txn.Status, txn.CapturedAmount = StatusCaptured, roundAmount(amount)
+	return copyResult(txn), nil
+}
+
+func (s *Simulator) Void(ctx context.Context, providerRef string) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn, ok := s.txns[providerRef]
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case txn.Status == StatusVoided:
+		return copyResult(txn), nil
+	case txn.Status != StatusAuthorized && txn.Status != StatusPending:
+		return copyResult(txn), ErrInvalidState
+	}
+	txn.Status, txn.ActionURL = StatusVoided, ""
+	return copyResult(txn), nil
+}
+
+// CompleteChallenge - Müşteri 3D Secure doğrulamasını bitirdi (approved=false: başarısız)
+// Sonuç bir kez belirlenir; tekrar çağrılırsa mevcut durum döner.
+func (s *Simulator) CompleteChallenge(ctx context.Context, providerRef string, approved bool) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn, ok := s.txns[providerRef]
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case txn.Status == StatusAuthorized || txn.Status == StatusFailed:
+		return copyResult(txn), nil
+	case txn.Status != StatusPending:
+		return copyResult(txn), ErrInvalidState
+	}
+	txn.ActionURL = ""
+	if approved {
+		txn.Status = StatusAuthorized
+	} else {
+		txn.Status = StatusFailed
+		txn.FailureCode, txn.FailureMessage = ErrAuthenticationFailed.Code, ErrAuthenticationFailed.Message
+	}
+	return copyResult(txn), nil
+}
+
+func (s *Simulator) Refund(ctx context.Context, providerRef string, req RefundRequest) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn, ok := s.txns[providerRef]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if req.RefundID != "" && s.refunds[req.RefundID] {
+		return copyResult(txn), nil
+	}
+
+	remaining := roundAmount(txn.CapturedAmount - txn.RefundedAmount)
+	switch {
+	case txn.Status == StatusRefunded && req.Amount == 0:
+		return copyResult(txn), nil
+	case txn.Status != StatusCaptured:
+		return copyResult(txn), ErrInvalidState
+	case req.Amount < 0 || req.Amount > remaining:
+		return copyResult(txn), ErrAmountExceeded
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	txn.RefundedAmount = roundAmount(txn.RefundedAmount + amount)
+	if txn.RefundedAmount >= txn.CapturedAmount {
+		txn.Status = StatusRefunded
+	}
+	if req.RefundID != "" {
+		s.refunds[req.RefundID] = true
+	}
+	return copyResult(txn), nil
+}
+
+func (s *Simulator) Status(ctx context.Context, providerRef string) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn, ok := s.txns[providerRef]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyResult(txn), nil
+}
+
+// copyResult - Çağıran, simülatörün iç durumunu değiştiremesin
+func copyResult(r *Result) *Result {
+	c := *r
+	return &c
+}
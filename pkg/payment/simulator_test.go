@@ -0,0 +1,170 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulatorScenarios(t *testing.T) {
+	sim := NewSimulator(SimulatorConfig{
+		Scenarios: map[string]Scenario{"4111111111111111": ScenarioDeclined},
+		Fallback: func(card string) Scenario {
+			if strings.HasSuffix(card, "1") {
+				return ScenarioInsufficientFunds
+			}
+			return ScenarioApprove
+		},
+	})
+
+	tests := []struct {
+		card string
+		want error
+	}{
+		{"4000000000000002", ErrDeclined},
+		{"4000000000009995", ErrInsufficientFunds},
+		{"4000000000000119", ErrTimeout},
+		{"4000000000003220", ErrAuthenticationRequired},
+		{"4111111111111111", ErrDeclined},          // Config kazanır
+		{"5555555555554441", ErrInsufficientFunds}, // Fallback
+		{"5555555555554444", nil},
+		{"", ErrInvalidCard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.card, func(t *testing.T) {
+			_, err := sim.Authorize(context.Background(), AuthorizeRequest{Amount: 10, Card: Card{Number: tt.card}})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulatorLifecycle(t *testing.T) {
+	ctx := context.Background()
+	sim := NewSimulator(SimulatorConfig{})
+	card := Card{Number: "5555555555554444"}
+
+	first, err := sim.Authorize(ctx, AuthorizeRequest{Reference: "saga-1", Amount: 100, Card: card})
+	if err != nil || first.ProviderRef != "SIM_000001" || first.Status != StatusAuthorized {
+		t.Fatalf("authorize = %+v, %v", first, err)
+	}
+	again, _ := sim.Authorize(ctx, AuthorizeRequest{Reference: "saga-1", Amount: 100, Card: card})
+	if again.ProviderRef != first.ProviderRef {
+		t.Errorf("aynı referans yeni işlem açtı: %s", again.ProviderRef)
+	}
+
+	steps := []struct {
+		name       string
+		run        func() (*Result, error)
+		wantErr    error
+		wantStatus string
+		wantRefund float64
+	}{
+		{"void'den önce capture", func() (*Result, error) { return sim.Capture(ctx, first.ProviderRef, 0) }, nil, StatusCaptured, 0},
+		{"capture tekrar", func() (*Result, error) { return sim.Capture(ctx, first.ProviderRef, 0) }, nil, StatusCaptured, 0},
+		{"çekilmiş işlem void edilemez", func() (*Result, error) { return sim.Void(ctx, first.ProviderRef) }, ErrInvalidState, StatusCaptured, 0},
+		{"kısmi iade", func() (*Result, error) {
+			return sim.Refund(ctx, first.ProviderRef, RefundRequest{Amount: 30, RefundID: "r1"})
+		}, nil, StatusCaptured, 30},
+		{"aynı iade tekrar", func() (*Result, error) {
+			return sim.Refund(ctx, first.ProviderRef, RefundRequest{Amount: 30, RefundID: "r1"})
+		}, nil, StatusCaptured, 30},
+		{"kalandan fazla", func() (*Result, error) {
+			return sim.Refund(ctx, first.ProviderRef, RefundRequest{Amount: 80, RefundID: "r2"})
+		}, ErrAmountExceeded, StatusCaptured, 30},
+		{"kalanın tamamı", func() (*Result, error) { return sim.Refund(ctx, first.ProviderRef, RefundRequest{RefundID: "r3"}) }, nil, StatusRefunded, 100},
+		{"tam iade tekrar", func() (*Result, error) { return sim.Refund(ctx, first.ProviderRef, RefundRequest{}) }, nil, StatusRefunded, 100},
+		{"durum", func() (*Result, error) { return sim.Status(ctx, first.ProviderRef) }, nil, StatusRefunded, 100},
+	}
+	for _, step := range steps {
+		got, err := step.run()
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: err = %v, want %v", step.name, err, step.wantErr)
+		}
+		if got.Status != step.wantStatus || got.RefundedAmount != step.wantRefund {
+			t.Fatalf("%s: %s / %.2f, want %s / %.2f", step.name, got.Status, got.RefundedAmount, step.wantStatus, step.wantRefund)
+		}
+	}
+
+	if _, err := sim.Void(ctx, "SIM_999999"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("olmayan işlem: %v", err)
+	}
+}
+
+func TestSimulator3DSChallenge(t *testing.T) {
+	ctx := context.Background()
+	sim := NewSimulator(SimulatorConfig{ChallengeURL: "http://pay.test/3ds/"})
+	card := Card{Number: "4000000000003220"}
+
+	// ReturnURL yoksa asenkron akış desteklenmiyor: eskisi gibi reddedilir
+	if _, err := sim.Authorize(ctx, AuthorizeRequest{Amount: 50, Card: card}); !errors.Is(err, ErrAuthenticationRequired) {
+		t.Fatalf("err = %v, want %v", err, ErrAuthenticationRequired)
+	}
+
+	tests := []struct {
+		name       string
+		approved   bool
+		wantStatus string
+		wantCode   string
+	}{
+		{"onay", true, StatusAuthorized, ""},
+		{"başarısız doğrulama", false, StatusFailed, ErrAuthenticationFailed.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending, err := sim.Authorize(ctx, AuthorizeRequest{Reference: tt.name, Amount: 50, Card: card, ReturnURL: "http://shop.test/done"})
+			if err != nil || pending.Status != StatusPending || pending.ActionURL != "http://pay.test/3ds/"+pending.ProviderRef {
+				t.Fatalf("authorize = %+v, %v", pending, err)
+			}
+			if _, err := sim.Capture(ctx, pending.ProviderRef, 0); !errors.Is(err, ErrInvalidState) {
+				t.Errorf("doğrulanmamış işlem çekildi: %v", err)
+			}
+
+			done, err := sim.CompleteChallenge(ctx, pending.ProviderRef, tt.approved)
+			if err != nil || done.Status != tt.wantStatus || done.FailureCode != tt.wantCode || done.ActionURL != "" {
+				t.Fatalf("complete = %+v, %v", done, err)
+			}
+			// Sonuç bir kez belirlenir
+			again, _ := sim.CompleteChallenge(ctx, pending.ProviderRef, !tt.approved)
+			if again.Status != tt.wantStatus {
+				t.Errorf("tekrar çağrı sonucu değiştirdi: %s", again.Status)
+			}
+		})
+	}
+
+	// Süresi dolan bekleyen işlem iptal edilir, sonradan onaylanamaz
+	pending, _ := sim.Authorize(ctx, AuthorizeRequest{Reference: "expired", Amount: 50, Card: card, ReturnURL: "http://shop.test/done"})
+	if voided, err := sim.Void(ctx, pending.ProviderRef); err != nil || voided.Status != StatusVoided {
+		t.Fatalf("void = %+v, %v", voided, err)
+	}
+	if _, err := sim.CompleteChallenge(ctx, pending.ProviderRef, true); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("iptal edilen işlem onaylandı: %v", err)
+	}
+}
+
+func TestSimulatorTimeoutHonorsContext(t *testing.T) {
+	sim := NewSimulator(SimulatorConfig{TimeoutDelay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := sim.Authorize(ctx, AuthorizeRequest{Amount: 10, Card: Card{Number: "4000000000000119"}})
+	if !errors.Is(err, ErrTimeout) || time.Since(start) > time.Second {
+		t.Errorf("err = %v after %s", err, time.Since(start))
+	}
+}
+
+func TestParseScenarios(t *testing.T) {
+	got, err := ParseScenarios(" 4111111111111111=declined, 5555555555554444=3ds_required ,")
+	if err != nil || got["4111111111111111"] != ScenarioDeclined || got["5555555555554444"] != Scenario3DSRequired {
+		t.Errorf("got %v, %v", got, err)
+	}
+	for _, bad := range []string{"4111=unknown", "4111"} {
+		if _, err := ParseScenarios(bad); err == nil {
+			t.Errorf("%q kabul edildi", bad)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package payment
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ==============================================================================
+// KART TOKENIZATION (Vault)
+// ==============================================================================
+/*
+Kart bilgisi sadece payment-service'e girer:
+
+	1. İstemci kartı POST /api/payments/tokens ile gönderir
+	   → Kart doğrulanır, şifrelenip kısa süreli saklanır → {"token": "tok_..."}
+	2. İstemci siparişi token ile verir; order-service kartı hiç görmez
+	3. payment-service /authorize'da token'ı çözüp sağlayıcıya iletir
+
+Saklama:
+  - Kart AES-256-GCM ile şifrelenir (anahtar: PAYMENT_TOKEN_KEY); token
+    "additional data" olarak bağlanır, şifreli veri başka token'a taşınamaz
+  - Kayıt TTL'lidir (varsayılan 15 dk); başarılı provizyondan sonra silinir
+  - CVV sadece bu kısa süre boyunca ve şifreli durur, kalıcı hiçbir yere yazılmaz
+*/
+
+var ErrInvalidToken = &Error{Code: "invalid_token", Message: "Ödeme bilgisi geçersiz veya süresi dolmuş, kartı tekrar girin"}
+
+// errVaultMiss - VaultStore'da kayıt yok (süresi dolmuş olabilir)
+var errVaultMiss = errors.New("vault: kayıt yok")
+
+// VaultStore - Şifreli kart kayıtlarının TTL'li deposu
+type VaultStore interface {
+	Put(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Get(ctx context.Context, key string) ([]byte, error) // Kayıt yoksa errVaultMiss
+	Delete(ctx context.Context, key string) error
+}
+
+// CardToken - Token ve kartın gösterilebilir bilgileri
+type CardToken struct {
+	Token     string    `json:"token"`
+	Brand     string    `json:"brand"`
+	Last4     string    `json:"last4"`
+	ExpMonth  int       `json:"exp_month"`
+	ExpYear   int       `json:"exp_year"`
+	ExpiresAt time.Time `json:"expires_at"` // Token'ın geçerlilik sonu
+}
+
+// vaultEntry - Şifrelenen içerik
+type vaultEntry struct {
+	Card  Card      `json:"card"`
+	Token CardToken `json:"token"`
+}
+
+// Vault - Kartları tokenize eder ve token'ları çözer
+type Vault struct {
+	store VaultStore
+	aead  cipher.AEAD
+	ttl   time.Duration
+}
+
+// NewVault - key: 32 byte (AES-256)
+func NewVault(store VaultStore, key []byte, ttl time.Duration) (*Vault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errors.New("vault: anahtar 32 byte olmalı")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{store: store, aead: aead, ttl: ttl}, nil
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("payment: rastgele token üretilemedi: " + err.Error())
+	}
+	return "tok_" + hex.EncodeToString(b)
+}
+
+// Tokenize - Kartı doğrular ve şifreleyip saklar
+func (v *Vault) Tokenize(ctx context.Context, card Card) (*CardToken, error) {
+	now := time.Now()
+	card, brand, err := ValidateCard(card, now)
+	if err != nil {
+		return nil, err
+	}
+	month, year, _ := ParseExpiry(card.Expiry)
+
+	token := &CardToken{
+		Token:     newToken(),
+		Brand:     brand,
+		Last4:     Last4(card.Number),
+		ExpMonth:  month,
+		ExpYear:   year,
+		ExpiresAt: now.Add(v.ttl),
+	}
+	plain, err := json.Marshal(vaultEntry{Card: card, Token: *token})
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := v.aead.Seal(nonce, nonce, plain, []byte(token.Token))
+	if err := v.store.Put(ctx, token.Token, sealed, v.ttl); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Resolve - Token'ın kartını döner
+func (v *Vault) Resolve(ctx context.Context, token string) (Card, *CardToken, error) {
+	sealed, err := v.store.Get(ctx, token)
+	if errors.Is(err, errVaultMiss) {
+		return Card{}, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return Card{}, nil, err
+	}
+
+	size := v.aead.NonceSize()
+	if len(sealed) < size {
+		return Card{}, nil, ErrInvalidToken
+	}
+	plain, err := v.aead.Open(nil, sealed[:size], sealed[size:], []byte(token))
+	if err != nil {
+		return Card{}, nil, ErrInvalidToken // Başka anahtarla şifrelenmiş veya bozulmuş
+	}
+	var entry vaultEntry
+	if err := json.Unmarshal(plain, &entry); err != nil {
+		return Card{}, nil, ErrInvalidToken
+	}
+	return entry.Card, &entry.Token, nil
+}
+
+// Delete - Token'ı siler (provizyon alındıktan sonra kart tekrar kullanılmaz)
+func (v *Vault) Delete(ctx context.Context, token string) error {
+	return v.store.Delete(ctx, token)
+}
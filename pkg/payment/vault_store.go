@@ -0,0 +1,83 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==============================================================================
+// REDIS VAULT STORE (Production - instance'lar arası paylaşılır)
+// ==============================================================================
+
+const vaultKeyPrefix = "payment:token:"
+
+type RedisVaultStore struct {
+	client *redis.Client
+}
+
+func NewRedisVaultStore(client *redis.Client) *RedisVaultStore {
+	return &RedisVaultStore{client: client}
+}
+
+func (s *RedisVaultStore) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, vaultKeyPrefix+key, value, ttl).Err()
+}
+
+func (s *RedisVaultStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, vaultKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errVaultMiss
+	}
+	return value, err
+}
+
+func (s *RedisVaultStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, vaultKeyPrefix+key).Err()
+}
+
+// ==============================================================================
+// MEMORY VAULT STORE (Tek instance / test)
+// ==============================================================================
+
+type memoryVaultEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+type MemoryVaultStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryVaultEntry
+}
+
+func NewMemoryVaultStore() *MemoryVaultStore {
+	return &MemoryVaultStore{entries: make(map[string]memoryVaultEntry)}
+}
+
+func (s *MemoryVaultStore) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryVaultEntry{value: append([]byte(nil), value...), expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryVaultStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil, errVaultMiss
+	}
+	return entry.value, nil
+}
+
+func (s *MemoryVaultStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
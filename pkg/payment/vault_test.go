@@ -0,0 +1,78 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testVaultKey = bytes.Repeat([]byte{7}, 32)
+
+func TestVaultTokenizeResolve(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryVaultStore()
+	vault, err := NewVault(store, testVaultKey, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := vault.Tokenize(ctx, Card{Number: "4111 1111 1111 1111", CVV: "123", Expiry: "12/49"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Brand != BrandVisa || token.Last4 != "1111" || token.ExpMonth != 12 || token.ExpYear != 2049 {
+		t.Errorf("token = %+v", token)
+	}
+
+	// Depoda kart numarası ve CVV açık yazılmaz
+	sealed, _ := store.Get(ctx, token.Token)
+	if bytes.Contains(sealed, []byte("4111111111111111")) || bytes.Contains(sealed, []byte(`"123"`)) {
+		t.Error("kart bilgisi şifrelenmeden saklanmış")
+	}
+
+	card, info, err := vault.Resolve(ctx, token.Token)
+	if err != nil || card.Number != "4111111111111111" || card.CVV != "123" || info.Last4 != "1111" {
+		t.Fatalf("resolve = %+v, %+v, %v", card, info, err)
+	}
+
+	// Şifreli veri başka bir token'a taşınamaz
+	store.Put(ctx, "tok_other", sealed, time.Minute)
+	if _, _, err := vault.Resolve(ctx, "tok_other"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("taşınan kayıt çözüldü: %v", err)
+	}
+
+	// Farklı anahtarlı vault çözemez
+	other, _ := NewVault(store, bytes.Repeat([]byte{8}, 32), time.Minute)
+	if _, _, err := other.Resolve(ctx, token.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("başka anahtarla çözüldü: %v", err)
+	}
+
+	vault.Delete(ctx, token.Token)
+	if _, _, err := vault.Resolve(ctx, token.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("silinen token çözüldü: %v", err)
+	}
+}
+
+func TestVaultRejectsInvalidCardsAndExpiredTokens(t *testing.T) {
+	ctx := context.Background()
+	vault, _ := NewVault(NewMemoryVaultStore(), testVaultKey, 20*time.Millisecond)
+
+	if _, err := vault.Tokenize(ctx, Card{Number: "4111111111111112", CVV: "123", Expiry: "12/49"}); !errors.Is(err, ErrInvalidCardNumber) {
+		t.Errorf("Luhn hatalı kart tokenize edildi: %v", err)
+	}
+
+	token, err := vault.Tokenize(ctx, Card{Number: "5555555555554444", CVV: "123", Expiry: "12/49"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, _, err := vault.Resolve(ctx, token.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("süresi dolan token çözüldü: %v", err)
+	}
+
+	if _, err := NewVault(NewMemoryVaultStore(), []byte("short"), time.Minute); err == nil {
+		t.Error("kısa anahtar kabul edildi")
+	}
+}
@@ -0,0 +1,83 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================================================================
+// WEBHOOK İMZASI (HMAC-SHA256)
+// ==============================================================================
+/*
+Sağlayıcı, bekleyen (3D Secure) bir işlemin sonucunu webhook ile bildirir.
+İsteğin sağlayıcıdan geldiği ve yolda değişmediği imza ile doğrulanır:
+
+	X-Payment-Signature: t=1735689600,v1=5f8c...
+	v1 = hex(HMAC-SHA256(secret, "<t>.<body>"))
+
+İmzaya zaman damgası dahildir: yakalanıp sonradan tekrar gönderilen istek
+tolerans süresi (varsayılan 5 dk) geçince reddedilir.
+*/
+
+const (
+	SignatureHeader           = "X-Payment-Signature"
+	DefaultSignatureTolerance = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSignature = errors.New("webhook imzası geçersiz")
+	ErrSignatureExpired = errors.New("webhook imzasının süresi dolmuş")
+)
+
+func signature(secret, body []byte, timestamp int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignWebhook - SignatureHeader değeri
+func SignWebhook(secret, body []byte, at time.Time) string {
+	t := at.Unix()
+	return "t=" + strconv.FormatInt(t, 10) + ",v1=" + signature(secret, body, t)
+}
+
+// VerifyWebhook - İmzayı ve zaman damgasını doğrular
+func VerifyWebhook(secret []byte, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signatures = append(signatures, value) // Anahtar değişiminde birden fazla olabilir
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	expected := signature(secret, body, timestamp)
+	valid := false
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+	return nil
+}
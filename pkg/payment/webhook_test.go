@@ -0,0 +1,40 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"id":"SIM_000001","status":"authorized"}`)
+	now := time.Unix(1735689600, 0)
+	header := SignWebhook(secret, body, now)
+
+	tests := []struct {
+		name   string
+		secret []byte
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"valid", secret, header, body, now, nil},
+		{"clock skew within tolerance", secret, header, body, now.Add(4 * time.Minute), nil},
+		{"rotated secret listed second", secret, "t=1735689600,v1=deadbeef," + header[len("t=1735689600,"):], body, now, nil},
+		{"tampered body", secret, header, []byte(`{"id":"SIM_000001","status":"failed"}`), now, ErrInvalidSignature},
+		{"wrong secret", []byte("other"), header, body, now, ErrInvalidSignature},
+		{"replayed later", secret, header, body, now.Add(10 * time.Minute), ErrSignatureExpired},
+		{"missing signature", secret, "t=1735689600", body, now, ErrInvalidSignature},
+		{"garbage", secret, "sha256=abc", body, now, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhook(tt.secret, tt.header, tt.body, tt.now, DefaultSignatureTolerance)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -8,8 +9,11 @@ import (
 	"strconv"
 	"time"
 
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/health"
+	"ecommerce-backend/pkg/outbox"
+
 	"github.com/gofiber/adaptor/v2" // Standart handler çevirici
-	jwtware "github.com/gofiber/contrib/jwt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -22,8 +26,10 @@ import (
 
 var DB *gorm.DB
 var ch *amqp.Channel
+var relay *outbox.Relay
 
-const SecretKey = "benim_cok_gizli_anahtarim_senior_oluyorum"
+// outboxSource - Bu servisin outbox mesajlarının source değeri (relay sadece bunları yayınlar)
+const outboxSource = "product-service"
 
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -76,7 +82,15 @@ type OrderItem struct {
 }
 
 type OrderEvent struct {
-	Items []OrderItem `json:"items"` // Artık sadece ID değil, adet de taşıyoruz
+	ReservationID string      `json:"reservation_id"` // Saga ile oluşan siparişlerde dolu
+	Items         []OrderItem `json:"items"`          // Artık sadece ID değil, adet de taşıyoruz
+}
+
+// OrderCancelledEvent - Order Service'in "order.cancelled" eventi
+type OrderCancelledEvent struct {
+	OrderID       uint        `json:"order_id"`
+	ReservationID string      `json:"reservation_id"`
+	Items         []OrderItem `json:"items"`
 }
 
 func initDatabase() {
@@ -105,7 +119,10 @@ func initDatabase() {
 	fmt.Println("✅ Product DB Bağlandı!")
 
 	// Önce Category, sonra Product (Foreign Key ilişkisi için)
-	DB.AutoMigrate(&Category{}, &Product{})
+	// idx_reservation_product oluşturulamazsa rezervasyonlar idempotent olmaz → başlama
+	if err := DB.AutoMigrate(&Category{}, &Product{}, &StockReservation{}, &StockReturn{}, &outbox.Message{}); err != nil {
+		log.Fatal("❌ Product DB migration başarısız: ", err)
+	}
 
 	// Varsayılan kategorileri oluştur (eğer yoksa)
 	seedCategories()
@@ -132,6 +149,12 @@ func seedCategories() {
 	}
 }
 
+// productEvent - Search Service'in dinlediği "product_created" eventi
+// Aynı ürünün eventleri (aggregate product/<id>) sırasıyla yayınlanır.
+func productEvent(p Product) (outbox.Message, error) {
+	return outbox.NewMessage("product", strconv.FormatUint(uint64(p.ID), 10), "", "product_created", p)
+}
+
 func failOnError(err error, msg string) {
 	if err != nil {
 		log.Fatalf("%s: %s", msg, err)
@@ -166,9 +189,19 @@ func main() {
 	defer ch.Close()
 
 	// 1. Ürün Eklendiğinde Haber Verilecek Kuyruk (Producer - Search Service için)
-	qProduct, err := ch.QueueDeclare("product_created", false, false, false, false, nil)
+	// Eventler outbox üzerinden relay ile yayınlanır (publisher confirm)
+	_, err = ch.QueueDeclare("product_created", false, false, false, false, nil)
 	failOnError(err, "Ürün kuyruğu hatası")
 
+	outboxStore := outbox.NewGormStore(DB, outboxSource)
+	if adopted, err := outboxStore.AdoptLegacy(context.Background(), "product"); err != nil {
+		log.Printf("⚠️ Eski outbox mesajları devralınamadı: %s", err)
+	} else if adopted > 0 {
+		fmt.Printf("📦 %d eski outbox mesajı devralındı\n", adopted)
+	}
+	relay = outbox.NewRelay(outboxStore, outbox.NewAMQPPublisher(conn), outboxSource)
+	go relay.Run(context.Background())
+
 	// 2. EXCHANGE TANIMLAMA (Consumer - Siparişleri Dinlemek İçin)
 	// Order Service ile aynı ismi kullanmalıyız: "order_fanout"
 	err = ch.ExchangeDeclare("order_fanout", "fanout", true, false, false, false, nil)
@@ -190,7 +223,7 @@ func main() {
 	msgs, err := ch.Consume(qStock.Name, "", true, false, false, false, nil)
 	failOnError(err, "Consumer başlatılamadı")
 
-	// --- ARKA PLAN İŞÇİSİ: STOK DÜŞME ---
+	// --- ARKA PLAN İŞÇİSİ: STOK ONAYI ---
 	go func() {
 		fmt.Println("🎧 Product Service: Stok güncellemek için siparişleri dinliyor...")
 		for d := range msgs {
@@ -199,13 +232,98 @@ func main() {
 
 			fmt.Printf("📦 Sipariş Yakalandı! Stoklar güncelleniyor...\n")
 
-			// Adetli düşüş yap
+			// Rezervasyonlu sipariş: stok rezervasyonda düşülmüştü, sadece onaylanır
+			// (saga zaten onayladıysa bir şey değişmez)
+			if orderEvent.ReservationID != "" {
+				if err := confirmReservation(orderEvent.ReservationID); err != nil {
+					fmt.Printf("❌ Rezervasyon onaylanamadı (%s): %s\n", orderEvent.ReservationID, err)
+				}
+				continue
+			}
+
+			// Rezervasyonsuz (eski) event: adetli düşüş, stok eksiye inmez
 			for _, item := range orderEvent.Items {
-				DB.Model(&Product{}).Where("id = ?", item.ProductID).UpdateColumn("stock", gorm.Expr("stock - ?", item.Quantity))
+				if ok, _ := decrementStock(DB, item.ProductID, item.Quantity); !ok {
+					fmt.Printf("⚠️ Stok düşülemedi: ürün %d, adet %d\n", item.ProductID, item.Quantity)
+				}
 			}
 		}
 	}()
 
+	// 6. İPTAL VE İADE EDİLEN SİPARİŞLER (order_events topic exchange'i)
+	// İptal edilen siparişin rezervasyonu bırakılır, iade edilen ürünler
+	// depoya ulaşınca stoğa eklenir.
+	err = ch.ExchangeDeclare("order_events", "topic", true, false, false, false, nil)
+	failOnError(err, "Exchange hatası")
+
+	qRestore, err := ch.QueueDeclare("stock_restore_queue", true, false, false, false, nil)
+	failOnError(err, "Stok iade kuyruğu hatası")
+
+	for _, key := range []string{"order.cancelled", "order.return.received"} {
+		err = ch.QueueBind(qRestore.Name, key, "order_events", false, nil)
+		failOnError(err, "Bind hatası")
+	}
+
+	// Manuel ack: stok geri eklenemezse mesaj kuyrukta kalır ve tekrar denenir
+	restoreMsgs, err := ch.Consume(qRestore.Name, "", false, false, false, false, nil)
+	failOnError(err, "Consumer başlatılamadı")
+
+	// --- ARKA PLAN İŞÇİSİ: İPTAL VE İADEDE STOK İADESİ ---
+	go func() {
+		for d := range restoreMsgs {
+			if d.RoutingKey == "order.return.received" {
+				var event OrderReturnReceivedEvent
+				if err := json.Unmarshal(d.Body, &event); err != nil || event.ReturnID == 0 {
+					d.Nack(false, false)
+					continue
+				}
+				// return_id ile idempotent → event tekrar gelse de stok iki kez eklenmez
+				restocked, err := restockReturn(&event)
+				if err != nil {
+					log.Printf("❌ İade #%d stoğa eklenemedi: %s", event.ReturnID, err)
+					time.Sleep(time.Second)
+					d.Nack(false, true)
+					continue
+				}
+				if restocked {
+					fmt.Printf("↩️ İade #%d (Sipariş #%d) stoğa eklendi\n", event.ReturnID, event.OrderID)
+				}
+				d.Ack(false)
+				continue
+			}
+
+			var event OrderCancelledEvent
+			if err := json.Unmarshal(d.Body, &event); err != nil {
+				d.Nack(false, false) // Bozuk mesaj: tekrar denemenin anlamı yok
+				continue
+			}
+
+			// Saga'sız (eski) siparişin rezervasyonu yok: hangi stoğun düşüldüğü
+			// bilinmediği için otomatik iade yapılmaz
+			if event.ReservationID == "" {
+				log.Printf("⚠️ Sipariş #%d iptal edildi ama rezervasyonu yok, stok elle düzeltilmeli", event.OrderID)
+				d.Ack(false)
+				continue
+			}
+
+			// Rezervasyon bir kez "released" olur → event tekrar gelse de stok iki kez eklenmez
+			released, err := releaseReservation(event.ReservationID, false)
+			if err != nil {
+				log.Printf("❌ Sipariş #%d stoğu geri eklenemedi: %s", event.OrderID, err)
+				time.Sleep(time.Second)
+				d.Nack(false, true)
+				continue
+			}
+			if released > 0 {
+				fmt.Printf("↩️ Sipariş #%d iptal edildi, stok geri eklendi\n", event.OrderID)
+			}
+			d.Ack(false)
+		}
+	}()
+
+	// --- ARKA PLAN İŞÇİSİ: SÜRESİ DOLAN REZERVASYONLAR ---
+	go sweepExpiredReservations()
+
 	// --- WEB SUNUCUSU ---
 	app := fiber.New()
 
@@ -230,44 +348,15 @@ func main() {
 	}))
 
 	// ==============================================================================
-	// HEALTH CHECK ENDPOINT
+	// HEALTH CHECK ENDPOINT'LERİ (/health, /livez, /readyz)
 	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		checks := make(map[string]interface{})
-		status := "healthy"
-
-		// PostgreSQL kontrolü
-		sqlDB, err := DB.DB()
-		if err != nil {
-			checks["postgres"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else if err := sqlDB.Ping(); err != nil {
-			checks["postgres"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else {
-			checks["postgres"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		// RabbitMQ kontrolü
-		if ch == nil {
-			checks["rabbitmq"] = map[string]string{"status": "unhealthy", "message": "channel is nil"}
-			status = "unhealthy"
-		} else {
-			checks["rabbitmq"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		statusCode := 200
-		if status != "healthy" {
-			statusCode = 503
-		}
-
-		return c.Status(statusCode).JSON(fiber.Map{
-			"status":    status,
-			"service":   "product-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks":    checks,
-		})
-	})
+	// RabbitMQ kritik değil: ürünler listelenebilir, sadece stok eventleri gecikir
+	sqlDB, _ := DB.DB()
+	checker := health.NewHealthChecker("product-service")
+	checker.AddCheck("postgres", health.NewPostgresChecker(sqlDB))
+	checker.AddCheck("rabbitmq", health.NewRabbitMQChecker(conn), health.NonCritical())
+	checker.AddCheck("rabbitmq_channel", health.NewCloseWatcher(ch.NotifyClose(make(chan *amqp.Error, 1))), health.NonCritical())
+	checker.Register(app)
 
 	// =====================
 	// KATEGORİ ENDPOINT'LERİ
@@ -458,6 +547,7 @@ func main() {
 		return c.JSON(product)
 	})
 	// --- STOK KONTROLÜ (Senkron) ---
+	// Sadece bilgi amaçlı: stok ayırmaz. Sipariş akışı /products/reservations kullanır.
 	app.Post("/products/validate", func(c *fiber.Ctx) error {
 		req := new(StockCheckReq)
 		if err := c.BodyParser(req); err != nil {
@@ -471,7 +561,7 @@ func main() {
 				return c.Status(404).JSON(fiber.Map{"error": fmt.Sprintf("Ürün bulunamadı: ID %d", item.ProductID)})
 			}
 
-			// Stok Yetersiz mi?
+			// Stok Yetersiz mi? (Rezerve edilen adetler stoktan zaten düşülmüş)
 			if product.Stock < item.Quantity {
 				return c.Status(400).JSON(fiber.Map{
 					"error": fmt.Sprintf("Yetersiz Stok: %s (Kalan: %d, İstenen: %d)", product.Name, product.Stock, item.Quantity),
@@ -482,9 +572,22 @@ func main() {
 		// Her şey yolunda
 		return c.Status(200).JSON(fiber.Map{"message": "Stok uygun"})
 	})
+
+	// =====================
+	// ADMIN ENDPOINT'LERİ (Token + products:write yetkisi gerektirir)
+	// =====================
+	app.Use(auth.New(auth.Config{
+		JWKSURL:      getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		Denylist:     auth.DialRedisDenylist(fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379"))),
+		ServiceToken: getEnv("SERVICE_TOKEN", ""),
+	}))
+
+	// --- STOK REZERVASYONU (Order Service saga'sı, servis anahtarı ister) ---
+	registerReservationRoutes(app)
+
 	// --- SENKRONİZASYON ENDPOINT'İ (YENİ) ---
-	// Kullanımı: POST http://localhost:3001/products/sync
-	app.Post("/products/sync", func(c *fiber.Ctx) error {
+	// Kullanımı: POST http://localhost:3001/products/sync (Admin)
+	app.Post("/products/sync", auth.RequirePermission(auth.PermProductsWrite), func(c *fiber.Ctx) error {
 		// 1. Tüm ürünleri DB'den çek
 		var products []Product
 		if result := DB.Find(&products); result.Error != nil {
@@ -493,47 +596,31 @@ func main() {
 
 		fmt.Printf("🔄 Senkronizasyon Başladı! Toplam %d ürün aktarılacak...\n", len(products))
 
-		// 2. Her bir ürünü RabbitMQ'ya gönder
-		successCount := 0
+		// 2. Her ürün için 'product_created' eventi outbox'a yazılır (Search Service dinliyor)
+		// Relay, ürünün daha önceki eventlerinden sonra sırayla yayınlar.
+		messages := make([]outbox.Message, 0, len(products))
 		for _, p := range products {
-			messageBody, _ := json.Marshal(p)
-
-			// 'product_created' kuyruğuna atıyoruz (Search Service bunu dinliyor)
-			err := ch.Publish(
-				"",                // Exchange (Boş bırakıyoruz, direkt kuyruğa)
-				"product_created", // Routing Key (Kuyruk Adı)
-				false,
-				false,
-				amqp.Publishing{
-					ContentType: "application/json",
-					Body:        messageBody,
-					Timestamp:   time.Now(),
-				})
-
+			msg, err := productEvent(p)
 			if err != nil {
 				fmt.Printf("❌ Hata (%s): %s\n", p.Name, err)
-			} else {
-				fmt.Printf("📤 Gönderildi: %s\n", p.Name)
-				successCount++
+				continue
 			}
+			messages = append(messages, msg)
 		}
+		if err := outbox.Enqueue(DB, outboxSource, messages...); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Senkronizasyon eventleri kaydedilemedi"})
+		}
+		relay.Notify()
 
 		return c.JSON(fiber.Map{
-			"message":      "Senkronizasyon tamamlandı",
+			"message":      "Senkronizasyon başlatıldı",
 			"total_found":  len(products),
-			"total_synced": successCount,
+			"total_synced": len(messages),
 		})
 	})
 
-	app.Use(jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: []byte(SecretKey)},
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Giriş yapmanız gerekiyor!"})
-		},
-	}))
-
 	// Yeni ürün ekle
-	app.Post("/products", func(c *fiber.Ctx) error {
+	app.Post("/products", auth.RequirePermission(auth.PermProductsWrite), func(c *fiber.Ctx) error {
 		product := new(Product)
 		if err := c.BodyParser(product); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Veri hatası"})
@@ -547,24 +634,30 @@ func main() {
 			}
 		}
 
-		if result := DB.Create(&product); result.Error != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "DB Kayıt Hatası"})
-		}
-
-		// Kategori bilgisini de yükle
-		DB.Preload("Category").First(&product, product.ID)
+		// Ürün ve "product_created" eventi (Search Service için) aynı transaction'da
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&product).Error; err != nil {
+				return err
+			}
+			// Kategori bilgisini de yükle
+			tx.Preload("Category").First(&product, product.ID)
 
-		// Yeni ürün eklendi eventini fırlat (Search Service için)
-		messageBody, _ := json.Marshal(product)
-		ch.Publish("", qProduct.Name, false, false, amqp.Publishing{
-			ContentType: "application/json", Body: messageBody, Timestamp: time.Now(),
+			msg, err := productEvent(*product)
+			if err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, outboxSource, msg)
 		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "DB Kayıt Hatası"})
+		}
+		relay.Notify()
 
 		return c.Status(201).JSON(product)
 	})
 
 	// Ürün güncelle (PUT)
-	app.Put("/products/:id", func(c *fiber.Ctx) error {
+	app.Put("/products/:id", auth.RequirePermission(auth.PermProductsWrite), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		var product Product
 		if err := DB.First(&product, id).Error; err != nil {
@@ -593,7 +686,7 @@ func main() {
 	})
 
 	// Ürün sil (DELETE)
-	app.Delete("/products/:id", func(c *fiber.Ctx) error {
+	app.Delete("/products/:id", auth.RequirePermission(auth.PermProductsWrite), func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		var product Product
 		if err := DB.First(&product, id).Error; err != nil {
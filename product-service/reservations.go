@@ -0,0 +1,354 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==============================================================================
+// STOK REZERVASYONLARI (TTL'li)
+// ==============================================================================
+/*
+Eski akış: /products/validate stoğu kontrol eder, stok çok sonra
+stock_queue consumer'ında düşülürdü → iki eşzamanlı sipariş aynı son ürünü
+alıp stoğu eksiye düşürebilirdi.
+
+Yeni akış: Stok rezervasyon anında ATOMİK olarak düşülür:
+
+	UPDATE products SET stock = stock - 2 WHERE id = 5 AND stock >= 2
+
+Satır güncellenmezse stok yetmiyordur; stok hiçbir zaman eksiye düşmez.
+
+	POST   /products/reservations              → Stok ayır (TTL'li)
+	POST   /products/reservations/:id/confirm  → Sipariş kesinleşti
+	DELETE /products/reservations/:id          → Bırak, stok geri eklenir
+
+Durumlar:
+
+	reserved ──confirm──► confirmed
+	    │                     │
+	    └──release / TTL──►  released ◄──release (sipariş iptali)
+
+Onaylanmayan rezervasyonun süresi (RESERVATION_TTL, varsayılan 15m) dolunca
+sweeper onu bırakır ve stok satışa geri döner. Süresi dolmuş ama henüz
+bırakılmamış rezervasyon onaylanabilir (stok hâlâ ayrılmış durumda).
+
+Tüm işlemler reservation_id (Order Service'teki saga ID) ile idempotenttir.
+(reservation_id, product_id) unique'tir: aynı ID ile eşzamanlı iki istek stoğu
+iki kez düşemez (bkz. reserveStock).
+*/
+
+const (
+	ReservationReserved  = "reserved"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+)
+
+const reservationSweepInterval = 30 * time.Second
+
+var reservationsExpiredTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "stock_reservations_expired_total",
+	Help: "Süresi dolduğu için bırakılan stok rezervasyonları",
+})
+
+var (
+	errReservationReleased = errors.New("Rezervasyon süresi doldu veya bırakıldı")
+	errReservationNotFound = errors.New("Rezervasyon bulunamadı")
+	errReservationExists   = errors.New("Rezervasyon eşzamanlı bir istekle oluşturuldu")
+)
+
+// StockReservation - Bir siparişin tek ürün için ayırdığı stok
+type StockReservation struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	ReservationID string    `json:"reservation_id" gorm:"uniqueIndex:idx_reservation_product"` // Order Service'teki saga ID
+	ProductID     int       `json:"product_id" gorm:"index;uniqueIndex:idx_reservation_product"`
+	Quantity      int       `json:"quantity"`
+	Status        string    `json:"status" gorm:"index"`
+	ExpiresAt     time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type ReserveStockReq struct {
+	ReservationID string      `json:"reservation_id"`
+	Items         []OrderItem `json:"items"`
+	TTLSeconds    int         `json:"ttl_seconds"` // Boşsa RESERVATION_TTL
+}
+
+// errInsufficientStock - Rezervasyon transaction'ını geri alıp 400 döndürmek için
+type errInsufficientStock struct{ message string }
+
+func (e errInsufficientStock) Error() string { return e.message }
+
+// reservationTTL - RESERVATION_TTL ortam değişkeni (ör. "15m")
+func reservationTTL() time.Duration {
+	ttl, err := time.ParseDuration(getEnv("RESERVATION_TTL", "15m"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute
+	}
+	return ttl
+}
+
+// decrementStock - Stok yetiyorsa atomik olarak düşer, yetmiyorsa hiçbir şey yapmaz
+func decrementStock(tx *gorm.DB, productID, quantity int) (bool, error) {
+	result := tx.Model(&Product{}).
+		Where("id = ? AND stock >= ?", productID, quantity).
+		UpdateColumn("stock", gorm.Expr("stock - ?", quantity))
+	return result.RowsAffected == 1, result.Error
+}
+
+// mergeItems - Aynı ürünün satırlarını birleştirir, ürün ID'sine göre sıralar
+// (reservation_id, product_id) unique olduğundan her ürün tek satırla rezerve edilir.
+func mergeItems(items []OrderItem) []OrderItem {
+	quantities := make(map[int]int, len(items))
+	merged := make([]OrderItem, 0, len(items))
+	for _, item := range items {
+		if _, ok := quantities[item.ProductID]; !ok {
+			merged = append(merged, OrderItem{ProductID: item.ProductID})
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+	for i := range merged {
+		merged[i].Quantity = quantities[merged[i].ProductID]
+	}
+	// Ürün satırları hep aynı sırada kilitlenir → eşzamanlı siparişlerde deadlock olmaz
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ProductID < merged[j].ProductID })
+	return merged
+}
+
+// isDuplicate - Unique index ihlali (eşzamanlı aynı istek)
+func isDuplicate(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || (err != nil && strings.Contains(err.Error(), "23505"))
+}
+
+// reserveStock - Tüm ürünleri tek transaction'da rezerve eder (ya hepsi ya hiçbiri)
+// Rezervasyon satırları stok düşülmeden ÖNCE yazılır: saga zaman aşımından sonra
+// aynı ID ile tekrar denerse, ikinci istek idx_reservation_product'ta ilk
+// transaction'ı bekler. İlki commit ederse unique ihlali alır ve stoğu ikinci kez
+// düşmeden ilk sonucu döner; ilki geri alınırsa rezervasyonu kendisi yapar.
+func reserveStock(req *ReserveStockReq) ([]StockReservation, error) {
+	var reservations []StockReservation
+
+	ttl := reservationTTL()
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	expiresAt := time.Now().Add(ttl)
+	items := mergeItems(req.Items)
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Aynı ID ile daha önce rezerve edildiyse aynı sonucu dön (idempotent)
+		tx.Where("reservation_id = ?", req.ReservationID).Order("product_id").Find(&reservations)
+		if len(reservations) > 0 {
+			if reservations[0].Status == ReservationReleased {
+				return errReservationReleased
+			}
+			return nil
+		}
+
+		for _, item := range items {
+			reservation := StockReservation{
+				ReservationID: req.ReservationID,
+				ProductID:     item.ProductID,
+				Quantity:      item.Quantity,
+				Status:        ReservationReserved,
+				ExpiresAt:     expiresAt,
+			}
+			if err := tx.Create(&reservation).Error; err != nil {
+				if isDuplicate(err) {
+					return errReservationExists
+				}
+				return err
+			}
+			reservations = append(reservations, reservation)
+		}
+
+		for _, item := range items {
+			ok, err := decrementStock(tx, item.ProductID, item.Quantity)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				var product Product
+				if err := tx.First(&product, item.ProductID).Error; err != nil {
+					return errInsufficientStock{fmt.Sprintf("Ürün bulunamadı: ID %d", item.ProductID)}
+				}
+				return errInsufficientStock{fmt.Sprintf("Yetersiz Stok: %s (Kalan: %d, İstenen: %d)", product.Name, product.Stock, item.Quantity)}
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errReservationExists) {
+		return existingReservation(req.ReservationID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// existingReservation - Eşzamanlı isteğin commit ettiği rezervasyonu döner
+func existingReservation(reservationID string) ([]StockReservation, error) {
+	var reservations []StockReservation
+	if err := DB.Where("reservation_id = ?", reservationID).Order("product_id").Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+	if len(reservations) == 0 {
+		return nil, errReservationNotFound
+	}
+	if reservations[0].Status == ReservationReleased {
+		return nil, errReservationReleased
+	}
+	return reservations, nil
+}
+
+// confirmReservation - Sipariş kesinleşti; stok zaten rezervasyonda düşülmüştü
+// Tekrar çağrılırsa (saga + order eventi) bir şey değişmez.
+func confirmReservation(reservationID string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var reservations []StockReservation
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("reservation_id = ?", reservationID).Find(&reservations)
+		if len(reservations) == 0 {
+			return errReservationNotFound
+		}
+		for _, r := range reservations {
+			if r.Status == ReservationReleased {
+				return errReservationReleased
+			}
+		}
+		return tx.Model(&StockReservation{}).
+			Where("reservation_id = ? AND status = ?", reservationID, ReservationReserved).
+			Update("status", ReservationConfirmed).Error
+	})
+}
+
+// releaseReservation - Rezervasyonu bırakır ve stoğu geri ekler
+// onlyExpired: sweeper sadece süresi dolmuş ve onaylanmamış satırları bırakır.
+// Her satır durum geçişi ile birlikte iade edilir → stok iki kez eklenmez.
+func releaseReservation(reservationID string, onlyExpired bool) (int64, error) {
+	var released int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reservation_id = ?", reservationID)
+		if onlyExpired {
+			query = query.Where("status = ? AND expires_at < ?", ReservationReserved, time.Now())
+		} else {
+			query = query.Where("status IN ?", []string{ReservationReserved, ReservationConfirmed})
+		}
+
+		var reservations []StockReservation
+		if err := query.Find(&reservations).Error; err != nil {
+			return err
+		}
+		for _, r := range reservations {
+			if err := tx.Model(&Product{}).Where("id = ?", r.ProductID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", r.Quantity)).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&r).Update("status", ReservationReleased).Error; err != nil {
+				return err
+			}
+			released++
+		}
+		return nil
+	})
+	return released, err
+}
+
+// sweepExpiredReservations - Süresi dolan rezervasyonları periyodik olarak bırakır
+func sweepExpiredReservations() {
+	for range time.Tick(reservationSweepInterval) {
+		var ids []string
+		DB.Model(&StockReservation{}).
+			Where("status = ? AND expires_at < ?", ReservationReserved, time.Now()).
+			Distinct().Pluck("reservation_id", &ids)
+
+		for _, id := range ids {
+			released, err := releaseReservation(id, true)
+			if err != nil {
+				log.Printf("❌ Rezervasyon bırakılamadı (%s): %s", id, err)
+				continue
+			}
+			if released > 0 {
+				reservationsExpiredTotal.Add(float64(released))
+				fmt.Printf("⌛ Süresi dolan rezervasyon bırakıldı: %s\n", id)
+			}
+		}
+	}
+}
+
+// registerReservationRoutes - Sadece Order Service çağırır (service:call)
+// auth.New'den SONRA kaydedilmeli: servis anahtarı middleware'de doğrulanır.
+func registerReservationRoutes(app fiber.Router) {
+	internal := auth.RequirePermission(auth.PermServiceCall)
+
+	app.Post("/products/reservations", internal, func(c *fiber.Ctx) error {
+		req := new(ReserveStockReq)
+		if err := c.BodyParser(req); err != nil || req.ReservationID == "" || len(req.Items) == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Veri formatı hatalı"})
+		}
+		for _, item := range req.Items {
+			if item.Quantity <= 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "Adet 0'dan büyük olmalı"})
+			}
+		}
+
+		reservations, err := reserveStock(req)
+		var insufficient errInsufficientStock
+		switch {
+		case errors.As(err, &insufficient):
+			return c.Status(400).JSON(fiber.Map{"error": insufficient.message})
+		case errors.Is(err, errReservationReleased):
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(500).JSON(fiber.Map{"error": "Stok rezerve edilemedi"})
+		}
+
+		fmt.Printf("🔒 Stok rezerve edildi: %s (%d ürün)\n", req.ReservationID, len(reservations))
+		return c.Status(201).JSON(fiber.Map{
+			"reservation_id": req.ReservationID,
+			"expires_at":     reservations[0].ExpiresAt,
+			"items":          reservations,
+		})
+	})
+
+	app.Post("/products/reservations/:id/confirm", internal, func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		err := confirmReservation(id)
+		switch {
+		case errors.Is(err, errReservationNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, errReservationReleased):
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(500).JSON(fiber.Map{"error": "Rezervasyon onaylanamadı"})
+		}
+		return c.JSON(fiber.Map{"reservation_id": id, "status": ReservationConfirmed})
+	})
+
+	// Rezervasyonu bırak: ayrılan (veya onaylanmış) stok geri eklenir
+	app.Delete("/products/reservations/:id", internal, func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		released, err := releaseReservation(id, false)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Rezervasyon bırakılamadı"})
+		}
+
+		if released > 0 {
+			fmt.Printf("🔓 Stok rezervasyonu bırakıldı: %s\n", id)
+		}
+		return c.JSON(fiber.Map{"reservation_id": id, "released": released})
+	})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Rezervasyon idempotency'si veritabanında (idx_reservation_product unique
+// index) uygulanır, bu yüzden testler gerçek bir Postgres ister:
+//
+//	TEST_DATABASE_DSN="host=localhost user=user password=password dbname=ecommerce port=5432 sslmode=disable" go test ./product-service/
+//
+// Her çalıştırma kendi şemasını açar ve sonunda siler.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN tanımlı değil, Postgres testleri atlandı")
+	}
+
+	schema := fmt.Sprintf("product_test_%d", time.Now().UnixNano())
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("veritabanına bağlanılamadı: %v", err)
+	}
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("şema oluşturulamadı: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("veritabanına bağlanılamadı: %v", err)
+	}
+	if err := db.AutoMigrate(&Category{}, &Product{}, &StockReservation{}); err != nil {
+		t.Fatalf("migration başarısız: %v", err)
+	}
+	DB = db
+}
+
+func createTestProduct(t *testing.T, stock int) Product {
+	t.Helper()
+	product := Product{Name: "Test Ürün", Code: fmt.Sprintf("T%d", time.Now().UnixNano()), Price: 100, Stock: stock}
+	if err := DB.Create(&product).Error; err != nil {
+		t.Fatalf("ürün oluşturulamadı: %v", err)
+	}
+	return product
+}
+
+func stockOf(t *testing.T, id uint) int {
+	t.Helper()
+	var p Product
+	if err := DB.First(&p, id).Error; err != nil {
+		t.Fatalf("ürün okunamadı: %v", err)
+	}
+	return p.Stock
+}
+
+func TestMergeItems(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []OrderItem
+		want  []OrderItem
+	}{
+		{"single item", []OrderItem{{ProductID: 5, Quantity: 2}}, []OrderItem{{ProductID: 5, Quantity: 2}}},
+		{"sorted by product", []OrderItem{{ProductID: 9, Quantity: 1}, {ProductID: 3, Quantity: 4}}, []OrderItem{{ProductID: 3, Quantity: 4}, {ProductID: 9, Quantity: 1}}},
+		{
+			"same product twice",
+			[]OrderItem{{ProductID: 7, Quantity: 1}, {ProductID: 2, Quantity: 1}, {ProductID: 7, Quantity: 3}},
+			[]OrderItem{{ProductID: 2, Quantity: 1}, {ProductID: 7, Quantity: 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeItems(tt.items)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("mergeItems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReserveStockIdempotent(t *testing.T) {
+	openTestDB(t)
+
+	tests := []struct {
+		name      string
+		stock     int
+		items     []OrderItem
+		repeat    int
+		wantStock int
+		wantRows  int
+		wantErr   bool
+	}{
+		{"single reserve", 10, []OrderItem{{Quantity: 3}}, 1, 7, 1, false},
+		{"retry returns the first result", 10, []OrderItem{{Quantity: 3}}, 3, 7, 1, false},
+		{"same product twice is one row", 10, []OrderItem{{Quantity: 2}, {Quantity: 3}}, 2, 5, 1, false},
+		{"insufficient stock leaves nothing behind", 2, []OrderItem{{Quantity: 3}}, 1, 2, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := createTestProduct(t, tt.stock)
+			items := make([]OrderItem, len(tt.items))
+			for i, item := range tt.items {
+				items[i] = OrderItem{ProductID: int(product.ID), Quantity: item.Quantity}
+			}
+			req := &ReserveStockReq{ReservationID: fmt.Sprintf("saga-%d", product.ID), Items: items}
+
+			for i := 0; i < tt.repeat; i++ {
+				_, err := reserveStock(req)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("deneme %d: reserveStock err = %v, wantErr %v", i, err, tt.wantErr)
+				}
+			}
+
+			if got := stockOf(t, product.ID); got != tt.wantStock {
+				t.Errorf("stok = %d, want %d", got, tt.wantStock)
+			}
+			var rows int64
+			DB.Model(&StockReservation{}).Where("reservation_id = ?", req.ReservationID).Count(&rows)
+			if rows != int64(tt.wantRows) {
+				t.Errorf("%d rezervasyon satırı, want %d", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+// Saga zaman aşımından sonra aynı ID ile tekrar denediğinde ilk istek hâlâ
+// sürüyor olabilir: stok yine sadece bir kez düşülmeli.
+func TestReserveStockConcurrentSameID(t *testing.T) {
+	openTestDB(t)
+
+	const stock, quantity, attempts = 10, 3, 2
+	first, second := createTestProduct(t, stock), createTestProduct(t, stock)
+	req := &ReserveStockReq{
+		ReservationID: fmt.Sprintf("saga-concurrent-%d", first.ID),
+		Items: []OrderItem{
+			{ProductID: int(second.ID), Quantity: quantity},
+			{ProductID: int(first.ID), Quantity: quantity},
+		},
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan []StockReservation, attempts)
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reservations, err := reserveStock(req)
+			if err != nil {
+				t.Errorf("reserveStock: %v", err)
+				return
+			}
+			results <- reservations
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var ids [][]uint
+	for reservations := range results {
+		if len(reservations) != 2 {
+			t.Fatalf("%d rezervasyon satırı döndü, want 2", len(reservations))
+		}
+		ids = append(ids, []uint{reservations[0].ID, reservations[1].ID})
+	}
+	if len(ids) == attempts && fmt.Sprint(ids[0]) != fmt.Sprint(ids[1]) {
+		t.Errorf("istekler farklı rezervasyonlar döndü: %v", ids)
+	}
+
+	for _, p := range []Product{first, second} {
+		if got := stockOf(t, p.ID); got != stock-quantity {
+			t.Errorf("ürün %d stok = %d, want %d", p.ID, got, stock-quantity)
+		}
+	}
+
+	if err := confirmReservation(req.ReservationID); err != nil {
+		t.Fatalf("confirmReservation: %v", err)
+	}
+	var confirmed int64
+	DB.Model(&StockReservation{}).Where("reservation_id = ? AND status = ?", req.ReservationID, ReservationConfirmed).Count(&confirmed)
+	if confirmed != 2 {
+		t.Errorf("%d satır onaylandı, want 2", confirmed)
+	}
+}
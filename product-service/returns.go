@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==============================================================================
+// İADE EDİLEN ÜRÜNLERİN STOĞA EKLENMESİ
+// ==============================================================================
+/*
+Order Service, iade edilen ürünler depoya ulaşınca "order.return.received"
+eventi yayınlar. Event RabbitMQ'dan birden fazla kez gelebilir (at-least-once),
+bu yüzden her iade stock_returns tablosuna return_id (UNIQUE) ile yazılır:
+kayıt zaten varsa stok tekrar eklenmez. Kayıt ve stok artışı aynı
+transaction'dadır.
+*/
+
+// StockReturn - Stoğa eklenmiş bir iade (idempotency kaydı)
+type StockReturn struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ReturnID  uint      `json:"return_id" gorm:"uniqueIndex"` // Order Service'teki iade ID'si
+	OrderID   uint      `json:"order_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrderReturnReceivedEvent - Order Service'in "order.return.received" eventi
+type OrderReturnReceivedEvent struct {
+	OrderID  uint        `json:"order_id"`
+	ReturnID uint        `json:"return_id"`
+	Items    []OrderItem `json:"items"`
+}
+
+// restockReturn - İade edilen adetleri stoğa ekler; aynı iade için ikinci çağrı bir şey yapmaz
+func restockReturn(event *OrderReturnReceivedEvent) (bool, error) {
+	restocked := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&StockReturn{ReturnID: event.ReturnID, OrderID: event.OrderID})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error // Zaten stoğa eklenmiş
+		}
+
+		// Ürün satırları hep aynı sırada kilitlenir (reserveStock ile aynı)
+		items := append([]OrderItem(nil), event.Items...)
+		sort.Slice(items, func(i, j int) bool { return items[i].ProductID < items[j].ProductID })
+		for _, item := range items {
+			if err := tx.Model(&Product{}).Where("id = ?", item.ProductID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+				return err
+			}
+		}
+		restocked = true
+		return nil
+	})
+	return restocked, err
+}
@@ -7,6 +7,9 @@ import (
 	"os"
 	"time"
 
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/health"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"go.mongodb.org/mongo-driver/bson"
@@ -71,42 +74,29 @@ func main() {
 	}))
 
 	// ==============================================================================
-	// HEALTH CHECK ENDPOINT
+	// HEALTH CHECK ENDPOINT'LERİ (/health, /livez, /readyz)
 	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		checks := make(map[string]interface{})
-		status := "healthy"
-
-		// MongoDB kontrolü
-		err := mongoClient.Ping(context.TODO(), nil)
-		if err != nil {
-			checks["mongodb"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else {
-			checks["mongodb"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		statusCode := 200
-		if status != "healthy" {
-			statusCode = 503
-		}
-
-		return c.Status(statusCode).JSON(fiber.Map{
-			"status":    status,
-			"service":   "review-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks":    checks,
-		})
+	checker := health.NewHealthChecker("review-service")
+	checker.AddCheck("mongodb", health.NewMongoChecker(mongoClient))
+	checker.Register(app)
+
+	// Token doğrulayıcı - sadece yazma işlemlerinde kullanılır (okuma herkese açık)
+	requireAuth := auth.New(auth.Config{
+		JWKSURL:  getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		Denylist: auth.DialRedisDenylist(fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379"))),
 	})
 
-	// 1. Yorum Ekle (POST)
-	app.Post("/reviews", func(c *fiber.Ctx) error {
+	// 1. Yorum Ekle (POST) - Giriş yapmış müşteri
+	app.Post("/reviews", requireAuth, auth.RequirePermission(auth.PermReviewsWrite), func(c *fiber.Ctx) error {
 		review := new(Review)
 		if err := c.BodyParser(review); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Veri hatası"})
 		}
 
 		// Otomatik alanları doldur
+		// 👤 Yorum token sahibi adına kaydedilir (body'deki user_id'ye güvenilmez)
+		userID, _ := auth.UserID(c)
+		review.UserID = int(userID)
 		review.ID = primitive.NewObjectID() // Rastgele eşsiz ID üret
 		review.CreatedAt = time.Now()
 
@@ -11,6 +11,9 @@ import (
 	"strconv"
 	"time"
 
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/health"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/olivere/elastic/v7"
@@ -240,33 +243,13 @@ func main() {
 	}))
 
 	// ==============================================================================
-	// HEALTH CHECK ENDPOINT
+	// HEALTH CHECK ENDPOINT'LERİ (/health, /livez, /readyz)
 	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		checks := make(map[string]interface{})
-		status := "healthy"
-
-		// Elasticsearch kontrolü
-		_, _, err := client.Ping(getEnv("ELASTICSEARCH_URL", "http://localhost:9200")).Do(ctx)
-		if err != nil {
-			checks["elasticsearch"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else {
-			checks["elasticsearch"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		statusCode := 200
-		if status != "healthy" {
-			statusCode = 503
-		}
-
-		return c.Status(statusCode).JSON(fiber.Map{
-			"status":    status,
-			"service":   "search-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks":    checks,
-		})
-	})
+	// RabbitMQ kritik değil: arama çalışır, sadece yeni ürünler geç indekslenir
+	checker := health.NewHealthChecker("search-service")
+	checker.AddCheck("elasticsearch", health.NewElasticsearchChecker(getEnv("ELASTICSEARCH_URL", "http://localhost:9200")))
+	checker.AddCheck("rabbitmq", health.NewRabbitMQChecker(conn), health.NonCritical())
+	checker.Register(app)
 
 	// --- ANA ARAMA - PAGİNATİON DESTEKLİ ---
 	/*
@@ -410,14 +393,22 @@ func main() {
 		return c.JSON(suggestions)
 	})
 
+	// ==============================================================================
+	// ADMIN ENDPOINT'LERİ (Token + search:admin yetkisi)
+	// ==============================================================================
+	app.Use(auth.New(auth.Config{
+		JWKSURL:  getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		Denylist: auth.DialRedisDenylist(fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379"))),
+	}))
+
 	// --- MANUEL SENKRONİZASYON ---
-	app.Post("/search/sync", func(c *fiber.Ctx) error {
+	app.Post("/search/sync", auth.RequirePermission(auth.PermSearchAdmin), func(c *fiber.Ctx) error {
 		go syncProductsFromDB()
 		return c.JSON(fiber.Map{"message": "Senkronizasyon başlatıldı"})
 	})
 
 	// --- MANUEL İNDEKSLEME ---
-	app.Post("/search/manual", func(c *fiber.Ctx) error {
+	app.Post("/search/manual", auth.RequirePermission(auth.PermSearchAdmin), func(c *fiber.Ctx) error {
 		p := new(ProductIndex)
 		if err := c.BodyParser(p); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Veri hatası"})
@@ -437,7 +428,7 @@ func main() {
 	})
 
 	// --- İNDEX İSTATİSTİKLERİ ---
-	app.Get("/search/stats", func(c *fiber.Ctx) error {
+	app.Get("/search/stats", auth.RequirePermission(auth.PermSearchAdmin), func(c *fiber.Ctx) error {
 		count, err := client.Count("products").Do(ctx)
 		if err != nil {
 			return c.JSON(fiber.Map{"indexed_products": 0})
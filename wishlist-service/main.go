@@ -5,16 +5,15 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"time"
 
-	jwtware "github.com/gofiber/contrib/jwt"
+	"ecommerce-backend/pkg/auth"
+	"ecommerce-backend/pkg/health"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/redis/go-redis/v9"
 )
 
-const SecretKey = "benim_cok_gizli_anahtarim_senior_oluyorum"
-
 var rdb *redis.Client
 var ctx = context.Background()
 
@@ -58,48 +57,26 @@ func main() {
 	}))
 
 	// ==============================================================================
-	// HEALTH CHECK ENDPOINT (JWT'den ÖNCE - public olmalı)
+	// HEALTH CHECK ENDPOINT'LERİ (/health, /livez, /readyz) - JWT'den ÖNCE, public olmalı
 	// ==============================================================================
-	app.Get("/health", func(c *fiber.Ctx) error {
-		checks := make(map[string]interface{})
-		status := "healthy"
-
-		// Redis kontrolü
-		_, err := rdb.Ping(ctx).Result()
-		if err != nil {
-			checks["redis"] = map[string]string{"status": "unhealthy", "message": err.Error()}
-			status = "unhealthy"
-		} else {
-			checks["redis"] = map[string]string{"status": "healthy", "message": "connection OK"}
-		}
-
-		statusCode := 200
-		if status != "healthy" {
-			statusCode = 503
-		}
-
-		return c.Status(statusCode).JSON(fiber.Map{
-			"status":    status,
-			"service":   "wishlist-service",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"checks":    checks,
-		})
-	})
+	checker := health.NewHealthChecker("wishlist-service")
+	checker.AddCheck("redis", health.NewRedisChecker(rdb))
+	checker.Register(app)
 
 	// 🔥 GÜVENLİK DUVARI (MIDDLEWARE) 🔥
 	// Buradan sonraki tüm rotalar Token ister!
-	app.Use(jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: []byte(SecretKey)},
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Giriş yapmalısınız!"})
-		},
+	app.Use(auth.New(auth.Config{
+		JWKSURL:             getEnv("AUTH_JWKS_URL", auth.DefaultJWKSURL),
+		UnauthorizedMessage: "Giriş yapmalısınız!",
+		Denylist:            auth.NewRedisDenylist(rdb),
 	}))
 
 	// Anahtar Formatı: "wishlist:{userID}" -> [1, 55, 102]
+	// 👤 :userid token sahibine ait olmalı (admin hariç), /wishlist/me de kullanılabilir
 
 	// 1. Favoriye Ekle (POST /wishlist/:userid)
-	app.Post("/wishlist/:userid", func(c *fiber.Ctx) error {
-		userID := c.Params("userid")
+	app.Post("/wishlist/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userID := auth.ParamUserID(c, "userid")
 		req := new(WishlistReq)
 		if err := c.BodyParser(req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Veri hatası"})
@@ -117,8 +94,8 @@ func main() {
 	})
 
 	// 2. Favoriden Çıkar (DELETE /wishlist/:userid)
-	app.Delete("/wishlist/:userid", func(c *fiber.Ctx) error {
-		userID := c.Params("userid")
+	app.Delete("/wishlist/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userID := auth.ParamUserID(c, "userid")
 		req := new(WishlistReq)
 		if err := c.BodyParser(req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Veri hatası"})
@@ -136,8 +113,8 @@ func main() {
 	})
 
 	// 3. Favorileri Getir (GET /wishlist/:userid)
-	app.Get("/wishlist/:userid", func(c *fiber.Ctx) error {
-		userID := c.Params("userid")
+	app.Get("/wishlist/:userid", auth.RequireSelfOrAdmin("userid"), func(c *fiber.Ctx) error {
+		userID := auth.ParamUserID(c, "userid")
 		key := fmt.Sprintf("wishlist:%s", userID)
 
 		// Redis SMEMBERS: Tüm listeyi getir